@@ -15,16 +15,229 @@
 package awskinesisexporter
 
 import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	"github.com/aws/aws-sdk-go/service/kinesis"
 	"go.opentelemetry.io/collector/config"
 	"go.opentelemetry.io/collector/exporter/exporterhelper"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/compress"
 )
 
+// Encoding defines the encoding that is used to export the data into records.
+type Encoding struct {
+	Name string `mapstructure:"name"`
+	// Signals overrides the encoding used by each signal.
+	Signals          SignalEncodings `mapstructure:"signals"`
+	Compression      string          `mapstructure:"compression"`
+	CompressionLevel int             `mapstructure:"compression_level"`
+	// CompressionMinSize is the smallest encoded record that is compressed,
+	// smaller records are written uncompressed.
+	CompressionMinSize int `mapstructure:"compression_min_size"`
+	// CompressionMinRatio writes the records that do not compress by at least the ratio of their
+	// uncompressed to their compressed size uncompressed, records are always compressed when unset.
+	CompressionMinRatio float64 `mapstructure:"compression_min_ratio"`
+	// CompressionCandidates are the formats that the auto compression chooses between.
+	CompressionCandidates []string `mapstructure:"compression_candidates"`
+	// CompressionAutoMinSize is the smallest record that the auto compression compresses
+	// with every candidate, smaller records only use the first candidate.
+	CompressionAutoMinSize int `mapstructure:"compression_auto_min_size"`
+	// CompressionDictionary is the path of the trained zstd dictionary used to
+	// compress the records, or the dictionary itself when prefixed by base64:.
+	CompressionDictionary string `mapstructure:"compression_dictionary"`
+	// CompressionMarker is where the compression of each record is marked,
+	// either as a prefix of the partition key or as the leading byte of the data.
+	CompressionMarker string `mapstructure:"compression_marker"`
+	// BatchCompression compresses the records of each batch together as a single
+	// aggregated record instead of compressing each record.
+	BatchCompression bool `mapstructure:"batch_compression"`
+	// Passthrough writes the kinesis.raw_body attribute of log records
+	// as is instead of encoding them.
+	Passthrough bool `mapstructure:"passthrough"`
+	// GlueRegistry prefixes each avro record with the AWS Glue Schema Registry
+	// header of the registered schema version.
+	GlueRegistry GlueRegistrySettings `mapstructure:"glue_registry"`
+}
+
+// SignalEncodings defines the encoding used by each signal,
+// an unset encoding uses the encoding name.
+type SignalEncodings struct {
+	Traces  string `mapstructure:"traces"`
+	Metrics string `mapstructure:"metrics"`
+	Logs    string `mapstructure:"logs"`
+}
+
+// GlueRegistrySettings identifies the schema within the AWS Glue Schema Registry
+// that the avro schema is registered as, it is not used when unset.
+type GlueRegistrySettings struct {
+	Name   string `mapstructure:"name"`
+	Schema string `mapstructure:"schema"`
+}
+
+// ThrottleRetrySettings defines the exponential backoff used to retry
+// records that kinesis has throttled.
+type ThrottleRetrySettings struct {
+	InitialInterval time.Duration `mapstructure:"initial_interval"`
+	MaxInterval     time.Duration `mapstructure:"max_interval"`
+	MaxElapsedTime  time.Duration `mapstructure:"max_elapsed_time"`
+	Multiplier      float64       `mapstructure:"multiplier"`
+}
+
+// DeadLetterConfig defines the stream that records are written
+// to once they have permanently failed to be exported.
+type DeadLetterConfig struct {
+	StreamName string `mapstructure:"stream_name"`
+}
+
+// RateLimitSettings defines the rate that records are written at,
+// an unset limit is not applied.
+type RateLimitSettings struct {
+	RecordsPerSecond int `mapstructure:"records_per_second"`
+	BytesPerSecond   int `mapstructure:"bytes_per_second"`
+}
+
+// RetryBudgetSettings limits the writes that are retried by each exporter
+// within a window, the budget is not applied when the window is unset.
+type RetryBudgetSettings struct {
+	MaxRetries int           `mapstructure:"max_retries"`
+	Window     time.Duration `mapstructure:"window"`
+}
+
+// LogFailuresSettings limits the logging of failed writes, which the metrics already count.
+// Each second the first failure of each message is logged followed by one of every sample_rate failures.
+type LogFailuresSettings struct {
+	Enabled    bool `mapstructure:"enabled"`
+	SampleRate int  `mapstructure:"sample_rate"`
+}
+
+// Secret is a configuration value that is redacted when printed or marshaled.
+type Secret string
+
+const redacted = "[REDACTED]"
+
+// String returns the redacted value, an unset secret is left empty.
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+// GoString redacts the value when printed with %#v.
+func (s Secret) GoString() string {
+	return `"` + s.String() + `"`
+}
+
+// MarshalText redacts the value when the configuration is marshaled.
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// RecordAttributesSettings defines the static attributes added to every record.
+type RecordAttributesSettings struct {
+	Attributes map[string]string `mapstructure:"attributes"`
+	// Overwrite replaces the attributes that are already set on a resource.
+	Overwrite bool `mapstructure:"overwrite"`
+}
+
+// ProducerIDSettings identifies the collector that wrote each record,
+// for consumers of streams that are written to by many collectors.
+type ProducerIDSettings struct {
+	Enabled bool `mapstructure:"enabled"`
+	// ID is the id of the collector, the hostname is used when unset.
+	ID string `mapstructure:"id"`
+}
+
+// HTTPSettings defines the http client used to call the AWS apis,
+// the defaults of the AWS SDK are used when unset.
+type HTTPSettings struct {
+	// Timeout limits the time of each http attempt, the retries by the AWS SDK and
+	// their backoff are not included, RequestTimeout limits the whole request.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// MaxIdleConns is the number of idle connections kept open to the endpoint.
+	MaxIdleConns int `mapstructure:"max_idle_conns"`
+	// IdleConnTimeout is the time an idle connection is kept open before it is closed.
+	IdleConnTimeout time.Duration `mapstructure:"idle_conn_timeout"`
+	// ProxyURL is the proxy that requests are sent through instead of the proxy
+	// set by the environment, the hosts in NO_PROXY are still reached directly.
+	ProxyURL string `mapstructure:"proxy_url"`
+	// CAFile is the PEM bundle of the certificate authorities trusted
+	// by the client along with the system roots, such as of a VPC endpoint.
+	CAFile string `mapstructure:"ca_file"`
+}
+
+// EMFSettings defines the CloudWatch log group that the health of the exporter
+// is written to using the embedded metric format.
+type EMFSettings struct {
+	Enabled   bool   `mapstructure:"enabled"`
+	Namespace string `mapstructure:"namespace"`
+	LogGroup  string `mapstructure:"log_group"`
+	// Interval is how often the records sent, failed and throttled are written.
+	Interval time.Duration `mapstructure:"interval"`
+}
+
+// StreamsConfig defines the stream used by each signal,
+// an unset stream uses the default stream name.
+type StreamsConfig struct {
+	Traces  string `mapstructure:"traces"`
+	Metrics string `mapstructure:"metrics"`
+	Logs    string `mapstructure:"logs"`
+}
+
 // AWSConfig contains AWS specific configuration such as awskinesis stream, region, etc.
 type AWSConfig struct {
-	StreamName      string `mapstructure:"stream_name"`
+	StreamName string `mapstructure:"stream_name"`
+	// StreamARN is written to instead of StreamName using the StreamARN parameter,
+	// which allows resource based access to a stream in another account.
+	StreamARN string `mapstructure:"stream_arn"`
+	// Streams overrides the stream used by each signal.
+	Streams StreamsConfig `mapstructure:"streams"`
+	// StreamNameTemplate routes each resource to the stream named by the template,
+	// such as otel-{tenant.id}, resources without the attribute use the stream name.
+	StreamNameTemplate string `mapstructure:"stream_name_template"`
+	// Endpoint overrides the regional endpoint of the target service,
+	// such as a VPC endpoint or a local test environment.
+	Endpoint string `mapstructure:"endpoint"`
+	// KinesisEndpoint is deprecated in favour of Endpoint.
 	KinesisEndpoint string `mapstructure:"kinesis_endpoint"`
+	// DisableSSL sends requests to the endpoint without TLS.
+	DisableSSL bool `mapstructure:"disable_ssl"`
+	// UserAgentSuffix is appended to the user agent of every AWS request,
+	// the default user agent of the SDK is used when unset.
+	UserAgentSuffix string `mapstructure:"user_agent_suffix"`
+	// PropagateTraceContext adds the W3C traceparent header of the span
+	// writing the records to every AWS request.
+	PropagateTraceContext bool `mapstructure:"propagate_trace_context"`
+	// SDKMaxRetries is the number of times the AWS SDK retries a failed request before
+	// returning the error to the exporter, -1 uses the default of each service.
+	SDKMaxRetries int `mapstructure:"sdk_max_retries"`
+	// UseFIPSEndpoint sends requests to the FIPS 140-2 validated endpoint of the region,
+	// Endpoint takes precedence when both are set.
+	UseFIPSEndpoint bool   `mapstructure:"use_fips_endpoint"`
 	Region          string `mapstructure:"region"`
-	Role            string `mapstructure:"role"`
+	// RoleARN is the role that is assumed to write the records,
+	// which allows writing to a stream in another account.
+	RoleARN string `mapstructure:"role_arn"`
+	// Role is deprecated in favour of RoleARN.
+	Role string `mapstructure:"role"`
+	// RoleSessionName identifies the assumed role session, defaults to otel-collector.
+	RoleSessionName string `mapstructure:"role_session_name"`
+	// ExternalID is passed when assuming the role if required by its trust policy.
+	ExternalID string `mapstructure:"external_id"`
+	// AccessKey, SecretKey and SessionToken are static credentials used instead of
+	// the default credential chain, such as when the instance metadata service is blocked.
+	AccessKey    string `mapstructure:"access_key"`
+	SecretKey    Secret `mapstructure:"secret_key"`
+	SessionToken Secret `mapstructure:"session_token"`
 }
 
 // Config contains the main configuration options for the awskinesis exporter
@@ -34,9 +247,640 @@ type Config struct {
 	exporterhelper.QueueSettings   `mapstructure:"sending_queue"`
 	exporterhelper.RetrySettings   `mapstructure:"retry_on_failure"`
 
-	AWS                AWSConfig `mapstructure:"aws"`
-	MaxRecordsPerBatch int       `mapstructure:"max_records_per_batch"`
-	MaxRecordSize      int       `mapstructure:"max_record_size"`
+	// Target is the AWS service that the records are delivered to.
+	Target string `mapstructure:"target"`
+
+	Encoding           `mapstructure:"encoding"`
+	AWS                AWSConfig             `mapstructure:"aws"`
+	ThrottleRetry      ThrottleRetrySettings `mapstructure:"throttle_retry"`
+	HTTP               HTTPSettings          `mapstructure:"http"`
+	DeadLetter         DeadLetterConfig      `mapstructure:"dead_letter"`
+	MaxRecordsPerBatch int                   `mapstructure:"max_records_per_batch"`
+	MaxRecordSize      int                   `mapstructure:"max_record_size"`
+	// AdaptiveBatching starts each request at MaxRecordsPerBatch records, halving the
+	// records whenever a request is throttled and growing them back once written.
+	AdaptiveBatching bool `mapstructure:"adaptive_batching"`
+	// AdaptiveMinRecords is the fewest records per request that adaptive batching shrinks to.
+	AdaptiveMinRecords int `mapstructure:"adaptive_min_records"`
+	// MirrorRegions are the regions that every batch is also written to, to the stream of the
+	// same name, so that the records are available from another region for disaster recovery.
+	MirrorRegions []string `mapstructure:"mirror_regions"`
+	// MirrorRequireAll fails the export when a batch fails to be written to any of the
+	// mirror regions, otherwise only the failures of the primary region fail the export.
+	MirrorRequireAll bool `mapstructure:"mirror_require_all"`
+	// Aggregation packs records that share a partition key into aggregated
+	// records using the kinesis producer library format.
+	Aggregation bool `mapstructure:"aggregation"`
+	// Envelope is the format of the aggregated records, either the kinesis producer
+	// library format or the data of each record preceded by its length.
+	Envelope string `mapstructure:"envelope"`
+	// SingleRecordMode writes each record with PutRecord instead of batching them with PutRecords.
+	SingleRecordMode bool `mapstructure:"single_record_mode"`
+	// SequenceOrdering orders the records of each partition key across PutRecord
+	// calls using the sequence number of the previous record, in single record mode only.
+	SequenceOrdering bool `mapstructure:"sequence_ordering"`
+	// ChecksumRecords appends a record holding the checksum of the other records
+	// to each request so consumers can verify that no records were lost or corrupted.
+	ChecksumRecords bool `mapstructure:"checksum_records"`
+	// RecordID prepends an ID derived from the partition key and data of each
+	// record so consumers can drop the records that were written again on retry.
+	RecordID bool `mapstructure:"record_id"`
+	// FlushInterval is the longest time records are buffered to be combined
+	// with the records of later exports, no records are buffered when unset.
+	FlushInterval time.Duration `mapstructure:"flush_interval"`
+	// FlushRecordCount writes the buffered records once this many are pending,
+	// ahead of a full batch of MaxRecordsPerBatch records when lower.
+	FlushRecordCount int `mapstructure:"flush_record_count"`
+	// MaxConcurrentRequests is the number of chunks of a batch that are written concurrently.
+	MaxConcurrentRequests int `mapstructure:"max_concurrent_requests"`
+	// RequestTimeout limits the time of each write request, a request that
+	// times out is retried within throttle_retry, no limit is applied when unset.
+	RequestTimeout time.Duration `mapstructure:"request_timeout"`
+	// Ordered writes the chunks of each partition key one at a time and in order,
+	// chunks of other keys are still written concurrently.
+	Ordered bool `mapstructure:"ordered"`
+	// MaxBufferedRecords limits the records being written by each exporter,
+	// exports block until enough records are written, no limit is applied when unset.
+	MaxBufferedRecords int `mapstructure:"max_buffered_records"`
+	// MaxInflightBytes limits the bytes of the requests being written at once by each exporter,
+	// requests block until enough bytes are written, no limit is applied when unset.
+	MaxInflightBytes int `mapstructure:"max_in_flight_bytes"`
+	// RateLimit limits the records and bytes written per second by each exporter.
+	RateLimit RateLimitSettings `mapstructure:"rate_limit"`
+	// EMF writes the health of the exporter to a log group as embedded metric format logs.
+	EMF EMFSettings `mapstructure:"emf"`
+	// RetryBudget limits the failed writes that are retried, so that a sustained outage is not amplified by retries.
+	RetryBudget RetryBudgetSettings `mapstructure:"retry_budget"`
+	// LogFailures limits the logging of failed writes so that sustained failures do not flood the logs.
+	LogFailures LogFailuresSettings `mapstructure:"log_failures"`
+	// CreateStreamIfMissing creates the stream with ShardCount shards
+	// when the exporter starts if it does not exist.
+	CreateStreamIfMissing bool `mapstructure:"create_stream_if_missing"`
+	ShardCount            int  `mapstructure:"shard_count"`
+	// SkipStreamCheck starts the exporter without checking that the stream is active,
+	// for roles that are not allowed to describe the stream.
+	SkipStreamCheck bool `mapstructure:"skip_stream_check"`
+	// Warmup describes the stream on start when the stream check is skipped, so that the
+	// connection and credentials are ready before the first write, failures are only logged.
+	Warmup bool `mapstructure:"warmup"`
+	// LazyInit creates the AWS clients on the first write instead of when the exporter is
+	// created, so that failing to set them up is retried instead of failing the start.
+	LazyInit bool `mapstructure:"lazy_init"`
+	// DryRun encodes and chunks the records as usual but only logs the requests
+	// that would have been made, no records are written and the stream is not checked.
+	DryRun bool `mapstructure:"dry_run"`
+	// StartupJitter is the longest random delay before the exporter starts,
+	// so that collectors started together do not write in lockstep.
+	StartupJitter time.Duration `mapstructure:"startup_jitter"`
+	// ShutdownTimeout bounds the time that shutdown waits on the buffered and
+	// in-flight records to be written, the records still being written are
+	// dropped once it has passed. It does not bound shutdown when zero.
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	// RecordAttributes are added to the resource of every record, or as a JSON header of raw bodies.
+	RecordAttributes RecordAttributesSettings `mapstructure:"record_attributes"`
+	// ProducerID is added to every record like the record attributes,
+	// and to the logs and metrics of the exporter.
+	ProducerID ProducerIDSettings `mapstructure:"producer_id"`
+	// BatchSequence sets the sequence number of each export and the instance id of
+	// the exporter on the resource of every record, so that lost exports can be detected.
+	BatchSequence bool `mapstructure:"batch_sequence"`
+	// DropResourceAttributes are removed from the resource of the data before it is encoded,
+	// such as attributes with a high cardinality or personal data.
+	DropResourceAttributes []string `mapstructure:"drop_resource_attributes"`
+	// SamplingRatio is the ratio of partition keys whose records are written,
+	// the records of the other keys are dropped.
+	SamplingRatio float64 `mapstructure:"sampling_ratio"`
+	// RetryableErrorCodes replaces the record error codes that are retried,
+	// records that fail with any other code are dropped with a permanent error.
+	RetryableErrorCodes []string `mapstructure:"retryable_error_codes"`
+	// OnPermanentError is how records that permanently failed to be written are handled,
+	// either failing the export or dropping the records so the pipeline is not blocked.
+	OnPermanentError string `mapstructure:"on_permanent_error"`
+	// ValidatePayloads drops the records with an empty payload, and the otlp records
+	// without any spans, metrics or log records, instead of writing them.
+	ValidatePayloads bool `mapstructure:"validate_payloads"`
+
+	// PartitionKey is the strategy used to derive the partition key of each record.
+	PartitionKey string `mapstructure:"partition_key"`
+	// RoundRobinKeys is the number of keys cycled through by the round_robin partition key.
+	RoundRobinKeys int `mapstructure:"round_robin_keys"`
+	// RoundRobinRefreshInterval is how often the open shards of the stream are counted
+	// to resize the round_robin keys to match, the keys are not resized when unset.
+	RoundRobinRefreshInterval time.Duration `mapstructure:"round_robin_refresh_interval"`
+	// PartitionKeySource is the resource attribute used as the partition key,
+	// records without the attribute will use a random partition key.
+	PartitionKeySource string `mapstructure:"partition_key_source"`
+	// PartitionKeySources are the resource attributes used as the partition key in order,
+	// the first attribute that is set is used, records without any of them use a random partition key.
+	PartitionKeySources []string `mapstructure:"partition_key_sources"`
+	// PartitionKeyAttribute is the span or log record attribute whose value is used as
+	// the partition key of that record, overriding the partition key strategy.
+	PartitionKeyAttribute string `mapstructure:"partition_key_attribute"`
+	// ExplicitHashKeySource is the resource attribute used as the explicit hash key
+	// of each record, values that are not a 128 bit decimal integer are hashed into one.
+	ExplicitHashKeySource string `mapstructure:"explicit_hash_key_source"`
+	// ConsistentHashingRefreshInterval is how often the hash key ranges of the open shards are listed
+	// so that each partition key is consistently hashed onto a shard as the explicit hash key
+	// of its records, the partition keys are hashed by kinesis when unset.
+	ConsistentHashingRefreshInterval time.Duration `mapstructure:"consistent_hashing_refresh_interval"`
+	// PartitionKeySalt is appended to every partition key to move the keys to other shards.
+	PartitionKeySalt string `mapstructure:"partition_key_salt"`
+	// MaxPartitionKeys bounds the distinct partition keys, once reached the records
+	// with any other key use one of the keys already seen instead. Unset does not bound the keys.
+	MaxPartitionKeys int `mapstructure:"max_partition_keys"`
 }
 
+const (
+	// targetKinesis delivers the records to a kinesis data stream.
+	targetKinesis = "kinesis"
+	// targetFirehose delivers the records to a kinesis data firehose delivery stream.
+	targetFirehose = "firehose"
+
+	// partitionByTraceID uses the trace id as the partition key of each record.
+	partitionByTraceID = "trace_id"
+	// partitionByContentHash uses the hash of each encoded record as its partition key.
+	partitionByContentHash = "content_hash"
+	// partitionByRoundRobin cycles through a fixed set of partition keys.
+	partitionByRoundRobin = "round_robin"
+
+	// encodingAvro writes each span as an avro record.
+	encodingAvro = "avro"
+
+	// markerPartitionKey prefixes the partition key with the compression name.
+	markerPartitionKey = "partition_key"
+	// markerByte prepends the compression marker byte to the data of each record.
+	markerByte = "byte"
+
+	// envelopeKPL aggregates the records using the kinesis producer library format.
+	envelopeKPL = "kpl"
+	// envelopeLengthPrefixed aggregates the records by preceding each with its length.
+	envelopeLengthPrefixed = "length_prefixed"
+
+	// permanentErrorFail returns the permanent error from the export.
+	permanentErrorFail = "fail"
+	// permanentErrorDrop logs and counts the permanently failed records without failing the export.
+	permanentErrorDrop = "drop"
+
+	// inlineDictionaryPrefix marks a compression dictionary given as base64 instead of a path.
+	inlineDictionaryPrefix = "base64:"
+
+	// producerIDAttribute is the record attribute that holds the producer_id.
+	producerIDAttribute = "kinesis.producer_id"
+
+	// maxPartitionKeySaltLength leaves at least half of the partition key limit for the key itself.
+	maxPartitionKeySaltLength = batch.MaxPartitionKeyLength / 2
+)
+
 var _ config.Exporter = (*Config)(nil)
+
+// streamName returns the stream that the signal is written to.
+func (cfg *Config) streamName(signal config.DataType) string {
+	var stream string
+	switch signal {
+	case config.TracesDataType:
+		stream = cfg.AWS.Streams.Traces
+	case config.MetricsDataType:
+		stream = cfg.AWS.Streams.Metrics
+	case config.LogsDataType:
+		stream = cfg.AWS.Streams.Logs
+	}
+	if stream == "" {
+		stream = cfg.AWS.StreamName
+	}
+	if stream == "" {
+		stream = cfg.AWS.StreamARN
+	}
+	return stream
+}
+
+// validateStreamARN checks that the ARN is the ARN of a kinesis data stream.
+func validateStreamARN(streamARN string) error {
+	parsed, err := arn.Parse(streamARN)
+	if err != nil {
+		return fmt.Errorf("invalid stream_arn: %w", err)
+	}
+	if parsed.Service != kinesis.ServiceName || !strings.HasPrefix(parsed.Resource, "stream/") || len(parsed.Resource) == len("stream/") {
+		return fmt.Errorf("invalid stream_arn %q, must be the ARN of a kinesis data stream", streamARN)
+	}
+	return nil
+}
+
+// endpointsID returns the id of the target service used to resolve its endpoints.
+func (cfg *Config) endpointsID() string {
+	if cfg.Target == targetFirehose {
+		return firehose.EndpointsID
+	}
+	return kinesis.EndpointsID
+}
+
+// name returns the encoding used by the signal, which is the encoding name unless overridden.
+func (e Encoding) name(signal config.DataType) string {
+	var name string
+	switch signal {
+	case config.TracesDataType:
+		name = e.Signals.Traces
+	case config.MetricsDataType:
+		name = e.Signals.Metrics
+	case config.LogsDataType:
+		name = e.Signals.Logs
+	}
+	if name == "" {
+		name = e.Name
+	}
+	return name
+}
+
+// compressor returns the compressor of the encoding using the compression dictionary when set.
+func (e Encoding) compressor() (compress.Compressor, error) {
+	var opts []compress.Option
+	if e.Compression == compress.Auto {
+		opts = append(opts,
+			compress.WithCandidates(e.CompressionCandidates...),
+			compress.WithAutoMinSize(e.CompressionAutoMinSize),
+		)
+	}
+	if e.CompressionDictionary != "" {
+		dictionary, err := e.dictionary()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, compress.WithDictionary(dictionary))
+	}
+	return compress.NewCompressor(e.Compression, e.CompressionLevel, opts...)
+}
+
+// dictionary reads the compression dictionary from its path or decodes it when inlined.
+func (e Encoding) dictionary() ([]byte, error) {
+	if inline := strings.TrimPrefix(e.CompressionDictionary, inlineDictionaryPrefix); inline != e.CompressionDictionary {
+		dictionary, err := base64.StdEncoding.DecodeString(inline)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode compression_dictionary: %w", err)
+		}
+		return dictionary, nil
+	}
+	dictionary, err := ioutil.ReadFile(e.CompressionDictionary)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compression_dictionary: %w", err)
+	}
+	return dictionary, nil
+}
+
+// Validate checks if the exporter configuration is valid
+func (cfg *Config) Validate() error {
+	if cfg.MaxRecordsPerBatch < 1 {
+		return errors.New("max_records_per_batch must be at least 1")
+	}
+	if cfg.MaxRecordSize < 1 || cfg.MaxRecordSize > batch.MaxRecordSize {
+		return fmt.Errorf("max_record_size must be within [1, %d]", batch.MaxRecordSize)
+	}
+	if cfg.AdaptiveBatching && (cfg.AdaptiveMinRecords < 1 || cfg.AdaptiveMinRecords > cfg.MaxRecordsPerBatch) {
+		return errors.New("adaptive_min_records must be within [1, max_records_per_batch] with adaptive_batching")
+	}
+	if cfg.FlushInterval < 0 {
+		return errors.New("flush_interval must not be negative")
+	}
+	if cfg.FlushRecordCount < 0 {
+		return errors.New("flush_record_count must not be negative")
+	}
+	if cfg.FlushRecordCount > 0 && cfg.FlushInterval == 0 {
+		return errors.New("flush_record_count requires flush_interval")
+	}
+	if cfg.MaxConcurrentRequests < 1 {
+		return errors.New("max_concurrent_requests must be at least 1")
+	}
+	if cfg.DryRun && cfg.CreateStreamIfMissing {
+		return errors.New("dry_run can not be used with create_stream_if_missing")
+	}
+	if cfg.LazyInit && cfg.CreateStreamIfMissing {
+		return errors.New("lazy_init can not be used with create_stream_if_missing")
+	}
+	if cfg.CreateStreamIfMissing && cfg.ShardCount < 1 {
+		return errors.New("shard_count must be at least 1 when creating missing streams")
+	}
+	if cfg.RateLimit.RecordsPerSecond < 0 || cfg.RateLimit.BytesPerSecond < 0 {
+		return errors.New("rate_limit must not be negative")
+	}
+	if cfg.AWS.SDKMaxRetries < defaultSDKMaxRetries {
+		return errors.New("sdk_max_retries must be at least -1")
+	}
+	if cfg.RetryBudget.MaxRetries < 0 || cfg.RetryBudget.Window < 0 {
+		return errors.New("retry_budget must not be negative")
+	}
+	if cfg.StartupJitter < 0 {
+		return errors.New("startup_jitter must not be negative")
+	}
+	if cfg.ProducerID.ID != "" && !cfg.ProducerID.Enabled {
+		return errors.New("producer_id.id requires producer_id.enabled")
+	}
+	if cfg.ShutdownTimeout < 0 {
+		return errors.New("shutdown_timeout must not be negative")
+	}
+	if cfg.MaxBufferedRecords < 0 {
+		return errors.New("max_buffered_records must not be negative")
+	}
+	if cfg.MaxInflightBytes < 0 {
+		return errors.New("max_in_flight_bytes must not be negative")
+	}
+	if cfg.SingleRecordMode && cfg.Aggregation {
+		return errors.New("single_record_mode can not be used with aggregation")
+	}
+	if cfg.SequenceOrdering && !cfg.SingleRecordMode {
+		return errors.New("sequence_ordering requires single_record_mode")
+	}
+	if cfg.SamplingRatio < 0 || cfg.SamplingRatio > 1 {
+		return errors.New("sampling_ratio must be within [0, 1]")
+	}
+	if cfg.RequestTimeout < 0 {
+		return errors.New("request_timeout must not be negative")
+	}
+	if cfg.HTTP.Timeout < 0 || cfg.HTTP.MaxIdleConns < 0 || cfg.HTTP.IdleConnTimeout < 0 {
+		return errors.New("http settings must not be negative")
+	}
+	if cfg.HTTP.ProxyURL != "" {
+		if u, err := url.Parse(cfg.HTTP.ProxyURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid http proxy_url %q", cfg.HTTP.ProxyURL)
+		}
+	}
+	if cfg.LogFailures.Enabled && cfg.LogFailures.SampleRate < 1 {
+		return errors.New("log_failures sample_rate must be at least 1 when enabled")
+	}
+	if cfg.EMF.Enabled {
+		if cfg.EMF.Namespace == "" || cfg.EMF.LogGroup == "" {
+			return errors.New("emf namespace and log_group must be set when enabled")
+		}
+		if cfg.EMF.Interval <= 0 {
+			return errors.New("emf interval must be positive")
+		}
+	}
+	if cfg.AWS.Endpoint != "" && cfg.AWS.KinesisEndpoint != "" {
+		return errors.New("only one of endpoint and kinesis_endpoint can be set")
+	}
+	if cfg.AWS.RoleARN != "" && cfg.AWS.Role != "" {
+		return errors.New("only one of role_arn and role can be set")
+	}
+	if cfg.AWS.StreamName != "" && cfg.AWS.StreamARN != "" {
+		return errors.New("only one of stream_name and stream_arn can be set")
+	}
+	if cfg.AWS.StreamARN != "" {
+		if err := validateStreamARN(cfg.AWS.StreamARN); err != nil {
+			return err
+		}
+		if cfg.CreateStreamIfMissing {
+			// Streams can only be created by name within the account of the caller
+			return errors.New("create_stream_if_missing can not be used with stream_arn")
+		}
+		if len(cfg.MirrorRegions) > 0 {
+			// The ARN names the region of the stream
+			return errors.New("mirror_regions can not be used with stream_arn")
+		}
+	}
+	if (cfg.AWS.AccessKey == "") != (cfg.AWS.SecretKey == "") {
+		return errors.New("access_key and secret_key must be set together")
+	}
+	if cfg.AWS.SessionToken != "" && cfg.AWS.AccessKey == "" {
+		return errors.New("session_token can not be used without access_key and secret_key")
+	}
+	if cfg.AWS.UseFIPSEndpoint && cfg.AWS.Endpoint == "" && cfg.AWS.KinesisEndpoint == "" {
+		if _, err := fipsEndpoint(cfg.endpointsID(), cfg.AWS.Region); err != nil {
+			return err
+		}
+	}
+	if cfg.MirrorRequireAll && len(cfg.MirrorRegions) == 0 {
+		return errors.New("mirror_require_all can not be used without mirror_regions")
+	}
+	if len(cfg.MirrorRegions) > 0 && (cfg.AWS.Endpoint != "" || cfg.AWS.KinesisEndpoint != "") {
+		// The endpoint overrides the region of every client
+		return errors.New("mirror_regions can not be used with an endpoint")
+	}
+	if len(cfg.MirrorRegions) > 0 && cfg.ChecksumRecords {
+		// Each region chunks the batch, which would skip checksum sequence numbers
+		return errors.New("mirror_regions can not be used with checksum_records")
+	}
+	if len(cfg.MirrorRegions) > 0 && cfg.Encoding.BatchCompression {
+		// Each region chunks the batch, which would compress the frames once per region
+		return errors.New("mirror_regions can not be used with batch_compression")
+	}
+	for i, region := range cfg.MirrorRegions {
+		switch {
+		case region == "":
+			return errors.New("mirror_regions must not contain an empty region")
+		case region == cfg.AWS.Region:
+			return fmt.Errorf("mirror_regions must not contain the primary region %q", region)
+		case contains(cfg.MirrorRegions[:i], region):
+			return fmt.Errorf("mirror_regions must not contain the region %q more than once", region)
+		}
+		if cfg.AWS.UseFIPSEndpoint {
+			if _, err := fipsEndpoint(cfg.endpointsID(), region); err != nil {
+				return err
+			}
+		}
+	}
+	switch cfg.Target {
+	case "", targetKinesis:
+	case targetFirehose:
+		if cfg.AWS.StreamARN != "" {
+			return fmt.Errorf("stream_arn can not be used with target %q", cfg.Target)
+		}
+		if cfg.DeadLetter.StreamName != "" {
+			return fmt.Errorf("dead_letter can not be used with target %q", cfg.Target)
+		}
+		if cfg.Aggregation {
+			return fmt.Errorf("aggregation can not be used with target %q", cfg.Target)
+		}
+		if cfg.CreateStreamIfMissing {
+			return fmt.Errorf("create_stream_if_missing can not be used with target %q", cfg.Target)
+		}
+		if cfg.AdaptiveBatching {
+			return fmt.Errorf("adaptive_batching can not be used with target %q", cfg.Target)
+		}
+		if cfg.SingleRecordMode {
+			return fmt.Errorf("single_record_mode can not be used with target %q", cfg.Target)
+		}
+		if cfg.AWS.StreamNameTemplate != "" {
+			return fmt.Errorf("stream_name_template can not be used with target %q", cfg.Target)
+		}
+		if cfg.Ordered {
+			return fmt.Errorf("ordered can not be used with target %q", cfg.Target)
+		}
+		if cfg.Encoding.BatchCompression {
+			return fmt.Errorf("batch_compression can not be used with target %q", cfg.Target)
+		}
+		if cfg.DryRun {
+			return fmt.Errorf("dry_run can not be used with target %q", cfg.Target)
+		}
+		if cfg.RoundRobinRefreshInterval > 0 {
+			return fmt.Errorf("round_robin_refresh_interval can not be used with target %q", cfg.Target)
+		}
+		if cfg.ConsistentHashingRefreshInterval > 0 {
+			return fmt.Errorf("consistent_hashing_refresh_interval can not be used with target %q", cfg.Target)
+		}
+		if cfg.ChecksumRecords {
+			return fmt.Errorf("checksum_records can not be used with target %q", cfg.Target)
+		}
+		if cfg.RecordID {
+			return fmt.Errorf("record_id can not be used with target %q", cfg.Target)
+		}
+		// Firehose drops the partition keys so the marker has to be within the data
+		compressed := cfg.Encoding.Compression != "" && cfg.Encoding.Compression != compress.None
+		if compressed && cfg.Encoding.CompressionMarker != markerByte {
+			return fmt.Errorf("compression requires compression_marker %q with target %q", markerByte, cfg.Target)
+		}
+	default:
+		return fmt.Errorf("unknown target %q", cfg.Target)
+	}
+	usesAvro := false
+	for _, signal := range []config.DataType{config.TracesDataType, config.MetricsDataType, config.LogsDataType} {
+		name := cfg.Encoding.name(signal)
+		if _, err := batch.NewEncoder(name); err != nil {
+			return fmt.Errorf("invalid encoding of %s: %w", signal, err)
+		}
+		usesAvro = usesAvro || name == encodingAvro
+	}
+	if gr := cfg.Encoding.GlueRegistry; gr.Name != "" || gr.Schema != "" {
+		if !usesAvro {
+			return fmt.Errorf("glue_registry can only be used with encoding %q", encodingAvro)
+		}
+		if gr.Name == "" || gr.Schema == "" {
+			return errors.New("glue_registry name and schema must both be set")
+		}
+		if cfg.LazyInit {
+			return errors.New("glue_registry can not be used with lazy_init")
+		}
+	}
+	if cfg.AWS.StreamNameTemplate != "" {
+		if _, err := batch.NewStreamTemplate(cfg.AWS.StreamNameTemplate); err != nil {
+			return fmt.Errorf("invalid stream_name_template: %w", err)
+		}
+	}
+	if _, err := cfg.Encoding.compressor(); err != nil {
+		return fmt.Errorf("invalid compression: %w", err)
+	}
+	if cfg.Encoding.CompressionMinSize < 0 {
+		return errors.New("compression_min_size must not be negative")
+	}
+	if cfg.Encoding.CompressionMinRatio < 0 {
+		return errors.New("compression_min_ratio must not be negative")
+	}
+	if cfg.Encoding.CompressionMinRatio > 0 {
+		if cfg.Encoding.Compression == "" || cfg.Encoding.Compression == compress.None {
+			return errors.New("compression_min_ratio requires compression")
+		}
+		if cfg.Encoding.BatchCompression {
+			return errors.New("compression_min_ratio can not be used with batch_compression")
+		}
+	}
+	if cfg.Encoding.CompressionAutoMinSize < 0 {
+		return errors.New("compression_auto_min_size must not be negative")
+	}
+	if len(cfg.Encoding.CompressionCandidates) > 0 && cfg.Encoding.Compression != compress.Auto {
+		return fmt.Errorf("compression_candidates can only be used with compression %q", compress.Auto)
+	}
+	switch cfg.Encoding.CompressionMarker {
+	case "", markerPartitionKey, markerByte:
+	default:
+		return fmt.Errorf("unknown compression_marker %q", cfg.Encoding.CompressionMarker)
+	}
+	switch cfg.Envelope {
+	case "", envelopeKPL:
+	case envelopeLengthPrefixed:
+		if !cfg.Aggregation {
+			return fmt.Errorf("envelope %q requires aggregation", cfg.Envelope)
+		}
+	default:
+		return fmt.Errorf("unknown envelope %q", cfg.Envelope)
+	}
+	if cfg.RecordID && cfg.Aggregation {
+		return errors.New("record_id can not be used with aggregation")
+	}
+	if cfg.RecordID && cfg.Encoding.BatchCompression {
+		return errors.New("record_id can not be used with batch_compression")
+	}
+	if cfg.Encoding.BatchCompression {
+		if cfg.Encoding.Compression == "" || cfg.Encoding.Compression == compress.None {
+			return errors.New("batch_compression requires a compression")
+		}
+		if cfg.Aggregation {
+			return errors.New("batch_compression can not be used with aggregation")
+		}
+		if cfg.ExplicitHashKeySource != "" {
+			return errors.New("batch_compression can not be used with explicit_hash_key_source")
+		}
+	}
+	switch cfg.OnPermanentError {
+	case "", permanentErrorFail, permanentErrorDrop:
+	default:
+		return fmt.Errorf("unknown on_permanent_error %q", cfg.OnPermanentError)
+	}
+	switch cfg.PartitionKey {
+	case "":
+	case partitionByRoundRobin:
+		if cfg.RoundRobinKeys < 1 {
+			return fmt.Errorf("round_robin_keys must be at least 1 with partition_key %q", cfg.PartitionKey)
+		}
+		fallthrough
+	case partitionByTraceID, partitionByContentHash:
+		if cfg.PartitionKeySource != "" {
+			return fmt.Errorf("partition_key_source can not be used with partition_key %q", cfg.PartitionKey)
+		}
+		if len(cfg.PartitionKeySources) > 0 {
+			return fmt.Errorf("partition_key_sources can not be used with partition_key %q", cfg.PartitionKey)
+		}
+	default:
+		return fmt.Errorf("unknown partition_key %q", cfg.PartitionKey)
+	}
+	if len(cfg.PartitionKeySalt) > maxPartitionKeySaltLength {
+		return fmt.Errorf("partition_key_salt must not be longer than %d bytes", maxPartitionKeySaltLength)
+	}
+	if cfg.MaxPartitionKeys < 0 {
+		return errors.New("max_partition_keys must not be negative")
+	}
+	if cfg.RoundRobinRefreshInterval < 0 {
+		return errors.New("round_robin_refresh_interval must not be negative")
+	}
+	if cfg.RoundRobinRefreshInterval > 0 && cfg.PartitionKey != partitionByRoundRobin {
+		return fmt.Errorf("round_robin_refresh_interval can only be used with partition_key %q", partitionByRoundRobin)
+	}
+	if cfg.ConsistentHashingRefreshInterval < 0 {
+		return errors.New("consistent_hashing_refresh_interval must not be negative")
+	}
+	if cfg.ConsistentHashingRefreshInterval > 0 {
+		if cfg.ExplicitHashKeySource != "" {
+			return errors.New("consistent_hashing_refresh_interval can not be used with explicit_hash_key_source")
+		}
+		if cfg.Encoding.BatchCompression {
+			return errors.New("consistent_hashing_refresh_interval can not be used with batch_compression")
+		}
+		if cfg.AWS.StreamNameTemplate != "" {
+			// The routed streams have shards of their own
+			return errors.New("consistent_hashing_refresh_interval can not be used with stream_name_template")
+		}
+	}
+	if cfg.PartitionKeyAttribute != "" && cfg.PartitionKey == partitionByContentHash {
+		// The content hash replaces the key of every record once it is encoded
+		return fmt.Errorf("partition_key_attribute can not be used with partition_key %q", cfg.PartitionKey)
+	}
+	if cfg.PartitionKeySource != "" && len(cfg.PartitionKeySources) > 0 {
+		return errors.New("partition_key_source can not be used with partition_key_sources")
+	}
+	for _, k := range cfg.PartitionKeySources {
+		if k == "" {
+			return errors.New("partition_key_sources must not contain an empty attribute")
+		}
+	}
+	for _, k := range cfg.DropResourceAttributes {
+		// The keys are derived from the resource while it is encoded, after the attributes are dropped
+		if k == cfg.PartitionKeySource || k == cfg.ExplicitHashKeySource || contains(cfg.PartitionKeySources, k) {
+			return fmt.Errorf("drop_resource_attributes can not drop %q that is used as a key source", k)
+		}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
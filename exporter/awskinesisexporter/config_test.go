@@ -15,18 +15,27 @@
 package awskinesisexporter
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"path"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go/service/kinesis"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/collector/component/componenttest"
 	"go.opentelemetry.io/collector/config"
 	"go.opentelemetry.io/collector/config/configtest"
 	"go.opentelemetry.io/collector/exporter/exporterhelper"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 
 	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/compress"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -47,11 +56,40 @@ func TestDefaultConfig(t *testing.T) {
 			QueueSettings:    exporterhelper.DefaultQueueSettings(),
 			RetrySettings:    exporterhelper.DefaultRetrySettings(),
 			TimeoutSettings:  exporterhelper.DefaultTimeoutSettings(),
+			Target:           "kinesis",
+			OnPermanentError: "fail",
+			Encoding: Encoding{
+				Name:                   "jaeger_proto",
+				Compression:            "none",
+				CompressionMinSize:     1024,
+				CompressionAutoMinSize: 16384,
+				CompressionMarker:      "partition_key",
+			},
 			AWS: AWSConfig{
-				Region: "us-west-2",
+				Region:        "us-west-2",
+				SDKMaxRetries: -1,
+			},
+			ThrottleRetry: ThrottleRetrySettings{
+				InitialInterval: 100 * time.Millisecond,
+				MaxInterval:     time.Second,
+				MaxElapsedTime:  5 * time.Second,
+				Multiplier:      1.5,
+			},
+			MaxRecordsPerBatch:    batch.MaxBatchedRecords,
+			MaxRecordSize:         batch.MaxRecordSize,
+			AdaptiveMinRecords:    10,
+			MaxConcurrentRequests: 1,
+			RoundRobinKeys:        4,
+			ShardCount:            1,
+			SamplingRatio:         1,
+			ShutdownTimeout:       30 * time.Second,
+			EMF: EMFSettings{
+				Interval: time.Minute,
+			},
+			LogFailures: LogFailuresSettings{
+				Enabled:    true,
+				SampleRate: 100,
 			},
-			MaxRecordsPerBatch: batch.MaxBatchedRecords,
-			MaxRecordSize:      batch.MaxRecordSize,
 		},
 	)
 }
@@ -78,21 +116,745 @@ func TestConfig(t *testing.T) {
 				InitialInterval: 5 * time.Second,
 				MaxElapsedTime:  300 * time.Second,
 			},
-			TimeoutSettings: exporterhelper.DefaultTimeoutSettings(),
-			QueueSettings:   exporterhelper.DefaultQueueSettings(),
+			TimeoutSettings:  exporterhelper.DefaultTimeoutSettings(),
+			QueueSettings:    exporterhelper.DefaultQueueSettings(),
+			Target:           "kinesis",
+			OnPermanentError: "drop",
+			ValidatePayloads: true,
+			Encoding: Encoding{
+				Name: "otlp_proto",
+				Signals: SignalEncodings{
+					Logs: "otlp_json",
+				},
+				Compression:            "gzip",
+				CompressionMinSize:     256,
+				CompressionMinRatio:    1.1,
+				CompressionAutoMinSize: 16384,
+				CompressionMarker:      "byte",
+				Passthrough:            true,
+			},
 			AWS: AWSConfig{
-				StreamName:      "test-stream",
-				KinesisEndpoint: "awskinesis.mars-1.aws.galactic",
-				Region:          "mars-1",
-				Role:            "arn:test-role",
+				StreamName: "test-stream",
+				Streams: StreamsConfig{
+					Metrics: "test-metrics-stream",
+				},
+				StreamNameTemplate:    "otel-{tenant.id}",
+				Endpoint:              "awskinesis.mars-1.aws.galactic",
+				DisableSSL:            true,
+				UserAgentSuffix:       "team-a/1.0",
+				PropagateTraceContext: true,
+				SDKMaxRetries:         0,
+				Region:                "mars-1",
+				RoleARN:               "arn:test-role",
+				RoleSessionName:       "test-session",
+				ExternalID:            "test-external-id",
+				AccessKey:             "test-access-key",
+				SecretKey:             "test-secret-key",
+			},
+			ThrottleRetry: ThrottleRetrySettings{
+				InitialInterval: 50 * time.Millisecond,
+				MaxInterval:     2 * time.Second,
+				MaxElapsedTime:  10 * time.Second,
+				Multiplier:      2,
+			},
+			DeadLetter: DeadLetterConfig{
+				StreamName: "test-dead-letter-stream",
+			},
+			MaxRecordSize:         1000,
+			MaxRecordsPerBatch:    10,
+			AdaptiveMinRecords:    10,
+			MaxConcurrentRequests: 4,
+			RequestTimeout:        2 * time.Second,
+			Ordered:               true,
+			RoundRobinKeys:        4,
+			CreateStreamIfMissing: true,
+			ShardCount:            2,
+			SamplingRatio:         0.25,
+			SkipStreamCheck:       true,
+			Warmup:                true,
+			StartupJitter:         5 * time.Second,
+			ShutdownTimeout:       10 * time.Second,
+			Aggregation:           true,
+			Envelope:              "length_prefixed",
+			ChecksumRecords:       true,
+			RecordAttributes: RecordAttributesSettings{
+				Attributes: map[string]string{
+					"deployment.environment": "test",
+					"collector.id":           "test-collector",
+				},
+				Overwrite: true,
+			},
+			ProducerID: ProducerIDSettings{
+				Enabled: true,
+				ID:      "collector-1",
+			},
+			BatchSequence: true,
+			DropResourceAttributes: []string{
+				"k8s.pod.uid",
 			},
-			MaxRecordSize:      1000,
-			MaxRecordsPerBatch: 10,
+			HTTP: HTTPSettings{
+				Timeout:         10 * time.Second,
+				MaxIdleConns:    16,
+				IdleConnTimeout: time.Minute,
+				ProxyURL:        "http://proxy.internal:3128",
+			},
+			FlushInterval:      time.Second,
+			FlushRecordCount:   100,
+			MaxBufferedRecords: 5000,
+			MaxInflightBytes:   16 << 20,
+			RateLimit: RateLimitSettings{
+				RecordsPerSecond: 1000,
+				BytesPerSecond:   1048576,
+			},
+			EMF: EMFSettings{
+				Enabled:   true,
+				Namespace: "test-namespace",
+				LogGroup:  "test-log-group",
+				Interval:  30 * time.Second,
+			},
+			RetryBudget: RetryBudgetSettings{
+				MaxRetries: 100,
+				Window:     time.Minute,
+			},
+			LogFailures: LogFailuresSettings{
+				Enabled:    true,
+				SampleRate: 10,
+			},
+			RetryableErrorCodes:   []string{"ProvisionedThroughputExceededException", "InternalFailure", "KMSThrottlingException"},
+			PartitionKeySource:    "service.name",
+			PartitionKeyAttribute: "kinesis.partition_key",
+			ExplicitHashKeySource: "tenant.shard",
+			PartitionKeySalt:      "-2021-10",
+			MaxPartitionKeys:      1000,
 		},
 	)
 }
 
+func TestConfigDeprecatedKeys(t *testing.T) {
+	factories, err := componenttest.NopFactories()
+	assert.Nil(t, err)
+
+	factory := NewFactory()
+	factories.Exporters[factory.Type()] = factory
+	cfg, err := configtest.LoadConfigAndValidate(path.Join(".", "testdata", "config_deprecated.yaml"), factories)
+	require.NoError(t, err)
+	require.NotNil(t, cfg)
+
+	e := cfg.Exporters[config.NewComponentID(typeStr)].(*Config)
+	assert.Equal(t, "arn:test-role", e.AWS.Role, "Must load the deprecated role")
+	assert.Empty(t, e.AWS.RoleARN, "Must not set role_arn from the deprecated role")
+
+	assert.Equal(t, "awskinesis.mars-1.aws.galactic", e.AWS.KinesisEndpoint, "Must load the deprecated kinesis_endpoint")
+	assert.Empty(t, e.AWS.Endpoint, "Must not set endpoint from the deprecated kinesis_endpoint")
+
+	sess, cfgs, err := newSession(e)
+	require.NoError(t, err, "Must not error when creating the session")
+	client := kinesis.New(sess, cfgs...)
+	assert.Equal(t, "https://awskinesis.mars-1.aws.galactic", client.Endpoint, "Must use the deprecated kinesis_endpoint as the endpoint")
+	role := newAssumeRoleProvider(sess, e)
+	require.NotNil(t, role, "Must assume the deprecated role")
+	assert.Equal(t, "arn:test-role", role.RoleARN, "Must use the deprecated role as the role_arn")
+
+	core, logs := observer.New(zap.WarnLevel)
+	params := componenttest.NewNopExporterCreateSettings()
+	params.Logger = zap.New(core)
+	_, err = createExporter(e, params, config.TracesDataType)
+	require.NoError(t, err, "Must not error when creating the exporter")
+	assert.Equal(t, 1, logs.FilterMessage("aws.role is deprecated, use aws.role_arn instead").Len(), "Must warn about the deprecated role")
+	assert.Equal(t, 1, logs.FilterMessage("aws.kinesis_endpoint is deprecated, use aws.endpoint instead").Len(),
+		"Must warn about the deprecated kinesis_endpoint")
+}
+
 func TestConfigCheck(t *testing.T) {
 	cfg := (NewFactory()).CreateDefaultConfig()
 	assert.NoError(t, configtest.CheckConfigStruct(cfg))
 }
+
+func TestSecretRedacted(t *testing.T) {
+	t.Parallel()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.AWS.AccessKey = "test-access-key"
+	cfg.AWS.SecretKey = "test-secret-key"
+	cfg.AWS.SessionToken = "test-session-token"
+
+	for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+		out := fmt.Sprintf(format, cfg.AWS)
+		assert.NotContains(t, out, "test-secret-key", "Must redact the secret key with %s", format)
+		assert.NotContains(t, out, "test-session-token", "Must redact the session token with %s", format)
+	}
+	assert.Equal(t, "[REDACTED]", cfg.AWS.SecretKey.String(), "Must redact the secret key")
+	assert.Empty(t, Secret("").String(), "Must leave an unset secret empty")
+
+	out, err := json.Marshal(cfg.AWS)
+	require.NoError(t, err, "Must be able to marshal the config")
+	assert.NotContains(t, string(out), "test-secret-key", "Must redact the secret key when marshaled")
+}
+
+func TestConfigValidation(t *testing.T) {
+	t.Parallel()
+
+	cfg := createDefaultConfig().(*Config)
+	assert.NoError(t, cfg.Validate(), "Must not error with the default config")
+
+	cfg.Encoding.Name = "otlp_json"
+	assert.NoError(t, cfg.Validate(), "Must not error with a known encoding")
+
+	cfg.Encoding.Name = "not-an-encoding"
+	assert.ErrorIs(t, cfg.Validate(), batch.ErrUnknownExportEncoder, "Must error with an unknown encoding")
+
+	cfg.Encoding.Name = "otlp_proto"
+	cfg.Encoding.Signals.Logs = "otlp_json"
+	assert.NoError(t, cfg.Validate(), "Must not error with a known encoding of a signal")
+
+	cfg.Encoding.Signals.Logs = "not-an-encoding"
+	assert.ErrorIs(t, cfg.Validate(), batch.ErrUnknownExportEncoder, "Must error with an unknown encoding of a signal")
+
+	cfg.Encoding.Signals.Logs = ""
+	cfg.Encoding.Signals.Traces = "avro"
+	cfg.Encoding.GlueRegistry = GlueRegistrySettings{Name: "test-registry", Schema: "spans"}
+	assert.NoError(t, cfg.Validate(), "Must not error when using the glue registry with the avro encoding of a signal")
+
+	cfg.Encoding.Signals.Traces = ""
+	cfg.Encoding.Name = "avro"
+	cfg.Encoding.GlueRegistry = GlueRegistrySettings{Name: "test-registry", Schema: "spans"}
+	assert.NoError(t, cfg.Validate(), "Must not error when using the glue registry with avro")
+
+	cfg.Encoding.GlueRegistry.Schema = ""
+	assert.Error(t, cfg.Validate(), "Must error when the glue registry schema is missing")
+
+	cfg.Encoding.GlueRegistry.Schema = "spans"
+	cfg.LazyInit = true
+	assert.Error(t, cfg.Validate(), "Must error when using the glue registry with lazy init")
+
+	cfg.LazyInit = false
+	cfg.Encoding.Name = defaultEncoding
+	assert.Error(t, cfg.Validate(), "Must error when using the glue registry without avro")
+
+	cfg.Encoding.GlueRegistry = GlueRegistrySettings{}
+	cfg.Encoding.Compression = "not-a-compression"
+	assert.ErrorIs(t, cfg.Validate(), compress.ErrUnknownCompression, "Must error with an unknown compression")
+
+	cfg.Encoding.Compression = compress.None
+	cfg.Encoding.CompressionLevel = 3
+	assert.ErrorIs(t, cfg.Validate(), compress.ErrInvalidLevel, "Must error when setting a level without compression")
+
+	cfg.Encoding.Compression = compress.Zstd
+	assert.NoError(t, cfg.Validate(), "Must not error with a valid compression level")
+
+	cfg.Encoding.CompressionMarker = "byte"
+	assert.NoError(t, cfg.Validate(), "Must not error with a known compression marker")
+
+	cfg.Encoding.CompressionMarker = "not-a-marker"
+	assert.Error(t, cfg.Validate(), "Must error with an unknown compression marker")
+
+	cfg.Encoding.CompressionMarker = ""
+	cfg.Encoding.CompressionMinSize = -1
+	assert.Error(t, cfg.Validate(), "Must error with a negative compression min size")
+
+	cfg.Encoding.CompressionMinSize = 0
+	cfg.Encoding.CompressionMinRatio = -1
+	assert.Error(t, cfg.Validate(), "Must error with a negative compression min ratio")
+
+	cfg.Encoding.CompressionMinRatio = 1.1
+	assert.NoError(t, cfg.Validate(), "Must not error with a compression min ratio")
+
+	cfg.Encoding.BatchCompression = true
+	assert.EqualError(t, cfg.Validate(), "compression_min_ratio can not be used with batch_compression")
+
+	cfg.Encoding.BatchCompression = false
+	cfg.Encoding.Compression = compress.None
+	cfg.Encoding.CompressionLevel = 0
+	assert.EqualError(t, cfg.Validate(), "compression_min_ratio requires compression")
+
+	cfg.Encoding.Compression = compress.Zstd
+	cfg.Encoding.CompressionLevel = 3
+	cfg.Encoding.CompressionMinRatio = 0
+	cfg.Encoding.CompressionAutoMinSize = -1
+	assert.Error(t, cfg.Validate(), "Must error with a negative compression auto min size")
+
+	cfg.Encoding.CompressionAutoMinSize = 0
+	cfg.Encoding.CompressionCandidates = []string{compress.Gzip, compress.Snappy}
+	assert.Error(t, cfg.Validate(), "Must error with compression candidates without auto compression")
+
+	cfg.Encoding.Compression = compress.Auto
+	assert.ErrorIs(t, cfg.Validate(), compress.ErrInvalidLevel, "Must error with a level a compression candidate does not support")
+
+	cfg.Encoding.CompressionLevel = 0
+	assert.NoError(t, cfg.Validate(), "Must not error with auto compression")
+
+	cfg.Encoding.CompressionCandidates = []string{compress.None}
+	assert.ErrorIs(t, cfg.Validate(), compress.ErrInvalidCandidates, "Must error with an invalid compression candidate")
+
+	cfg.Encoding.CompressionCandidates = nil
+	cfg.Encoding.Compression = compress.Zstd
+	cfg.Encoding.CompressionLevel = 3
+	cfg.Encoding.CompressionDictionary = "internal/compress/testdata/otlp.dict"
+	assert.NoError(t, cfg.Validate(), "Must not error with a zstd dictionary file")
+
+	dictionary, err := ioutil.ReadFile(cfg.Encoding.CompressionDictionary)
+	require.NoError(t, err)
+	cfg.Encoding.CompressionDictionary = "base64:" + base64.StdEncoding.EncodeToString(dictionary)
+	assert.NoError(t, cfg.Validate(), "Must not error with an inline zstd dictionary")
+
+	cfg.Encoding.CompressionDictionary = "base64:not base64"
+	assert.Error(t, cfg.Validate(), "Must error with an invalid inline dictionary")
+
+	cfg.Encoding.CompressionDictionary = "testdata/not-a-dictionary.dict"
+	assert.Error(t, cfg.Validate(), "Must error with a missing dictionary file")
+
+	cfg.Encoding.CompressionDictionary = "base64:" + base64.StdEncoding.EncodeToString([]byte("not a dictionary"))
+	assert.ErrorIs(t, cfg.Validate(), compress.ErrInvalidDictionary, "Must error with an invalid dictionary")
+
+	cfg.Encoding.CompressionDictionary = "base64:" + base64.StdEncoding.EncodeToString(dictionary)
+	cfg.Encoding.Compression = compress.Gzip
+	cfg.Encoding.CompressionLevel = 0
+	assert.ErrorIs(t, cfg.Validate(), compress.ErrInvalidDictionary, "Must error when using a dictionary without zstd")
+
+	cfg.Encoding.CompressionDictionary = ""
+	cfg.Encoding.Compression = compress.Zstd
+	cfg.Encoding.CompressionLevel = 3
+	cfg.Encoding.BatchCompression = true
+	assert.NoError(t, cfg.Validate(), "Must not error when compressing whole batches")
+
+	cfg.Aggregation = true
+	assert.Error(t, cfg.Validate(), "Must error when compressing whole batches with aggregation")
+
+	cfg.Aggregation = false
+	cfg.ExplicitHashKeySource = "tenant.shard"
+	assert.Error(t, cfg.Validate(), "Must error when compressing whole batches with explicit hash keys")
+
+	cfg.ExplicitHashKeySource = ""
+	cfg.Encoding.Compression = compress.None
+	cfg.Encoding.CompressionLevel = 0
+	assert.Error(t, cfg.Validate(), "Must error when compressing whole batches without a compression")
+
+	cfg.Encoding.Compression = compress.Zstd
+	cfg.Encoding.CompressionLevel = 3
+	cfg.RecordID = true
+	assert.Error(t, cfg.Validate(), "Must error when adding record ids with batch compression")
+
+	cfg.Encoding.BatchCompression = false
+	assert.NoError(t, cfg.Validate(), "Must not error when adding record ids")
+
+	cfg.Aggregation = true
+	assert.Error(t, cfg.Validate(), "Must error when adding record ids with aggregation")
+
+	cfg.Aggregation = false
+	cfg.RecordID = false
+	cfg.Envelope = "length_prefixed"
+	assert.Error(t, cfg.Validate(), "Must error with a length prefixed envelope without aggregation")
+
+	cfg.Aggregation = true
+	assert.NoError(t, cfg.Validate(), "Must not error with a length prefixed envelope")
+
+	cfg.Envelope = "not-an-envelope"
+	assert.Error(t, cfg.Validate(), "Must error with an unknown envelope")
+
+	cfg.Aggregation = false
+	cfg.Envelope = ""
+
+	cfg.OnPermanentError = "drop"
+	assert.NoError(t, cfg.Validate(), "Must not error when dropping permanently failed records")
+
+	cfg.OnPermanentError = "not-a-mode"
+	assert.Error(t, cfg.Validate(), "Must error with an unknown on_permanent_error")
+
+	cfg.OnPermanentError = ""
+	cfg.PartitionKey = "trace_id"
+	assert.NoError(t, cfg.Validate(), "Must not error with a known partition key")
+
+	cfg.PartitionKeySource = "service.name"
+	assert.Error(t, cfg.Validate(), "Must error when using a partition key source with trace_id")
+
+	cfg.PartitionKey = "content_hash"
+	assert.Error(t, cfg.Validate(), "Must error when using a partition key source with content_hash")
+
+	cfg.PartitionKeySource = ""
+	cfg.PartitionKeySources = []string{"service.name", "host.name"}
+	assert.Error(t, cfg.Validate(), "Must error when using partition key sources with content_hash")
+
+	cfg.PartitionKeySources = nil
+	assert.NoError(t, cfg.Validate(), "Must not error with the content hash partition key")
+
+	cfg.PartitionKey = "round_robin"
+	assert.NoError(t, cfg.Validate(), "Must not error with the round robin partition key")
+
+	cfg.RoundRobinKeys = 0
+	assert.Error(t, cfg.Validate(), "Must error without any round robin keys")
+
+	cfg.RoundRobinKeys = defaultRoundRobinKeys
+	cfg.RoundRobinRefreshInterval = time.Minute
+	assert.NoError(t, cfg.Validate(), "Must not error when refreshing the round robin keys")
+
+	cfg.RoundRobinRefreshInterval = -time.Minute
+	assert.Error(t, cfg.Validate(), "Must error with a negative round robin refresh interval")
+
+	cfg.RoundRobinRefreshInterval = time.Minute
+	cfg.PartitionKey = "trace_id"
+	assert.Error(t, cfg.Validate(), "Must error when refreshing the keys without the round robin partition key")
+
+	cfg.RoundRobinRefreshInterval = 0
+	cfg.PartitionKey = ""
+	cfg.ConsistentHashingRefreshInterval = time.Minute
+	assert.NoError(t, cfg.Validate(), "Must not error when consistently hashing the partition keys")
+
+	cfg.ConsistentHashingRefreshInterval = -time.Minute
+	assert.Error(t, cfg.Validate(), "Must error with a negative consistent hashing refresh interval")
+
+	cfg.ConsistentHashingRefreshInterval = time.Minute
+	cfg.ExplicitHashKeySource = "tenant.shard"
+	assert.Error(t, cfg.Validate(), "Must error when consistently hashing with an explicit hash key source")
+
+	cfg.ExplicitHashKeySource = ""
+	cfg.AWS.StreamNameTemplate = "otel-{tenant.id}"
+	assert.Error(t, cfg.Validate(), "Must error when consistently hashing routed streams")
+
+	cfg.AWS.StreamNameTemplate = ""
+	cfg.ConsistentHashingRefreshInterval = 0
+	cfg.PartitionKey = "not-a-partition-key"
+	cfg.PartitionKeySource = ""
+	assert.Error(t, cfg.Validate(), "Must error with an unknown partition key")
+
+	cfg.PartitionKey = ""
+	cfg.PartitionKeySalt = strings.Repeat("s", maxPartitionKeySaltLength+1)
+	assert.Error(t, cfg.Validate(), "Must error with a partition key salt that leaves no room for the key")
+
+	cfg.PartitionKeySalt = ""
+	cfg.MaxPartitionKeys = -1
+	assert.Error(t, cfg.Validate(), "Must error with negative max partition keys")
+
+	cfg.MaxPartitionKeys = 0
+	cfg.PartitionKey = partitionByContentHash
+	cfg.PartitionKeyAttribute = "kinesis.partition_key"
+	assert.Error(t, cfg.Validate(), "Must error when overriding the content hash partition key")
+
+	cfg.PartitionKey = ""
+	assert.NoError(t, cfg.Validate(), "Must not error when overriding the partition key")
+
+	cfg.PartitionKeyAttribute = ""
+	cfg.DropResourceAttributes = []string{"k8s.pod.uid"}
+	assert.NoError(t, cfg.Validate(), "Must not error when dropping resource attributes")
+
+	cfg.PartitionKeySource = "k8s.pod.uid"
+	assert.Error(t, cfg.Validate(), "Must error when dropping the partition key source")
+
+	cfg.PartitionKeySource = ""
+	cfg.PartitionKeySources = []string{"service.name", "k8s.pod.uid"}
+	assert.Error(t, cfg.Validate(), "Must error when dropping one of the partition key sources")
+
+	cfg.DropResourceAttributes = nil
+	assert.NoError(t, cfg.Validate(), "Must not error with partition key sources")
+
+	cfg.PartitionKeySources = []string{"service.name", ""}
+	assert.Error(t, cfg.Validate(), "Must error with an empty partition key source")
+
+	cfg.PartitionKeySources = []string{"host.name"}
+	cfg.PartitionKeySource = "service.name"
+	assert.Error(t, cfg.Validate(), "Must error when using both partition_key_source and partition_key_sources")
+
+	cfg.PartitionKeySource = ""
+	cfg.PartitionKeySources = nil
+	cfg.MirrorRequireAll = true
+	assert.Error(t, cfg.Validate(), "Must error when requiring mirrors without mirror regions")
+
+	cfg.MirrorRegions = []string{"eu-west-1"}
+	assert.NoError(t, cfg.Validate(), "Must not error with a mirror region")
+
+	cfg.MirrorRegions = []string{"eu-west-1", cfg.AWS.Region}
+	assert.Error(t, cfg.Validate(), "Must error when mirroring the primary region")
+
+	cfg.MirrorRegions = []string{"eu-west-1", "eu-west-1"}
+	assert.Error(t, cfg.Validate(), "Must error when mirroring a region more than once")
+
+	cfg.MirrorRegions = []string{""}
+	assert.Error(t, cfg.Validate(), "Must error with an empty mirror region")
+
+	cfg.MirrorRegions = []string{"eu-west-1"}
+	cfg.AWS.Endpoint = "http://localhost:4566"
+	assert.Error(t, cfg.Validate(), "Must error when mirroring with an endpoint")
+
+	cfg.AWS.Endpoint = ""
+	cfg.ChecksumRecords = true
+	assert.Error(t, cfg.Validate(), "Must error when mirroring checksum records")
+
+	cfg.ChecksumRecords = false
+	cfg.Encoding.BatchCompression = true
+	assert.Error(t, cfg.Validate(), "Must error when mirroring compressed batches")
+
+	cfg.Encoding.BatchCompression = false
+	cfg.MirrorRegions = nil
+	cfg.MirrorRequireAll = false
+	cfg.Encoding.Compression = compress.None
+	cfg.Encoding.CompressionLevel = 0
+	cfg.Target = "firehose"
+	assert.NoError(t, cfg.Validate(), "Must not error with a known target")
+
+	cfg.DeadLetter.StreamName = "dead-letter"
+	assert.Error(t, cfg.Validate(), "Must error when using a dead letter stream with firehose")
+
+	cfg.DeadLetter.StreamName = ""
+	cfg.Aggregation = true
+	assert.Error(t, cfg.Validate(), "Must error when using aggregation with firehose")
+
+	cfg.Aggregation = false
+	cfg.CreateStreamIfMissing = true
+	assert.Error(t, cfg.Validate(), "Must error when creating missing streams with firehose")
+
+	cfg.CreateStreamIfMissing = false
+	cfg.SingleRecordMode = true
+	assert.Error(t, cfg.Validate(), "Must error when using single record mode with firehose")
+
+	cfg.SingleRecordMode = false
+	cfg.AWS.StreamNameTemplate = "otel-{tenant.id}"
+	assert.Error(t, cfg.Validate(), "Must error when routing streams with firehose")
+
+	cfg.AWS.StreamNameTemplate = ""
+	cfg.Ordered = true
+	assert.Error(t, cfg.Validate(), "Must error when ordering chunks with firehose")
+
+	cfg.Ordered = false
+	cfg.Encoding.BatchCompression = true
+	assert.Error(t, cfg.Validate(), "Must error when compressing whole batches with firehose")
+
+	cfg.Encoding.BatchCompression = false
+	cfg.DryRun = true
+	assert.Error(t, cfg.Validate(), "Must error when using dry run with firehose")
+
+	cfg.DryRun = false
+	cfg.RoundRobinRefreshInterval = time.Minute
+	assert.Error(t, cfg.Validate(), "Must error when refreshing the shard count of firehose")
+
+	cfg.RoundRobinRefreshInterval = 0
+	cfg.ConsistentHashingRefreshInterval = time.Minute
+	assert.Error(t, cfg.Validate(), "Must error when consistently hashing the keys of firehose")
+
+	cfg.ConsistentHashingRefreshInterval = 0
+	cfg.ChecksumRecords = true
+	assert.Error(t, cfg.Validate(), "Must error when appending checksum records with firehose")
+
+	cfg.ChecksumRecords = false
+	cfg.RecordID = true
+	assert.Error(t, cfg.Validate(), "Must error when prepending record ids with firehose")
+
+	cfg.RecordID = false
+	cfg.Encoding.Compression = compress.Zstd
+	assert.Error(t, cfg.Validate(), "Must error when marking the compression within the partition key with firehose")
+
+	cfg.Encoding.CompressionMarker = "byte"
+	assert.NoError(t, cfg.Validate(), "Must not error when marking the compression within the data with firehose")
+
+	cfg.Encoding.CompressionMarker = ""
+	cfg.Encoding.CompressionLevel = 3
+	cfg.Target = "not-a-target"
+	assert.Error(t, cfg.Validate(), "Must error with an unknown target")
+
+	cfg.Target = ""
+	cfg.AWS.StreamNameTemplate = "otel-{tenant.id"
+	assert.Error(t, cfg.Validate(), "Must error with an unclosed stream name template")
+
+	cfg.AWS.StreamNameTemplate = "otel-{tenant.id}"
+	assert.NoError(t, cfg.Validate(), "Must not error with a valid stream name template")
+
+	cfg.AWS.StreamNameTemplate = ""
+	cfg.AWS.Endpoint = "localhost:4566"
+	assert.NoError(t, cfg.Validate(), "Must not error with an endpoint")
+
+	cfg.AWS.KinesisEndpoint = "localhost:4566"
+	assert.Error(t, cfg.Validate(), "Must error when using both endpoint and kinesis_endpoint")
+
+	cfg.AWS.KinesisEndpoint = ""
+	cfg.AWS.RoleARN = "arn:test-role"
+	assert.NoError(t, cfg.Validate(), "Must not error with a role arn")
+
+	cfg.AWS.Role = "arn:test-role"
+	assert.Error(t, cfg.Validate(), "Must error when using both role_arn and role")
+
+	cfg.AWS.Role = ""
+	cfg.AWS.StreamName = "test-stream"
+	cfg.AWS.StreamARN = "arn:aws:kinesis:us-west-2:123456789012:stream/test-stream"
+	assert.Error(t, cfg.Validate(), "Must error when using both stream_name and stream_arn")
+
+	cfg.AWS.StreamName = ""
+	assert.NoError(t, cfg.Validate(), "Must not error with a stream arn")
+	assert.Equal(t, cfg.AWS.StreamARN, cfg.streamName(config.TracesDataType), "Must write to the stream arn")
+
+	cfg.CreateStreamIfMissing = true
+	assert.Error(t, cfg.Validate(), "Must error when creating a missing stream by arn")
+
+	cfg.CreateStreamIfMissing = false
+	cfg.MirrorRegions = []string{"eu-west-1"}
+	assert.Error(t, cfg.Validate(), "Must error when mirroring a stream arn")
+
+	cfg.MirrorRegions = nil
+	cfg.Target = "firehose"
+	assert.Error(t, cfg.Validate(), "Must error when using a stream arn with firehose")
+
+	cfg.Target = ""
+	cfg.AWS.StreamARN = "test-stream"
+	assert.Error(t, cfg.Validate(), "Must error when the stream arn is not an arn")
+
+	cfg.AWS.StreamARN = "arn:aws:firehose:us-west-2:123456789012:deliverystream/test-stream"
+	assert.Error(t, cfg.Validate(), "Must error when the arn is not of a kinesis data stream")
+
+	cfg.AWS.StreamARN = ""
+	cfg.AWS.AccessKey = "test-access-key"
+	assert.Error(t, cfg.Validate(), "Must error when using an access_key without a secret_key")
+
+	cfg.AWS.SecretKey = "test-secret-key"
+	assert.NoError(t, cfg.Validate(), "Must not error with static credentials")
+
+	cfg.AWS.AccessKey, cfg.AWS.SecretKey = "", ""
+	cfg.AWS.SessionToken = "test-session-token"
+	assert.Error(t, cfg.Validate(), "Must error when using a session_token without static credentials")
+
+	cfg.AWS.SessionToken = ""
+	cfg.MaxConcurrentRequests = 0
+	assert.Error(t, cfg.Validate(), "Must error without any concurrent requests")
+
+	cfg.MaxConcurrentRequests = 1
+	cfg.FlushInterval = -time.Second
+	assert.Error(t, cfg.Validate(), "Must error with a negative flush interval")
+
+	cfg.FlushInterval = 0
+	cfg.FlushRecordCount = -1
+	assert.Error(t, cfg.Validate(), "Must error with a negative flush record count")
+
+	cfg.FlushRecordCount = 100
+	assert.Error(t, cfg.Validate(), "Must error with a flush record count without a flush interval")
+
+	cfg.FlushRecordCount = 0
+	cfg.RequestTimeout = -time.Second
+	assert.Error(t, cfg.Validate(), "Must error with a negative request timeout")
+
+	cfg.RequestTimeout = 0
+	cfg.RateLimit.BytesPerSecond = -1
+	assert.Error(t, cfg.Validate(), "Must error with a negative rate limit")
+
+	cfg.RateLimit.BytesPerSecond = 0
+	cfg.RetryBudget.MaxRetries = -1
+	assert.Error(t, cfg.Validate(), "Must error with a negative retry budget")
+
+	cfg.RetryBudget.MaxRetries = 0
+	cfg.AWS.SDKMaxRetries = 0
+	assert.NoError(t, cfg.Validate(), "Must not error when disabling the SDK retries")
+
+	cfg.AWS.SDKMaxRetries = -2
+	assert.Error(t, cfg.Validate(), "Must error with negative SDK max retries")
+
+	cfg.AWS.SDKMaxRetries = defaultSDKMaxRetries
+	cfg.LogFailures.SampleRate = 0
+	assert.Error(t, cfg.Validate(), "Must error when sampling failure logs without a sample rate")
+
+	cfg.LogFailures.Enabled = false
+	assert.NoError(t, cfg.Validate(), "Must not error when failure logging is disabled")
+
+	cfg.LogFailures = LogFailuresSettings{Enabled: true, SampleRate: defaultLogFailuresSampleRate}
+	cfg.StartupJitter = -time.Second
+	assert.Error(t, cfg.Validate(), "Must error with a negative startup jitter")
+
+	cfg.StartupJitter = 0
+	cfg.ProducerID.ID = "collector-1"
+	assert.Error(t, cfg.Validate(), "Must error with a producer id that is not enabled")
+
+	cfg.ProducerID.Enabled = true
+	assert.NoError(t, cfg.Validate(), "Must not error with an enabled producer id")
+
+	cfg.ProducerID = ProducerIDSettings{}
+	cfg.ShutdownTimeout = -time.Second
+	assert.Error(t, cfg.Validate(), "Must error with a negative shutdown timeout")
+
+	cfg.ShutdownTimeout = 0
+	assert.NoError(t, cfg.Validate(), "Must not error when the shutdown is not bounded")
+
+	cfg.ShutdownTimeout = defaultShutdownTimeout
+	cfg.MaxBufferedRecords = -1
+	assert.Error(t, cfg.Validate(), "Must error with negative max buffered records")
+
+	cfg.MaxBufferedRecords = 0
+	cfg.MaxInflightBytes = -1
+	assert.Error(t, cfg.Validate(), "Must error with negative max in-flight bytes")
+
+	cfg.MaxInflightBytes = 0
+	cfg.HTTP.Timeout = -time.Second
+	assert.Error(t, cfg.Validate(), "Must error with a negative http timeout")
+
+	cfg.HTTP.Timeout = 0
+	cfg.HTTP.ProxyURL = "proxy.internal:3128"
+	assert.Error(t, cfg.Validate(), "Must error with a proxy url without a scheme")
+
+	cfg.HTTP.ProxyURL = "http://proxy.internal:3128"
+	assert.NoError(t, cfg.Validate(), "Must not error with a valid proxy url")
+
+	cfg.HTTP.ProxyURL = ""
+	cfg.EMF = EMFSettings{Enabled: true, Namespace: "test-namespace", Interval: time.Minute}
+	assert.Error(t, cfg.Validate(), "Must error when emf is enabled without a log group")
+
+	cfg.EMF.LogGroup = "test-log-group"
+	cfg.EMF.Interval = 0
+	assert.Error(t, cfg.Validate(), "Must error when emf is enabled without an interval")
+
+	cfg.EMF = EMFSettings{}
+	cfg.SamplingRatio = 1.5
+	assert.Error(t, cfg.Validate(), "Must error with a sampling ratio above 1")
+
+	cfg.SamplingRatio = 0
+	assert.NoError(t, cfg.Validate(), "Must not error when dropping every record")
+
+	cfg.SamplingRatio = 1
+	cfg.SingleRecordMode = true
+	assert.NoError(t, cfg.Validate(), "Must not error in single record mode")
+
+	cfg.Aggregation = true
+	assert.Error(t, cfg.Validate(), "Must error when using aggregation in single record mode")
+
+	cfg.Aggregation = false
+	cfg.SequenceOrdering = true
+	assert.NoError(t, cfg.Validate(), "Must not error when ordering records in single record mode")
+
+	cfg.SingleRecordMode = false
+	assert.Error(t, cfg.Validate(), "Must error when ordering records without single record mode")
+
+	cfg.SequenceOrdering = false
+	cfg.CreateStreamIfMissing = true
+	assert.NoError(t, cfg.Validate(), "Must not error when creating missing streams")
+
+	cfg.DryRun = true
+	assert.Error(t, cfg.Validate(), "Must error when creating missing streams in dry run mode")
+
+	cfg.DryRun = false
+	cfg.LazyInit = true
+	assert.Error(t, cfg.Validate(), "Must error when creating missing streams with lazy init")
+
+	cfg.LazyInit = false
+	cfg.ShardCount = 0
+	assert.Error(t, cfg.Validate(), "Must error when creating missing streams without any shards")
+
+	cfg.CreateStreamIfMissing = false
+	cfg.MaxRecordSize = 256 << 10
+	assert.NoError(t, cfg.Validate(), "Must not error with a max record size below the kinesis limit")
+
+	cfg.MaxRecordSize = batch.MaxRecordSize + 1
+	assert.Error(t, cfg.Validate(), "Must error with a max record size above the kinesis limit")
+
+	cfg.MaxRecordSize = 0
+	assert.Error(t, cfg.Validate(), "Must error without a max record size")
+
+	cfg.MaxRecordSize = batch.MaxRecordSize
+	cfg.MaxRecordsPerBatch = 0
+	assert.Error(t, cfg.Validate(), "Must error without any records per batch")
+
+	cfg.MaxRecordsPerBatch = batch.MaxBatchedRecords
+	cfg.AdaptiveBatching = true
+	assert.NoError(t, cfg.Validate(), "Must not error with adaptive batching")
+
+	cfg.AdaptiveMinRecords = 0
+	assert.Error(t, cfg.Validate(), "Must error without an adaptive minimum")
+
+	cfg.AdaptiveMinRecords = batch.MaxBatchedRecords + 1
+	assert.Error(t, cfg.Validate(), "Must error with an adaptive minimum above the records per batch")
+
+	cfg.AdaptiveMinRecords = defaultAdaptiveMinRecords
+	cfg.Target = targetFirehose
+	assert.Error(t, cfg.Validate(), "Must error with adaptive batching to firehose")
+}
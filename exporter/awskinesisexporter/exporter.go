@@ -16,17 +16,39 @@ package awskinesisexporter
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	"github.com/aws/aws-sdk-go/service/glue"
 	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/google/uuid"
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/config"
 	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/model/pdata"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
 	"go.uber.org/zap"
+	"golang.org/x/net/http/httpproxy"
 
 	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/producer"
@@ -36,6 +58,18 @@ import (
 type Exporter struct {
 	producer producer.Batcher
 	batcher  batch.Encoder
+	// ensureStream is called on start when missing streams are created
+	ensureStream func(ctx context.Context) error
+	// checkStream checks that the stream can be written to on start
+	checkStream bool
+	// warmup checks the stream on start when it is not checked,
+	// only logging the failures, to set up the connection early.
+	warmup bool
+	log    *zap.Logger
+	// startupJitter is the longest random delay before the exporter starts
+	startupJitter time.Duration
+	// shutdownTimeout bounds Shutdown when set
+	shutdownTimeout time.Duration
 }
 
 var (
@@ -44,32 +78,563 @@ var (
 	_ component.LogsExporter    = (*Exporter)(nil)
 )
 
-func createExporter(c config.Exporter, log *zap.Logger) (*Exporter, error) {
+func createExporter(c config.Exporter, params component.ExporterCreateSettings, signal config.DataType) (*Exporter, error) {
 	conf, ok := c.(*Config)
 	if !ok || conf == nil {
 		return nil, errors.New("incorrect config provided")
 	}
-	sess, err := session.NewSession(aws.NewConfig().WithRegion(conf.AWS.Region))
-	if err != nil {
-		return nil, err
+	stream := conf.streamName(signal)
+	if stream == "" {
+		return nil, fmt.Errorf("no stream configured for %s", signal)
+	}
+	log := params.Logger
+	if conf.AWS.KinesisEndpoint != "" {
+		log.Warn("aws.kinesis_endpoint is deprecated, use aws.endpoint instead")
 	}
-
-	var cfgs []*aws.Config
 	if conf.AWS.Role != "" {
-		cfgs = append(cfgs, &aws.Config{Credentials: stscreds.NewCredentials(sess, conf.AWS.Role)})
+		log.Warn("aws.role is deprecated, use aws.role_arn instead")
 	}
-	if conf.AWS.KinesisEndpoint != "" {
-		cfgs = append(cfgs, &aws.Config{Endpoint: aws.String(conf.AWS.KinesisEndpoint)})
+	if conf.AWS.UseFIPSEndpoint && (conf.AWS.Endpoint != "" || conf.AWS.KinesisEndpoint != "") {
+		log.Warn("aws.use_fips_endpoint is ignored since the endpoint is overridden")
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.String("exporter", conf.ID().String()),
+		attribute.String("stream", stream),
+	}
+	recordAttributes := conf.RecordAttributes.Attributes
+	if conf.ProducerID.Enabled {
+		id, err := producerID(conf.ProducerID.ID)
+		if err != nil {
+			return nil, err
+		}
+		log = log.With(zap.String("producer_id", id))
+		attrs = append(attrs, attribute.String("producer_id", id))
+		recordAttributes = withProducerID(recordAttributes, id)
 	}
 
-	producer, err := producer.NewBatcher(kinesis.New(sess, cfgs...), conf.AWS.StreamName,
+	// The session is created along with the batcher on the first write with lazy_init,
+	// which can not be used with the options that need the session on start.
+	var (
+		sess *session.Session
+		cfgs []*aws.Config
+		err  error
+	)
+	if !conf.LazyInit {
+		if sess, cfgs, err = newSession(conf); err != nil {
+			return nil, err
+		}
+	}
+
+	opts := []producer.BatcherOptions{
 		producer.WithLogger(log),
+		producer.WithMeterProvider(params.MeterProvider, attrs...),
+		producer.WithTracerProvider(params.TracerProvider),
+		producer.WithMaxConcurrency(conf.MaxConcurrentRequests),
+		producer.WithRequestTimeout(conf.RequestTimeout),
+		producer.WithRateLimit(conf.RateLimit.RecordsPerSecond, conf.RateLimit.BytesPerSecond),
+		producer.WithMaxBufferedRecords(conf.MaxBufferedRecords),
+		producer.WithMaxInflightBytes(conf.MaxInflightBytes),
+		producer.WithBackoff(producer.BackoffSettings{
+			InitialInterval: conf.ThrottleRetry.InitialInterval,
+			MaxInterval:     conf.ThrottleRetry.MaxInterval,
+			MaxElapsedTime:  conf.ThrottleRetry.MaxElapsedTime,
+			Multiplier:      conf.ThrottleRetry.Multiplier,
+		}),
+	}
+	if conf.DeadLetter.StreamName != "" {
+		opts = append(opts, producer.WithDeadLetter(nil, conf.DeadLetter.StreamName))
+	}
+	if conf.SingleRecordMode {
+		opts = append(opts, producer.WithSingleRecordMode())
+	}
+	if conf.OnPermanentError == permanentErrorDrop {
+		opts = append(opts, producer.WithDropPermanentErrors())
+	}
+	if conf.SequenceOrdering {
+		opts = append(opts, producer.WithSequenceOrdering())
+	}
+	if conf.Ordered {
+		opts = append(opts, producer.WithOrdering())
+	}
+	if conf.DryRun {
+		opts = append(opts, producer.WithDryRun())
+	}
+	if conf.RetryBudget.Window > 0 {
+		opts = append(opts, producer.WithRetryBudget(conf.RetryBudget.MaxRetries, conf.RetryBudget.Window))
+	}
+	opts = append(opts, producer.WithFailureLogging(conf.LogFailures.Enabled, conf.LogFailures.SampleRate))
+	if len(conf.RetryableErrorCodes) > 0 {
+		opts = append(opts, producer.WithRetryableErrorCodes(conf.RetryableErrorCodes...))
+	}
+	if conf.AdaptiveBatching {
+		opts = append(opts, producer.WithAdaptiveBatching(conf.AdaptiveMinRecords, min(conf.MaxRecordsPerBatch, batch.MaxBatchedRecords)))
+	}
+	// The mirrors are written like the primary stream, only
+	// the primary emits the EMF health and resizes the keys.
+	mirrorOpts := opts[:len(opts):len(opts)]
+	partitioner := newPartitioner(conf, log)
+	if rp, ok := partitioner.(batch.ResizablePartitioner); ok && conf.RoundRobinRefreshInterval > 0 {
+		opts = append(opts, producer.WithShardCountRefresh(conf.RoundRobinRefreshInterval, rp.Resize))
+	}
+	var hasher *batch.ShardHasher
+	if conf.ConsistentHashingRefreshInterval > 0 {
+		hasher = batch.NewShardHasher()
+		opts = append(opts, producer.WithShardRangeRefresh(conf.ConsistentHashingRefreshInterval, hasher.SetRanges))
+	}
+	if conf.PartitionKeyAttribute != "" {
+		partitioner = batch.NewOverridePartitioner(partitioner, conf.PartitionKeyAttribute, log)
+	}
+
+	batchOpts := []batch.Option{
+		batch.WithMaxRecordSize(conf.MaxRecordSize),
+		batch.WithMaxRecordsPerBatch(conf.MaxRecordsPerBatch),
+	}
+
+	if conf.MaxRecordsPerBatch > batch.MaxBatchedRecords {
+		log.Warn("max_records_per_batch exceeds the kinesis limit and will be clamped",
+			zap.Int("max_records_per_batch", conf.MaxRecordsPerBatch),
+			zap.Int("limit", batch.MaxBatchedRecords),
+		)
+	}
+	if conf.ExplicitHashKeySource != "" {
+		batchOpts = append(batchOpts, batch.WithExplicitHashKeySource(conf.ExplicitHashKeySource))
+	}
+	if hasher != nil {
+		batchOpts = append(batchOpts, batch.WithShardHasher(hasher))
+	}
+	if conf.ValidatePayloads {
+		batchOpts = append(batchOpts, batch.WithPayloadValidation(log))
+	}
+	if conf.PartitionKeySalt != "" {
+		batchOpts = append(batchOpts, batch.WithPartitionKeySalt(conf.PartitionKeySalt))
+	}
+	if conf.Aggregation {
+		batchOpts = append(batchOpts, batch.WithAggregation())
+	}
+	if conf.Envelope == envelopeLengthPrefixed {
+		batchOpts = append(batchOpts, batch.WithLengthPrefixedAggregation())
+	}
+
+	if conf.Target == targetFirehose {
+		if conf.PartitionKey != "" || conf.PartitionKeySource != "" || len(conf.PartitionKeySources) > 0 || conf.PartitionKeyAttribute != "" || conf.ExplicitHashKeySource != "" || conf.PartitionKeySalt != "" {
+			log.Warn("Partition keys are not used by firehose and will be ignored")
+		}
+		batchOpts = append(batchOpts,
+			batch.WithMaxRecordSize(min(conf.MaxRecordSize, batch.MaxFirehoseRecordSize)),
+			batch.WithMaxBatchBytes(batch.MaxFirehoseBatchSize),
+		)
+	}
+	newProducer := func(sess *session.Session, cfgs []*aws.Config) (producer.Batcher, error) {
+		opts := opts[:len(opts):len(opts)]
+		if conf.EMF.Enabled {
+			opts = append(opts, producer.WithEMF(
+				cloudwatchlogs.New(sess, credentialConfigs(sess, conf)...),
+				producer.EMFSettings{
+					Namespace: conf.EMF.Namespace,
+					LogGroup:  conf.EMF.LogGroup,
+					LogStream: emfLogStream(conf, stream),
+					Interval:  conf.EMF.Interval,
+				},
+			))
+		}
+		var (
+			p   producer.Batcher
+			err error
+		)
+		if conf.Target == targetFirehose {
+			p, err = producer.NewFirehoseBatcher(firehose.New(sess, cfgs...), stream, opts...)
+		} else {
+			p, err = producer.NewBatcher(kinesis.New(sess, cfgs...), stream, opts...)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(conf.MirrorRegions) > 0 {
+			mirrors := make([]producer.Mirror, 0, len(conf.MirrorRegions))
+			for _, region := range conf.MirrorRegions {
+				m, err := newMirror(conf, params.MeterProvider, log, attrs, stream, region, mirrorOpts)
+				if err != nil {
+					return nil, err
+				}
+				mirrors = append(mirrors, producer.Mirror{Name: region, Batcher: m})
+			}
+			p = producer.NewMirroredBatcher(p, mirrors, conf.MirrorRequireAll, log)
+		}
+		return p, nil
+	}
+
+	var p producer.Batcher
+	if conf.LazyInit {
+		p = producer.NewLazyBatcher(func() (producer.Batcher, error) {
+			sess, cfgs, err := newSession(conf)
+			if err != nil {
+				return nil, err
+			}
+			return newProducer(sess, cfgs)
+		})
+	} else if p, err = newProducer(sess, cfgs); err != nil {
+		return nil, err
+	}
+	var ensureStream func(context.Context) error
+	if conf.CreateStreamIfMissing {
+		client := kinesis.New(sess, cfgs...)
+		ensureStream = func(ctx context.Context) error {
+			return producer.EnsureStream(ctx, client, stream, conf.ShardCount, streamPollInterval, log)
+		}
+	}
+
+	if conf.FlushInterval > 0 {
+		p = producer.NewBufferedBatcher(p, conf.FlushInterval, flushRecordCount(conf, log), params.MeterProvider, attrs, log)
+	}
+
+	compressor, err := conf.Encoding.compressor()
+	if err != nil {
+		return nil, err
+	}
+
+	batchOpts = append(batchOpts,
+		batch.WithCompression(compressor),
+		batch.WithCompressionMinSize(conf.Encoding.CompressionMinSize),
+		batch.WithCompressionMinRatio(conf.Encoding.CompressionMinRatio),
+		batch.WithPartitioner(partitioner),
 	)
+	if conf.Encoding.CompressionMarker == markerByte {
+		batchOpts = append(batchOpts, batch.WithCompressionMarkerByte())
+	}
+	if conf.Encoding.BatchCompression {
+		batchOpts = append(batchOpts, batch.WithBatchCompression())
+	}
+	if conf.MaxPartitionKeys > 0 {
+		batchOpts = append(batchOpts, batch.WithKeyLimiter(batch.NewKeyLimiter(conf.MaxPartitionKeys)))
+	}
+	if conf.SamplingRatio < 1 {
+		batchOpts = append(batchOpts, batch.WithSampler(batch.NewSampler(conf.SamplingRatio)))
+	}
+	if conf.ChecksumRecords {
+		batchOpts = append(batchOpts, batch.WithChecksums(batch.NewChecksums()))
+	}
+	if conf.RecordID {
+		batchOpts = append(batchOpts, batch.WithRecordID())
+	}
+	var encoder batch.Encoder
+	name := conf.Encoding.name(signal)
+	if gr := conf.Encoding.GlueRegistry; gr.Name != "" && name == encodingAvro {
+		client := glue.New(sess, credentialConfigs(sess, conf)...)
+		encoder = batch.NewAvro(batch.NewGlueRegistry(client, gr.Name, gr.Schema), batchOpts...)
+	} else if encoder, err = batch.NewEncoder(name, batchOpts...); err != nil {
+		return nil, err
+	}
+	if conf.Encoding.Passthrough {
+		encoder = batch.NewPassthrough(encoder,
+			append(batchOpts, batch.WithRawBodyHeader(recordAttributes))...,
+		)
+	}
+	if len(conf.DropResourceAttributes) > 0 {
+		// The attributes are dropped once the streams are resolved so they can still be used to route the records
+		encoder = batch.NewDropAttributes(encoder, conf.DropResourceAttributes)
+	}
+	if conf.AWS.StreamNameTemplate != "" {
+		template, err := batch.NewStreamTemplate(conf.AWS.StreamNameTemplate)
+		if err != nil {
+			return nil, err
+		}
+		encoder = batch.NewStreamRouter(encoder, template)
+	}
+	if len(recordAttributes) > 0 {
+		encoder = batch.NewRecordAttributes(encoder, recordAttributes, conf.RecordAttributes.Overwrite)
+	}
+	if conf.BatchSequence {
+		encoder = batch.NewBatchSequence(encoder, uuid.NewString())
+	}
 
 	return &Exporter{
-		producer: producer,
-		batcher:  batch.NewJaeger(conf.MaxRecordsPerBatch, conf.MaxRecordSize),
-	}, err
+		producer:        p,
+		batcher:         encoder,
+		ensureStream:    ensureStream,
+		checkStream:     !conf.SkipStreamCheck,
+		warmup:          conf.Warmup,
+		log:             log,
+		startupJitter:   conf.StartupJitter,
+		shutdownTimeout: conf.ShutdownTimeout,
+	}, nil
+}
+
+// producerID returns the id of the collector, which is the hostname unless set.
+func producerID(id string) (string, error) {
+	if id != "" {
+		return id, nil
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		return "", fmt.Errorf("failed to use the hostname as the producer_id: %w", err)
+	}
+	return host, nil
+}
+
+// withProducerID returns a copy of the record attributes with the producer id added.
+func withProducerID(attributes map[string]string, id string) map[string]string {
+	merged := make(map[string]string, len(attributes)+1)
+	for k, v := range attributes {
+		merged[k] = v
+	}
+	merged[producerIDAttribute] = id
+	return merged
+}
+
+// newMirror returns the Batcher that writes to the stream within the mirror region,
+// its metrics and logs are told apart from the primary region by the region.
+func newMirror(conf *Config, mp metric.MeterProvider, log *zap.Logger, attrs []attribute.KeyValue, stream, region string, opts []producer.BatcherOptions) (producer.Batcher, error) {
+	mirror := *conf
+	mirror.AWS.Region = region
+	sess, cfgs, err := newSession(&mirror)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts,
+		producer.WithLogger(log.With(zap.String("region", region))),
+		producer.WithMeterProvider(mp, append(attrs[:len(attrs):len(attrs)], attribute.String("region", region))...),
+	)
+	if conf.Target == targetFirehose {
+		return producer.NewFirehoseBatcher(firehose.New(sess, cfgs...), stream, opts...)
+	}
+	return producer.NewBatcher(kinesis.New(sess, cfgs...), stream, opts...)
+}
+
+// newSession returns the session and the client configs
+// used to build the client of the configured target.
+func newSession(conf *Config) (*session.Session, []*aws.Config, error) {
+	base := aws.NewConfig().WithRegion(conf.AWS.Region).WithMaxRetries(conf.AWS.SDKMaxRetries)
+	if conf.AWS.AccessKey != "" {
+		// Static credentials are also used to assume the role when one is configured
+		base = base.WithCredentials(credentials.NewStaticCredentials(
+			conf.AWS.AccessKey, string(conf.AWS.SecretKey), string(conf.AWS.SessionToken),
+		))
+	}
+	client, err := newHTTPClient(conf.HTTP)
+	if err != nil {
+		return nil, nil, err
+	}
+	var tlsConfig *tls.Config
+	if client != nil {
+		base = base.WithHTTPClient(client)
+		tlsConfig = client.Transport.(*http.Transport).TLSClientConfig
+	}
+	var roots *x509.CertPool
+	if tlsConfig != nil {
+		roots = tlsConfig.RootCAs
+	}
+	sess, err := session.NewSession(base)
+	if err != nil {
+		return nil, nil, err
+	}
+	if roots != nil {
+		// The SDK replaces the roots of the client with the AWS_CA_BUNDLE
+		// when it is set, the ca_file of the exporter takes precedence.
+		tlsConfig.RootCAs = roots
+	}
+	if conf.AWS.UserAgentSuffix != "" {
+		// The handlers of the session are copied by every client created from it
+		sess.Handlers.Build.PushBack(request.MakeAddToUserAgentFreeFormHandler(conf.AWS.UserAgentSuffix))
+	}
+	if conf.AWS.PropagateTraceContext {
+		sess.Handlers.Build.PushBackNamed(traceContextHandler)
+	}
+
+	cfgs := credentialConfigs(sess, conf)
+	endpoint := conf.AWS.Endpoint
+	if endpoint == "" {
+		endpoint = conf.AWS.KinesisEndpoint
+	}
+	if endpoint == "" && conf.AWS.UseFIPSEndpoint {
+		if endpoint, err = fipsEndpoint(conf.endpointsID(), conf.AWS.Region); err != nil {
+			return nil, nil, err
+		}
+	}
+	if endpoint != "" {
+		cfgs = append(cfgs, &aws.Config{Endpoint: aws.String(endpoint)})
+	}
+	if conf.AWS.DisableSSL {
+		cfgs = append(cfgs, &aws.Config{DisableSSL: aws.Bool(true)})
+	}
+	return sess, cfgs, nil
+}
+
+// traceContextHandler injects the W3C trace context of the span
+// within the context of each request into its headers.
+var traceContextHandler = request.NamedHandler{
+	Name: "awskinesisexporter.TraceContextHandler",
+	Fn: func(r *request.Request) {
+		propagation.TraceContext{}.Inject(r.Context(), propagation.HeaderCarrier(r.HTTPRequest.Header))
+	},
+}
+
+// credentialConfigs returns the configs that assume the configured role,
+// which are used without the endpoint overrides by the clients of other services.
+func credentialConfigs(sess *session.Session, conf *Config) []*aws.Config {
+	var cfgs []*aws.Config
+	if role := newAssumeRoleProvider(sess, conf); role != nil {
+		cfgs = append(cfgs, &aws.Config{Credentials: credentials.NewCredentials(role)})
+	}
+	return cfgs
+}
+
+// emfLogStream returns the log stream that the health metrics of the exporter
+// are written to, the host is included so that collectors do not share a log stream.
+func emfLogStream(conf *Config, stream string) string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	// Log stream names can not contain the colons of a stream_arn
+	if parsed, err := arn.Parse(stream); err == nil {
+		stream = strings.TrimPrefix(parsed.Resource, "stream/")
+	}
+	return fmt.Sprintf("%s/%s/%s", conf.ID(), stream, host)
+}
+
+// newProxy returns the proxy of the transport that sends every request through the
+// proxy url, other than the requests to the hosts excluded by NO_PROXY.
+func newProxy(proxyURL string) func(*http.Request) (*url.URL, error) {
+	cfg := httpproxy.FromEnvironment()
+	cfg.HTTPProxy, cfg.HTTPSProxy = proxyURL, proxyURL
+	proxy := cfg.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}
+}
+
+// newHTTPClient returns the http client built from the settings,
+// nil is returned to use the default client of the AWS SDK when unset.
+func newHTTPClient(settings HTTPSettings) (*http.Client, error) {
+	if settings == (HTTPSettings{}) {
+		return nil, nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if settings.MaxIdleConns > 0 {
+		// Every request is sent to the same endpoint so the limits are the same
+		transport.MaxIdleConns = settings.MaxIdleConns
+		transport.MaxIdleConnsPerHost = settings.MaxIdleConns
+	}
+	if settings.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = settings.IdleConnTimeout
+	}
+	if settings.ProxyURL != "" {
+		transport.Proxy = newProxy(settings.ProxyURL)
+	}
+	if settings.CAFile != "" {
+		roots, err := newRootCAs(settings.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			RootCAs:    roots,
+		}
+	}
+	return &http.Client{
+		Timeout:   settings.Timeout,
+		Transport: transport,
+	}, nil
+}
+
+// newRootCAs returns the system roots along with the certificates of the PEM bundle,
+// only the certificates of the bundle are used when the system roots are unavailable.
+func newRootCAs(caFile string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read http ca_file: %w", err)
+	}
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in http ca_file %q", caFile)
+	}
+	return roots, nil
+}
+
+// fipsEndpoint returns the FIPS endpoint of the service within the region.
+// The endpoints of GovCloud regions are FIPS validated so are used as is,
+// an error is returned if the service does not offer one in the region.
+func fipsEndpoint(service, region string) (string, error) {
+	resolver := endpoints.DefaultResolver()
+	if e, err := resolver.EndpointFor(service, "fips-"+region, endpoints.StrictMatchingOption); err == nil {
+		return e.URL, nil
+	}
+	if p, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), region); ok && p.ID() == endpoints.AwsUsGovPartitionID {
+		if e, err := resolver.EndpointFor(service, region, endpoints.StrictMatchingOption); err == nil {
+			return e.URL, nil
+		}
+	}
+	return "", fmt.Errorf("%s does not offer a FIPS endpoint in region %q", service, region)
+}
+
+// newAssumeRoleProvider returns the provider used to assume the configured role
+// using the region of the session, nil is returned if no role is configured.
+func newAssumeRoleProvider(sess *session.Session, conf *Config) *stscreds.AssumeRoleProvider {
+	arn := conf.AWS.RoleARN
+	if arn == "" {
+		arn = conf.AWS.Role
+	}
+	if arn == "" {
+		return nil
+	}
+	role := &stscreds.AssumeRoleProvider{
+		// The regional endpoint is used so that sts is called within the configured region
+		Client:          sts.New(sess, &aws.Config{STSRegionalEndpoint: endpoints.RegionalSTSEndpoint}),
+		RoleARN:         arn,
+		RoleSessionName: conf.AWS.RoleSessionName,
+		Duration:        stscreds.DefaultDuration,
+	}
+	if role.RoleSessionName == "" {
+		role.RoleSessionName = defaultRoleSessionName
+	}
+	if conf.AWS.ExternalID != "" {
+		role.ExternalID = aws.String(conf.AWS.ExternalID)
+	}
+	return role
+}
+
+func newPartitioner(conf *Config, log *zap.Logger) batch.Partitioner {
+	switch {
+	case conf.PartitionKey == partitionByTraceID:
+		return batch.NewTraceIDPartitioner()
+	case conf.PartitionKey == partitionByContentHash:
+		return batch.NewContentPartitioner()
+	case conf.PartitionKey == partitionByRoundRobin:
+		return batch.NewRoundRobinPartitioner(conf.RoundRobinKeys)
+	case conf.PartitionKeySource != "":
+		return batch.NewAttributePartitioner(conf.PartitionKeySource, log)
+	case len(conf.PartitionKeySources) > 0:
+		return batch.NewAttributesPartitioner(conf.PartitionKeySources, log)
+	}
+	return batch.NewRandomPartitioner()
+}
+
+// flushRecordCount returns the pending records that are written by flush_interval,
+// which is a full batch unless flush_record_count is lower.
+func flushRecordCount(conf *Config, log *zap.Logger) int {
+	limit := min(conf.MaxRecordsPerBatch, batch.MaxBatchedRecords)
+	if conf.FlushRecordCount == 0 {
+		return limit
+	}
+	if conf.FlushRecordCount > limit {
+		log.Warn("flush_record_count exceeds the records per batch and will be clamped",
+			zap.Int("flush_record_count", conf.FlushRecordCount),
+			zap.Int("limit", limit),
+		)
+		return limit
+	}
+	return conf.FlushRecordCount
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
 }
 
 // Start tells the exporter to start. The exporter may prepare for exporting
@@ -80,14 +645,64 @@ func (e Exporter) Start(ctx context.Context, _ component.Host) error {
 	return e.producer.Ready(ctx)
 }
 
+// start waits for a random startup delay within the jitter, then creates the
+// stream if it is missing and configured to do so, then checks that it can be
+// written to unless the check is skipped. A skipped check is still made to
+// warm up the connection when configured, without failing the start.
+func (e Exporter) start(ctx context.Context, host component.Host) error {
+	if err := e.wait(ctx); err != nil {
+		return err
+	}
+	if e.ensureStream != nil {
+		if err := e.ensureStream(ctx); err != nil {
+			return err
+		}
+	}
+	if e.checkStream {
+		return e.Start(ctx, host)
+	}
+	if e.warmup {
+		if err := e.Start(ctx, host); err != nil {
+			e.log.Warn("Failed to warm up the connection to the stream", zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// wait sleeps for a random duration within the startup jitter so that
+// collectors that are started together do not write in lockstep.
+func (e Exporter) wait(ctx context.Context) error {
+	if e.startupJitter <= 0 {
+		return nil
+	}
+	// The global source is not seeded, which would delay every collector the same
+	jitter := rand.New(rand.NewSource(time.Now().UnixNano())).Int63n(int64(e.startupJitter))
+	timer := time.NewTimer(time.Duration(jitter))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("startup jitter interrupted: %w", ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
+
 // Capabilities implements the consumer interface.
 func (e Exporter) Capabilities() consumer.Capabilities {
 	return consumer.Capabilities{MutatesData: false}
 }
 
-// Shutdown is invoked during exporter shutdown.
-func (e Exporter) Shutdown(context.Context) error {
-	return nil
+// Shutdown is invoked during exporter shutdown,
+// any buffered records are written before returning.
+// The records still being written once the shutdown timeout
+// has passed are dropped.
+func (e Exporter) Shutdown(ctx context.Context) error {
+	if e.shutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.shutdownTimeout)
+		defer cancel()
+	}
+	return e.producer.Shutdown(ctx)
 }
 
 // ConsumeTraces receives a span batch and exports it to AWS Kinesis
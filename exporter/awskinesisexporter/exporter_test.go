@@ -0,0 +1,678 @@
+// Copyright 2019 OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awskinesisexporter
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/model/otlp"
+	"go.opentelemetry.io/collector/model/pdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/producer"
+)
+
+// fakeBatcher records the batches that are put instead of writing them.
+type fakeBatcher struct {
+	err      error
+	readyErr error
+	records  int
+	data     [][]byte
+	ready    bool
+	shutdown bool
+}
+
+var _ producer.Batcher = (*fakeBatcher)(nil)
+
+func (fb *fakeBatcher) Put(_ context.Context, bt *batch.Batch) error {
+	fb.records += bt.Len()
+	for _, chunk := range bt.Chunk() {
+		for _, record := range chunk {
+			fb.data = append(fb.data, record.Data)
+		}
+	}
+	return fb.err
+}
+
+func (fb *fakeBatcher) Ready(_ context.Context) error {
+	fb.ready = true
+	return fb.readyErr
+}
+
+func (fb *fakeBatcher) Shutdown(_ context.Context) error {
+	fb.shutdown = true
+	return nil
+}
+
+func TestCreateExporterWithEndpoint(t *testing.T) {
+	t.Parallel()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.AWS.StreamName = "test-stream"
+	cfg.AWS.Endpoint = "localhost:4566"
+	cfg.AWS.DisableSSL = true
+
+	exp, err := createExporter(cfg, componenttest.NewNopExporterCreateSettings(), config.TracesDataType)
+	require.NoError(t, err, "Must not error when creating the exporter")
+	require.NotNil(t, exp, "Must have created the exporter")
+
+	sess, cfgs, err := newSession(cfg)
+	require.NoError(t, err, "Must not error when creating the session")
+	client := kinesis.New(sess, cfgs...)
+	assert.Equal(t, "http://localhost:4566", client.Endpoint, "Must use the configured endpoint without TLS")
+}
+
+func TestCreateExporterDefaultEndpoint(t *testing.T) {
+	t.Parallel()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.AWS.StreamName = "test-stream"
+
+	sess, cfgs, err := newSession(cfg)
+	require.NoError(t, err, "Must not error when creating the session")
+	client := kinesis.New(sess, cfgs...)
+	assert.Equal(t, "https://kinesis.us-west-2.amazonaws.com", client.Endpoint, "Must use the regional endpoint")
+}
+
+func TestCreateExporterFIPSEndpoint(t *testing.T) {
+	t.Parallel()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.AWS.StreamName = "test-stream"
+	cfg.AWS.Region = "us-east-1"
+	cfg.AWS.UseFIPSEndpoint = true
+	require.NoError(t, cfg.Validate(), "Must not error in a region with a FIPS endpoint")
+
+	sess, cfgs, err := newSession(cfg)
+	require.NoError(t, err, "Must not error when creating the session")
+	client := kinesis.New(sess, cfgs...)
+	assert.Contains(t, client.Endpoint, "fips", "Must use the FIPS endpoint")
+	assert.Equal(t, "https://kinesis-fips.us-east-1.amazonaws.com", client.Endpoint, "Must use the FIPS endpoint of the region")
+
+	cfg.Target = targetFirehose
+	sess, cfgs, err = newSession(cfg)
+	require.NoError(t, err, "Must not error when creating the session")
+	assert.Contains(t, firehose.New(sess, cfgs...).Endpoint, "firehose-fips", "Must use the FIPS endpoint of the target")
+
+	cfg.Target = targetKinesis
+	cfg.AWS.Region = "us-gov-west-1"
+	require.NoError(t, cfg.Validate(), "Must not error in a GovCloud region")
+
+	cfg.AWS.Region = "eu-west-1"
+	assert.Error(t, cfg.Validate(), "Must error in a region without a FIPS endpoint")
+
+	core, logs := observer.New(zap.WarnLevel)
+	settings := componenttest.NewNopExporterCreateSettings()
+	settings.Logger = zap.New(core)
+	cfg.AWS.Endpoint = "localhost:4566"
+	require.NoError(t, cfg.Validate(), "Must not validate the FIPS endpoint when the endpoint is overridden")
+	_, err = createExporter(cfg, settings, config.TracesDataType)
+	require.NoError(t, err, "Must not error when creating the exporter")
+	assert.Equal(t, 1, logs.FilterMessageSnippet("use_fips_endpoint").Len(), "Must warn that the endpoint override is used")
+
+	sess, cfgs, err = newSession(cfg)
+	require.NoError(t, err, "Must not error when creating the session")
+	assert.Equal(t, "https://localhost:4566", kinesis.New(sess, cfgs...).Endpoint, "Must use the endpoint override")
+}
+
+func TestStartChecksStream(t *testing.T) {
+	t.Parallel()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.AWS.StreamName = "test-stream"
+	cfg.AWS.Endpoint = "localhost:4566"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	exp, err := createExporter(cfg, componenttest.NewNopExporterCreateSettings(), config.TracesDataType)
+	require.NoError(t, err, "Must not error when creating the exporter")
+	assert.Error(t, exp.start(ctx, componenttest.NewNopHost()), "Must check the stream on start")
+
+	cfg.SkipStreamCheck = true
+	exp, err = createExporter(cfg, componenttest.NewNopExporterCreateSettings(), config.TracesDataType)
+	require.NoError(t, err, "Must not error when creating the exporter")
+	assert.NoError(t, exp.start(ctx, componenttest.NewNopHost()), "Must not check the stream when skipped")
+}
+
+func TestStartWarmup(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zap.WarnLevel)
+	fb := &fakeBatcher{}
+	exp := &Exporter{producer: fb, log: zap.New(core)}
+	require.NoError(t, exp.start(context.Background(), componenttest.NewNopHost()), "Must not error when starting")
+	assert.False(t, fb.ready, "Must not describe the stream without warmup when the check is skipped")
+
+	exp.warmup = true
+	require.NoError(t, exp.start(context.Background(), componenttest.NewNopHost()), "Must not error when starting")
+	assert.True(t, fb.ready, "Must have described the stream to warm up the connection")
+	assert.Zero(t, logs.Len(), "Must not log a successful warmup")
+
+	fb.ready, fb.readyErr = false, errors.New("access denied")
+	require.NoError(t, exp.start(context.Background(), componenttest.NewNopHost()), "Must not fail the start when the warmup failed")
+	assert.True(t, fb.ready, "Must have described the stream to warm up the connection")
+	assert.Equal(t, 1, logs.FilterMessage("Failed to warm up the connection to the stream").Len(), "Must have logged the failed warmup")
+}
+
+func TestStartupJitter(t *testing.T) {
+	t.Parallel()
+
+	const jitter = 50 * time.Millisecond
+	fb := &fakeBatcher{}
+	exp := &Exporter{producer: fb, checkStream: true, startupJitter: jitter}
+
+	started := time.Now()
+	require.NoError(t, exp.start(context.Background(), componenttest.NewNopHost()), "Must not error when starting")
+	assert.Less(t, time.Since(started), 2*jitter, "Must start within the jitter")
+	assert.True(t, fb.ready, "Must have checked the batcher is ready after the delay")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	exp.startupJitter = time.Hour
+	assert.ErrorIs(t, exp.start(ctx, componenttest.NewNopHost()), context.Canceled, "Must stop waiting once the context is done")
+
+	fb.ready = false
+	exp.startupJitter = 0
+	assert.NoError(t, exp.start(ctx, componenttest.NewNopHost()), "Must not wait without any jitter")
+	assert.True(t, fb.ready, "Must have checked the batcher is ready")
+}
+
+func TestDryRun(t *testing.T) {
+	t.Parallel()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.AWS.StreamName = "test-stream"
+	// Any request to the endpoint fails
+	cfg.AWS.Endpoint = "localhost:1"
+	cfg.AWS.SDKMaxRetries = 0
+	cfg.Encoding.Name = "otlp_proto"
+	cfg.DryRun = true
+
+	core, logs := observer.New(zap.InfoLevel)
+	settings := componenttest.NewNopExporterCreateSettings()
+	settings.Logger = zap.New(core)
+	exp, err := createExporter(cfg, settings, config.TracesDataType)
+	require.NoError(t, err, "Must not error when creating the exporter")
+	require.NoError(t, exp.start(context.Background(), componenttest.NewNopHost()), "Must not check the stream in dry run mode")
+
+	td := pdata.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().InstrumentationLibrarySpans().AppendEmpty().Spans()
+	for i := 0; i < 10; i++ {
+		spans.AppendEmpty().SetName("span")
+	}
+	require.NoError(t, exp.ConsumeTraces(context.Background(), td), "Must not write the traces in dry run mode")
+
+	entries := logs.FilterMessage("Dry run, skipped writing records to kinesis").All()
+	require.Len(t, entries, 1, "Must have logged the request that would have been made")
+	assert.EqualValues(t, 1, entries[0].ContextMap()["records"], "Must have encoded the traces into a record")
+	assert.Greater(t, entries[0].ContextMap()["bytes"], int64(10*len("span")), "Must have logged the size of the encoded record")
+}
+
+func TestPerSignalEncodings(t *testing.T) {
+	t.Parallel()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.AWS.StreamName = "test-stream"
+	cfg.Encoding.Name = "otlp_proto"
+	cfg.Encoding.Signals.Logs = "otlp_json"
+	require.NoError(t, cfg.Validate(), "Must not error with the per signal encodings")
+
+	exp, err := createExporter(cfg, componenttest.NewNopExporterCreateSettings(), config.TracesDataType)
+	require.NoError(t, err, "Must not error when creating the exporter")
+	traces := &fakeBatcher{}
+	exp.producer = traces
+
+	td := pdata.NewTraces()
+	td.ResourceSpans().AppendEmpty().InstrumentationLibrarySpans().AppendEmpty().Spans().AppendEmpty().SetName("span")
+	require.NoError(t, exp.ConsumeTraces(context.Background(), td), "Must not error when writing traces")
+	require.Len(t, traces.data, 1, "Must have put the encoded traces")
+	assert.False(t, json.Valid(traces.data[0]), "Must not have encoded the traces as otlp_json")
+	_, err = otlp.NewProtobufTracesUnmarshaler().UnmarshalTraces(traces.data[0])
+	assert.NoError(t, err, "Must have encoded the traces as otlp_proto")
+
+	exp, err = createExporter(cfg, componenttest.NewNopExporterCreateSettings(), config.LogsDataType)
+	require.NoError(t, err, "Must not error when creating the exporter")
+	logs := &fakeBatcher{}
+	exp.producer = logs
+
+	ld := pdata.NewLogs()
+	ld.ResourceLogs().AppendEmpty().InstrumentationLibraryLogs().AppendEmpty().Logs().AppendEmpty().SetName("log")
+	require.NoError(t, exp.ConsumeLogs(context.Background(), ld), "Must not error when writing logs")
+	require.Len(t, logs.data, 1, "Must have put the encoded logs")
+	assert.True(t, json.Valid(logs.data[0]), "Must have encoded the logs as otlp_json")
+	_, err = otlp.NewJSONLogsUnmarshaler().UnmarshalLogs(logs.data[0])
+	assert.NoError(t, err, "Must have encoded the logs as otlp_json")
+}
+
+func TestProducerID(t *testing.T) {
+	t.Parallel()
+
+	host, err := os.Hostname()
+	require.NoError(t, err, "Must be able to read the hostname")
+
+	for _, tc := range []struct {
+		name     string
+		id       string
+		expected string
+	}{
+		{name: "configured", id: "collector-1", expected: "collector-1"},
+		{name: "hostname", expected: host},
+	} {
+		cfg := createDefaultConfig().(*Config)
+		cfg.AWS.StreamName = "test-stream"
+		cfg.Encoding.Name = "otlp_proto"
+		cfg.RecordAttributes.Attributes = map[string]string{"deployment.environment": "test"}
+		cfg.ProducerID = ProducerIDSettings{Enabled: true, ID: tc.id}
+		require.NoError(t, cfg.Validate(), "Must not error with the producer id")
+
+		exp, err := createExporter(cfg, componenttest.NewNopExporterCreateSettings(), config.TracesDataType)
+		require.NoError(t, err, "Must not error when creating the exporter")
+		fb := &fakeBatcher{}
+		exp.producer = fb
+
+		td := pdata.NewTraces()
+		td.ResourceSpans().AppendEmpty().InstrumentationLibrarySpans().AppendEmpty().Spans().AppendEmpty().SetName("span")
+		require.NoError(t, exp.ConsumeTraces(context.Background(), td), "Must not error when writing traces")
+		require.Len(t, fb.data, 1, "Must have put the encoded traces")
+
+		decoded, err := otlp.NewProtobufTracesUnmarshaler().UnmarshalTraces(fb.data[0])
+		require.NoError(t, err, "Must be able to parse the encoded record")
+		attrs := decoded.ResourceSpans().At(0).Resource().Attributes()
+		id, ok := attrs.Get(producerIDAttribute)
+		if assert.True(t, ok, "Must have added the producer id to the %s record", tc.name) {
+			assert.Equal(t, tc.expected, id.StringVal(), "Must use the %s producer id", tc.name)
+		}
+		_, ok = attrs.Get("deployment.environment")
+		assert.True(t, ok, "Must keep the record attributes")
+		assert.Len(t, cfg.RecordAttributes.Attributes, 1, "Must not modify the configured record attributes")
+	}
+}
+
+func TestExporterWithFakeBatcher(t *testing.T) {
+	t.Parallel()
+
+	encoder, err := batch.NewEncoder("otlp_proto")
+	require.NoError(t, err, "Must have a valid encoder")
+
+	fb := &fakeBatcher{}
+	exp := &Exporter{producer: fb, batcher: encoder, checkStream: true}
+	require.NoError(t, exp.start(context.Background(), componenttest.NewNopHost()), "Must not error when starting")
+	assert.True(t, fb.ready, "Must have checked the batcher is ready on start")
+
+	td := pdata.NewTraces()
+	td.ResourceSpans().AppendEmpty().InstrumentationLibrarySpans().AppendEmpty().Spans().AppendEmpty().SetName("span")
+	require.NoError(t, exp.ConsumeTraces(context.Background(), td), "Must not error when writing traces")
+	assert.Equal(t, 1, fb.records, "Must have put the encoded records")
+
+	fb.err = errors.New("failed to put")
+	assert.ErrorIs(t, exp.ConsumeTraces(context.Background(), td), fb.err, "Must return the error of the batcher")
+
+	require.NoError(t, exp.Shutdown(context.Background()), "Must not error when shutting down")
+	assert.True(t, fb.shutdown, "Must have shut down the batcher")
+}
+
+func TestStaticCredentials(t *testing.T) {
+	t.Parallel()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.AWS.AccessKey = "test-access-key"
+	cfg.AWS.SecretKey = "test-secret-key"
+	cfg.AWS.SessionToken = "test-session-token"
+
+	sess, _, err := newSession(cfg)
+	require.NoError(t, err, "Must not error when creating the session")
+	creds, err := sess.Config.Credentials.Get()
+	require.NoError(t, err, "Must not error when getting the static credentials")
+	assert.Equal(t, credentials.StaticProviderName, creds.ProviderName, "Must use the static credentials provider")
+	assert.Equal(t, "test-access-key", creds.AccessKeyID)
+	assert.Equal(t, "test-secret-key", creds.SecretAccessKey)
+	assert.Equal(t, "test-session-token", creds.SessionToken)
+}
+
+func TestHTTPClient(t *testing.T) {
+	t.Parallel()
+
+	cfg := createDefaultConfig().(*Config)
+	sess, _, err := newSession(cfg)
+	require.NoError(t, err, "Must not error when creating the session")
+	assert.Equal(t, http.DefaultClient, sess.Config.HTTPClient, "Must use the default client when unset")
+
+	cfg.HTTP = HTTPSettings{
+		Timeout:         time.Second,
+		MaxIdleConns:    10,
+		IdleConnTimeout: 30 * time.Second,
+	}
+	sess, _, err = newSession(cfg)
+	require.NoError(t, err, "Must not error when creating the session")
+
+	client := sess.Config.HTTPClient
+	assert.Equal(t, time.Second, client.Timeout, "Must have applied the timeout")
+	transport, ok := client.Transport.(*http.Transport)
+	require.True(t, ok, "Must use an http transport")
+	assert.Equal(t, 10, transport.MaxIdleConns, "Must have applied the max idle connections")
+	assert.Equal(t, 10, transport.MaxIdleConnsPerHost, "Must have applied the max idle connections to the endpoint")
+	assert.Equal(t, 30*time.Second, transport.IdleConnTimeout, "Must have applied the idle connection timeout")
+}
+
+func TestUserAgentSuffix(t *testing.T) {
+	t.Parallel()
+
+	userAgent := func(cfg *Config) string {
+		sess, cfgs, err := newSession(cfg)
+		require.NoError(t, err, "Must not error when creating the session")
+		req, _ := kinesis.New(sess, cfgs...).PutRecordsRequest(&kinesis.PutRecordsInput{
+			StreamName: aws.String("test-stream"),
+			Records: []*kinesis.PutRecordsRequestEntry{
+				{Data: []byte("data"), PartitionKey: aws.String("key")},
+			},
+		})
+		require.NoError(t, req.Build(), "Must not error when building the request")
+		return req.HTTPRequest.Header.Get("User-Agent")
+	}
+
+	cfg := createDefaultConfig().(*Config)
+	defaultAgent := userAgent(cfg)
+	assert.NotContains(t, defaultAgent, "team-a/1.0", "Must use the default user agent when unset")
+
+	cfg.AWS.UserAgentSuffix = "team-a/1.0"
+	agent := userAgent(cfg)
+	assert.True(t, strings.HasPrefix(agent, defaultAgent), "Must keep the default user agent")
+	assert.True(t, strings.HasSuffix(agent, " team-a/1.0"), "Must have appended the suffix to the user agent")
+}
+
+func TestPropagateTraceContext(t *testing.T) {
+	t.Parallel()
+
+	traceparent := func(ctx context.Context, cfg *Config) string {
+		sess, cfgs, err := newSession(cfg)
+		require.NoError(t, err, "Must not error when creating the session")
+		req, _ := kinesis.New(sess, cfgs...).PutRecordsRequest(&kinesis.PutRecordsInput{
+			StreamName: aws.String("test-stream"),
+			Records: []*kinesis.PutRecordsRequestEntry{
+				{Data: []byte("data"), PartitionKey: aws.String("key")},
+			},
+		})
+		req.SetContext(ctx)
+		require.NoError(t, req.Build(), "Must not error when building the request")
+		return req.HTTPRequest.Header.Get("traceparent")
+	}
+
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "export")
+	defer span.End()
+	sc := span.SpanContext()
+
+	cfg := createDefaultConfig().(*Config)
+	assert.Empty(t, traceparent(ctx, cfg), "Must not propagate the trace context when unset")
+
+	cfg.AWS.PropagateTraceContext = true
+	assert.Equal(t, "00-"+sc.TraceID().String()+"-"+sc.SpanID().String()+"-01", traceparent(ctx, cfg),
+		"Must have added the traceparent of the active span")
+	assert.Empty(t, traceparent(context.Background(), cfg), "Must not add a traceparent without an active span")
+}
+
+func TestSDKMaxRetries(t *testing.T) {
+	t.Parallel()
+
+	maxRetries := func(retries int) int {
+		cfg := createDefaultConfig().(*Config)
+		cfg.AWS.SDKMaxRetries = retries
+		sess, cfgs, err := newSession(cfg)
+		require.NoError(t, err, "Must not error when creating the session")
+		return kinesis.New(sess, cfgs...).MaxRetries()
+	}
+
+	assert.Equal(t, client.DefaultRetryerMaxNumRetries, maxRetries(defaultSDKMaxRetries), "Must use the SDK default retries")
+	assert.Equal(t, 0, maxRetries(0), "Must have disabled the SDK retries")
+	assert.Equal(t, 5, maxRetries(5), "Must use the configured SDK retries")
+}
+
+func TestHTTPProxy(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "http://environment.internal:8080")
+	t.Setenv("NO_PROXY", "kinesis.us-east-1.amazonaws.com")
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.HTTP.ProxyURL = "http://proxy.internal:3128"
+	sess, _, err := newSession(cfg)
+	require.NoError(t, err, "Must not error when creating the session")
+	transport, ok := sess.Config.HTTPClient.Transport.(*http.Transport)
+	require.True(t, ok, "Must use an http transport")
+
+	req, err := http.NewRequest(http.MethodPost, "https://kinesis.us-west-2.amazonaws.com", nil)
+	require.NoError(t, err)
+	proxy, err := transport.Proxy(req)
+	require.NoError(t, err, "Must not error when resolving the proxy")
+	require.NotNil(t, proxy, "Must send the request through a proxy")
+	assert.Equal(t, "http://proxy.internal:3128", proxy.String(), "Must use the configured proxy over the environment")
+
+	req, err = http.NewRequest(http.MethodPost, "https://kinesis.us-east-1.amazonaws.com", nil)
+	require.NoError(t, err)
+	proxy, err = transport.Proxy(req)
+	require.NoError(t, err, "Must not error when resolving the proxy")
+	assert.Nil(t, proxy, "Must reach the hosts in NO_PROXY directly")
+}
+
+func TestHTTPCAFile(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	bundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, bundle, 0600), "Must be able to write the ca file")
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.HTTP.CAFile = caFile
+	sess, _, err := newSession(cfg)
+	require.NoError(t, err, "Must not error when creating the session")
+	transport, ok := sess.Config.HTTPClient.Transport.(*http.Transport)
+	require.True(t, ok, "Must use an http transport")
+	require.NotNil(t, transport.TLSClientConfig, "Must have configured tls")
+
+	_, err = srv.Certificate().Verify(x509.VerifyOptions{Roots: transport.TLSClientConfig.RootCAs})
+	assert.NoError(t, err, "Must trust the certificates of the ca file")
+
+	resp, err := sess.Config.HTTPClient.Get(srv.URL)
+	require.NoError(t, err, "Must connect to the endpoint signed by the ca file")
+	assert.NoError(t, resp.Body.Close())
+
+	cfg.HTTP.CAFile = filepath.Join(t.TempDir(), "missing.pem")
+	_, _, err = newSession(cfg)
+	assert.Error(t, err, "Must error when the ca file can not be read")
+
+	require.NoError(t, os.WriteFile(caFile, []byte("not a certificate"), 0600), "Must be able to write the ca file")
+	cfg.HTTP.CAFile = caFile
+	_, _, err = newSession(cfg)
+	assert.Error(t, err, "Must error when the ca file has no certificates")
+}
+
+func TestLazyInit(t *testing.T) {
+	t.Parallel()
+
+	// The session can not be created while the ca file is missing
+	cfg := createDefaultConfig().(*Config)
+	cfg.AWS.StreamName = "test-stream"
+	cfg.Encoding.Name = "otlp_proto"
+	cfg.HTTP.CAFile = filepath.Join(t.TempDir(), "missing.pem")
+	_, err := createExporter(cfg, componenttest.NewNopExporterCreateSettings(), config.TracesDataType)
+	assert.Error(t, err, "Must fail to create the clients up front without lazy init")
+
+	cfg.LazyInit = true
+	require.NoError(t, cfg.Validate(), "Must not error with lazy init")
+	exp, err := createExporter(cfg, componenttest.NewNopExporterCreateSettings(), config.TracesDataType)
+	require.NoError(t, err, "Must not create the clients when creating the exporter")
+	require.NoError(t, exp.start(context.Background(), componenttest.NewNopHost()), "Must not create the clients on start")
+
+	td := pdata.NewTraces()
+	td.ResourceSpans().AppendEmpty().InstrumentationLibrarySpans().AppendEmpty().Spans().AppendEmpty().SetName("span")
+	err = exp.ConsumeTraces(context.Background(), td)
+	require.Error(t, err, "Must create the clients on the first write")
+	assert.Contains(t, err.Error(), "failed to create the batcher", "Must report that the clients could not be created")
+	assert.False(t, consumererror.IsPermanent(err), "Must retry the write once the clients can be created")
+
+	require.NoError(t, exp.Shutdown(context.Background()), "Must not error when the clients were never created")
+}
+
+func TestAssumeRoleProvider(t *testing.T) {
+	t.Parallel()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.AWS.Region = "eu-west-1"
+
+	sess, cfgs, err := newSession(cfg)
+	require.NoError(t, err, "Must not error when creating the session")
+	assert.Empty(t, cfgs, "Must not override the credentials without a role")
+	assert.Nil(t, newAssumeRoleProvider(sess, cfg), "Must not assume a role when unset")
+
+	cfg.AWS.RoleARN = "arn:aws:iam::123456789012:role/writer"
+	cfg.AWS.ExternalID = "external-id"
+
+	sess, cfgs, err = newSession(cfg)
+	require.NoError(t, err, "Must not error when creating the session")
+	require.Len(t, cfgs, 1, "Must have overridden the credentials")
+	assert.NotNil(t, cfgs[0].Credentials, "Must have set the assumed role credentials")
+
+	role := newAssumeRoleProvider(sess, cfg)
+	require.NotNil(t, role, "Must assume the configured role")
+	assert.Equal(t, "arn:aws:iam::123456789012:role/writer", role.RoleARN)
+	assert.Equal(t, "otel-collector", role.RoleSessionName, "Must use the default session name")
+	assert.Equal(t, "external-id", aws.StringValue(role.ExternalID))
+
+	client, ok := role.Client.(*sts.STS)
+	require.True(t, ok, "Must use the sts client")
+	assert.Equal(t, "eu-west-1", client.SigningRegion, "Must use the configured region for sts")
+
+	cfg.AWS.RoleSessionName = "custom-session"
+	assert.Equal(t, "custom-session", newAssumeRoleProvider(sess, cfg).RoleSessionName, "Must use the configured session name")
+}
+
+func TestPerSignalStreams(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "test-access-key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test-secret-key")
+
+	var (
+		mu      sync.Mutex
+		streams []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			StreamName string
+			Records    []json.RawMessage
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&in), "Must be a valid kinesis request")
+		mu.Lock()
+		streams = append(streams, in.StreamName)
+		mu.Unlock()
+
+		records := make([]map[string]string, 0, len(in.Records))
+		for range in.Records {
+			records = append(records, map[string]string{"ShardId": "shardId-000000000000", "SequenceNumber": "1"})
+		}
+		out := map[string]interface{}{"FailedRecordCount": 0, "Records": records}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		assert.NoError(t, json.NewEncoder(w).Encode(out))
+	}))
+	defer server.Close()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.AWS.Endpoint = server.URL
+	cfg.AWS.StreamName = "default-stream"
+	cfg.AWS.Streams.Metrics = "metrics-stream"
+	cfg.Encoding.Name = "otlp_proto"
+
+	exp, err := createExporter(cfg, componenttest.NewNopExporterCreateSettings(), config.MetricsDataType)
+	require.NoError(t, err, "Must not error when creating the exporter")
+
+	md := pdata.NewMetrics()
+	md.ResourceMetrics().AppendEmpty().InstrumentationLibraryMetrics().AppendEmpty().Metrics().AppendEmpty().SetName("metric")
+	require.NoError(t, exp.ConsumeMetrics(context.Background(), md), "Must not error when writing metrics")
+
+	exp, err = createExporter(cfg, componenttest.NewNopExporterCreateSettings(), config.LogsDataType)
+	require.NoError(t, err, "Must not error when creating the exporter")
+
+	ld := pdata.NewLogs()
+	ld.ResourceLogs().AppendEmpty().InstrumentationLibraryLogs().AppendEmpty().Logs().AppendEmpty().SetName("log")
+	require.NoError(t, exp.ConsumeLogs(context.Background(), ld), "Must not error when writing logs")
+
+	assert.Equal(t, []string{"metrics-stream", "default-stream"}, streams, "Must write each signal to its stream")
+
+	cfg.AWS.StreamName = ""
+	_, err = createExporter(cfg, componenttest.NewNopExporterCreateSettings(), config.TracesDataType)
+	assert.Error(t, err, "Must error when no stream is configured for the signal")
+}
+
+func TestCreateExporterClampsRecordsPerBatch(t *testing.T) {
+	t.Parallel()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.AWS.StreamName = "test-stream"
+	cfg.MaxRecordsPerBatch = 501
+
+	core, logs := observer.New(zap.WarnLevel)
+	params := componenttest.NewNopExporterCreateSettings()
+	params.Logger = zap.New(core)
+
+	_, err := createExporter(cfg, params, config.TracesDataType)
+	require.NoError(t, err, "Must not error when creating the exporter")
+	assert.Equal(t, 1, logs.FilterMessageSnippet("max_records_per_batch").Len(), "Must warn that the limit is clamped")
+}
+
+func TestFlushRecordCount(t *testing.T) {
+	t.Parallel()
+
+	cfg := createDefaultConfig().(*Config)
+	assert.Equal(t, batch.MaxBatchedRecords, flushRecordCount(cfg, zap.NewNop()), "Must flush a full batch by default")
+
+	cfg.FlushRecordCount = 100
+	assert.Equal(t, 100, flushRecordCount(cfg, zap.NewNop()), "Must flush at the flush record count")
+
+	core, logs := observer.New(zap.WarnLevel)
+	cfg.MaxRecordsPerBatch = 50
+	assert.Equal(t, 50, flushRecordCount(cfg, zap.New(core)), "Must clamp the flush record count to the records per batch")
+	assert.Equal(t, 1, logs.FilterMessageSnippet("flush_record_count").Len(), "Must warn that the flush record count is clamped")
+
+	cfg.MaxRecordsPerBatch = 1000
+	cfg.FlushRecordCount = 800
+	assert.Equal(t, batch.MaxBatchedRecords, flushRecordCount(cfg, zap.New(core)), "Must clamp the flush record count to the kinesis limit")
+}
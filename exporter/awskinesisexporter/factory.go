@@ -16,17 +16,52 @@ package awskinesisexporter
 
 import (
 	"context"
+	"time"
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/config"
 	"go.opentelemetry.io/collector/exporter/exporterhelper"
 
 	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/compress"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/producer"
 )
 
 const (
 	// The value of "type" key in configuration.
 	typeStr = "awskinesis"
+
+	defaultEncoding = "jaeger_proto"
+
+	// defaultCompressionMinSize leaves records that are too small to benefit from compression uncompressed.
+	defaultCompressionMinSize = 1 << 10
+
+	// defaultCompressionAutoMinSize only tries every candidate of the auto compression
+	// on records that are large enough for the savings to be worth the CPU.
+	defaultCompressionAutoMinSize = 16 << 10
+
+	defaultRoleSessionName = "otel-collector"
+
+	// defaultSDKMaxRetries uses the retries of the AWS SDK default retryer for each service.
+	defaultSDKMaxRetries = -1
+
+	// defaultAdaptiveMinRecords keeps adaptive batching from shrinking requests below where their overhead dominates.
+	defaultAdaptiveMinRecords = 10
+
+	defaultRoundRobinKeys = 4
+
+	defaultShardCount = 1
+
+	defaultSamplingRatio = 1
+
+	defaultEMFInterval = time.Minute
+
+	defaultLogFailuresSampleRate = 100
+
+	defaultShutdownTimeout = 30 * time.Second
+
+	// streamPollInterval is the interval used to check if a created stream is active.
+	streamPollInterval = 5 * time.Second
 )
 
 // NewFactory creates a factory for Kinesis exporter.
@@ -46,16 +81,50 @@ func createDefaultConfig() config.Exporter {
 		TimeoutSettings:  exporterhelper.DefaultTimeoutSettings(),
 		RetrySettings:    exporterhelper.DefaultRetrySettings(),
 		QueueSettings:    exporterhelper.DefaultQueueSettings(),
+		Target:           targetKinesis,
+		OnPermanentError: permanentErrorFail,
+		Encoding: Encoding{
+			Name:                   defaultEncoding,
+			Compression:            compress.None,
+			CompressionMinSize:     defaultCompressionMinSize,
+			CompressionAutoMinSize: defaultCompressionAutoMinSize,
+			CompressionMarker:      markerPartitionKey,
+		},
 		AWS: AWSConfig{
-			Region: "us-west-2",
+			Region:        "us-west-2",
+			SDKMaxRetries: defaultSDKMaxRetries,
+		},
+		ThrottleRetry:         defaultThrottleRetrySettings(),
+		MaxRecordsPerBatch:    batch.MaxBatchedRecords,
+		MaxRecordSize:         batch.MaxRecordSize,
+		MaxConcurrentRequests: 1,
+		AdaptiveMinRecords:    defaultAdaptiveMinRecords,
+		RoundRobinKeys:        defaultRoundRobinKeys,
+		ShardCount:            defaultShardCount,
+		SamplingRatio:         defaultSamplingRatio,
+		ShutdownTimeout:       defaultShutdownTimeout,
+		EMF: EMFSettings{
+			Interval: defaultEMFInterval,
 		},
-		MaxRecordsPerBatch: batch.MaxBatchedRecords,
-		MaxRecordSize:      batch.MaxRecordSize,
+		LogFailures: LogFailuresSettings{
+			Enabled:    true,
+			SampleRate: defaultLogFailuresSampleRate,
+		},
+	}
+}
+
+func defaultThrottleRetrySettings() ThrottleRetrySettings {
+	bs := producer.DefaultBackoffSettings()
+	return ThrottleRetrySettings{
+		InitialInterval: bs.InitialInterval,
+		MaxInterval:     bs.MaxInterval,
+		MaxElapsedTime:  bs.MaxElapsedTime,
+		Multiplier:      bs.Multiplier,
 	}
 }
 
 func NewTracesExporter(ctx context.Context, params component.ExporterCreateSettings, conf config.Exporter) (component.TracesExporter, error) {
-	exp, err := createExporter(conf, params.Logger)
+	exp, err := createExporter(conf, params, config.TracesDataType)
 	if err != nil {
 		return nil, err
 	}
@@ -67,11 +136,13 @@ func NewTracesExporter(ctx context.Context, params component.ExporterCreateSetti
 		exporterhelper.WithTimeout(c.TimeoutSettings),
 		exporterhelper.WithRetry(c.RetrySettings),
 		exporterhelper.WithQueue(c.QueueSettings),
+		exporterhelper.WithStart(exp.start),
+		exporterhelper.WithShutdown(exp.Shutdown),
 	)
 }
 
 func NewMetricsExporter(ctx context.Context, params component.ExporterCreateSettings, conf config.Exporter) (component.MetricsExporter, error) {
-	exp, err := createExporter(conf, params.Logger)
+	exp, err := createExporter(conf, params, config.MetricsDataType)
 	if err != nil {
 		return nil, err
 	}
@@ -83,11 +154,13 @@ func NewMetricsExporter(ctx context.Context, params component.ExporterCreateSett
 		exporterhelper.WithTimeout(c.TimeoutSettings),
 		exporterhelper.WithRetry(c.RetrySettings),
 		exporterhelper.WithQueue(c.QueueSettings),
+		exporterhelper.WithStart(exp.start),
+		exporterhelper.WithShutdown(exp.Shutdown),
 	)
 }
 
 func NewLogsExporter(ctx context.Context, params component.ExporterCreateSettings, conf config.Exporter) (component.LogsExporter, error) {
-	exp, err := createExporter(conf, params.Logger)
+	exp, err := createExporter(conf, params, config.LogsDataType)
 	if err != nil {
 		return nil, err
 	}
@@ -99,5 +172,7 @@ func NewLogsExporter(ctx context.Context, params component.ExporterCreateSetting
 		exporterhelper.WithTimeout(c.TimeoutSettings),
 		exporterhelper.WithRetry(c.RetrySettings),
 		exporterhelper.WithQueue(c.QueueSettings),
+		exporterhelper.WithStart(exp.start),
+		exporterhelper.WithShutdown(exp.Shutdown),
 	)
 }
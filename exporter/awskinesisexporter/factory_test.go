@@ -0,0 +1,51 @@
+// Copyright 2019 OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awskinesisexporter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+)
+
+func TestCreateMetricsExporter(t *testing.T) {
+	t.Parallel()
+
+	factory := NewFactory()
+	cfg := factory.CreateDefaultConfig().(*Config)
+	cfg.AWS.StreamName = "test-stream"
+	cfg.Encoding.Name = "otlp_proto"
+
+	exp, err := factory.CreateMetricsExporter(context.Background(), componenttest.NewNopExporterCreateSettings(), cfg)
+	require.NoError(t, err, "Must not error when creating the metrics exporter")
+	assert.NotNil(t, exp, "Must have created the metrics exporter")
+}
+
+func TestCreateLogsExporter(t *testing.T) {
+	t.Parallel()
+
+	factory := NewFactory()
+	cfg := factory.CreateDefaultConfig().(*Config)
+	cfg.AWS.StreamName = "test-stream"
+	cfg.Encoding.Name = "otlp_json"
+	cfg.PartitionKeySource = "tenant"
+
+	exp, err := factory.CreateLogsExporter(context.Background(), componenttest.NewNopExporterCreateSettings(), cfg)
+	require.NoError(t, err, "Must not error when creating the logs exporter")
+	assert.NotNil(t, exp, "Must have created the logs exporter")
+}
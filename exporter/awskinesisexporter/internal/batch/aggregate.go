@@ -0,0 +1,149 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"crypto/md5" //nolint:gosec // md5 is required by the aggregated record format
+	"encoding/binary"
+
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// AggregateMagic is the prefix of records using the
+// aggregated record format of the kinesis producer library
+var AggregateMagic = []byte{0xf3, 0x89, 0x9a, 0xc2}
+
+// LengthPrefixSize is the size of the big-endian length that
+// precedes each payload of a length prefixed aggregated record.
+const LengthPrefixSize = 8
+
+// Field numbers of the AggregatedRecord and Record messages
+// defined by the kinesis producer library.
+const (
+	aggregatePartitionKeyTable protowire.Number = 1
+	aggregateRecords           protowire.Number = 3
+
+	recordPartitionKeyIndex protowire.Number = 1
+	recordData              protowire.Number = 3
+)
+
+// aggregator packs the data of multiple records that share
+// a partition key into a single aggregated record.
+type aggregator struct {
+	key     string
+	hashKey string
+	data    [][]byte
+	// size is the encoded size of the aggregated record message
+	size int
+	// lengthPrefixed concatenates the data prefixed by its length
+	// instead of using the kinesis producer library format.
+	lengthPrefixed bool
+}
+
+func newAggregator(key, hashKey string, lengthPrefixed bool) *aggregator {
+	a := &aggregator{
+		key:            key,
+		hashKey:        hashKey,
+		lengthPrefixed: lengthPrefixed,
+	}
+	if !lengthPrefixed {
+		a.size = protowire.SizeTag(aggregatePartitionKeyTable) + protowire.SizeBytes(len(key))
+	}
+	return a
+}
+
+// entrySize returns the encoded size of the data once included in the aggregated record
+func (a *aggregator) entrySize(data []byte) int {
+	if a.lengthPrefixed {
+		return LengthPrefixSize + len(data)
+	}
+	return protowire.SizeTag(aggregateRecords) + protowire.SizeBytes(recordSize(data))
+}
+
+func recordSize(data []byte) int {
+	return protowire.SizeTag(recordPartitionKeyIndex) + protowire.SizeVarint(0) +
+		protowire.SizeTag(recordData) + protowire.SizeBytes(len(data))
+}
+
+// frameSize returns the size of the aggregated record once the data has been added
+func (a *aggregator) frameSize(data []byte) int {
+	if a.lengthPrefixed {
+		return a.size + a.entrySize(data)
+	}
+	return len(AggregateMagic) + a.size + a.entrySize(data) + md5.Size
+}
+
+// encodedSize returns the size of the aggregated record including its partition key
+func (a *aggregator) encodedSize() int {
+	if a.lengthPrefixed {
+		return a.size + len(a.key)
+	}
+	return len(AggregateMagic) + a.size + md5.Size + len(a.key)
+}
+
+func (a *aggregator) add(data []byte) {
+	a.size += a.entrySize(data)
+	a.data = append(a.data, data)
+}
+
+// entry returns the aggregated record as a record to be written
+func (a *aggregator) entry() *kinesis.PutRecordsRequestEntry {
+	return newEntry(a.encode(), a.key, a.hashKey)
+}
+
+// encode returns the aggregated record framed with the
+// magic prefix and trailing md5 checksum of the message.
+func (a *aggregator) encode() []byte {
+	if a.lengthPrefixed {
+		return a.encodeLengthPrefixed()
+	}
+	msg := make([]byte, 0, a.size)
+	msg = protowire.AppendTag(msg, aggregatePartitionKeyTable, protowire.BytesType)
+	msg = protowire.AppendString(msg, a.key)
+	for _, data := range a.data {
+		msg = protowire.AppendTag(msg, aggregateRecords, protowire.BytesType)
+		msg = protowire.AppendVarint(msg, uint64(recordSize(data)))
+		msg = protowire.AppendTag(msg, recordPartitionKeyIndex, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, 0)
+		msg = protowire.AppendTag(msg, recordData, protowire.BytesType)
+		msg = protowire.AppendBytes(msg, data)
+	}
+
+	return frame(msg)
+}
+
+// encodeLengthPrefixed returns the data concatenated with
+// each payload preceded by its length as a big-endian uint64.
+func (a *aggregator) encodeLengthPrefixed() []byte {
+	record := make([]byte, a.size)
+	n := 0
+	for _, data := range a.data {
+		binary.BigEndian.PutUint64(record[n:], uint64(len(data)))
+		n += LengthPrefixSize
+		n += copy(record[n:], data)
+	}
+	return record
+}
+
+// frame returns the aggregated record message framed with the
+// magic prefix and trailing md5 checksum of the message.
+func frame(msg []byte) []byte {
+	sum := md5.Sum(msg) //nolint:gosec // md5 is required by the aggregated record format
+	frame := make([]byte, 0, len(AggregateMagic)+len(msg)+len(sum))
+	frame = append(frame, AggregateMagic...)
+	frame = append(frame, msg...)
+	return append(frame, sum[:]...)
+}
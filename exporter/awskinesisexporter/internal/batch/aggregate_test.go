@@ -0,0 +1,175 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch_test
+
+import (
+	"bytes"
+	"crypto/md5" //nolint:gosec // md5 is required by the aggregated record format
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+)
+
+// deaggregate validates the aggregated record frame and returns
+// the partition key table and the data of each aggregated record.
+func deaggregate(t *testing.T, frame []byte) (keys []string, data [][]byte) {
+	keys, indexes, data := deaggregateIndexes(t, frame)
+	for _, index := range indexes {
+		assert.EqualValues(t, 0, index, "Must reference the only partition key")
+	}
+	return keys, data
+}
+
+// deaggregateIndexes returns the partition key table of the aggregated
+// record along with the partition key index and data of each record.
+func deaggregateIndexes(t *testing.T, frame []byte) (keys []string, indexes []uint64, data [][]byte) {
+	require.True(t, bytes.HasPrefix(frame, batch.AggregateMagic), "Must have the aggregated record magic prefix")
+	msg := frame[len(batch.AggregateMagic) : len(frame)-md5.Size]
+	sum := md5.Sum(msg) //nolint:gosec // md5 is required by the aggregated record format
+	require.Equal(t, sum[:], frame[len(frame)-md5.Size:], "Must have the trailing md5 checksum of the message")
+
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		require.GreaterOrEqual(t, n, 0, "Must be a valid tag")
+		require.Equal(t, protowire.BytesType, typ, "Must only contain length delimited fields")
+		msg = msg[n:]
+		value, n := protowire.ConsumeBytes(msg)
+		require.GreaterOrEqual(t, n, 0, "Must be a valid field")
+		msg = msg[n:]
+
+		switch num {
+		case 1:
+			keys = append(keys, string(value))
+		case 3:
+			var index uint64
+			for len(value) > 0 {
+				num, typ, n := protowire.ConsumeTag(value)
+				require.GreaterOrEqual(t, n, 0, "Must be a valid tag")
+				value = value[n:]
+				if typ == protowire.VarintType {
+					var n int
+					index, n = protowire.ConsumeVarint(value)
+					require.GreaterOrEqual(t, n, 0, "Must be a valid partition key index")
+					value = value[n:]
+					continue
+				}
+				require.EqualValues(t, 3, num, "Must be the record data")
+				record, n := protowire.ConsumeBytes(value)
+				require.GreaterOrEqual(t, n, 0, "Must be valid record data")
+				data = append(data, record)
+				value = value[n:]
+			}
+			indexes = append(indexes, index)
+		default:
+			t.Fatalf("Unexpected field %d", num)
+		}
+	}
+	return keys, indexes, data
+}
+
+func TestAggregatedRecords(t *testing.T) {
+	t.Parallel()
+
+	bt := batch.New(batch.WithAggregation())
+	for i := 0; i < 10; i++ {
+		key := "even"
+		if i%2 == 1 {
+			key = "odd"
+		}
+		require.NoError(t, bt.AddRecord([]byte(fmt.Sprintf("record-%d", i)), key))
+	}
+
+	chunks := bt.Chunk()
+	require.Len(t, chunks, 1, "Must have exactly one chunk")
+	require.Len(t, chunks[0], 2, "Must have one aggregated record per partition key")
+
+	for i, key := range []string{"even", "odd"} {
+		record := chunks[0][i]
+		assert.Equal(t, key, aws.StringValue(record.PartitionKey), "Must use the partition key of the aggregated records")
+
+		keys, data := deaggregate(t, record.Data)
+		assert.Equal(t, []string{key}, keys, "Must have the partition key within the table")
+		require.Len(t, data, 5, "Must have aggregated every record of the key")
+		for j, value := range data {
+			assert.Equal(t, fmt.Sprintf("record-%d", j*2+i), string(value), "Must have kept the records in order")
+		}
+	}
+}
+
+func TestAggregatedRecordSizeLimit(t *testing.T) {
+	t.Parallel()
+
+	bt := batch.New(batch.WithAggregation(), batch.WithMaxRecordSize(100))
+	for i := 0; i < 10; i++ {
+		require.NoError(t, bt.AddRecord(bytes.Repeat([]byte("d"), 20), "key"))
+	}
+	assert.ErrorIs(t, bt.AddRecord(bytes.Repeat([]byte("d"), 100), "key"), batch.ErrRecordLength,
+		"Must error when the aggregated frame of a single record is too large")
+
+	chunks := bt.Chunk()
+	require.Len(t, chunks, 1, "Must have exactly one chunk")
+	require.Greater(t, len(chunks[0]), 1, "Must have split the records across aggregated records")
+
+	var total int
+	for _, record := range chunks[0] {
+		assert.LessOrEqual(t, len(record.Data), 100, "Must not exceed the record size limit")
+		_, data := deaggregate(t, record.Data)
+		total += len(data)
+	}
+	assert.Equal(t, 10, total, "Must have aggregated every record")
+	assert.Len(t, bt.Chunk()[0], len(chunks[0]), "Must not modify the batch when chunking")
+}
+
+func TestLengthPrefixedAggregatedRecords(t *testing.T) {
+	t.Parallel()
+
+	bt := batch.New(batch.WithAggregation(), batch.WithLengthPrefixedAggregation(), batch.WithMaxRecordSize(64))
+	payloads := [][]byte{[]byte("a"), []byte("record"), bytes.Repeat([]byte("d"), 20), bytes.Repeat([]byte("e"), 30)}
+	for _, data := range payloads {
+		require.NoError(t, bt.AddRecord(data, "key"))
+	}
+	assert.ErrorIs(t, bt.AddRecord(bytes.Repeat([]byte("d"), 64-batch.LengthPrefixSize-len("key")+1), "key"), batch.ErrRecordLength,
+		"Must count the length prefix towards the record size limit")
+
+	chunks := bt.Chunk()
+	require.Len(t, chunks, 1, "Must have exactly one chunk")
+	require.Len(t, chunks[0], 2, "Must have split the records once the record size limit is reached")
+
+	var (
+		data [][]byte
+		size int
+	)
+	for _, record := range chunks[0] {
+		assert.LessOrEqual(t, len(record.Data)+len("key"), 64, "Must not exceed the record size limit")
+		size += len(record.Data) + len("key")
+		for frame := record.Data; len(frame) > 0; {
+			require.GreaterOrEqual(t, len(frame), batch.LengthPrefixSize, "Must have a complete length prefix")
+			n := binary.BigEndian.Uint64(frame)
+			frame = frame[batch.LengthPrefixSize:]
+			require.LessOrEqual(t, n, uint64(len(frame)), "Must not have a frame length past the record")
+			data = append(data, frame[:n])
+			frame = frame[n:]
+		}
+	}
+	assert.Equal(t, payloads, data, "Must have framed every payload with its length")
+	assert.Equal(t, size, bt.ByteSize(), "Must count the length prefixes within the batch size")
+}
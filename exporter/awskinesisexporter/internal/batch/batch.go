@@ -15,38 +15,117 @@
 package batch
 
 import (
+	"crypto/md5" //nolint:gosec // md5 is required by the aggregated record format
+	"encoding/json"
 	"errors"
+	"fmt"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/kinesis"
 	protov1 "github.com/golang/protobuf/proto" //nolint:staticcheck // Some encoding types uses legacy prototype version
 	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/model/pdata"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
 	protov2 "google.golang.org/protobuf/proto"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/compress"
 )
 
 const (
 	MaxRecordSize     = 1 << 20 // 1MiB
 	MaxBatchedRecords = 500
+	// MaxBatchSize is the kinesis limit of the total size of a PutRecords request
+	MaxBatchSize = 5 << 20 // 5MiB
+
+	// MaxFirehoseRecordSize is the firehose limit of the size of a single record
+	MaxFirehoseRecordSize = 1000 << 10 // 1000KiB
+	// MaxFirehoseBatchSize is the firehose limit of the total size of a PutRecordBatch request
+	MaxFirehoseBatchSize = 4 << 20 // 4MiB
+
+	// compressionSampleSize is the leading bytes of a record that are compressed
+	// first to check the min compression ratio of records larger than it.
+	compressionSampleSize = 4 << 10
 )
 
 var (
 	// ErrPartitionKeyLength is used when the given key exceeds the allowed kinesis limit of 256 characters
 	ErrPartitionKeyLength = errors.New("partition key size is greater than 256 characters")
-	// ErrRecordLength is used when attempted record results in a byte array greater than 1MiB
-	ErrRecordLength = consumererror.NewPermanent(errors.New("record size is greater than 1 MiB"))
+	// ErrRecordLength is used when attempted record results in a byte array greater than
+	// the max record size, which is the kinesis limit of 1MiB unless lowered
+	ErrRecordLength = consumererror.NewPermanent(errors.New("record size is greater than the max record size"))
 )
 
 type Batch struct {
 	maxBatchSize  int
+	maxBatchBytes int
 	maxRecordSize int
 
+	compression   compress.Compressor
+	markerByte    bool
+	checksums     *Checksums
+	sampler       *Sampler
+	partitioner   Partitioner
+	hashKeySource string
+	shardHasher   *ShardHasher
+	keyLimiter    *KeyLimiter
+	// compressMinSize is the smallest record that is compressed
+	compressMinSize int
+	// compressMinRatio is the ratio of the uncompressed to the compressed bytes
+	// below which records are written uncompressed, it is not checked when zero
+	compressMinRatio float64
+	// salt is appended to each partition key to change the shards the keys are mapped to
+	salt string
+	// rawHeader is prepended to raw bodies written by the passthrough encoder
+	rawHeader []byte
+	// recordID prepends the deterministic ID of each record to its data
+	recordID bool
+
+	// sampledOut is the number of records dropped by the sampler
+	sampledOut int
+	// validate drops the invalid records, which are counted by invalid and logged to log
+	validate bool
+	invalid  int
+	log      *zap.Logger
+	// uncompressedBytes and compressedBytes are the sizes of the
+	// compressed records before and after they were compressed
+	uncompressedBytes, compressedBytes int
+
+	// stream is the stream the records are written to, the stream
+	// of the Batcher is used when unset.
+	stream string
+	// routes are the batches of the records written to other streams
+	routes []*Batch
+
 	records []*kinesis.PutRecordsRequestEntry
+	// size is the data and partition key bytes of the records,
+	// including the aggregated records that are still accepting data.
+	size int
+
+	aggregate bool
+	// aggregators are the aggregated records that are still accepting data
+	// for each partition and hash key, keys are kept in the order they were first seen.
+	aggregators map[string]*aggregator
+	keys        []string
+
+	// lengthPrefixed aggregates the records by prefixing each with its length
+	// instead of using the kinesis producer library format.
+	lengthPrefixed bool
+
+	batchCompression bool
+	// frame holds the records that are compressed together as a single record
+	frame *batchFrame
 }
 
 type Option func(bt *Batch)
 
+// WithMaxRecordsPerBatch limits the number of records within each chunk,
+// limits above the kinesis limit are clamped and limits below one are ignored.
 func WithMaxRecordsPerBatch(limit int) Option {
 	return func(bt *Batch) {
+		if limit < 1 {
+			return
+		}
 		if MaxBatchedRecords < limit {
 			limit = MaxBatchedRecords
 		}
@@ -54,6 +133,21 @@ func WithMaxRecordsPerBatch(limit int) Option {
 	}
 }
 
+// WithMaxBatchBytes limits the total size of the records, including their
+// partition keys, within each chunk. Sizes above the kinesis limit are clamped
+// and sizes below one are ignored.
+func WithMaxBatchBytes(size int) Option {
+	return func(bt *Batch) {
+		if size < 1 {
+			return
+		}
+		if MaxBatchSize < size {
+			size = MaxBatchSize
+		}
+		bt.maxBatchBytes = size
+	}
+}
+
 func WithMaxRecordSize(size int) Option {
 	return func(bt *Batch) {
 		if MaxRecordSize < size {
@@ -63,11 +157,145 @@ func WithMaxRecordSize(size int) Option {
 	}
 }
 
+// WithCompression sets the compressor that is applied to each record
+// before it is added to the batch. When the compressor is not a no-op,
+// the partition key of each record is prefixed with the compression type
+// followed by a colon (for example "gzip:<key>") so consumers can
+// detect that the data needs to be decompressed. A compress.Selector
+// marks each record with the format it selected for the record.
+func WithCompression(compressor compress.Compressor) Option {
+	return func(bt *Batch) {
+		if compressor != nil {
+			bt.compression = compressor
+		}
+	}
+}
+
+// WithCompressionMinSize only compresses the records of at least size bytes,
+// smaller records are added uncompressed and are marked as uncompressed.
+func WithCompressionMinSize(size int) Option {
+	return func(bt *Batch) {
+		bt.compressMinSize = size
+	}
+}
+
+// WithCompressionMinRatio writes the records uncompressed when compressing them,
+// or a sample of the larger records, does not divide their size by at least the ratio,
+// such as records that hold data which has already been compressed.
+func WithCompressionMinRatio(ratio float64) Option {
+	return func(bt *Batch) {
+		bt.compressMinRatio = ratio
+	}
+}
+
+// WithCompressionMarkerByte marks the compression of each record by prepending
+// the byte returned by compress.Marker to its data instead of prefixing its
+// partition key, records that are not compressed are marked as well.
+func WithCompressionMarkerByte() Option {
+	return func(bt *Batch) {
+		bt.markerByte = true
+	}
+}
+
+// WithChecksums appends a checksum record to each chunk that holds the
+// SHA-256 hash of the data of the other records of the chunk, numbered by the
+// checksums. Space for the checksum record is reserved within each chunk.
+func WithChecksums(checksums *Checksums) Option {
+	return func(bt *Batch) {
+		bt.checksums = checksums
+	}
+}
+
+// WithSampler drops the records whose partition key is not kept by the sampler
+// before they are compressed, the dropped records are counted by SampledOut.
+func WithSampler(sampler *Sampler) Option {
+	return func(bt *Batch) {
+		bt.sampler = sampler
+	}
+}
+
+// WithPartitionKeySalt appends the salt to the partition key of each record
+// once the record has been sampled, so that changing the salt moves the keys
+// to other shards without changing which records are sampled.
+// Records with an explicit hash key are not moved by the salt.
+func WithPartitionKeySalt(salt string) Option {
+	return func(bt *Batch) {
+		bt.salt = salt
+	}
+}
+
+// WithRawBodyHeader prepends the attributes, encoded as a JSON object followed
+// by a new line, to the raw bodies written by the passthrough encoder.
+func WithRawBodyHeader(attributes map[string]string) Option {
+	return func(bt *Batch) {
+		if len(attributes) == 0 {
+			return
+		}
+		// A map of strings can not fail to be encoded
+		header, _ := json.Marshal(attributes)
+		bt.rawHeader = append(header, '\n')
+	}
+}
+
+// WithRecordID prepends an ID derived from the partition key and the encoded data
+// to the data of each record, ahead of the compression marker byte, so that consumers
+// can drop the duplicates of records written again on retry. The ID is not added to
+// aggregated records.
+func WithRecordID() Option {
+	return func(bt *Batch) {
+		bt.recordID = true
+	}
+}
+
+// WithPartitioner sets the partitioner that encoders use to derive
+// the partition key of records created from a resource.
+func WithPartitioner(partitioner Partitioner) Option {
+	return func(bt *Batch) {
+		if partitioner != nil {
+			bt.partitioner = partitioner
+		}
+	}
+}
+
+// WithAggregation packs the records that share a partition key into
+// aggregated records using the kinesis producer library format,
+// which allows consumers using the kinesis client library to de-aggregate them.
+func WithAggregation() Option {
+	return func(bt *Batch) {
+		bt.aggregate = true
+		bt.aggregators = make(map[string]*aggregator)
+	}
+}
+
+// WithLengthPrefixedAggregation changes the format of the aggregated records
+// to the data of each record preceded by its length as a big-endian uint64,
+// for consumers that do not use the kinesis client library.
+// Records are only aggregated with WithAggregation.
+func WithLengthPrefixedAggregation() Option {
+	return func(bt *Batch) {
+		bt.lengthPrefixed = true
+	}
+}
+
+// WithBatchCompression packs the records of the batch into a single aggregated record,
+// using the kinesis producer library format, that is compressed as a whole instead
+// of compressing each record. Once compressed records that exceed the record size
+// limit are split into multiple records.
+func WithBatchCompression() Option {
+	return func(bt *Batch) {
+		bt.batchCompression = true
+	}
+}
+
 func New(opts ...Option) *Batch {
+	noop, _ := compress.NewCompressor(compress.None, 0)
 	bt := &Batch{
 		maxBatchSize:  MaxBatchedRecords,
+		maxBatchBytes: MaxBatchSize,
 		maxRecordSize: MaxRecordSize,
-		records:       make([]*kinesis.PutRecordsRequestEntry, 0, MaxRecordSize),
+		compression:   noop,
+		partitioner:   NewRandomPartitioner(),
+		records:       make([]*kinesis.PutRecordsRequestEntry, 0, MaxBatchedRecords),
 	}
 
 	for _, op := range opts {
@@ -77,19 +305,166 @@ func New(opts ...Option) *Batch {
 	return bt
 }
 
-func (b *Batch) addRaw(raw []byte, key string) error {
-	if l := len(key); l == 0 || l > 256 {
+// Reset removes the records of the batch, including the routed batches and the
+// aggregated records, so that the batch can be reused with the same options.
+// The records of the batch must no longer be used once it is reset.
+func (b *Batch) Reset() {
+	for i := range b.records {
+		b.records[i] = nil
+	}
+	b.records = b.records[:0]
+	b.size = 0
+	b.sampledOut = 0
+	b.invalid = 0
+	b.uncompressedBytes, b.compressedBytes = 0, 0
+	b.stream = ""
+	b.routes = nil
+	for id := range b.aggregators {
+		delete(b.aggregators, id)
+	}
+	b.keys = b.keys[:0]
+	b.frame = nil
+}
+
+// AddRecord appends the already encoded data as a record using the provided
+// partition key, validating it against the kinesis record limits.
+// The record size limit is checked after compression has been applied.
+func (b *Batch) AddRecord(raw []byte, key string) error {
+	return b.AddRecordWithHashKey(raw, key, "")
+}
+
+// AddRecordWithHashKey appends the record like AddRecord and sets the
+// explicit hash key used by kinesis to select the shard instead of
+// the hash of the partition key, an empty hash key is not set.
+func (b *Batch) AddRecordWithHashKey(raw []byte, key, hashKey string) error {
+	if l := len(key); l == 0 || l > MaxPartitionKeyLength {
 		return ErrPartitionKeyLength
 	}
+	if b.validate && len(raw) == 0 {
+		b.dropInvalid(key, "empty payload")
+		return nil
+	}
+	if cp, ok := b.partitioner.(ContentPartitioner); ok {
+		key = cp.PartitionContent(raw)
+	}
+	if b.keyLimiter != nil {
+		key = b.keyLimiter.Limit(key)
+	}
+	if b.sampler != nil && !b.sampler.Sample(key) {
+		b.sampledOut++
+		return nil
+	}
+	if hashKey == "" && b.shardHasher != nil {
+		hashKey = b.shardHasher.HashKey(key)
+	}
+	if b.batchCompression {
+		return b.addFramed(raw, key)
+	}
+
+	var id []byte
+	if b.recordID {
+		id = recordID(key, raw)
+	}
+
+	record, compression := raw, compress.None
+	if len(raw) >= b.compressMinSize {
+		var err error
+		if record, compression, err = b.compress(raw); err != nil {
+			return err
+		}
+		b.compressed(raw, record)
+	}
+
+	record, key = b.mark(record, key, compression)
+	if b.aggregate {
+		return b.addAggregated(record, key, hashKey)
+	}
+	if id != nil {
+		record = append(id[:len(id):len(id)], record...)
+	}
 
-	if len(raw) > b.maxRecordSize {
-		return ErrRecordLength
+	// The partition key counts towards the record size limit
+	if size := len(record) + len(key); size > b.maxRecordSize {
+		return b.errRecordLength(size)
 	}
 
-	b.records = append(b.records, &kinesis.PutRecordsRequestEntry{Data: raw, PartitionKey: aws.String(key)})
+	b.records = append(b.records, newEntry(record, key, hashKey))
+	b.size += len(record) + len(key)
 	return nil
 }
 
+// mark adds the compression marker, as the leading byte of the record or as the
+// prefix of the partition key, and appends the salt to the partition key.
+func (b *Batch) mark(record []byte, key, compression string) ([]byte, string) {
+	var prefix string
+	if b.markerByte {
+		marker, _ := compress.Marker(compression)
+		record = append([]byte{marker}, record...)
+	} else if compression != compress.None {
+		prefix = compression + ":"
+	}
+	if prefix != "" || b.salt != "" {
+		// Trimming the key to allow for the prefix and salt to be added
+		// without exceeding the partition key limit.
+		if l := MaxPartitionKeyLength - len(prefix) - len(b.salt); len(key) > l {
+			key = truncateKey(key, l)
+		}
+		key = prefix + key + b.salt
+	}
+	return record, key
+}
+
+// errRecordLength wraps ErrRecordLength with the size of the record and the limit it exceeded.
+func (b *Batch) errRecordLength(size int) error {
+	return fmt.Errorf("%w: %d bytes exceeds %d bytes", ErrRecordLength, size, b.maxRecordSize)
+}
+
+func newEntry(data []byte, key, hashKey string) *kinesis.PutRecordsRequestEntry {
+	entry := &kinesis.PutRecordsRequestEntry{Data: data, PartitionKey: aws.String(key)}
+	if hashKey != "" {
+		entry.ExplicitHashKey = aws.String(hashKey)
+	}
+	return entry
+}
+
+// addAggregated adds the data to the aggregated record of the keys,
+// once the aggregated record is full it is added to the batch records.
+// The record size limit is checked against the aggregated record.
+func (b *Batch) addAggregated(data []byte, key, hashKey string) error {
+	if size := b.aggregatedSize(data, key, hashKey); size > b.maxRecordSize {
+		return b.errRecordLength(size)
+	}
+
+	id := key + "\x00" + hashKey
+	agg, ok := b.aggregators[id]
+	switch {
+	case !ok:
+		b.keys = append(b.keys, id)
+		agg = newAggregator(key, hashKey, b.lengthPrefixed)
+		b.aggregators[id] = agg
+		b.size += agg.encodedSize()
+	case agg.frameSize(data)+len(key) > b.maxRecordSize:
+		b.records = append(b.records, agg.entry())
+		agg = newAggregator(key, hashKey, b.lengthPrefixed)
+		b.aggregators[id] = agg
+		b.size += agg.encodedSize()
+	}
+	agg.add(data)
+	b.size += agg.entrySize(data)
+	return nil
+}
+
+// aggregatedSize returns the size of an aggregated record that only holds the data.
+func (b *Batch) aggregatedSize(data []byte, key, hashKey string) int {
+	return newAggregator(key, hashKey, b.lengthPrefixed).frameSize(data) + len(key)
+}
+
+// PartitionKey returns the partition key for records
+// created from the provided resource.
+func (b *Batch) PartitionKey(resource pdata.Resource) string {
+	return b.partitioner.Partition(resource)
+}
+
 // AddProtobufV1 allows for deprecated protobuf generated types to be exported
 // and marshaled into records to be exported to kinesis.
 // The protobuf v1 message is considered deprecated so where possible to use
@@ -99,7 +474,7 @@ func (b *Batch) AddProtobufV1(message protov1.Message, key string) error {
 	if err != nil {
 		return err
 	}
-	return b.addRaw(data, key)
+	return b.AddRecord(data, key)
 }
 
 // AddProtobufV2 accepts the protobuf message and marshal it into a record
@@ -109,23 +484,274 @@ func (b *Batch) AddProtobufV2(message protov2.Message, key string) error {
 	if err != nil {
 		return err
 	}
-	return b.addRaw(data, key)
+	return b.AddRecord(data, key)
+}
+
+// Len returns the number of records within the batch, including the routed
+// batches, aggregated records and the batch compressed record count as a single record.
+func (b *Batch) Len() int {
+	n := len(b.records) + len(b.keys)
+	if b.frame != nil {
+		n++
+	}
+	for _, r := range b.routes {
+		n += r.Len()
+	}
+	return n
+}
+
+// ByteSize returns the data and partition key bytes of the records within
+// the batch, including the routed batches, which count towards the limits
+// of each request. The size is kept as records are added, the batch compressed
+// record that is still accepting data counts with its uncompressed size.
+func (b *Batch) ByteSize() int {
+	n := b.size
+	if b.frame != nil {
+		n += len(AggregateMagic) + b.frame.size + md5.Size
+	}
+	for _, r := range b.routes {
+		n += r.ByteSize()
+	}
+	return n
+}
+
+// Stream returns the stream that the records of the batch are written to,
+// an empty stream is the stream of the Batcher.
+func (b *Batch) Stream() string {
+	return b.stream
+}
+
+// Routes returns the batch followed by the batches of the records
+// that are written to other streams, each with its own Stream.
+func (b *Batch) Routes() []*Batch {
+	return append([]*Batch{b}, b.routes...)
+}
+
+// route returns the batch of the records written to the stream, nil is
+// returned when none of the routed batches are written to the stream.
+func (b *Batch) route(stream string) *Batch {
+	for _, r := range b.Routes() {
+		if r.stream == stream {
+			return r
+		}
+	}
+	return nil
+}
+
+// Merge appends the records of the other batch to this batch, records written
+// to other streams are appended to the routed batch of their stream.
+// The data of aggregated records that are still accepting data is
+// aggregated again using the limits of this batch. None of the records
+// are merged when any of them exceeds the limits of this batch, so that
+// the other batch can be retried without duplicating its records.
+func (b *Batch) Merge(other *Batch) error {
+	if err := b.checkMerge(other); err != nil {
+		return err
+	}
+	var errs error
+	for _, r := range other.Routes() {
+		target := b.route(r.stream)
+		if target == nil {
+			routed := *r
+			routed.routes = nil
+			b.routes = append(b.routes, &routed)
+			continue
+		}
+		errs = multierr.Append(errs, target.merge(r))
+	}
+	return errs
+}
+
+// checkMerge returns the errors of the records of the other batch
+// that are added again by merge and exceed the limits of their batch.
+func (b *Batch) checkMerge(other *Batch) error {
+	var errs error
+	for _, r := range other.Routes() {
+		target := b.route(r.stream)
+		if target == nil {
+			continue
+		}
+		if target.aggregate {
+			for _, id := range r.keys {
+				agg := r.aggregators[id]
+				for _, data := range agg.data {
+					if size := target.aggregatedSize(data, agg.key, agg.hashKey); size > target.maxRecordSize {
+						errs = multierr.Append(errs, target.errRecordLength(size))
+					}
+				}
+			}
+		}
+		if r.frame != nil && target.batchCompression {
+			for _, fr := range r.frame.records {
+				if size := target.framedSize(fr.data, r.frame.keys[fr.key]); size > target.maxRecordSize {
+					errs = multierr.Append(errs, target.errRecordLength(size))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+func (b *Batch) merge(other *Batch) error {
+	b.records = append(b.records, other.records...)
+	b.size += other.size
+	b.sampledOut += other.sampledOut
+	b.invalid += other.invalid
+	uncompressed, compressed := other.uncompressedBytes, other.compressedBytes
+	b.uncompressedBytes += uncompressed
+	b.compressedBytes += compressed
+
+	var errs error
+	for _, id := range other.keys {
+		agg := other.aggregators[id]
+		// The open aggregated records are counted again once added
+		b.size -= agg.encodedSize()
+		if !b.aggregate {
+			b.records = append(b.records, agg.entry())
+			b.size += agg.encodedSize()
+			continue
+		}
+		for _, data := range agg.data {
+			errs = multierr.Append(errs, b.addAggregated(data, agg.key, agg.hashKey))
+		}
+	}
+	if other.frame != nil {
+		if !b.batchCompression {
+			entries := other.frameEntries(other.frame)
+			// The frame is compressed into entries by the other batch
+			b.uncompressedBytes += other.uncompressedBytes - uncompressed
+			b.compressedBytes += other.compressedBytes - compressed
+			b.records = append(b.records, entries...)
+			b.size += entriesSize(entries)
+			return errs
+		}
+		for _, r := range other.frame.records {
+			errs = multierr.Append(errs, b.addFramed(r.data, other.frame.keys[r.key]))
+		}
+	}
+	return errs
+}
+
+// SampledOut returns the number of records that were dropped by the sampler,
+// including the records of the routed batches.
+func (b *Batch) SampledOut() int {
+	n := b.sampledOut
+	for _, r := range b.routes {
+		n += r.sampledOut
+	}
+	return n
+}
+
+// CompressedBytes returns the size of the compressed records before and after
+// they were compressed, including the records of the routed batches.
+// Records are only counted when compression is enabled and they are at least
+// the compression min size, frames of the batch compression are counted once chunked.
+func (b *Batch) CompressedBytes() (uncompressed, compressed int) {
+	for _, r := range b.Routes() {
+		uncompressed += r.uncompressedBytes
+		compressed += r.compressedBytes
+	}
+	return uncompressed, compressed
+}
+
+// compressed counts the size of the record before and after compression.
+// compress compresses the record unless it is found to be incompressible by
+// the min ratio, the records larger than compressionSampleSize are only
+// compressed once their leading bytes compress by at least the min ratio.
+func (b *Batch) compress(raw []byte) ([]byte, string, error) {
+	if b.compressMinRatio <= 0 || b.compression.Type() == compress.None {
+		return compress.Compress(b.compression, raw)
+	}
+	if len(raw) > compressionSampleSize {
+		sample := raw[:compressionSampleSize]
+		out, _, err := compress.Compress(b.compression, sample)
+		if err != nil {
+			return nil, "", err
+		}
+		if !b.compressible(sample, out) {
+			return raw, compress.None, nil
+		}
+	}
+	out, compression, err := compress.Compress(b.compression, raw)
+	if err != nil {
+		return nil, "", err
+	}
+	if !b.compressible(raw, out) {
+		return raw, compress.None, nil
+	}
+	return out, compression, nil
+}
+
+// compressible reports if the data compressed by at least the min ratio.
+func (b *Batch) compressible(in, out []byte) bool {
+	return float64(len(in)) >= b.compressMinRatio*float64(len(out))
+}
+
+func (b *Batch) compressed(raw, record []byte) {
+	if b.compression.Type() != compress.None {
+		b.uncompressedBytes += len(raw)
+		b.compressedBytes += len(record)
+	}
 }
 
 // Chunk breaks up the iternal queue into blocks that can be used
-// to be written to he kinesis.PutRecords endpoint
+// to be written to he kinesis.PutRecords endpoint, the records of
+// the routed batches are chunked by their own batch.
 func (b *Batch) Chunk() (chunks [][]*kinesis.PutRecordsRequestEntry) {
 	// Using local copies to avoid mutating internal data
 	var (
 		slice = b.records
 		size  = b.maxBatchSize
+		bytes = b.maxBatchBytes
 	)
+	if b.aggregate {
+		slice = b.withOpenAggregates()
+	}
+	if b.frame != nil {
+		slice = append(slice[:len(slice):len(slice)], b.frameEntries(b.frame)...)
+	}
+	if b.checksums != nil {
+		// Reserving space for the checksum record of each chunk
+		if size > 1 {
+			size--
+		}
+		bytes -= checksumReserve
+	}
 	for len(slice) != 0 {
 		if len(slice) < size {
 			size = len(slice)
 		}
-		chunks = append(chunks, slice[0:size])
-		slice = slice[size:]
+		// Whichever limit is reached first closes the chunk
+		n := fitBytes(slice, size, bytes)
+		chunk := slice[0:n]
+		if b.checksums != nil {
+			chunk = append(chunk[:n:n], b.checksums.record(chunk, b.markerByte))
+		}
+		chunks = append(chunks, chunk)
+		slice = slice[n:]
 	}
 	return chunks
 }
+
+// withOpenAggregates returns the batch records followed by the
+// aggregated records that are still accepting data.
+func (b *Batch) withOpenAggregates() []*kinesis.PutRecordsRequestEntry {
+	records := b.records[:len(b.records):len(b.records)]
+	for _, id := range b.keys {
+		records = append(records, b.aggregators[id].entry())
+	}
+	return records
+}
+
+// fitBytes returns the number of records, up to limit, that fit
+// within the max bytes while always including at least one record.
+func fitBytes(records []*kinesis.PutRecordsRequestEntry, limit, maxBytes int) int {
+	total := 0
+	for i := 0; i < limit; i++ {
+		total += len(records[i].Data) + len(aws.StringValue(records[i].PartitionKey))
+		if total > maxBytes && i > 0 {
+			return i
+		}
+	}
+	return limit
+}
@@ -15,12 +15,18 @@
 package batch_test
 
 import (
+	"bytes"
+	"math/rand"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/golang/protobuf/ptypes/empty"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/compress"
 )
 
 func TestBatchingMessages(t *testing.T) {
@@ -51,6 +57,286 @@ func TestCustomBatchSizeConstraints(t *testing.T) {
 	assert.Len(t, b.Chunk(), records, "Must have one batch per record added")
 }
 
+func TestMaxRecordsPerBatchBoundaries(t *testing.T) {
+	t.Parallel()
+
+	const records = 1001
+	for _, tc := range []struct {
+		limit  int
+		chunks int
+	}{
+		{limit: 1, chunks: records},
+		{limit: 500, chunks: 3},
+		{limit: 501, chunks: 3},
+		{limit: 0, chunks: 3},
+	} {
+		b := batch.New(batch.WithMaxRecordsPerBatch(tc.limit))
+		for i := 0; i < records; i++ {
+			require.NoError(t, b.AddRecord([]byte("data"), "fixed-string"))
+		}
+
+		chunks := b.Chunk()
+		assert.Len(t, chunks, tc.chunks, "Must have split the records by the limit %d", tc.limit)
+		for _, chunk := range chunks {
+			assert.LessOrEqual(t, len(chunk), batch.MaxBatchedRecords, "Must not exceed the kinesis limit")
+		}
+	}
+}
+
+func TestChunkByteLimit(t *testing.T) {
+	t.Parallel()
+
+	b := batch.New(
+		batch.WithMaxBatchBytes(batch.MaxFirehoseBatchSize),
+	)
+	record := bytes.Repeat([]byte("a"), 500<<10)
+	for i := 0; i < 20; i++ {
+		require.NoError(t, b.AddRecord(record, "fixed-string"), "Must not error when adding elements into the batch")
+	}
+
+	chunks := b.Chunk()
+	require.Len(t, chunks, 3, "Must have split the batch by the byte limit")
+	for _, chunk := range chunks {
+		total := 0
+		for _, r := range chunk {
+			total += len(r.Data) + len(*r.PartitionKey)
+		}
+		assert.LessOrEqual(t, total, batch.MaxFirehoseBatchSize, "Must not exceed the byte limit")
+	}
+}
+
+func TestChunkPutRecordsSizeLimit(t *testing.T) {
+	t.Parallel()
+
+	b := batch.New()
+	key := strings.Repeat("k", batch.MaxPartitionKeyLength)
+	for i := 0; i < 300; i++ {
+		require.NoError(t, b.AddRecord(bytes.Repeat([]byte("d"), 50<<10), key))
+	}
+
+	chunks := b.Chunk()
+	require.Greater(t, len(chunks), 1, "Must have split the records by size")
+
+	var records int
+	for _, chunk := range chunks {
+		var size int
+		for _, record := range chunk {
+			size += len(record.Data) + len(*record.PartitionKey)
+		}
+		assert.LessOrEqual(t, size, batch.MaxBatchSize, "Must not exceed the PutRecords size limit")
+		records += len(chunk)
+	}
+	assert.Equal(t, 300, records, "Must have kept every record")
+
+	assert.ErrorIs(t, b.AddRecord(bytes.Repeat([]byte("d"), batch.MaxRecordSize-10), key), batch.ErrRecordLength,
+		"Must count the partition key towards the record size limit")
+}
+
+func TestCompressedRecords(t *testing.T) {
+	t.Parallel()
+
+	c, err := compress.NewCompressor(compress.Gzip, 0)
+	require.NoError(t, err, "Must have a valid compressor")
+
+	data := bytes.Repeat([]byte("highly compressible payload "), 50000)
+	require.Greater(t, len(data), batch.MaxRecordSize, "Must start with a payload beyond the record limit")
+
+	b := batch.New(batch.WithCompression(c))
+	require.NoError(t, b.AddRecord(data, "fixed-string"), "Must check the record size after compression")
+
+	chunks := b.Chunk()
+	require.Len(t, chunks, 1, "Must have exactly one chunk")
+	require.Len(t, chunks[0], 1, "Must have exactly one record")
+
+	record := chunks[0][0]
+	assert.Less(t, len(record.Data), len(data), "Must have stored the compressed data")
+	assert.Equal(t, "gzip:fixed-string", *record.PartitionKey, "Must have marked the record as compressed")
+}
+
+func TestCompressionMarkerByte(t *testing.T) {
+	t.Parallel()
+
+	data := bytes.Repeat([]byte("highly compressible payload "), 100)
+	for _, format := range []string{compress.None, compress.Gzip, compress.Zstd, compress.Snappy} {
+		c, err := compress.NewCompressor(format, 0)
+		require.NoError(t, err, "Must have a valid compressor")
+		marker, ok := compress.Marker(format)
+		require.True(t, ok, "Must have a marker for %s", format)
+
+		b := batch.New(batch.WithCompression(c), batch.WithCompressionMarkerByte())
+		require.NoError(t, b.AddRecord(data, "fixed-string"))
+
+		chunks := b.Chunk()
+		require.Len(t, chunks, 1, "Must have exactly one chunk")
+		require.Len(t, chunks[0], 1, "Must have exactly one record")
+
+		record := chunks[0][0]
+		assert.Equal(t, marker, record.Data[0], "Must have marked the record with the %s byte", format)
+		assert.Equal(t, "fixed-string", *record.PartitionKey, "Must not prefix the partition key")
+
+		compressed, err := c.Do(data)
+		require.NoError(t, err)
+		assert.Equal(t, compressed, record.Data[1:], "Must have compressed the record after the marker")
+	}
+
+	b := batch.New(batch.WithMaxRecordSize(100), batch.WithCompressionMarkerByte())
+	assert.ErrorIs(t, b.AddRecord(bytes.Repeat([]byte("d"), 100-len("key")), "key"), batch.ErrRecordLength,
+		"Must count the marker byte towards the record size limit")
+	assert.NoError(t, b.AddRecord(bytes.Repeat([]byte("d"), 99-len("key")), "key"))
+}
+
+func TestCompressionMinSize(t *testing.T) {
+	t.Parallel()
+
+	c, err := compress.NewCompressor(compress.Gzip, 0)
+	require.NoError(t, err, "Must have a valid compressor")
+
+	small := []byte("small")
+	large := bytes.Repeat([]byte("highly compressible payload "), 100)
+	for _, markerByte := range []bool{false, true} {
+		opts := []batch.Option{batch.WithCompression(c), batch.WithCompressionMinSize(1024)}
+		if markerByte {
+			opts = append(opts, batch.WithCompressionMarkerByte())
+		}
+		b := batch.New(opts...)
+		require.NoError(t, b.AddRecord(small, "small-key"))
+		require.NoError(t, b.AddRecord(large, "large-key"))
+
+		chunks := b.Chunk()
+		require.Len(t, chunks, 1, "Must have exactly one chunk")
+		require.Len(t, chunks[0], 2, "Must have both records")
+
+		compressed, err := c.Do(large)
+		require.NoError(t, err)
+		smallRecord, largeRecord := chunks[0][0], chunks[0][1]
+		if markerByte {
+			none, _ := compress.Marker(compress.None)
+			gzip, _ := compress.Marker(compress.Gzip)
+			assert.Equal(t, append([]byte{none}, small...), smallRecord.Data, "Must not compress the record below the min size")
+			assert.Equal(t, append([]byte{gzip}, compressed...), largeRecord.Data, "Must compress the record above the min size")
+			assert.Equal(t, "small-key", *smallRecord.PartitionKey)
+			assert.Equal(t, "large-key", *largeRecord.PartitionKey)
+		} else {
+			assert.Equal(t, small, smallRecord.Data, "Must not compress the record below the min size")
+			assert.Equal(t, compressed, largeRecord.Data, "Must compress the record above the min size")
+			assert.Equal(t, "small-key", *smallRecord.PartitionKey, "Must not mark the uncompressed record")
+			assert.Equal(t, "gzip:large-key", *largeRecord.PartitionKey, "Must mark the compressed record")
+		}
+	}
+}
+
+func TestCompressionMinRatio(t *testing.T) {
+	t.Parallel()
+
+	c, err := compress.NewCompressor(compress.Gzip, 0)
+	require.NoError(t, err, "Must have a valid compressor")
+
+	random := func(size int) []byte {
+		data := make([]byte, size)
+		rand.New(rand.NewSource(int64(size))).Read(data)
+		return data
+	}
+	compressible := bytes.Repeat([]byte("highly compressible payload "), 1000)
+	// Only the leading bytes of the larger records are sampled
+	sampled := append(random(4<<10), compressible...)
+
+	b := batch.New(
+		batch.WithCompression(c),
+		batch.WithCompressionMinSize(0),
+		batch.WithCompressionMinRatio(1.1),
+		batch.WithCompressionMarkerByte(),
+	)
+	records := [][]byte{random(1 << 10), random(64 << 10), sampled, compressible}
+	for _, data := range records {
+		require.NoError(t, b.AddRecord(data, "key"))
+	}
+
+	chunks := b.Chunk()
+	require.Len(t, chunks, 1, "Must have exactly one chunk")
+	require.Len(t, chunks[0], len(records), "Must have every record")
+
+	none, _ := compress.Marker(compress.None)
+	gzip, _ := compress.Marker(compress.Gzip)
+	for i, data := range records[:3] {
+		assert.Equal(t, append([]byte{none}, data...), chunks[0][i].Data, "Must write the incompressible record %d uncompressed", i)
+	}
+	compressed, err := c.Do(compressible)
+	require.NoError(t, err)
+	assert.Equal(t, append([]byte{gzip}, compressed...), chunks[0][3].Data, "Must compress the record above the min ratio")
+}
+
+func TestAutoCompressionMarker(t *testing.T) {
+	t.Parallel()
+
+	c, err := compress.NewCompressor(compress.Auto, 0, compress.WithCandidates(compress.Gzip, compress.Zstd, compress.Snappy))
+	require.NoError(t, err, "Must have a valid compressor")
+
+	payloads := map[string][]byte{
+		compress.Zstd:   bytes.Repeat([]byte("hello world "), 100),
+		compress.Snappy: []byte("hello hello hello world"),
+	}
+	for _, markerByte := range []bool{false, true} {
+		opts := []batch.Option{batch.WithCompression(c)}
+		if markerByte {
+			opts = append(opts, batch.WithCompressionMarkerByte())
+		}
+		b := batch.New(opts...)
+		for _, format := range []string{compress.Zstd, compress.Snappy} {
+			require.NoError(t, b.AddRecord(payloads[format], "fixed-string"))
+		}
+
+		chunks := b.Chunk()
+		require.Len(t, chunks, 1, "Must have exactly one chunk")
+		require.Len(t, chunks[0], 2, "Must have a record per payload")
+		for i, format := range []string{compress.Zstd, compress.Snappy} {
+			record := chunks[0][i]
+			if markerByte {
+				marker, _ := compress.Marker(format)
+				assert.Equal(t, marker, record.Data[0], "Must have marked the record with the %s byte", format)
+				continue
+			}
+			assert.Equal(t, format+":fixed-string", *record.PartitionKey, "Must have marked the record with %s", format)
+		}
+	}
+}
+
+func TestPartitionKeySalt(t *testing.T) {
+	t.Parallel()
+
+	keys := make(map[string]string)
+	for _, salt := range []string{"", "-salt-1", "-salt-2"} {
+		b := batch.New(batch.WithPartitionKeySalt(salt))
+		require.NoError(t, b.AddRecord([]byte("data"), "service-a"))
+
+		chunks := b.Chunk()
+		require.Len(t, chunks, 1, "Must have exactly one chunk")
+		require.Len(t, chunks[0], 1, "Must have exactly one record")
+		keys[salt] = *chunks[0][0].PartitionKey
+	}
+	assert.Equal(t, "service-a", keys[""], "Must not change the partition key without a salt")
+	assert.Equal(t, "service-a-salt-1", keys["-salt-1"], "Must have appended the salt")
+	assert.NotEqual(t, keys["-salt-1"], keys["-salt-2"], "Must use a different partition key for each salt")
+
+	c, err := compress.NewCompressor(compress.Gzip, 0)
+	require.NoError(t, err, "Must have a valid compressor")
+	b := batch.New(batch.WithCompression(c), batch.WithPartitionKeySalt("-salt"))
+	require.NoError(t, b.AddRecord([]byte("data"), strings.Repeat("k", batch.MaxPartitionKeyLength)))
+
+	key := *b.Chunk()[0][0].PartitionKey
+	assert.Len(t, key, batch.MaxPartitionKeyLength, "Must not exceed the partition key limit")
+	assert.True(t, strings.HasPrefix(key, "gzip:"), "Must keep the compression prefix")
+	assert.True(t, strings.HasSuffix(key, "-salt"), "Must keep the salt when trimming the key")
+
+	// The prefix and salt leave room for 246 bytes of the key,
+	// which falls in the middle of the trailing two byte character.
+	b = batch.New(batch.WithCompression(c), batch.WithPartitionKeySalt("-salt"))
+	require.NoError(t, b.AddRecord([]byte("data"), strings.Repeat("k", 245)+"é"))
+
+	key = *b.Chunk()[0][0].PartitionKey
+	assert.Equal(t, "gzip:"+strings.Repeat("k", 245)+"-salt", key, "Must not split a multi-byte character when trimming the key")
+	assert.True(t, utf8.ValidString(key), "Must keep the key valid utf-8")
+}
+
 func BenchmarkChunkingRecords(b *testing.B) {
 	bt := batch.New()
 	for i := 0; i < 948; i++ {
@@ -63,3 +349,90 @@ func BenchmarkChunkingRecords(b *testing.B) {
 		assert.Len(b, bt.Chunk(), 2, "Must have exactly two chunks")
 	}
 }
+
+func TestBatchByteSize(t *testing.T) {
+	t.Parallel()
+
+	for _, opts := range [][]batch.Option{
+		nil,
+		{batch.WithAggregation()},
+		{batch.WithAggregation(), batch.WithMaxRecordSize(100)},
+	} {
+		bt := batch.New(opts...)
+		assert.Zero(t, bt.Len(), "Must start with no records")
+		assert.Zero(t, bt.ByteSize(), "Must start with no bytes")
+
+		for i := 0; i < 10; i++ {
+			require.NoError(t, bt.AddRecord(bytes.Repeat([]byte("d"), 10+i), "key"))
+			require.NoError(t, bt.AddRecord([]byte("other"), "other-key"))
+		}
+		other := batch.New(opts...)
+		require.NoError(t, other.AddRecord([]byte("merged"), "key"))
+		require.NoError(t, bt.Merge(other))
+
+		var records, size int
+		for _, chunk := range bt.Chunk() {
+			for _, record := range chunk {
+				records++
+				size += len(record.Data) + len(*record.PartitionKey)
+			}
+		}
+		assert.Equal(t, records, bt.Len(), "Must count every record")
+		assert.Equal(t, size, bt.ByteSize(), "Must count the bytes of every record")
+	}
+}
+
+func TestBatchReset(t *testing.T) {
+	t.Parallel()
+
+	for _, opts := range [][]batch.Option{nil, {batch.WithAggregation()}} {
+		bt := batch.New(opts...)
+		for i := 0; i < 10; i++ {
+			require.NoError(t, bt.AddRecord([]byte("data"), "key"))
+		}
+		other := batch.New(opts...)
+		require.NoError(t, other.AddRecord([]byte("data"), "other-key"))
+		require.NoError(t, bt.Merge(other))
+
+		bt.Reset()
+		assert.Zero(t, bt.Len(), "Must have removed every record")
+		assert.Zero(t, bt.ByteSize(), "Must have reset the byte size")
+		assert.Empty(t, bt.Chunk(), "Must not have any chunks")
+		assert.Len(t, bt.Routes(), 1, "Must have removed the routed batches")
+
+		require.NoError(t, bt.AddRecord([]byte("reused"), "other-key"))
+		chunks := bt.Chunk()
+		require.Len(t, chunks, 1, "Must have exactly one chunk")
+		require.Len(t, chunks[0], 1, "Must only have the record added after the reset")
+		assert.Equal(t, len(chunks[0][0].Data)+len("other-key"), bt.ByteSize(), "Must only count the record added after the reset")
+	}
+}
+
+func TestMergeBatches(t *testing.T) {
+	t.Parallel()
+
+	for _, opts := range [][]batch.Option{nil, {batch.WithAggregation()}} {
+		bt, other := batch.New(opts...), batch.New(opts...)
+		require.NoError(t, bt.AddRecord([]byte("first"), "key"))
+		require.NoError(t, other.AddRecord([]byte("second"), "key"))
+		require.NoError(t, other.AddRecord([]byte("third"), "other-key"))
+
+		require.NoError(t, bt.Merge(other), "Must not error when merging batches")
+		if opts == nil {
+			assert.Equal(t, 3, bt.Len(), "Must have appended the records of the other batch")
+		} else {
+			assert.Equal(t, 2, bt.Len(), "Must have aggregated the records of the other batch by key")
+		}
+	}
+
+	bt := batch.New(batch.WithAggregation(), batch.WithMaxRecordSize(100))
+	require.NoError(t, bt.AddRecord([]byte("first"), "key"))
+	other := batch.New(batch.WithAggregation())
+	require.NoError(t, other.AddRecord([]byte("second"), "other-key"))
+	require.NoError(t, other.AddRecord(make([]byte, 200), "large-key"))
+	size := bt.ByteSize()
+
+	assert.ErrorIs(t, bt.Merge(other), batch.ErrRecordLength, "Must error when a record exceeds the limits of the batch")
+	assert.Equal(t, 1, bt.Len(), "Must not have merged any of the records")
+	assert.Equal(t, size, bt.ByteSize(), "Must not have counted any of the records")
+}
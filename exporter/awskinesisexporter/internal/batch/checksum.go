@@ -0,0 +1,81 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/compress"
+)
+
+// ChecksumKeyPrefix prefixes the partition key of checksum records,
+// followed by the sequence of the record.
+const ChecksumKeyPrefix = "checksum:"
+
+// checksumReserve is the number of bytes reserved within each chunk for its
+// checksum record, which is well above the size of the encoded summary.
+const checksumReserve = 256
+
+// Checksums numbers the checksum records that are appended to each chunk,
+// it is shared by every batch of an exporter so that the sequence of each
+// checksum record is unique and consumers can detect missing checksum records.
+type Checksums struct {
+	sequence uint64
+}
+
+// NewChecksums returns checksums that number records starting from 1.
+func NewChecksums() *Checksums {
+	return &Checksums{}
+}
+
+// ChecksumSummary is the data of a checksum record encoded as JSON.
+type ChecksumSummary struct {
+	// Sequence increases by one for each checksum record of the exporter.
+	Sequence uint64 `json:"sequence"`
+	// Records is the number of records within the chunk, excluding the checksum record.
+	Records int `json:"records"`
+	// SHA256 is the hex encoded SHA-256 hash of the concatenated data of the records in order.
+	SHA256 string `json:"sha256"`
+}
+
+// record returns the checksum record covering the records of a chunk.
+func (c *Checksums) record(records []*kinesis.PutRecordsRequestEntry, markerByte bool) *kinesis.PutRecordsRequestEntry {
+	h := sha256.New()
+	for _, r := range records {
+		h.Write(r.Data)
+	}
+	summary := ChecksumSummary{
+		Sequence: atomic.AddUint64(&c.sequence, 1),
+		Records:  len(records),
+		SHA256:   hex.EncodeToString(h.Sum(nil)),
+	}
+	// The summary only holds numbers and a hex string so can not fail to encode
+	data, _ := json.Marshal(summary)
+	if markerByte {
+		marker, _ := compress.Marker(compress.None)
+		data = append([]byte{marker}, data...)
+	}
+	return &kinesis.PutRecordsRequestEntry{
+		Data:         data,
+		PartitionKey: aws.String(ChecksumKeyPrefix + strconv.FormatUint(summary.Sequence, 10)),
+	}
+}
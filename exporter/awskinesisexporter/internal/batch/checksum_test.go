@@ -0,0 +1,86 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+)
+
+func TestChecksumRecords(t *testing.T) {
+	t.Parallel()
+
+	checksums := batch.NewChecksums()
+	bt := batch.New(batch.WithMaxRecordsPerBatch(3), batch.WithChecksums(checksums))
+	for _, data := range []string{"first", "second", "third", "fourth"} {
+		require.NoError(t, bt.AddRecord([]byte(data), "fixed-key"))
+	}
+
+	chunks := bt.Chunk()
+	require.Len(t, chunks, 2, "Must have reserved a record within each chunk for the checksum record")
+	require.Len(t, chunks[0], 3, "Must have appended the checksum record to the first chunk")
+	require.Len(t, chunks[1], 3, "Must have appended the checksum record to the second chunk")
+
+	for i, expect := range []struct {
+		data string
+		n    int
+	}{
+		{data: "firstsecond", n: 2},
+		{data: "thirdfourth", n: 2},
+	} {
+		sum := sha256.Sum256([]byte(expect.data))
+
+		record := chunks[i][len(chunks[i])-1]
+		var summary batch.ChecksumSummary
+		require.NoError(t, json.Unmarshal(record.Data, &summary), "Must have encoded the checksum record as json")
+		assert.Equal(t, batch.ChecksumSummary{
+			Sequence: uint64(i + 1),
+			Records:  expect.n,
+			SHA256:   hex.EncodeToString(sum[:]),
+		}, summary, "Must match the checksum of the chunk")
+		assert.Equal(t, batch.ChecksumKeyPrefix+[]string{"1", "2"}[i], *record.PartitionKey, "Must mark the checksum record by its partition key")
+	}
+
+	other := batch.New(batch.WithChecksums(checksums))
+	require.NoError(t, other.AddRecord([]byte("data"), "fixed-key"))
+	chunks = other.Chunk()
+	require.Len(t, chunks, 1)
+	assert.Equal(t, batch.ChecksumKeyPrefix+"3", *chunks[0][1].PartitionKey, "Must continue the sequence across batches")
+}
+
+func TestChecksumRecordMarkerByte(t *testing.T) {
+	t.Parallel()
+
+	bt := batch.New(batch.WithChecksums(batch.NewChecksums()), batch.WithCompressionMarkerByte())
+	require.NoError(t, bt.AddRecord([]byte("data"), "fixed-key"))
+
+	chunks := bt.Chunk()
+	require.Len(t, chunks, 1)
+	require.Len(t, chunks[0], 2)
+	record := chunks[0][1]
+	assert.Equal(t, byte(0), record.Data[0], "Must mark the checksum record as uncompressed")
+
+	sum := sha256.Sum256(chunks[0][0].Data)
+	var summary batch.ChecksumSummary
+	require.NoError(t, json.Unmarshal(record.Data[1:], &summary))
+	assert.Equal(t, hex.EncodeToString(sum[:]), summary.SHA256, "Must hash the data as it is written")
+}
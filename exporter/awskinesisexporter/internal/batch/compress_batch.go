@@ -0,0 +1,162 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"crypto/md5" //nolint:gosec // md5 is required by the aggregated record format
+
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/compress"
+)
+
+// batchFrame packs the records of every partition key into a single aggregated
+// record, using the aggregated record format of the kinesis producer library,
+// which is compressed as a whole once it is written.
+type batchFrame struct {
+	keys    []string
+	index   map[string]int
+	records []framedRecord
+	// size is the encoded size of the aggregated record message
+	size int
+}
+
+type framedRecord struct {
+	key  int
+	data []byte
+}
+
+func newBatchFrame() *batchFrame {
+	return &batchFrame{index: make(map[string]int)}
+}
+
+// framedSize returns the encoded size of the data once included in the aggregated record
+func framedSize(key int, data []byte) int {
+	record := protowire.SizeTag(recordPartitionKeyIndex) + protowire.SizeVarint(uint64(key)) +
+		protowire.SizeTag(recordData) + protowire.SizeBytes(len(data))
+	return protowire.SizeTag(aggregateRecords) + protowire.SizeBytes(record)
+}
+
+// grow returns the size of the aggregated record message once the data has been added
+func (f *batchFrame) grow(key string, data []byte) int {
+	index, ok := f.index[key]
+	size := f.size
+	if !ok {
+		index = len(f.keys)
+		size += protowire.SizeTag(aggregatePartitionKeyTable) + protowire.SizeBytes(len(key))
+	}
+	return size + framedSize(index, data)
+}
+
+func (f *batchFrame) add(key string, data []byte) {
+	f.size = f.grow(key, data)
+	index, ok := f.index[key]
+	if !ok {
+		index = len(f.keys)
+		f.index[key] = index
+		f.keys = append(f.keys, key)
+	}
+	f.records = append(f.records, framedRecord{key: index, data: data})
+}
+
+// split returns the halves of the records as separate frames
+func (f *batchFrame) split() (first, second *batchFrame) {
+	first, second = newBatchFrame(), newBatchFrame()
+	for i, r := range f.records {
+		half := first
+		if i >= len(f.records)/2 {
+			half = second
+		}
+		half.add(f.keys[r.key], r.data)
+	}
+	return first, second
+}
+
+func (f *batchFrame) encode() []byte {
+	msg := make([]byte, 0, f.size)
+	for _, key := range f.keys {
+		msg = protowire.AppendTag(msg, aggregatePartitionKeyTable, protowire.BytesType)
+		msg = protowire.AppendString(msg, key)
+	}
+	for _, r := range f.records {
+		record := protowire.SizeTag(recordPartitionKeyIndex) + protowire.SizeVarint(uint64(r.key)) +
+			protowire.SizeTag(recordData) + protowire.SizeBytes(len(r.data))
+		msg = protowire.AppendTag(msg, aggregateRecords, protowire.BytesType)
+		msg = protowire.AppendVarint(msg, uint64(record))
+		msg = protowire.AppendTag(msg, recordPartitionKeyIndex, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, uint64(r.key))
+		msg = protowire.AppendTag(msg, recordData, protowire.BytesType)
+		msg = protowire.AppendBytes(msg, r.data)
+	}
+	return frame(msg)
+}
+
+// framedSize returns the size of the data written on its own,
+// as it is when the frame does not fit once compressed.
+func (b *Batch) framedSize(data []byte, key string) int {
+	record, marked := b.mark(data, key, compress.None)
+	return len(record) + len(marked)
+}
+
+// addFramed adds the data to the batch frame, once the frame would exceed
+// the byte limit of a request it is compressed and added to the records.
+func (b *Batch) addFramed(data []byte, key string) error {
+	if size := b.framedSize(data, key); size > b.maxRecordSize {
+		return b.errRecordLength(size)
+	}
+
+	if b.frame == nil {
+		b.frame = newBatchFrame()
+	} else if len(b.frame.records) > 0 && len(AggregateMagic)+b.frame.grow(key, data)+md5.Size > b.maxBatchBytes {
+		entries := b.frameEntries(b.frame)
+		b.records = append(b.records, entries...)
+		b.size += entriesSize(entries)
+		b.frame = newBatchFrame()
+	}
+	b.frame.add(key, data)
+	return nil
+}
+
+// frameEntries returns the compressed frame as a single record using the partition
+// key of its first record, the frame is split in half until each part fits within
+// the record size limit once compressed. A single record that does not fit is
+// written uncompressed.
+func (b *Batch) frameEntries(f *batchFrame) []*kinesis.PutRecordsRequestEntry {
+	if len(f.records) == 0 {
+		return nil
+	}
+	data := f.encode()
+	if out, compression, err := compress.Compress(b.compression, data); err == nil {
+		record, key := b.mark(out, f.keys[0], compression)
+		if len(record)+len(key) <= b.maxRecordSize {
+			b.compressed(data, out)
+			return []*kinesis.PutRecordsRequestEntry{newEntry(record, key, "")}
+		}
+	}
+	if len(f.records) == 1 {
+		record, key := b.mark(f.records[0].data, f.keys[0], compress.None)
+		return []*kinesis.PutRecordsRequestEntry{newEntry(record, key, "")}
+	}
+	first, second := f.split()
+	return append(b.frameEntries(first), b.frameEntries(second)...)
+}
+
+func entriesSize(entries []*kinesis.PutRecordsRequestEntry) (size int) {
+	for _, e := range entries {
+		size += len(e.Data) + len(*e.PartitionKey)
+	}
+	return size
+}
@@ -0,0 +1,97 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/compress"
+)
+
+func gunzip(t *testing.T, data []byte) []byte {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err, "Must be a valid gzip stream")
+	out, err := ioutil.ReadAll(r)
+	require.NoError(t, err, "Must decompress the record")
+	return out
+}
+
+func TestBatchCompression(t *testing.T) {
+	t.Parallel()
+
+	compressor, err := compress.NewCompressor(compress.Gzip, 0)
+	require.NoError(t, err, "Must have a valid compressor")
+	bt := batch.New(batch.WithCompression(compressor), batch.WithBatchCompression())
+
+	for i := 0; i < 400; i++ {
+		span := fmt.Sprintf("span-%d:service-%d:operation", i, i%4)
+		require.NoError(t, bt.AddRecord([]byte(span), fmt.Sprintf("key-%d", i%4)))
+	}
+	assert.Equal(t, 1, bt.Len(), "Must count the batch compressed record as a single record")
+
+	chunks := bt.Chunk()
+	require.Len(t, chunks, 1, "Must have exactly one chunk")
+	require.Len(t, chunks[0], 1, "Must have compressed every span into a single record")
+	assert.Equal(t, "gzip:key-0", aws.StringValue(chunks[0][0].PartitionKey), "Must use the partition key of the first record")
+
+	keys, indexes, data := deaggregateIndexes(t, gunzip(t, chunks[0][0].Data))
+	assert.Equal(t, []string{"key-0", "key-1", "key-2", "key-3"}, keys, "Must have the partition key of each record")
+	require.Len(t, data, 400, "Must have every span")
+	for i := range data {
+		assert.Equal(t, fmt.Sprintf("span-%d:service-%d:operation", i, i%4), string(data[i]), "Must have kept the spans in order")
+		assert.EqualValues(t, i%4, indexes[i], "Must reference the partition key of the span")
+	}
+}
+
+func TestBatchCompressionRecordSizeLimit(t *testing.T) {
+	t.Parallel()
+
+	compressor, err := compress.NewCompressor(compress.Gzip, 0)
+	require.NoError(t, err, "Must have a valid compressor")
+	bt := batch.New(batch.WithCompression(compressor), batch.WithBatchCompression(), batch.WithMaxRecordSize(1<<10))
+
+	// Random data does not compress so the batch must be split
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		data := make([]byte, 200)
+		rnd.Read(data)
+		require.NoError(t, bt.AddRecord(data, "key"))
+	}
+	assert.ErrorIs(t, bt.AddRecord(make([]byte, 2<<10), "key"), batch.ErrRecordLength,
+		"Must error when a single record exceeds the record size limit")
+
+	chunks := bt.Chunk()
+	require.Len(t, chunks, 1, "Must have exactly one chunk")
+	require.Greater(t, len(chunks[0]), 1, "Must have split the batch across multiple records")
+
+	var total int
+	for _, record := range chunks[0] {
+		assert.LessOrEqual(t, len(record.Data)+len(aws.StringValue(record.PartitionKey)), 1<<10,
+			"Must not exceed the record size limit")
+		_, data := deaggregate(t, gunzip(t, record.Data))
+		total += len(data)
+	}
+	assert.Equal(t, 20, total, "Must have kept every record")
+}
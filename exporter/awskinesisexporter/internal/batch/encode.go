@@ -16,12 +16,19 @@ package batch
 
 import (
 	"errors"
+	"fmt"
+	"sync"
 
+	"go.opentelemetry.io/collector/model/otlp"
 	"go.opentelemetry.io/collector/model/pdata"
 )
 
-// ErrUnsupportedEncodedType is used when the encoder type does not support the type of encoding
-var ErrUnsupportedEncodedType = errors.New("unsupported type to encode")
+var (
+	// ErrUnsupportedEncodedType is used when the encoder type does not support the type of encoding
+	ErrUnsupportedEncodedType = errors.New("unsupported type to encode")
+	// ErrUnknownExportEncoder is used when a named encoding has not been registered
+	ErrUnknownExportEncoder = errors.New("unknown encoding export format")
+)
 
 // Encoder transforms the internal pipeline format into a configurable
 // format that is then used to export to kinesis.
@@ -32,3 +39,61 @@ type Encoder interface {
 
 	Logs(ld pdata.Logs) (*Batch, error)
 }
+
+// EncoderFactory creates an Encoder that applies the batch options
+// to every batch it creates.
+type EncoderFactory func(batchOptions ...Option) Encoder
+
+var (
+	encodersMu sync.RWMutex
+	encoders   = map[string]EncoderFactory{
+		"jaeger_proto": NewJaeger,
+		"avro": func(batchOptions ...Option) Encoder {
+			return NewAvro(nil, batchOptions...)
+		},
+		"otlp_proto": func(batchOptions ...Option) Encoder {
+			return newMarshaler(
+				otlp.NewProtobufTracesMarshaler(),
+				otlp.NewProtobufMetricsMarshaler(),
+				otlp.NewProtobufLogsMarshaler(),
+				batchOptions...,
+			)
+		},
+		"otlp_json": func(batchOptions ...Option) Encoder {
+			return newMarshaler(
+				otlp.NewJSONTracesMarshaler(),
+				otlp.NewJSONMetricsMarshaler(),
+				otlp.NewJSONLogsMarshaler(),
+				batchOptions...,
+			)
+		},
+		"ndjson": NewNDJSON,
+	}
+)
+
+// RegisterEncoder allows for custom encoders to be selected by name
+// from the exporter configuration.
+// An error is returned if the name is already in use.
+func RegisterEncoder(name string, factory EncoderFactory) error {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+
+	if _, exist := encoders[name]; exist {
+		return fmt.Errorf("encoder %q is already registered", name)
+	}
+	encoders[name] = factory
+	return nil
+}
+
+// NewEncoder returns the encoder registered with the given name,
+// otherwise ErrUnknownExportEncoder is returned.
+func NewEncoder(name string, batchOptions ...Option) (Encoder, error) {
+	encodersMu.RLock()
+	factory, exist := encoders[name]
+	encodersMu.RUnlock()
+
+	if !exist {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownExportEncoder, name)
+	}
+	return factory(batchOptions...), nil
+}
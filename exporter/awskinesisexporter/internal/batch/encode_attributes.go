@@ -0,0 +1,122 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"sort"
+
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+type recordAttributes struct {
+	next Encoder
+
+	// keys are sorted so that attributes are added in a stable order
+	keys       []string
+	attributes map[string]string
+	overwrite  bool
+}
+
+var _ Encoder = (*recordAttributes)(nil)
+
+// NewRecordAttributes returns an Encoder that adds the attributes to every
+// resource before it is encoded by the provided encoder, attributes that are
+// already set on a resource are only replaced when overwrite is set.
+func NewRecordAttributes(next Encoder, attributes map[string]string, overwrite bool) Encoder {
+	keys := make([]string, 0, len(attributes))
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return recordAttributes{next: next, keys: keys, attributes: attributes, overwrite: overwrite}
+}
+
+func (ra recordAttributes) apply(attrs pdata.AttributeMap) {
+	for _, k := range ra.keys {
+		if ra.overwrite {
+			attrs.UpsertString(k, ra.attributes[k])
+		} else {
+			attrs.InsertString(k, ra.attributes[k])
+		}
+	}
+}
+
+func (ra recordAttributes) Traces(td pdata.Traces) (*Batch, error) {
+	td = td.Clone()
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		ra.apply(td.ResourceSpans().At(i).Resource().Attributes())
+	}
+	return ra.next.Traces(td)
+}
+
+func (ra recordAttributes) Metrics(md pdata.Metrics) (*Batch, error) {
+	md = md.Clone()
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		ra.apply(md.ResourceMetrics().At(i).Resource().Attributes())
+	}
+	return ra.next.Metrics(md)
+}
+
+func (ra recordAttributes) Logs(ld pdata.Logs) (*Batch, error) {
+	ld = ld.Clone()
+	for i := 0; i < ld.ResourceLogs().Len(); i++ {
+		ra.apply(ld.ResourceLogs().At(i).Resource().Attributes())
+	}
+	return ra.next.Logs(ld)
+}
+
+type dropAttributes struct {
+	next Encoder
+	keys []string
+}
+
+var _ Encoder = (*dropAttributes)(nil)
+
+// NewDropAttributes returns an Encoder that removes the attributes from every
+// resource before it is encoded by the provided encoder, the provided data is
+// copied so that it is not modified for the other consumers of the pipeline.
+func NewDropAttributes(next Encoder, keys []string) Encoder {
+	return dropAttributes{next: next, keys: keys}
+}
+
+func (da dropAttributes) apply(attrs pdata.AttributeMap) {
+	for _, k := range da.keys {
+		attrs.Delete(k)
+	}
+}
+
+func (da dropAttributes) Traces(td pdata.Traces) (*Batch, error) {
+	td = td.Clone()
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		da.apply(td.ResourceSpans().At(i).Resource().Attributes())
+	}
+	return da.next.Traces(td)
+}
+
+func (da dropAttributes) Metrics(md pdata.Metrics) (*Batch, error) {
+	md = md.Clone()
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		da.apply(md.ResourceMetrics().At(i).Resource().Attributes())
+	}
+	return da.next.Metrics(md)
+}
+
+func (da dropAttributes) Logs(ld pdata.Logs) (*Batch, error) {
+	ld = ld.Clone()
+	for i := 0; i < ld.ResourceLogs().Len(); i++ {
+		da.apply(ld.ResourceLogs().At(i).Resource().Attributes())
+	}
+	return da.next.Logs(ld)
+}
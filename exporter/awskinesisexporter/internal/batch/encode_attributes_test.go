@@ -0,0 +1,129 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/model/otlp"
+	"go.opentelemetry.io/collector/model/pdata"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+)
+
+func TestRecordAttributes(t *testing.T) {
+	t.Parallel()
+
+	static := map[string]string{
+		"deployment.environment": "test",
+		"collector.id":           "collector-1",
+	}
+
+	td := pdata.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().InsertString("deployment.environment", "production")
+	rs.InstrumentationLibrarySpans().AppendEmpty().Spans().AppendEmpty().SetName("span")
+
+	for _, tc := range []struct {
+		overwrite   bool
+		environment string
+	}{
+		{overwrite: false, environment: "production"},
+		{overwrite: true, environment: "test"},
+	} {
+		next, err := batch.NewEncoder("otlp_proto")
+		require.NoError(t, err, "Must have a valid encoder")
+		enc := batch.NewRecordAttributes(next, static, tc.overwrite)
+
+		bt, err := enc.Traces(td)
+		require.NoError(t, err, "Must not error when encoding traces")
+		chunks := bt.Chunk()
+		require.Len(t, chunks, 1, "Must have exactly one chunk")
+		require.Len(t, chunks[0], 1, "Must have exactly one record")
+
+		decoded, err := otlp.NewProtobufTracesUnmarshaler().UnmarshalTraces(chunks[0][0].Data)
+		require.NoError(t, err, "Must be able to decode the record")
+		attrs := decoded.ResourceSpans().At(0).Resource().Attributes()
+
+		id, ok := attrs.Get("collector.id")
+		require.True(t, ok, "Must have added the static attribute")
+		assert.Equal(t, "collector-1", id.StringVal())
+		env, ok := attrs.Get("deployment.environment")
+		require.True(t, ok)
+		assert.Equal(t, tc.environment, env.StringVal(), "Must only replace existing attributes when overwriting")
+	}
+
+	_, ok := td.ResourceSpans().At(0).Resource().Attributes().Get("collector.id")
+	assert.False(t, ok, "Must not modify the provided traces")
+}
+
+func TestRecordAttributesAllSignals(t *testing.T) {
+	t.Parallel()
+
+	next, err := batch.NewEncoder("otlp_json")
+	require.NoError(t, err, "Must have a valid encoder")
+	enc := batch.NewRecordAttributes(next, map[string]string{"collector.id": "collector-1"}, false)
+
+	md := pdata.NewMetrics()
+	md.ResourceMetrics().AppendEmpty().InstrumentationLibraryMetrics().AppendEmpty().Metrics().AppendEmpty().SetName("metric")
+	bt, err := enc.Metrics(md)
+	require.NoError(t, err, "Must not error when encoding metrics")
+	decodedMetrics, err := otlp.NewJSONMetricsUnmarshaler().UnmarshalMetrics(bt.Chunk()[0][0].Data)
+	require.NoError(t, err, "Must be able to decode the metrics")
+	_, ok := decodedMetrics.ResourceMetrics().At(0).Resource().Attributes().Get("collector.id")
+	assert.True(t, ok, "Must have added the static attribute to metrics")
+
+	ld := pdata.NewLogs()
+	ld.ResourceLogs().AppendEmpty().InstrumentationLibraryLogs().AppendEmpty().Logs().AppendEmpty().SetName("log")
+	bt, err = enc.Logs(ld)
+	require.NoError(t, err, "Must not error when encoding logs")
+	decodedLogs, err := otlp.NewJSONLogsUnmarshaler().UnmarshalLogs(bt.Chunk()[0][0].Data)
+	require.NoError(t, err, "Must be able to decode the logs")
+	_, ok = decodedLogs.ResourceLogs().At(0).Resource().Attributes().Get("collector.id")
+	assert.True(t, ok, "Must have added the static attribute to logs")
+}
+
+func TestDropAttributes(t *testing.T) {
+	t.Parallel()
+
+	td := pdata.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().InsertString("service.name", "checkout")
+	rs.Resource().Attributes().InsertString("k8s.pod.uid", "3d0b2a2c-6f5e-4b1d-9a8e-1c2f3a4b5c6d")
+	rs.InstrumentationLibrarySpans().AppendEmpty().Spans().AppendEmpty().SetName("span")
+
+	next, err := batch.NewEncoder("otlp_proto")
+	require.NoError(t, err, "Must have a valid encoder")
+	enc := batch.NewDropAttributes(next, []string{"k8s.pod.uid", "not-set"})
+
+	bt, err := enc.Traces(td)
+	require.NoError(t, err, "Must not error when encoding traces")
+	chunks := bt.Chunk()
+	require.Len(t, chunks, 1, "Must have exactly one chunk")
+	require.Len(t, chunks[0], 1, "Must have exactly one record")
+
+	decoded, err := otlp.NewProtobufTracesUnmarshaler().UnmarshalTraces(chunks[0][0].Data)
+	require.NoError(t, err, "Must be able to decode the record")
+	attrs := decoded.ResourceSpans().At(0).Resource().Attributes()
+	_, ok := attrs.Get("k8s.pod.uid")
+	assert.False(t, ok, "Must have dropped the attribute")
+	_, ok = attrs.Get("service.name")
+	assert.True(t, ok, "Must have kept the other attributes")
+
+	_, ok = td.ResourceSpans().At(0).Resource().Attributes().Get("k8s.pod.uid")
+	assert.True(t, ok, "Must not modify the provided traces")
+}
@@ -0,0 +1,190 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"context"
+	"encoding/binary"
+	"sort"
+	"sync"
+
+	"go.opentelemetry.io/collector/model/pdata"
+	"go.uber.org/multierr"
+)
+
+// AvroSpanSchema is the Avro schema of the records written by the avro encoder,
+// which is the definition that is registered within a schema registry.
+const AvroSpanSchema = `{
+  "type": "record",
+  "name": "Span",
+  "namespace": "io.opentelemetry.awskinesis",
+  "fields": [
+    {"name": "trace_id", "type": "string"},
+    {"name": "span_id", "type": "string"},
+    {"name": "parent_span_id", "type": "string"},
+    {"name": "name", "type": "string"},
+    {"name": "kind", "type": "string"},
+    {"name": "start_time_unix_nano", "type": "long"},
+    {"name": "end_time_unix_nano", "type": "long"},
+    {"name": "status_code", "type": "string"},
+    {"name": "status_message", "type": "string"},
+    {"name": "attributes", "type": {"type": "map", "values": "string"}},
+    {"name": "resource_attributes", "type": {"type": "map", "values": "string"}},
+    {"name": "instrumentation_library", "type": "string"}
+  ]
+}`
+
+// SchemaRegistry resolves the registered version of a schema.
+type SchemaRegistry interface {
+	// SchemaVersionID returns the id of the schema version matching the definition.
+	SchemaVersionID(ctx context.Context, definition string) ([16]byte, error)
+}
+
+type avro struct {
+	batchOptions []Option
+
+	registry SchemaRegistry
+	// mu guards header, which is resolved from the registry once
+	mu     sync.Mutex
+	header []byte
+}
+
+var _ Encoder = (*avro)(nil)
+
+// NewAvro creates an Encoder that exports each span as an Avro record
+// using AvroSpanSchema. When registry is set, each record is prefixed with
+// the AWS Glue Schema Registry header holding the id of the schema version.
+func NewAvro(registry SchemaRegistry, batchOptions ...Option) Encoder {
+	return &avro{
+		batchOptions: batchOptions,
+		registry:     registry,
+	}
+}
+
+func (a *avro) Traces(td pdata.Traces) (*Batch, error) {
+	header, err := a.resolveHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	bt := New(a.batchOptions...)
+	tp, byTrace := bt.partitioner.(TracePartitioner)
+	sp, bySpan := bt.partitioner.(SpanPartitioner)
+
+	var errs error
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		resourceKey, hashKey := bt.PartitionKey(rs.Resource()), bt.ExplicitHashKey(rs.Resource())
+		resourceAttrs := rs.Resource().Attributes()
+		for j := 0; j < rs.InstrumentationLibrarySpans().Len(); j++ {
+			ils := rs.InstrumentationLibrarySpans().At(j)
+			for k := 0; k < ils.Spans().Len(); k++ {
+				span := ils.Spans().At(k)
+				key := resourceKey
+				switch {
+				case bySpan:
+					if k, ok := sp.PartitionSpan(rs.Resource(), span); ok {
+						key = k
+					}
+				case byTrace:
+					key = tp.PartitionTrace(rs.Resource(), span.TraceID())
+				}
+				record := encodeAvroSpan(append([]byte(nil), header...), span, resourceAttrs, ils.InstrumentationLibrary())
+				errs = multierr.Append(errs, bt.AddRecordWithHashKey(record, key, hashKey))
+			}
+		}
+	}
+	return bt, errs
+}
+
+func (*avro) Metrics(_ pdata.Metrics) (*Batch, error) {
+	return nil, ErrUnsupportedEncodedType
+}
+
+func (*avro) Logs(_ pdata.Logs) (*Batch, error) {
+	return nil, ErrUnsupportedEncodedType
+}
+
+const (
+	// glueHeaderVersion is the leading byte of records using the glue schema registry format.
+	glueHeaderVersion = 3
+	// glueCompressionNone marks that the glue schema registry did not compress the record.
+	glueCompressionNone = 0
+)
+
+// resolveHeader returns the header prefixing each record, the schema
+// version is resolved on first use and kept once it has been resolved.
+func (a *avro) resolveHeader() ([]byte, error) {
+	if a.registry == nil {
+		return nil, nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.header != nil {
+		return a.header, nil
+	}
+	id, err := a.registry.SchemaVersionID(context.Background(), AvroSpanSchema)
+	if err != nil {
+		return nil, err
+	}
+	a.header = append([]byte{glueHeaderVersion, glueCompressionNone}, id[:]...)
+	return a.header, nil
+}
+
+// encodeAvroSpan appends the span encoded using the Avro binary encoding of AvroSpanSchema.
+func encodeAvroSpan(buf []byte, span pdata.Span, resourceAttrs pdata.AttributeMap, il pdata.InstrumentationLibrary) []byte {
+	buf = appendAvroString(buf, span.TraceID().HexString())
+	buf = appendAvroString(buf, span.SpanID().HexString())
+	buf = appendAvroString(buf, span.ParentSpanID().HexString())
+	buf = appendAvroString(buf, span.Name())
+	buf = appendAvroString(buf, span.Kind().String())
+	buf = appendAvroLong(buf, int64(span.StartTimestamp()))
+	buf = appendAvroLong(buf, int64(span.EndTimestamp()))
+	buf = appendAvroString(buf, span.Status().Code().String())
+	buf = appendAvroString(buf, span.Status().Message())
+	buf = appendAvroAttributes(buf, span.Attributes())
+	buf = appendAvroAttributes(buf, resourceAttrs)
+	return appendAvroString(buf, il.Name())
+}
+
+// appendAvroLong appends the zig-zag variable length encoding used by Avro for longs.
+func appendAvroLong(buf []byte, v int64) []byte {
+	var scratch [binary.MaxVarintLen64]byte
+	return append(buf, scratch[:binary.PutVarint(scratch[:], v)]...)
+}
+
+func appendAvroString(buf []byte, s string) []byte {
+	return append(appendAvroLong(buf, int64(len(s))), s...)
+}
+
+// appendAvroAttributes appends the attributes as a single block of
+// a map of strings, sorted by key so that the encoding is stable.
+func appendAvroAttributes(buf []byte, attrs pdata.AttributeMap) []byte {
+	if attrs.Len() > 0 {
+		keys := make([]string, 0, attrs.Len())
+		attrs.Range(func(k string, _ pdata.AttributeValue) bool {
+			keys = append(keys, k)
+			return true
+		})
+		sort.Strings(keys)
+
+		buf = appendAvroLong(buf, int64(len(keys)))
+		for _, k := range keys {
+			v, _ := attrs.Get(k)
+			buf = appendAvroString(appendAvroString(buf, k), v.AsString())
+		}
+	}
+	return appendAvroLong(buf, 0)
+}
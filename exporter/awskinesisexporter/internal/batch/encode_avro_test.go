@@ -0,0 +1,204 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/glue"
+	"github.com/aws/aws-sdk-go/service/glue/glueiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/model/pdata"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+)
+
+// avroReader decodes the Avro binary encoding of the records.
+type avroReader struct {
+	t    *testing.T
+	data []byte
+}
+
+func (r *avroReader) long() int64 {
+	v, n := binary.Varint(r.data)
+	require.Greater(r.t, n, 0, "Must have a valid long")
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *avroReader) string() string {
+	l := int(r.long())
+	require.LessOrEqual(r.t, l, len(r.data), "Must have the full string")
+	s := string(r.data[:l])
+	r.data = r.data[l:]
+	return s
+}
+
+func (r *avroReader) stringMap() map[string]string {
+	m := make(map[string]string)
+	for n := r.long(); n != 0; n = r.long() {
+		for i := int64(0); i < n; i++ {
+			k := r.string()
+			m[k] = r.string()
+		}
+	}
+	return m
+}
+
+func newAvroTraces() pdata.Traces {
+	td := pdata.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().InsertString("service.name", "checkout")
+	ils := rs.InstrumentationLibrarySpans().AppendEmpty()
+	ils.InstrumentationLibrary().SetName("test-library")
+	span := ils.Spans().AppendEmpty()
+	span.SetTraceID(pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}))
+	span.SetSpanID(pdata.NewSpanID([8]byte{1, 2, 3, 4, 5, 6, 7, 8}))
+	span.SetName("GET /cart")
+	span.SetKind(pdata.SpanKindServer)
+	span.SetStartTimestamp(pdata.Timestamp(1633000000000000000))
+	span.SetEndTimestamp(pdata.Timestamp(1633000000500000000))
+	span.Status().SetCode(pdata.StatusCodeError)
+	span.Status().SetMessage("failed")
+	span.Attributes().InsertString("http.method", "GET")
+	span.Attributes().InsertInt("http.status_code", 500)
+	return td
+}
+
+func TestAvroEncoder(t *testing.T) {
+	t.Parallel()
+
+	enc, err := batch.NewEncoder("avro")
+	require.NoError(t, err, "Must have registered the avro encoder")
+
+	_, err = enc.Logs(pdata.NewLogs())
+	assert.Equal(t, err, batch.ErrUnsupportedEncodedType)
+
+	_, err = enc.Metrics(pdata.NewMetrics())
+	assert.Equal(t, err, batch.ErrUnsupportedEncodedType)
+
+	bt, err := enc.Traces(newAvroTraces())
+	require.NoError(t, err, "Must not error when encoding spans")
+	chunks := bt.Chunk()
+	require.Len(t, chunks, 1, "Must have exactly one chunk")
+	require.Len(t, chunks[0], 1, "Must have a record per span")
+
+	r := &avroReader{t: t, data: chunks[0][0].Data}
+	assert.Equal(t, "0102030405060708090a0b0c0d0e0f10", r.string(), "trace_id")
+	assert.Equal(t, "0102030405060708", r.string(), "span_id")
+	assert.Equal(t, "", r.string(), "parent_span_id")
+	assert.Equal(t, "GET /cart", r.string(), "name")
+	assert.Equal(t, "SPAN_KIND_SERVER", r.string(), "kind")
+	assert.EqualValues(t, 1633000000000000000, r.long(), "start_time_unix_nano")
+	assert.EqualValues(t, 1633000000500000000, r.long(), "end_time_unix_nano")
+	assert.Equal(t, "STATUS_CODE_ERROR", r.string(), "status_code")
+	assert.Equal(t, "failed", r.string(), "status_message")
+	assert.Equal(t, map[string]string{"http.method": "GET", "http.status_code": "500"}, r.stringMap(), "attributes")
+	assert.Equal(t, map[string]string{"service.name": "checkout"}, r.stringMap(), "resource_attributes")
+	assert.Equal(t, "test-library", r.string(), "instrumentation_library")
+	assert.Empty(t, r.data, "Must have decoded the whole record")
+}
+
+// fakeRegistry returns a fixed schema version id and counts the lookups.
+type fakeRegistry struct {
+	id      [16]byte
+	err     error
+	lookups int
+}
+
+func (fr *fakeRegistry) SchemaVersionID(_ context.Context, definition string) ([16]byte, error) {
+	fr.lookups++
+	if definition != batch.AvroSpanSchema {
+		return [16]byte{}, errors.New("unexpected schema definition")
+	}
+	return fr.id, fr.err
+}
+
+func TestAvroEncoderSchemaRegistry(t *testing.T) {
+	t.Parallel()
+
+	id := [16]byte{0xb7, 0xb4, 0xa7, 0xf0, 0x9c, 0x96, 0x4e, 0x4a, 0xa6, 0x87, 0xfb, 0x5d, 0xe9, 0xef, 0x0c, 0x63}
+	registry := &fakeRegistry{err: errors.New("registry unavailable")}
+	enc := batch.NewAvro(registry)
+
+	_, err := enc.Traces(newAvroTraces())
+	assert.Error(t, err, "Must error when the schema version can not be resolved")
+
+	registry.id, registry.err = id, nil
+	for i := 0; i < 3; i++ {
+		bt, err := enc.Traces(newAvroTraces())
+		require.NoError(t, err, "Must not error when encoding spans")
+
+		data := bt.Chunk()[0][0].Data
+		require.Greater(t, len(data), 18, "Must have the registry header")
+		assert.Equal(t, byte(3), data[0], "Must start with the glue header version")
+		assert.Equal(t, byte(0), data[1], "Must mark the record as not compressed by glue")
+		assert.Equal(t, id[:], data[2:18], "Must have the schema version id")
+
+		r := &avroReader{t: t, data: data[18:]}
+		assert.Equal(t, "0102030405060708090a0b0c0d0e0f10", r.string(), "Must have the avro record after the header")
+	}
+	assert.Equal(t, 2, registry.lookups, "Must have cached the resolved schema version")
+}
+
+type mockGlueAPI struct {
+	glueiface.GlueAPI
+
+	out *glue.GetSchemaByDefinitionOutput
+	err error
+	in  *glue.GetSchemaByDefinitionInput
+}
+
+func (m *mockGlueAPI) GetSchemaByDefinitionWithContext(_ context.Context, in *glue.GetSchemaByDefinitionInput, _ ...request.Option) (*glue.GetSchemaByDefinitionOutput, error) {
+	m.in = in
+	return m.out, m.err
+}
+
+func TestGlueRegistry(t *testing.T) {
+	t.Parallel()
+
+	client := &mockGlueAPI{out: &glue.GetSchemaByDefinitionOutput{
+		SchemaVersionId: aws.String("b7b4a7f0-9c96-4e4a-a687-fb5de9ef0c63"),
+		Status:          aws.String(glue.SchemaVersionStatusAvailable),
+	}}
+	registry := batch.NewGlueRegistry(client, "test-registry", "spans")
+
+	id, err := registry.SchemaVersionID(context.Background(), batch.AvroSpanSchema)
+	require.NoError(t, err, "Must not error when the schema version is available")
+	assert.Equal(t, [16]byte{0xb7, 0xb4, 0xa7, 0xf0, 0x9c, 0x96, 0x4e, 0x4a, 0xa6, 0x87, 0xfb, 0x5d, 0xe9, 0xef, 0x0c, 0x63}, id)
+	assert.Equal(t, "test-registry", aws.StringValue(client.in.SchemaId.RegistryName))
+	assert.Equal(t, "spans", aws.StringValue(client.in.SchemaId.SchemaName))
+	assert.Equal(t, batch.AvroSpanSchema, aws.StringValue(client.in.SchemaDefinition))
+
+	client.out.Status = aws.String(glue.SchemaVersionStatusPending)
+	_, err = registry.SchemaVersionID(context.Background(), batch.AvroSpanSchema)
+	assert.Error(t, err, "Must error when the schema version is not available")
+
+	client.out.Status, client.out.SchemaVersionId = aws.String(glue.SchemaVersionStatusAvailable), aws.String("not-a-uuid")
+	_, err = registry.SchemaVersionID(context.Background(), batch.AvroSpanSchema)
+	assert.Error(t, err, "Must error with an invalid schema version id")
+
+	client.err = awserr.New(glue.ErrCodeEntityNotFoundException, "schema not found", nil)
+	_, err = registry.SchemaVersionID(context.Background(), batch.AvroSpanSchema)
+	assert.True(t, consumererror.IsPermanent(err), "Must be permanent when the schema is not registered")
+}
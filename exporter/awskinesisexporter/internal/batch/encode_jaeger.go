@@ -15,6 +15,7 @@
 package batch
 
 import (
+	"github.com/jaegertracing/jaeger/model"
 	"go.opentelemetry.io/collector/model/pdata"
 	"go.uber.org/multierr"
 
@@ -22,16 +23,16 @@ import (
 )
 
 type jaeger struct {
-	batchSize  int
-	recordSize int
+	batchOptions []Option
 }
 
 var _ Encoder = (*jaeger)(nil)
 
-func NewJaeger(batchSize, recordSize int) Encoder {
+// NewJaeger creates an Encoder that exports each span as
+// a jaeger protobuf record keyed by its trace id.
+func NewJaeger(batchOptions ...Option) Encoder {
 	return jaeger{
-		batchSize:  batchSize,
-		recordSize: recordSize,
+		batchOptions: batchOptions,
 	}
 }
 
@@ -41,23 +42,65 @@ func (j jaeger) Traces(td pdata.Traces) (*Batch, error) {
 		return nil, err
 	}
 
-	bt := New(
-		WithMaxRecordSize(j.recordSize),
-		WithMaxRecordsPerBatch(j.batchSize),
-	)
+	bt := New(j.batchOptions...)
+	var keys map[spanKey]string
+	if sp, ok := bt.partitioner.(SpanPartitioner); ok {
+		keys = spanKeys(td, sp)
+	}
+
 	var errs error
 	for _, trace := range traces {
 		for _, span := range trace.GetSpans() {
 			if span.Process == nil {
 				span.Process = trace.Process
 			}
-			errs = multierr.Append(errs, bt.AddProtobufV1(span, span.TraceID.String()))
+			key, ok := keys[spanKey{traceID: span.TraceID, spanID: span.SpanID}]
+			if !ok {
+				key = span.TraceID.String()
+			}
+			errs = multierr.Append(errs, bt.AddProtobufV1(span, key))
 		}
 	}
 
 	return bt, errs
 }
 
+// spanKey identifies a span once it has been translated to jaeger.
+type spanKey struct {
+	traceID model.TraceID
+	spanID  model.SpanID
+}
+
+// spanKeys returns the partition keys that the partitioner gives to spans,
+// spans that it does not provide a key for keep their trace id as the key.
+func spanKeys(td pdata.Traces, sp SpanPartitioner) map[spanKey]string {
+	keys := make(map[spanKey]string)
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		for j := 0; j < rs.InstrumentationLibrarySpans().Len(); j++ {
+			ils := rs.InstrumentationLibrarySpans().At(j)
+			for k := 0; k < ils.Spans().Len(); k++ {
+				span := ils.Spans().At(k)
+				key, ok := sp.PartitionSpan(rs.Resource(), span)
+				if !ok {
+					continue
+				}
+				traceID, spanID := span.TraceID().Bytes(), span.SpanID().Bytes()
+				tid, err := model.TraceIDFromBytes(traceID[:])
+				if err != nil {
+					continue
+				}
+				sid, err := model.SpanIDFromBytes(spanID[:])
+				if err != nil {
+					continue
+				}
+				keys[spanKey{traceID: tid, spanID: sid}] = key
+			}
+		}
+	}
+	return keys
+}
+
 func (jaeger) Metrics(_ pdata.Metrics) (*Batch, error) {
 	return nil, ErrUnsupportedEncodedType
 }
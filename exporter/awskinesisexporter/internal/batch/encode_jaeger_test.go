@@ -26,7 +26,10 @@ import (
 func TestJaegerBatchEncoder(t *testing.T) {
 	t.Parallel()
 
-	jbe := batch.NewJaeger(1, 1)
+	jbe := batch.NewJaeger(
+		batch.WithMaxRecordsPerBatch(1),
+		batch.WithMaxRecordSize(1),
+	)
 
 	_, err := jbe.Logs(pdata.NewLogs())
 	assert.Equal(t, err, batch.ErrUnsupportedEncodedType)
@@ -0,0 +1,284 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/collector/model/pdata"
+	"go.uber.org/multierr"
+)
+
+type marshaler struct {
+	batchOptions []Option
+
+	traces  pdata.TracesMarshaler
+	metrics pdata.MetricsMarshaler
+	logs    pdata.LogsMarshaler
+}
+
+var _ Encoder = (*marshaler)(nil)
+
+func newMarshaler(traces pdata.TracesMarshaler, metrics pdata.MetricsMarshaler, logs pdata.LogsMarshaler, batchOptions ...Option) Encoder {
+	return &marshaler{
+		batchOptions: batchOptions,
+		traces:       traces,
+		metrics:      metrics,
+		logs:         logs,
+	}
+}
+
+// Due to kinesis limitations of only allowing 1Mb of data per record,
+// each resource is copied into its own export payload and marshaled
+// as a single record, data that is still too large is split
+// across multiple records.
+
+func (m *marshaler) Traces(td pdata.Traces) (*Batch, error) {
+	bt := New(m.batchOptions...)
+
+	if sp, ok := bt.partitioner.(SpanPartitioner); ok {
+		return bt, m.tracesByKey(bt, td, sp)
+	}
+	if tp, ok := bt.partitioner.(TracePartitioner); ok {
+		return bt, m.tracesByTraceID(bt, td, tp)
+	}
+	// Most exports have a single resource which is already
+	// an export payload, so it is marshaled without a copy.
+	if td.ResourceSpans().Len() == 1 {
+		rs := td.ResourceSpans().At(0)
+		return bt, m.addTraces(bt, td, bt.PartitionKey(rs.Resource()), bt.ExplicitHashKey(rs.Resource()))
+	}
+
+	export := pdata.NewTraces()
+	export.ResourceSpans().AppendEmpty()
+
+	var errs error
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		rs.CopyTo(export.ResourceSpans().At(0))
+
+		errs = multierr.Append(errs, m.addTraces(bt, export, bt.PartitionKey(rs.Resource()), bt.ExplicitHashKey(rs.Resource())))
+	}
+
+	return bt, errs
+}
+
+// addTraces adds the traces of a single resource as a record, if the record
+// is too large then the spans are split across multiple records using the same keys.
+// A permanent error is returned if a single span is too large for a record.
+func (m *marshaler) addTraces(bt *Batch, td pdata.Traces, key, hashKey string) error {
+	if bt.validate && td.SpanCount() == 0 {
+		bt.dropInvalid(key, "no spans")
+		return nil
+	}
+	data, err := m.traces.MarshalTraces(td)
+	if err != nil {
+		return err
+	}
+	err = bt.AddRecordWithHashKey(data, key, hashKey)
+	if !errors.Is(err, ErrRecordLength) {
+		return err
+	}
+
+	switch td.SpanCount() {
+	case 0:
+		return err
+	case 1:
+		span := firstSpan(td)
+		return fmt.Errorf("%w: span %q with span id %s of trace %s",
+			err, span.Name(), span.SpanID().HexString(), span.TraceID().HexString())
+	}
+
+	first, second := splitTraces(td)
+	return multierr.Append(m.addTraces(bt, first, key, hashKey), m.addTraces(bt, second, key, hashKey))
+}
+
+// firstSpan returns the first span of the traces that contain at least one span.
+func firstSpan(td pdata.Traces) pdata.Span {
+	libs := td.ResourceSpans().At(0).InstrumentationLibrarySpans()
+	for i := 0; i < libs.Len(); i++ {
+		if libs.At(i).Spans().Len() > 0 {
+			return libs.At(i).Spans().At(0)
+		}
+	}
+	return pdata.NewSpan()
+}
+
+func (m *marshaler) tracesByTraceID(bt *Batch, td pdata.Traces, tp TracePartitioner) error {
+	var errs error
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		ids, traces := splitByTraceID(rs)
+		for j, export := range traces {
+			errs = multierr.Append(errs, m.addTraces(bt, export, tp.PartitionTrace(rs.Resource(), ids[j]), bt.ExplicitHashKey(rs.Resource())))
+		}
+	}
+	return errs
+}
+
+func (m *marshaler) tracesByKey(bt *Batch, td pdata.Traces, sp SpanPartitioner) error {
+	var errs error
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		resourceKey, hashKey := bt.PartitionKey(rs.Resource()), bt.ExplicitHashKey(rs.Resource())
+		keys, traces := splitSpansByKey(rs, func(span pdata.Span) string {
+			if key, ok := sp.PartitionSpan(rs.Resource(), span); ok {
+				return key
+			}
+			return resourceKey
+		})
+		for j, export := range traces {
+			errs = multierr.Append(errs, m.addTraces(bt, export, keys[j], hashKey))
+		}
+	}
+	return errs
+}
+
+func (m *marshaler) Metrics(md pdata.Metrics) (*Batch, error) {
+	bt := New(m.batchOptions...)
+
+	export := pdata.NewMetrics()
+	export.ResourceMetrics().AppendEmpty()
+
+	var errs error
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		rm := md.ResourceMetrics().At(i)
+		rm.CopyTo(export.ResourceMetrics().At(0))
+
+		errs = multierr.Append(errs, m.addMetrics(bt, export, bt.PartitionKey(rm.Resource()), bt.ExplicitHashKey(rm.Resource())))
+	}
+
+	return bt, errs
+}
+
+// addMetrics adds the metrics of a single resource as a record, if the record
+// is too large then the metrics are split across multiple records using the same keys.
+// A permanent error is returned if a single metric is too large for a record.
+func (m *marshaler) addMetrics(bt *Batch, md pdata.Metrics, key, hashKey string) error {
+	if bt.validate && md.MetricCount() == 0 {
+		bt.dropInvalid(key, "no metrics")
+		return nil
+	}
+	data, err := m.metrics.MarshalMetrics(md)
+	if err != nil {
+		return err
+	}
+	err = bt.AddRecordWithHashKey(data, key, hashKey)
+	if !errors.Is(err, ErrRecordLength) {
+		return err
+	}
+
+	switch md.MetricCount() {
+	case 0:
+		return err
+	case 1:
+		return fmt.Errorf("%w: metric %q", err, firstMetric(md).Name())
+	}
+
+	first, second := splitMetrics(md)
+	return multierr.Append(m.addMetrics(bt, first, key, hashKey), m.addMetrics(bt, second, key, hashKey))
+}
+
+// firstMetric returns the first metric of the metrics that contain at least one metric.
+func firstMetric(md pdata.Metrics) pdata.Metric {
+	libs := md.ResourceMetrics().At(0).InstrumentationLibraryMetrics()
+	for i := 0; i < libs.Len(); i++ {
+		if libs.At(i).Metrics().Len() > 0 {
+			return libs.At(i).Metrics().At(0)
+		}
+	}
+	return pdata.NewMetric()
+}
+
+func (m *marshaler) Logs(ld pdata.Logs) (*Batch, error) {
+	bt := New(m.batchOptions...)
+
+	if lp, ok := bt.partitioner.(LogPartitioner); ok {
+		return bt, m.logsByKey(bt, ld, lp)
+	}
+
+	export := pdata.NewLogs()
+	export.ResourceLogs().AppendEmpty()
+
+	var errs error
+	for i := 0; i < ld.ResourceLogs().Len(); i++ {
+		rl := ld.ResourceLogs().At(i)
+		rl.CopyTo(export.ResourceLogs().At(0))
+
+		errs = multierr.Append(errs, m.addLogs(bt, export, bt.PartitionKey(rl.Resource()), bt.ExplicitHashKey(rl.Resource())))
+	}
+
+	return bt, errs
+}
+
+func (m *marshaler) logsByKey(bt *Batch, ld pdata.Logs, lp LogPartitioner) error {
+	var errs error
+	for i := 0; i < ld.ResourceLogs().Len(); i++ {
+		rl := ld.ResourceLogs().At(i)
+		resourceKey, hashKey := bt.PartitionKey(rl.Resource()), bt.ExplicitHashKey(rl.Resource())
+		keys, logs := splitLogsByKey(rl, func(record pdata.LogRecord) string {
+			if key, ok := lp.PartitionLog(record); ok {
+				return key
+			}
+			return resourceKey
+		})
+		for j, export := range logs {
+			errs = multierr.Append(errs, m.addLogs(bt, export, keys[j], hashKey))
+		}
+	}
+	return errs
+}
+
+// addLogs adds the logs of a single resource as a record, if the record
+// is too large then the log records are split across multiple records using the same keys.
+// A permanent error is returned if a single log record is too large for a record.
+func (m *marshaler) addLogs(bt *Batch, ld pdata.Logs, key, hashKey string) error {
+	if bt.validate && ld.LogRecordCount() == 0 {
+		bt.dropInvalid(key, "no log records")
+		return nil
+	}
+	data, err := m.logs.MarshalLogs(ld)
+	if err != nil {
+		return err
+	}
+	err = bt.AddRecordWithHashKey(data, key, hashKey)
+	if !errors.Is(err, ErrRecordLength) {
+		return err
+	}
+
+	switch ld.LogRecordCount() {
+	case 0:
+		return err
+	case 1:
+		record := firstLogRecord(ld)
+		return fmt.Errorf("%w: log record %q at %s with span id %s of trace %s",
+			err, record.Name(), record.Timestamp(), record.SpanID().HexString(), record.TraceID().HexString())
+	}
+
+	first, second := splitLogs(ld)
+	return multierr.Append(m.addLogs(bt, first, key, hashKey), m.addLogs(bt, second, key, hashKey))
+}
+
+// firstLogRecord returns the first record of the logs that contain at least one record.
+func firstLogRecord(ld pdata.Logs) pdata.LogRecord {
+	libs := ld.ResourceLogs().At(0).InstrumentationLibraryLogs()
+	for i := 0; i < libs.Len(); i++ {
+		if libs.At(i).Logs().Len() > 0 {
+			return libs.At(i).Logs().At(0)
+		}
+	}
+	return pdata.NewLogRecord()
+}
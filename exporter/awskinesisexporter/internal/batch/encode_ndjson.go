@@ -0,0 +1,276 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/collector/model/otlp"
+	"go.opentelemetry.io/collector/model/pdata"
+	"go.uber.org/multierr"
+)
+
+type ndjson struct {
+	batchOptions []Option
+
+	traces  pdata.TracesMarshaler
+	metrics pdata.MetricsMarshaler
+	logs    pdata.LogsMarshaler
+}
+
+var _ Encoder = (*ndjson)(nil)
+
+// NewNDJSON returns an Encoder that writes each span, metric and log record as
+// a line of OTLP JSON, holding the single item with its resource and instrumentation
+// library, and packs the lines that share a partition key into records until the
+// record size limit is reached. Every line, including the last of each record, ends
+// with a new line so that records concatenated by a consumer are still delimited.
+func NewNDJSON(batchOptions ...Option) Encoder {
+	return &ndjson{
+		batchOptions: batchOptions,
+		traces:       otlp.NewJSONTracesMarshaler(),
+		metrics:      otlp.NewJSONMetricsMarshaler(),
+		logs:         otlp.NewJSONLogsMarshaler(),
+	}
+}
+
+func (n *ndjson) Traces(td pdata.Traces) (*Batch, error) {
+	bt := New(n.batchOptions...)
+	p := newLinePacker(bt)
+	tp, byTrace := bt.partitioner.(TracePartitioner)
+	sp, bySpan := bt.partitioner.(SpanPartitioner)
+
+	var errs error
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		key, hashKey := p.key(rs.Resource()), bt.ExplicitHashKey(rs.Resource())
+		for j := 0; j < rs.InstrumentationLibrarySpans().Len(); j++ {
+			ils := rs.InstrumentationLibrarySpans().At(j)
+			for k := 0; k < ils.Spans().Len(); k++ {
+				span := ils.Spans().At(k)
+
+				export := pdata.NewTraces()
+				dest := export.ResourceSpans().AppendEmpty()
+				rs.Resource().CopyTo(dest.Resource())
+				dest.SetSchemaUrl(rs.SchemaUrl())
+				lib := dest.InstrumentationLibrarySpans().AppendEmpty()
+				ils.InstrumentationLibrary().CopyTo(lib.InstrumentationLibrary())
+				lib.SetSchemaUrl(ils.SchemaUrl())
+				span.CopyTo(lib.Spans().AppendEmpty())
+
+				line, err := n.traces.MarshalTraces(export)
+				if err != nil {
+					errs = multierr.Append(errs, err)
+					continue
+				}
+				spanKey := key
+				switch {
+				case bySpan:
+					if k, ok := sp.PartitionSpan(rs.Resource(), span); ok {
+						spanKey = k
+					}
+				case byTrace:
+					spanKey = tp.PartitionTrace(rs.Resource(), span.TraceID())
+				}
+				if err := p.add(spanKey, hashKey, line); err != nil {
+					errs = multierr.Append(errs, fmt.Errorf("%w: span %q with span id %s of trace %s",
+						err, span.Name(), span.SpanID().HexString(), span.TraceID().HexString()))
+				}
+			}
+		}
+	}
+	return bt, multierr.Append(errs, p.flush())
+}
+
+func (n *ndjson) Metrics(md pdata.Metrics) (*Batch, error) {
+	bt := New(n.batchOptions...)
+	p := newLinePacker(bt)
+
+	var errs error
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		rm := md.ResourceMetrics().At(i)
+		key, hashKey := p.key(rm.Resource()), bt.ExplicitHashKey(rm.Resource())
+		for j := 0; j < rm.InstrumentationLibraryMetrics().Len(); j++ {
+			ilm := rm.InstrumentationLibraryMetrics().At(j)
+			for k := 0; k < ilm.Metrics().Len(); k++ {
+				metric := ilm.Metrics().At(k)
+
+				export := pdata.NewMetrics()
+				dest := export.ResourceMetrics().AppendEmpty()
+				rm.Resource().CopyTo(dest.Resource())
+				dest.SetSchemaUrl(rm.SchemaUrl())
+				lib := dest.InstrumentationLibraryMetrics().AppendEmpty()
+				ilm.InstrumentationLibrary().CopyTo(lib.InstrumentationLibrary())
+				lib.SetSchemaUrl(ilm.SchemaUrl())
+				metric.CopyTo(lib.Metrics().AppendEmpty())
+
+				line, err := n.metrics.MarshalMetrics(export)
+				if err != nil {
+					errs = multierr.Append(errs, err)
+					continue
+				}
+				if err := p.add(key, hashKey, line); err != nil {
+					errs = multierr.Append(errs, fmt.Errorf("%w: metric %q", err, metric.Name()))
+				}
+			}
+		}
+	}
+	return bt, multierr.Append(errs, p.flush())
+}
+
+func (n *ndjson) Logs(ld pdata.Logs) (*Batch, error) {
+	bt := New(n.batchOptions...)
+	p := newLinePacker(bt)
+	lp, byRecord := bt.partitioner.(LogPartitioner)
+
+	var errs error
+	for i := 0; i < ld.ResourceLogs().Len(); i++ {
+		rl := ld.ResourceLogs().At(i)
+		key, hashKey := p.key(rl.Resource()), bt.ExplicitHashKey(rl.Resource())
+		for j := 0; j < rl.InstrumentationLibraryLogs().Len(); j++ {
+			ill := rl.InstrumentationLibraryLogs().At(j)
+			for k := 0; k < ill.Logs().Len(); k++ {
+				record := ill.Logs().At(k)
+
+				export := pdata.NewLogs()
+				dest := export.ResourceLogs().AppendEmpty()
+				rl.Resource().CopyTo(dest.Resource())
+				dest.SetSchemaUrl(rl.SchemaUrl())
+				lib := dest.InstrumentationLibraryLogs().AppendEmpty()
+				ill.InstrumentationLibrary().CopyTo(lib.InstrumentationLibrary())
+				lib.SetSchemaUrl(ill.SchemaUrl())
+				record.CopyTo(lib.Logs().AppendEmpty())
+
+				line, err := n.logs.MarshalLogs(export)
+				if err != nil {
+					errs = multierr.Append(errs, err)
+					continue
+				}
+				recordKey := key
+				if byRecord {
+					if k, ok := lp.PartitionLog(record); ok {
+						recordKey = k
+					}
+				}
+				if err := p.add(recordKey, hashKey, line); err != nil {
+					errs = multierr.Append(errs, fmt.Errorf("%w: log record %q at %s with span id %s of trace %s",
+						err, record.Name(), record.Timestamp(), record.SpanID().HexString(), record.TraceID().HexString()))
+				}
+			}
+		}
+	}
+	return bt, multierr.Append(errs, p.flush())
+}
+
+// linePacker packs the lines that share a partition and hash key into records,
+// records are added to the batch once full and the remaining records on flush.
+type linePacker struct {
+	bt *Batch
+	// limit is the size of the lines of a record, which leaves room for the
+	// longest partition key so that the record stays within the record size limit.
+	limit int
+	// random generates a new key for each record instead of each resource,
+	// so that the lines of every resource can share the records.
+	random bool
+
+	records map[string]*lineRecord
+	// order keeps the records in the order that their keys were first seen
+	order []string
+}
+
+type lineRecord struct {
+	key, hashKey string
+	lines        [][]byte
+	size         int
+}
+
+func newLinePacker(bt *Batch) *linePacker {
+	_, random := bt.partitioner.(randomPartitioner)
+	return &linePacker{
+		bt:      bt,
+		limit:   bt.maxRecordSize - MaxPartitionKeyLength,
+		random:  random,
+		records: make(map[string]*lineRecord),
+	}
+}
+
+// key returns the partition key of the lines created from the resource,
+// an empty key is replaced by a random key once the record is added.
+func (p *linePacker) key(resource pdata.Resource) string {
+	if p.random {
+		return ""
+	}
+	return p.bt.PartitionKey(resource)
+}
+
+// add appends the line to the record of its keys, the record is added to the
+// batch first if the line does not fit. A line that can not fit within a
+// record on its own returns ErrRecordLength.
+func (p *linePacker) add(key, hashKey string, line []byte) error {
+	size := len(line) + 1
+	if size > p.limit {
+		return p.bt.errRecordLength(size)
+	}
+	id := key + "\x00" + hashKey
+	rec, ok := p.records[id]
+	if !ok {
+		rec = &lineRecord{key: key, hashKey: hashKey}
+		p.records[id] = rec
+		p.order = append(p.order, id)
+	}
+	var err error
+	if rec.size+size > p.limit {
+		err = p.write(rec.key, rec.hashKey, rec.lines)
+		rec.lines, rec.size = nil, 0
+	}
+	rec.lines = append(rec.lines, line)
+	rec.size += size
+	return err
+}
+
+// flush adds the records that are still accepting lines to the batch.
+func (p *linePacker) flush() error {
+	var errs error
+	for _, id := range p.order {
+		rec := p.records[id]
+		errs = multierr.Append(errs, p.write(rec.key, rec.hashKey, rec.lines))
+	}
+	p.records, p.order = make(map[string]*lineRecord), nil
+	return errs
+}
+
+// write adds the lines as a single record, if the record is still too
+// large, such as once marked, then the lines are split across two records.
+func (p *linePacker) write(key, hashKey string, lines [][]byte) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	if key == "" {
+		key = p.bt.PartitionKey(pdata.NewResource())
+	}
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	err := p.bt.AddRecordWithHashKey(buf.Bytes(), key, hashKey)
+	if !errors.Is(err, ErrRecordLength) || len(lines) == 1 {
+		return err
+	}
+	half := len(lines) / 2
+	return multierr.Append(p.write(key, hashKey, lines[:half]), p.write(key, hashKey, lines[half:]))
+}
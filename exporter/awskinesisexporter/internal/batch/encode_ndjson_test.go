@@ -0,0 +1,164 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/model/otlp"
+	"go.opentelemetry.io/collector/model/pdata"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+)
+
+// ndjsonLines splits the record into its lines, requiring that the record ends with a new line.
+func ndjsonLines(t *testing.T, data []byte) [][]byte {
+	require.True(t, bytes.HasSuffix(data, []byte("\n")), "Must end the record with a new line")
+	return bytes.Split(data[:len(data)-1], []byte("\n"))
+}
+
+func TestNDJSONEncoderTraces(t *testing.T) {
+	t.Parallel()
+
+	enc, err := batch.NewEncoder("ndjson",
+		batch.WithPartitioner(batch.NewAttributePartitioner("service.name", zap.NewNop())),
+	)
+	require.NoError(t, err, "Must have a valid encoder")
+
+	services := []string{"frontend", "checkout"}
+	td := pdata.NewTraces()
+	for i, name := range services {
+		rs := td.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().InsertString("service.name", name)
+		ils := rs.InstrumentationLibrarySpans().AppendEmpty()
+		ils.InstrumentationLibrary().SetName("library")
+		for j := 0; j < 3; j++ {
+			span := ils.Spans().AppendEmpty()
+			span.SetName(fmt.Sprintf("span-%d-%d", i, j))
+			span.SetTraceID(pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}))
+			span.SetSpanID(pdata.NewSpanID([8]byte{1, 2, 3, 4, 5, 6, 7, byte(j)}))
+		}
+	}
+
+	bt, err := enc.Traces(td)
+	require.NoError(t, err, "Must not error when encoding traces")
+
+	chunks := bt.Chunk()
+	require.Len(t, chunks, 1, "Must have exactly one chunk")
+	require.Len(t, chunks[0], 2, "Must have packed the spans of each resource into a record")
+
+	var names []string
+	for i, record := range chunks[0] {
+		assert.Equal(t, services[i], *record.PartitionKey, "Must have used the service as the partition key")
+		lines := ndjsonLines(t, record.Data)
+		assert.Len(t, lines, 3, "Must have a line per span")
+		for _, line := range lines {
+			decoded, err := otlp.NewJSONTracesUnmarshaler().UnmarshalTraces(line)
+			require.NoError(t, err, "Must be able to parse each line")
+			require.Equal(t, 1, decoded.SpanCount(), "Must have a single span per line")
+
+			rs := decoded.ResourceSpans().At(0)
+			service, _ := rs.Resource().Attributes().Get("service.name")
+			assert.Equal(t, services[i], service.StringVal(), "Must keep the resource of the span")
+			ils := rs.InstrumentationLibrarySpans().At(0)
+			assert.Equal(t, "library", ils.InstrumentationLibrary().Name(), "Must keep the library of the span")
+			names = append(names, ils.Spans().At(0).Name())
+		}
+	}
+	assert.Equal(t, []string{"span-0-0", "span-0-1", "span-0-2", "span-1-0", "span-1-1", "span-1-2"}, names,
+		"Must have kept every span in order")
+}
+
+func TestNDJSONEncoderMetricsAndLogs(t *testing.T) {
+	t.Parallel()
+
+	enc, err := batch.NewEncoder("ndjson", batch.WithPartitioner(batch.NewRandomPartitioner()))
+	require.NoError(t, err, "Must have a valid encoder")
+
+	md := pdata.NewMetrics()
+	ld := pdata.NewLogs()
+	for i := 0; i < 2; i++ {
+		metrics := md.ResourceMetrics().AppendEmpty().InstrumentationLibraryMetrics().AppendEmpty().Metrics()
+		logs := ld.ResourceLogs().AppendEmpty().InstrumentationLibraryLogs().AppendEmpty().Logs()
+		for j := 0; j < 2; j++ {
+			metrics.AppendEmpty().SetName(fmt.Sprintf("metric-%d-%d", i, j))
+			logs.AppendEmpty().SetName(fmt.Sprintf("log-%d-%d", i, j))
+		}
+	}
+
+	bt, err := enc.Metrics(md)
+	require.NoError(t, err, "Must not error when encoding metrics")
+	records := bt.Chunk()[0]
+	require.Len(t, records, 1, "Must have packed the metrics of every resource into a single record")
+	for i, line := range ndjsonLines(t, records[0].Data) {
+		decoded, err := otlp.NewJSONMetricsUnmarshaler().UnmarshalMetrics(line)
+		require.NoError(t, err, "Must be able to parse each line")
+		require.Equal(t, 1, decoded.MetricCount(), "Must have a single metric per line")
+		metric := decoded.ResourceMetrics().At(0).InstrumentationLibraryMetrics().At(0).Metrics().At(0)
+		assert.Equal(t, fmt.Sprintf("metric-%d-%d", i/2, i%2), metric.Name(), "Must have kept every metric in order")
+	}
+
+	bt, err = enc.Logs(ld)
+	require.NoError(t, err, "Must not error when encoding logs")
+	records = bt.Chunk()[0]
+	require.Len(t, records, 1, "Must have packed the logs of every resource into a single record")
+	for i, line := range ndjsonLines(t, records[0].Data) {
+		decoded, err := otlp.NewJSONLogsUnmarshaler().UnmarshalLogs(line)
+		require.NoError(t, err, "Must be able to parse each line")
+		require.Equal(t, 1, decoded.LogRecordCount(), "Must have a single log record per line")
+		record := decoded.ResourceLogs().At(0).InstrumentationLibraryLogs().At(0).Logs().At(0)
+		assert.Equal(t, fmt.Sprintf("log-%d-%d", i/2, i%2), record.Name(), "Must have kept every log record in order")
+	}
+}
+
+func TestNDJSONEncoderMaxRecordSize(t *testing.T) {
+	t.Parallel()
+
+	const limit = 16 << 10
+
+	td := pdata.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().InstrumentationLibrarySpans().AppendEmpty().Spans()
+	for i := 0; i < 16; i++ {
+		largeSpan(spans, fmt.Sprintf("span-%d", i), 3<<10)
+	}
+
+	enc, err := batch.NewEncoder("ndjson", batch.WithMaxRecordSize(limit))
+	require.NoError(t, err, "Must have a valid encoder")
+
+	bt, err := enc.Traces(td)
+	require.NoError(t, err, "Must not error when encoding traces")
+
+	records := bt.Chunk()[0]
+	assert.Greater(t, len(records), 1, "Must have split the spans across records")
+	assert.Less(t, len(records), 16, "Must have packed several spans per record")
+
+	lines := 0
+	for _, record := range records {
+		assert.LessOrEqual(t, len(record.Data)+len(*record.PartitionKey), limit, "Must fit within the record limit")
+		lines += len(ndjsonLines(t, record.Data))
+	}
+	assert.Equal(t, 16, lines, "Must have kept every span")
+
+	largeSpan(spans, "oversized", 32<<10)
+	_, err = enc.Traces(td)
+	assert.True(t, consumererror.IsPermanent(err), "Must return a permanent error for a span above the limit")
+	assert.Contains(t, err.Error(), `span "oversized"`, "Must identify the oversized span")
+}
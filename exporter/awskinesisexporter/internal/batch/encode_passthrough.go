@@ -0,0 +1,106 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/collector/model/pdata"
+	"go.uber.org/multierr"
+)
+
+// RawBodyAttribute is the log record attribute that holds an already
+// serialized payload to be written as the record without being encoded.
+const RawBodyAttribute = "kinesis.raw_body"
+
+type passthrough struct {
+	Encoder
+
+	batchOptions []Option
+}
+
+var _ Encoder = (*passthrough)(nil)
+
+// NewPassthrough returns an Encoder that writes the RawBodyAttribute value
+// of each log record as is, as its own record, and uses the provided
+// encoder for all other data. Byte and string values are supported,
+// the header set by WithRawBodyHeader is prepended to each raw body.
+func NewPassthrough(next Encoder, batchOptions ...Option) Encoder {
+	return passthrough{Encoder: next, batchOptions: batchOptions}
+}
+
+func (p passthrough) Logs(ld pdata.Logs) (*Batch, error) {
+	bt := New(p.batchOptions...)
+	lp, byRecord := bt.partitioner.(LogPartitioner)
+
+	rest := ld.Clone()
+	var errs error
+	for i := 0; i < rest.ResourceLogs().Len(); i++ {
+		rl := rest.ResourceLogs().At(i)
+		resourceKey, hashKey := bt.PartitionKey(rl.Resource()), bt.ExplicitHashKey(rl.Resource())
+		for j := 0; j < rl.InstrumentationLibraryLogs().Len(); j++ {
+			rl.InstrumentationLibraryLogs().At(j).Logs().RemoveIf(func(record pdata.LogRecord) bool {
+				raw, ok := rawBody(record)
+				if !ok {
+					return false
+				}
+				key := resourceKey
+				if byRecord {
+					if k, ok := lp.PartitionLog(record); ok {
+						key = k
+					}
+				}
+				if len(bt.rawHeader) > 0 {
+					raw = append(bt.rawHeader[:len(bt.rawHeader):len(bt.rawHeader)], raw...)
+				}
+				if err := bt.AddRecordWithHashKey(raw, key, hashKey); err != nil {
+					if errors.Is(err, ErrRecordLength) {
+						err = fmt.Errorf("%w: raw body of log record %q at %s", err, record.Name(), record.Timestamp())
+					}
+					errs = multierr.Append(errs, err)
+				}
+				return true
+			})
+		}
+	}
+
+	if rest.LogRecordCount() == 0 {
+		return bt, errs
+	}
+	encoded, err := p.Encoder.Logs(rest)
+	if err != nil {
+		errs = multierr.Append(errs, err)
+	}
+	if encoded != nil {
+		errs = multierr.Append(errs, bt.Merge(encoded))
+	}
+	return bt, errs
+}
+
+// rawBody returns the payload held by the RawBodyAttribute of the record.
+func rawBody(record pdata.LogRecord) ([]byte, bool) {
+	v, ok := record.Attributes().Get(RawBodyAttribute)
+	if !ok {
+		return nil, false
+	}
+	switch v.Type() {
+	case pdata.AttributeValueTypeBytes:
+		return v.BytesVal(), true
+	case pdata.AttributeValueTypeString:
+		return []byte(v.StringVal()), true
+	}
+	return nil, false
+}
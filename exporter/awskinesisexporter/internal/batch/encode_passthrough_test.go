@@ -0,0 +1,86 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/model/otlp"
+	"go.opentelemetry.io/collector/model/pdata"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+)
+
+func TestPassthroughLogs(t *testing.T) {
+	t.Parallel()
+
+	next, err := batch.NewEncoder("otlp_proto")
+	require.NoError(t, err, "Must have a valid encoder")
+	enc := batch.NewPassthrough(next)
+
+	raw := []byte{0x0a, 0x03, 'r', 'a', 'w', 0xff}
+	ld := pdata.NewLogs()
+	rl := ld.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().InsertString("service.name", "service")
+	logs := rl.InstrumentationLibraryLogs().AppendEmpty().Logs()
+	logs.AppendEmpty().Attributes().InsertBytes(batch.RawBodyAttribute, raw)
+	logs.AppendEmpty().Attributes().InsertString(batch.RawBodyAttribute, "raw string")
+	logs.AppendEmpty().SetName("encoded")
+
+	bt, err := enc.Logs(ld)
+	require.NoError(t, err, "Must not error when encoding logs")
+
+	chunks := bt.Chunk()
+	require.Len(t, chunks, 1, "Must have exactly one chunk")
+	require.Len(t, chunks[0], 3, "Must have a record for each raw body and one for the remaining logs")
+
+	assert.Equal(t, raw, chunks[0][0].Data, "Must have written the raw body as is")
+	assert.Equal(t, []byte("raw string"), chunks[0][1].Data, "Must have written the raw string as is")
+
+	decoded, err := otlp.NewProtobufLogsUnmarshaler().UnmarshalLogs(chunks[0][2].Data)
+	require.NoError(t, err, "Must be able to decode the remaining logs")
+	require.Equal(t, 1, decoded.LogRecordCount(), "Must have only encoded the logs without a raw body")
+	assert.Equal(t, "encoded", decoded.ResourceLogs().At(0).InstrumentationLibraryLogs().At(0).Logs().At(0).Name())
+	assert.Equal(t, 3, ld.LogRecordCount(), "Must not modify the provided logs")
+
+	large := pdata.NewLogs()
+	large.ResourceLogs().AppendEmpty().InstrumentationLibraryLogs().AppendEmpty().Logs().AppendEmpty().
+		Attributes().InsertBytes(batch.RawBodyAttribute, bytes.Repeat([]byte("a"), batch.MaxRecordSize))
+	_, err = enc.Logs(large)
+	assert.ErrorIs(t, err, batch.ErrRecordLength, "Must apply the record size limit to raw bodies")
+}
+
+func TestPassthroughRawBodyHeader(t *testing.T) {
+	t.Parallel()
+
+	next, err := batch.NewEncoder("otlp_proto")
+	require.NoError(t, err, "Must have a valid encoder")
+	enc := batch.NewPassthrough(next, batch.WithRawBodyHeader(map[string]string{"region": "us-west-2", "env": "test"}))
+
+	ld := pdata.NewLogs()
+	ld.ResourceLogs().AppendEmpty().InstrumentationLibraryLogs().AppendEmpty().Logs().AppendEmpty().
+		Attributes().InsertString(batch.RawBodyAttribute, "raw string")
+
+	bt, err := enc.Logs(ld)
+	require.NoError(t, err, "Must not error when encoding logs")
+	chunks := bt.Chunk()
+	require.Len(t, chunks, 1, "Must have exactly one chunk")
+	require.Len(t, chunks[0], 1, "Must have exactly one record")
+	assert.Equal(t, "{\"env\":\"test\",\"region\":\"us-west-2\"}\nraw string", string(chunks[0][0].Data),
+		"Must have prepended the header to the raw body")
+}
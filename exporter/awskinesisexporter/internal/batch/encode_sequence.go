@@ -0,0 +1,80 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"sync/atomic"
+
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+const (
+	// SequenceAttribute is the resource attribute set to the sequence number of
+	// the export, which increases by one with every export of the encoder.
+	SequenceAttribute = "kinesis.batch.sequence"
+	// InstanceIDAttribute is the resource attribute set to the id of the encoder,
+	// which changes when the collector restarts and the sequence starts again.
+	InstanceIDAttribute = "kinesis.instance.id"
+)
+
+type batchSequence struct {
+	next Encoder
+
+	instanceID string
+	// sequence is the last sequence number, updated atomically
+	sequence *uint64
+}
+
+var _ Encoder = (*batchSequence)(nil)
+
+// NewBatchSequence returns an Encoder that sets the sequence number of each export
+// and the instance id on every resource before it is encoded by the provided encoder,
+// so that consumers can detect the exports that were lost by a gap in the sequence.
+// The sequence numbers start from 1 and can be taken concurrently.
+func NewBatchSequence(next Encoder, instanceID string) Encoder {
+	return batchSequence{next: next, instanceID: instanceID, sequence: new(uint64)}
+}
+
+func (bs batchSequence) apply(attrs pdata.AttributeMap, sequence uint64) {
+	attrs.UpsertInt(SequenceAttribute, int64(sequence))
+	attrs.UpsertString(InstanceIDAttribute, bs.instanceID)
+}
+
+func (bs batchSequence) Traces(td pdata.Traces) (*Batch, error) {
+	sequence := atomic.AddUint64(bs.sequence, 1)
+	td = td.Clone()
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		bs.apply(td.ResourceSpans().At(i).Resource().Attributes(), sequence)
+	}
+	return bs.next.Traces(td)
+}
+
+func (bs batchSequence) Metrics(md pdata.Metrics) (*Batch, error) {
+	sequence := atomic.AddUint64(bs.sequence, 1)
+	md = md.Clone()
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		bs.apply(md.ResourceMetrics().At(i).Resource().Attributes(), sequence)
+	}
+	return bs.next.Metrics(md)
+}
+
+func (bs batchSequence) Logs(ld pdata.Logs) (*Batch, error) {
+	sequence := atomic.AddUint64(bs.sequence, 1)
+	ld = ld.Clone()
+	for i := 0; i < ld.ResourceLogs().Len(); i++ {
+		bs.apply(ld.ResourceLogs().At(i).Resource().Attributes(), sequence)
+	}
+	return bs.next.Logs(ld)
+}
@@ -0,0 +1,94 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch_test
+
+import (
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/model/otlp"
+	"go.opentelemetry.io/collector/model/pdata"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+)
+
+func TestBatchSequence(t *testing.T) {
+	t.Parallel()
+
+	next, err := batch.NewEncoder("otlp_proto")
+	require.NoError(t, err, "Must have a valid encoder")
+	enc := batch.NewBatchSequence(next, "instance-1")
+
+	td := pdata.NewTraces()
+	for i := 0; i < 2; i++ {
+		td.ResourceSpans().AppendEmpty().InstrumentationLibrarySpans().AppendEmpty().Spans().AppendEmpty().SetName("span")
+	}
+	sequences := func(bt *batch.Batch) (values []int64) {
+		for _, chunk := range bt.Chunk() {
+			for _, record := range chunk {
+				decoded, err := otlp.NewProtobufTracesUnmarshaler().UnmarshalTraces(record.Data)
+				require.NoError(t, err, "Must be able to decode the record")
+				for i := 0; i < decoded.ResourceSpans().Len(); i++ {
+					attrs := decoded.ResourceSpans().At(i).Resource().Attributes()
+					id, ok := attrs.Get(batch.InstanceIDAttribute)
+					require.True(t, ok, "Must have set the instance id")
+					assert.Equal(t, "instance-1", id.StringVal())
+					sequence, ok := attrs.Get(batch.SequenceAttribute)
+					require.True(t, ok, "Must have set the sequence")
+					values = append(values, sequence.IntVal())
+				}
+			}
+		}
+		return values
+	}
+
+	var last int64
+	for i := 0; i < 3; i++ {
+		bt, err := enc.Traces(td)
+		require.NoError(t, err, "Must not error when encoding traces")
+		values := sequences(bt)
+		require.Len(t, values, 2, "Must have set the sequence of every resource")
+		assert.Equal(t, values[0], values[1], "Must use the same sequence for every resource of an export")
+		assert.Greater(t, values[0], last, "Must have increased the sequence with every export")
+		last = values[0]
+	}
+	_, ok := td.ResourceSpans().At(0).Resource().Attributes().Get(batch.SequenceAttribute)
+	assert.False(t, ok, "Must not modify the provided traces")
+
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		got []int64
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bt, err := enc.Traces(td)
+			assert.NoError(t, err, "Must not error when encoding traces")
+			mu.Lock()
+			got = append(got, sequences(bt)[0])
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+	for i, sequence := range got {
+		assert.Equal(t, last+int64(i)+1, sequence, "Must take every sequence once when exporting concurrently")
+	}
+}
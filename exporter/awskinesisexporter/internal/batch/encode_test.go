@@ -0,0 +1,428 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/model/otlp"
+	"go.opentelemetry.io/collector/model/pdata"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/compress"
+)
+
+type fakeEncoder struct {
+	batchOptions []batch.Option
+}
+
+var _ batch.Encoder = (*fakeEncoder)(nil)
+
+func (fe fakeEncoder) encode(payload string) (*batch.Batch, error) {
+	bt := batch.New(fe.batchOptions...)
+	return bt, bt.AddRecord([]byte(payload), "fake-key")
+}
+
+func (fe fakeEncoder) Traces(_ pdata.Traces) (*batch.Batch, error) {
+	return fe.encode("fake-traces")
+}
+
+func (fe fakeEncoder) Metrics(_ pdata.Metrics) (*batch.Batch, error) {
+	return fe.encode("fake-metrics")
+}
+
+func (fe fakeEncoder) Logs(_ pdata.Logs) (*batch.Batch, error) {
+	return fe.encode("fake-logs")
+}
+
+func TestRegisteredEncoder(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, batch.RegisterEncoder("fake", func(batchOptions ...batch.Option) batch.Encoder {
+		return fakeEncoder{batchOptions: batchOptions}
+	}), "Must not error when registering a new encoder")
+
+	assert.Error(t, batch.RegisterEncoder("fake", func(batchOptions ...batch.Option) batch.Encoder {
+		return fakeEncoder{batchOptions: batchOptions}
+	}), "Must error when registering an existing name")
+
+	enc, err := batch.NewEncoder("fake")
+	require.NoError(t, err, "Must have found the registered encoder")
+
+	bt, err := enc.Traces(pdata.NewTraces())
+	require.NoError(t, err, "Must not error when encoding traces")
+
+	chunks := bt.Chunk()
+	require.Len(t, chunks, 1, "Must have exactly one chunk")
+	require.Len(t, chunks[0], 1, "Must have exactly one record")
+	assert.Equal(t, []byte("fake-traces"), chunks[0][0].Data, "Must have used the fake encoder output")
+	assert.Equal(t, "fake-key", *chunks[0][0].PartitionKey, "Must have used the fake encoder partition key")
+}
+
+func TestUnknownEncoder(t *testing.T) {
+	t.Parallel()
+
+	enc, err := batch.NewEncoder("not-a-real-encoder")
+	assert.ErrorIs(t, err, batch.ErrUnknownExportEncoder, "Must error with unknown encoder")
+	assert.Nil(t, enc, "Must not return an encoder")
+}
+
+func TestDefaultEncoders(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"jaeger_proto", "otlp_proto", "otlp_json", "ndjson"} {
+		enc, err := batch.NewEncoder(name)
+		assert.NoError(t, err, "Must have registered %s by default", name)
+		assert.NotNil(t, enc, "Must return a valid encoder for %s", name)
+	}
+}
+
+func TestOTLPProtoEncoderRecordPerResource(t *testing.T) {
+	t.Parallel()
+
+	enc, err := batch.NewEncoder("otlp_proto")
+	require.NoError(t, err, "Must have a valid encoder")
+
+	td := pdata.NewTraces()
+	for i := 0; i < 3; i++ {
+		td.ResourceSpans().AppendEmpty().InstrumentationLibrarySpans().AppendEmpty().Spans().AppendEmpty().SetName("span")
+	}
+
+	bt, err := enc.Traces(td)
+	require.NoError(t, err, "Must not error when encoding traces")
+
+	chunks := bt.Chunk()
+	require.Len(t, chunks, 1, "Must have exactly one chunk")
+	assert.Len(t, chunks[0], 3, "Must have one record per resource")
+}
+
+// singleResourceTraces returns traces of a single resource with spans
+// across libraries, and the same traces followed by an empty resource
+// that is dropped by the payload validation so that every resource
+// is copied into its own export before being encoded.
+func singleResourceTraces(spans int) (single, general pdata.Traces) {
+	single = pdata.NewTraces()
+	rs := single.ResourceSpans().AppendEmpty()
+	rs.SetSchemaUrl("https://opentelemetry.io/schemas/1.6.1")
+	rs.Resource().Attributes().InsertString("service.name", "checkout")
+	rs.Resource().Attributes().InsertInt("service.instance", 7)
+	for _, lib := range []string{"http", "db"} {
+		ils := rs.InstrumentationLibrarySpans().AppendEmpty()
+		ils.InstrumentationLibrary().SetName(lib)
+		for i := 0; i < spans; i++ {
+			span := ils.Spans().AppendEmpty()
+			span.SetName(fmt.Sprintf("%s-%d", lib, i))
+			span.SetTraceID(pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, byte(i)}))
+			span.SetSpanID(pdata.NewSpanID([8]byte{1, 2, 3, 4, 5, 6, 7, byte(i)}))
+			span.Attributes().InsertString("http.method", "GET")
+		}
+	}
+
+	general = single.Clone()
+	general.ResourceSpans().AppendEmpty()
+	return single, general
+}
+
+func TestOTLPEncoderSingleResourceMatchesGeneral(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"otlp_proto", "otlp_json"} {
+		enc, err := batch.NewEncoder(name, batch.WithPayloadValidation(zap.NewNop()))
+		require.NoError(t, err, "Must have a valid encoder")
+
+		single, general := singleResourceTraces(10)
+		fast, err := enc.Traces(single)
+		require.NoError(t, err, "Must not error when encoding a single resource")
+		slow, err := enc.Traces(general)
+		require.NoError(t, err, "Must not error when encoding every resource")
+
+		// The partition keys are random by default so only the data is compared
+		require.Equal(t, 1, fast.Len(), "Must have a single record for the resource")
+		require.Equal(t, 1, slow.Len(), "Must have dropped the empty resource")
+		assert.Equal(t, slow.Chunk()[0][0].Data, fast.Chunk()[0][0].Data, "Must encode %s records identical to the general path", name)
+	}
+}
+
+func BenchmarkOTLPProtoSingleResourceTraces(b *testing.B) {
+	enc, err := batch.NewEncoder("otlp_proto", batch.WithPayloadValidation(zap.NewNop()))
+	require.NoError(b, err, "Must have a valid encoder")
+	single, general := singleResourceTraces(50)
+
+	for _, bench := range []struct {
+		name string
+		td   pdata.Traces
+	}{
+		{name: "fast", td: single},
+		{name: "general", td: general},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := enc.Traces(bench.td); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestOTLPJSONEncoderRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	enc, err := batch.NewEncoder("otlp_json")
+	require.NoError(t, err, "Must have a valid encoder")
+
+	td := pdata.NewTraces()
+	for i := 0; i < 2; i++ {
+		rs := td.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().InsertString("service.name", "round-trip")
+		spans := rs.InstrumentationLibrarySpans().AppendEmpty().Spans()
+		for _, name := range []string{"first", "second"} {
+			span := spans.AppendEmpty()
+			span.SetName(name)
+			span.SetTraceID(pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}))
+			span.SetSpanID(pdata.NewSpanID([8]byte{1, 2, 3, 4, 5, 6, 7, 8}))
+		}
+	}
+
+	bt, err := enc.Traces(td)
+	require.NoError(t, err, "Must not error when encoding traces")
+
+	chunks := bt.Chunk()
+	require.Len(t, chunks, 1, "Must have exactly one chunk")
+	require.Len(t, chunks[0], 2, "Must have one record per resource")
+
+	for i, record := range chunks[0] {
+		decoded, err := otlp.NewJSONTracesUnmarshaler().UnmarshalTraces(record.Data)
+		require.NoError(t, err, "Must be able to parse the encoded record")
+
+		expect := pdata.NewTraces()
+		td.ResourceSpans().At(i).CopyTo(expect.ResourceSpans().AppendEmpty())
+		assert.Equal(t, expect, decoded, "Must match the original resource spans")
+	}
+}
+
+// largeSpan appends a span with attributes that total to roughly size bytes.
+func largeSpan(spans pdata.SpanSlice, name string, size int) {
+	span := spans.AppendEmpty()
+	span.SetName(name)
+	span.SetTraceID(pdata.NewTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}))
+	span.SetSpanID(pdata.NewSpanID([8]byte{1, 2, 3, 4, 5, 6, 7, 8}))
+	value := strings.Repeat("v", 1<<10)
+	for i := 0; i < size>>10; i++ {
+		span.Attributes().InsertString(fmt.Sprintf("attribute.%d", i), value)
+	}
+}
+
+func TestOTLPProtoEncoderSplitsLargeTraces(t *testing.T) {
+	t.Parallel()
+
+	enc, err := batch.NewEncoder("otlp_proto")
+	require.NoError(t, err, "Must have a valid encoder")
+
+	td := pdata.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().InstrumentationLibrarySpans().AppendEmpty().Spans()
+	for _, name := range []string{"first", "second", "third"} {
+		largeSpan(spans, name, 600<<10)
+	}
+
+	bt, err := enc.Traces(td)
+	require.NoError(t, err, "Must not error when encoding large traces")
+
+	chunks := bt.Chunk()
+	require.Len(t, chunks, 1, "Must have exactly one chunk")
+	require.Len(t, chunks[0], 3, "Must have split the resource across three records")
+
+	var names []string
+	for _, record := range chunks[0] {
+		assert.LessOrEqual(t, len(record.Data), batch.MaxRecordSize, "Must fit within the record limit")
+		assert.Equal(t, chunks[0][0].PartitionKey, record.PartitionKey, "Must use the same partition key for every split record")
+
+		decoded, err := otlp.NewProtobufTracesUnmarshaler().UnmarshalTraces(record.Data)
+		require.NoError(t, err, "Must be able to parse the encoded record")
+		spans := decoded.ResourceSpans().At(0).InstrumentationLibrarySpans().At(0).Spans()
+		for i := 0; i < spans.Len(); i++ {
+			names = append(names, spans.At(i).Name())
+		}
+	}
+	assert.Equal(t, []string{"first", "second", "third"}, names, "Must have kept every span in order")
+}
+
+func TestOTLPProtoEncoderSpanTooLarge(t *testing.T) {
+	t.Parallel()
+
+	enc, err := batch.NewEncoder("otlp_proto")
+	require.NoError(t, err, "Must have a valid encoder")
+
+	td := pdata.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().InstrumentationLibrarySpans().AppendEmpty().Spans()
+	largeSpan(spans, "small", 1<<10)
+	largeSpan(spans, "oversized", 1200<<10)
+
+	bt, err := enc.Traces(td)
+	assert.True(t, consumererror.IsPermanent(err), "Must return a permanent error for an oversized span")
+	assert.Contains(t, err.Error(), `span "oversized"`, "Must identify the oversized span")
+	assert.Len(t, bt.Chunk(), 1, "Must still contain the records that fit")
+}
+
+func TestOTLPProtoEncoderMaxRecordSize(t *testing.T) {
+	t.Parallel()
+
+	td := pdata.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().InstrumentationLibrarySpans().AppendEmpty().Spans()
+	for _, name := range []string{"first", "second", "third", "fourth"} {
+		largeSpan(spans, name, 200<<10)
+	}
+
+	records := func(opts ...batch.Option) []*kinesis.PutRecordsRequestEntry {
+		enc, err := batch.NewEncoder("otlp_proto", opts...)
+		require.NoError(t, err, "Must have a valid encoder")
+		bt, err := enc.Traces(td)
+		require.NoError(t, err, "Must not error when encoding the traces")
+		return bt.Chunk()[0]
+	}
+
+	assert.Len(t, records(), 1, "Must fit every span in a single record with the default limit")
+
+	limited := records(batch.WithMaxRecordSize(256 << 10))
+	assert.Len(t, limited, 4, "Must have split every span into its own record")
+	for _, record := range limited {
+		assert.LessOrEqual(t, len(record.Data)+len(*record.PartitionKey), 256<<10, "Must fit within the lowered limit")
+	}
+
+	c, err := compress.NewCompressor(compress.Gzip, 0)
+	require.NoError(t, err, "Must have a valid compressor")
+	assert.Len(t, records(batch.WithMaxRecordSize(256<<10), batch.WithCompression(c)), 1,
+		"Must check the lowered limit against the compressed record")
+
+	largeSpan(spans, "oversized", 300<<10)
+	enc, err := batch.NewEncoder("otlp_proto", batch.WithMaxRecordSize(256<<10))
+	require.NoError(t, err, "Must have a valid encoder")
+	_, err = enc.Traces(td)
+	assert.True(t, consumererror.IsPermanent(err), "Must return a permanent error for a span above the lowered limit")
+	assert.Contains(t, err.Error(), "exceeds 262144 bytes", "Must report the lowered limit")
+	assert.Contains(t, err.Error(), `span "oversized"`, "Must identify the oversized span")
+}
+
+func TestOTLPEncoderMetrics(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"otlp_proto", "otlp_json"} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			enc, err := batch.NewEncoder(name, batch.WithPartitioner(batch.NewAttributePartitioner("service.name", nil)))
+			require.NoError(t, err, "Must have a valid encoder")
+
+			md := pdata.NewMetrics()
+			for _, service := range []string{"first", "second", "third"} {
+				rm := md.ResourceMetrics().AppendEmpty()
+				rm.Resource().Attributes().InsertString("service.name", service)
+				metric := rm.InstrumentationLibraryMetrics().AppendEmpty().Metrics().AppendEmpty()
+				metric.SetName("requests")
+				metric.SetDataType(pdata.MetricDataTypeSum)
+				metric.Sum().DataPoints().AppendEmpty().SetIntVal(1)
+			}
+
+			bt, err := enc.Metrics(md)
+			require.NoError(t, err, "Must not error when encoding metrics")
+
+			chunks := bt.Chunk()
+			require.Len(t, chunks, 1, "Must have exactly one chunk")
+			require.Len(t, chunks[0], 3, "Must have one record per resource")
+			for i, key := range []string{"first", "second", "third"} {
+				assert.Equal(t, key, *chunks[0][i].PartitionKey, "Must partition by the resource attribute")
+			}
+		})
+	}
+}
+
+func TestOTLPProtoEncoderSplitsLargeMetrics(t *testing.T) {
+	t.Parallel()
+
+	enc, err := batch.NewEncoder("otlp_proto")
+	require.NoError(t, err, "Must have a valid encoder")
+
+	md := pdata.NewMetrics()
+	metrics := md.ResourceMetrics().AppendEmpty().InstrumentationLibraryMetrics().AppendEmpty().Metrics()
+	for _, name := range []string{"first", "second", "third", "oversized"} {
+		metric := metrics.AppendEmpty()
+		metric.SetName(name)
+		metric.SetDescription(strings.Repeat("d", 600<<10))
+	}
+	metrics.At(3).SetDescription(strings.Repeat("d", 1200<<10))
+
+	bt, err := enc.Metrics(md)
+	assert.True(t, consumererror.IsPermanent(err), "Must return a permanent error for an oversized metric")
+	assert.Contains(t, err.Error(), `metric "oversized"`, "Must identify the oversized metric")
+
+	chunks := bt.Chunk()
+	require.Len(t, chunks, 1, "Must have exactly one chunk")
+	assert.Len(t, chunks[0], 3, "Must have split the metrics that fit across three records")
+}
+
+func TestOTLPEncoderLogs(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"otlp_proto", "otlp_json"} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			enc, err := batch.NewEncoder(name)
+			require.NoError(t, err, "Must have a valid encoder")
+
+			ld := pdata.NewLogs()
+			for i := 0; i < 3; i++ {
+				ld.ResourceLogs().AppendEmpty().InstrumentationLibraryLogs().AppendEmpty().Logs().AppendEmpty().SetName("log")
+			}
+
+			bt, err := enc.Logs(ld)
+			require.NoError(t, err, "Must not error when encoding logs")
+
+			chunks := bt.Chunk()
+			require.Len(t, chunks, 1, "Must have exactly one chunk")
+			assert.Len(t, chunks[0], 3, "Must have one record per resource")
+		})
+	}
+}
+
+func TestOTLPProtoEncoderSplitsLargeLogs(t *testing.T) {
+	t.Parallel()
+
+	enc, err := batch.NewEncoder("otlp_proto")
+	require.NoError(t, err, "Must have a valid encoder")
+
+	ld := pdata.NewLogs()
+	logs := ld.ResourceLogs().AppendEmpty().InstrumentationLibraryLogs().AppendEmpty().Logs()
+	for _, name := range []string{"first", "second", "third", "oversized"} {
+		record := logs.AppendEmpty()
+		record.SetName(name)
+		record.Body().SetStringVal(strings.Repeat("b", 600<<10))
+	}
+	logs.At(3).Body().SetStringVal(strings.Repeat("b", 1200<<10))
+
+	bt, err := enc.Logs(ld)
+	assert.True(t, consumererror.IsPermanent(err), "Must return a permanent error for an oversized log record")
+	assert.Contains(t, err.Error(), `log record "oversized"`, "Must identify the oversized log record")
+
+	chunks := bt.Chunk()
+	require.Len(t, chunks, 1, "Must have exactly one chunk")
+	assert.Len(t, chunks[0], 3, "Must have split the log records that fit across three records")
+}
@@ -0,0 +1,72 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/glue"
+	"github.com/aws/aws-sdk-go/service/glue/glueiface"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+)
+
+type glueRegistry struct {
+	client   glueiface.GlueAPI
+	registry string
+	schema   string
+}
+
+var _ SchemaRegistry = (*glueRegistry)(nil)
+
+// NewGlueRegistry creates a SchemaRegistry that looks up the version of the schema
+// within the AWS Glue Schema Registry, the schema version must already be registered.
+func NewGlueRegistry(client glueiface.GlueAPI, registry, schema string) SchemaRegistry {
+	return &glueRegistry{client: client, registry: registry, schema: schema}
+}
+
+func (gr *glueRegistry) SchemaVersionID(ctx context.Context, definition string) (id [16]byte, err error) {
+	out, err := gr.client.GetSchemaByDefinitionWithContext(ctx, &glue.GetSchemaByDefinitionInput{
+		SchemaId: &glue.SchemaId{
+			RegistryName: aws.String(gr.registry),
+			SchemaName:   aws.String(gr.schema),
+		},
+		SchemaDefinition: aws.String(definition),
+	})
+	if err != nil {
+		aerr, ok := err.(awserr.Error)
+		err = fmt.Errorf("failed to resolve the version of schema %q in registry %q: %w", gr.schema, gr.registry, err)
+		if ok && aerr.Code() == glue.ErrCodeEntityNotFoundException {
+			// The schema version has to be registered before it can be used
+			err = consumererror.NewPermanent(err)
+		}
+		return id, err
+	}
+	if status := aws.StringValue(out.Status); status != glue.SchemaVersionStatusAvailable {
+		return id, fmt.Errorf("schema %q in registry %q is not available, its status is %s", gr.schema, gr.registry, status)
+	}
+
+	version := aws.StringValue(out.SchemaVersionId)
+	b, err := hex.DecodeString(strings.ReplaceAll(version, "-", ""))
+	if err != nil || len(b) != len(id) {
+		return id, fmt.Errorf("invalid schema version id %q", version)
+	}
+	copy(id[:], b)
+	return id, nil
+}
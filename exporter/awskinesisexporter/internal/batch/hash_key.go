@@ -0,0 +1,58 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"crypto/md5" //nolint:gosec // md5 is used by kinesis to map partition keys to hash keys
+	"math/big"
+
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+// maxHashKey is the largest explicit hash key accepted by kinesis, 2^128 - 1
+var maxHashKey = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+
+// WithExplicitHashKeySource sets the resource attribute used to derive
+// the explicit hash key of records created from a resource.
+func WithExplicitHashKeySource(attribute string) Option {
+	return func(bt *Batch) {
+		bt.hashKeySource = attribute
+	}
+}
+
+// ExplicitHashKey returns the explicit hash key for records created from
+// the provided resource, an empty key is returned if no source is set
+// or the resource does not have the attribute.
+func (b *Batch) ExplicitHashKey(resource pdata.Resource) string {
+	if b.hashKeySource == "" {
+		return ""
+	}
+	v, ok := resource.Attributes().Get(b.hashKeySource)
+	if !ok || v.AsString() == "" {
+		return ""
+	}
+	return explicitHashKey(v.AsString())
+}
+
+// explicitHashKey returns the value if it is already a decimal 128 bit
+// unsigned integer, otherwise the value is hashed into one using md5 which
+// matches how kinesis maps partition keys to hash keys.
+func explicitHashKey(value string) string {
+	if key, ok := new(big.Int).SetString(value, 10); ok && key.Sign() >= 0 && key.Cmp(maxHashKey) <= 0 {
+		return key.String()
+	}
+	sum := md5.Sum([]byte(value)) //nolint:gosec // md5 is used by kinesis to map partition keys to hash keys
+	return new(big.Int).SetBytes(sum[:]).String()
+}
@@ -0,0 +1,94 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/model/pdata"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+)
+
+func TestExplicitHashKey(t *testing.T) {
+	t.Parallel()
+
+	max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+
+	bt := batch.New(batch.WithExplicitHashKeySource("tenant.shard"))
+	for _, tc := range []struct {
+		name   string
+		value  string
+		expect string
+	}{
+		{name: "decimal hash key", value: "170141183460469231731687303715884105728", expect: "170141183460469231731687303715884105728"},
+		{name: "max hash key", value: max.String(), expect: max.String()},
+		{name: "hashed attribute", value: "tenant-a"},
+		{name: "out of range", value: new(big.Int).Add(max, big.NewInt(1)).String()},
+	} {
+		resource := pdata.NewResource()
+		resource.Attributes().InsertString("tenant.shard", tc.value)
+
+		key := bt.ExplicitHashKey(resource)
+		v, ok := new(big.Int).SetString(key, 10)
+		require.True(t, ok, "Must be a decimal hash key for %s", tc.name)
+		assert.True(t, v.Sign() >= 0 && v.Cmp(max) <= 0, "Must be within the uint128 range for %s", tc.name)
+		if tc.expect != "" {
+			assert.Equal(t, tc.expect, key, "Must use the value as the hash key for %s", tc.name)
+		}
+		assert.Equal(t, key, bt.ExplicitHashKey(resource), "Must be stable for %s", tc.name)
+	}
+
+	assert.Empty(t, bt.ExplicitHashKey(pdata.NewResource()), "Must not set a hash key without the attribute")
+	assert.Empty(t, batch.New().ExplicitHashKey(pdata.NewResource()), "Must not set a hash key without a source")
+}
+
+func TestExplicitHashKeyRecords(t *testing.T) {
+	t.Parallel()
+
+	for _, opts := range [][]batch.Option{
+		{batch.WithExplicitHashKeySource("tenant.shard")},
+		{batch.WithExplicitHashKeySource("tenant.shard"), batch.WithAggregation()},
+	} {
+		enc, err := batch.NewEncoder("otlp_proto", opts...)
+		require.NoError(t, err, "Must have a valid encoder")
+
+		td := pdata.NewTraces()
+		for _, shard := range []string{"0", "1", ""} {
+			rs := td.ResourceSpans().AppendEmpty()
+			if shard != "" {
+				rs.Resource().Attributes().InsertString("tenant.shard", shard)
+			}
+			rs.InstrumentationLibrarySpans().AppendEmpty().Spans().AppendEmpty().SetName("span")
+		}
+
+		bt, err := enc.Traces(td)
+		require.NoError(t, err, "Must not error when encoding traces")
+
+		chunks := bt.Chunk()
+		require.Len(t, chunks, 1, "Must have exactly one chunk")
+		require.Len(t, chunks[0], 3, "Must have one record per resource")
+
+		for i, expect := range []string{"0", "1", ""} {
+			record := chunks[0][i]
+			assert.NotEmpty(t, aws.StringValue(record.PartitionKey), "Must still set a partition key")
+			assert.Equal(t, expect, aws.StringValue(record.ExplicitHashKey), "Must set the explicit hash key of the resource")
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+)
+
+// KeyLimiter bounds the number of distinct partition keys, the first keys
+// are kept as they are until the limit is reached and every key after that
+// collides with one of them, chosen by the hash of the key.
+type KeyLimiter struct {
+	max int
+
+	mu   sync.RWMutex
+	seen map[string]struct{}
+	keys []string
+}
+
+// NewKeyLimiter returns a KeyLimiter that allows up to max distinct keys,
+// max must be above zero.
+func NewKeyLimiter(max int) *KeyLimiter {
+	return &KeyLimiter{
+		max:  max,
+		seen: make(map[string]struct{}, max),
+		keys: make([]string, 0, max),
+	}
+}
+
+// Limit returns the key to use in place of the key, which is the key itself
+// while it is one of the allowed keys, or while there is room for more keys.
+func (kl *KeyLimiter) Limit(key string) string {
+	kl.mu.RLock()
+	_, seen := kl.seen[key]
+	full := len(kl.keys) >= kl.max
+	if !seen && full {
+		key = kl.collide(key)
+	}
+	kl.mu.RUnlock()
+	if seen || full {
+		return key
+	}
+
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+	if _, seen := kl.seen[key]; seen {
+		return key
+	}
+	if len(kl.keys) >= kl.max {
+		return kl.collide(key)
+	}
+	kl.seen[key] = struct{}{}
+	kl.keys = append(kl.keys, key)
+	return key
+}
+
+// collide returns the allowed key that the key maps to, the allowed keys
+// must be full so that a key always maps to the same allowed key.
+func (kl *KeyLimiter) collide(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return kl.keys[binary.BigEndian.Uint64(sum[:8])%uint64(len(kl.keys))]
+}
+
+// WithKeyLimiter bounds the distinct partition keys of the records using
+// the key limiter, the keys are limited before they are sampled or salted.
+func WithKeyLimiter(limiter *KeyLimiter) Option {
+	return func(bt *Batch) {
+		bt.keyLimiter = limiter
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+)
+
+func TestKeyLimiter(t *testing.T) {
+	t.Parallel()
+
+	const limit = 8
+	kl := batch.NewKeyLimiter(limit)
+
+	for i := 0; i < limit; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		assert.Equal(t, key, kl.Limit(key), "Must keep the keys within the limit")
+	}
+
+	distinct := make(map[string]struct{})
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		limited := kl.Limit(key)
+		distinct[limited] = struct{}{}
+		assert.Equal(t, limited, kl.Limit(key), "Must map the key %q to the same key", key)
+	}
+	assert.Len(t, distinct, limit, "Must map the keys onto at most the limit of distinct keys")
+}
+
+func TestKeyLimiterConcurrent(t *testing.T) {
+	t.Parallel()
+
+	const limit = 16
+	kl := batch.NewKeyLimiter(limit)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		distinct = make(map[string]struct{})
+	)
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				limited := kl.Limit(fmt.Sprintf("key-%d-%d", w, i))
+				mu.Lock()
+				distinct[limited] = struct{}{}
+				mu.Unlock()
+			}
+		}(w)
+	}
+	wg.Wait()
+	assert.Len(t, distinct, limit, "Must not exceed the limit when keys are added concurrently")
+}
+
+func TestKeyLimitedRecords(t *testing.T) {
+	t.Parallel()
+
+	bt := batch.New(batch.WithKeyLimiter(batch.NewKeyLimiter(3)), batch.WithPartitionKeySalt("-salt"))
+	for i := 0; i < 100; i++ {
+		require.NoError(t, bt.AddRecord([]byte("data"), fmt.Sprintf("key-%d", i)))
+	}
+
+	keys := make(map[string]struct{})
+	for _, chunk := range bt.Chunk() {
+		for _, record := range chunk {
+			keys[*record.PartitionKey] = struct{}{}
+		}
+	}
+	assert.Equal(t, map[string]struct{}{
+		"key-0-salt": {},
+		"key-1-salt": {},
+		"key-2-salt": {},
+	}, keys, "Must limit the keys before they are salted")
+}
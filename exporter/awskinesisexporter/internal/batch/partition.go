@@ -0,0 +1,317 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/collector/model/pdata"
+	"go.uber.org/zap"
+)
+
+// MaxPartitionKeyLength is the kinesis limit of the partition key size
+const MaxPartitionKeyLength = 256
+
+// truncateKey cuts the key down to at most limit bytes,
+// backing up to a rune boundary so that a multi-byte character is not split.
+func truncateKey(key string, limit int) string {
+	if len(key) <= limit {
+		return key
+	}
+	for limit > 0 && !utf8.RuneStart(key[limit]) {
+		limit--
+	}
+	return key[:limit]
+}
+
+// Partitioner derives the partition key of a record
+// from the resource that the record was created from.
+type Partitioner interface {
+	Partition(resource pdata.Resource) string
+}
+
+// TracePartitioner is implemented by partitioners that require
+// the spans of each trace to be exported within the same records.
+// Encoders that support it will group spans by trace id before encoding
+// and use PartitionTrace to derive the key for each group.
+type TracePartitioner interface {
+	Partitioner
+
+	PartitionTrace(resource pdata.Resource, traceID pdata.TraceID) string
+}
+
+// LogPartitioner is implemented by partitioners that are able to derive
+// the partition key from the log record itself. Encoders that support it
+// will group log records by their key before encoding, records that
+// PartitionLog does not provide a key for use the key of their resource.
+type LogPartitioner interface {
+	Partitioner
+
+	PartitionLog(record pdata.LogRecord) (string, bool)
+}
+
+// SpanPartitioner is implemented by partitioners that are able to derive
+// the partition key from the span itself. Encoders that support it will
+// group spans by their key before encoding, spans that PartitionSpan does
+// not provide a key for use the key of their resource.
+type SpanPartitioner interface {
+	Partitioner
+
+	PartitionSpan(resource pdata.Resource, span pdata.Span) (string, bool)
+}
+
+// ContentPartitioner is implemented by partitioners that derive the
+// partition key from the encoded record instead of its resource.
+// The batch replaces the key given by the encoder with PartitionContent
+// when adding each record.
+type ContentPartitioner interface {
+	Partitioner
+
+	PartitionContent(data []byte) string
+}
+
+// ResizablePartitioner is implemented by partitioners that cycle through
+// a number of keys which can be changed while in use, such as to match
+// the number of shards of the stream once it has been resharded.
+type ResizablePartitioner interface {
+	Partitioner
+
+	Resize(n int)
+}
+
+type randomPartitioner struct{}
+
+var _ Partitioner = (*randomPartitioner)(nil)
+
+// NewRandomPartitioner returns a Partitioner that
+// uses a random key for each record.
+func NewRandomPartitioner() Partitioner {
+	return randomPartitioner{}
+}
+
+func (randomPartitioner) Partition(_ pdata.Resource) string {
+	return uuid.NewString()
+}
+
+type attributePartitioner struct {
+	attributes []string
+	fallback   Partitioner
+
+	log       *zap.Logger
+	truncated sync.Once
+}
+
+var _ LogPartitioner = (*attributePartitioner)(nil)
+
+// NewAttributePartitioner returns a Partitioner that uses the value of the
+// resource attribute as the partition key, resources without the attribute
+// are given a random key. Log records that have the attribute use its value
+// instead of the resource attribute.
+// Values that exceed the kinesis partition key limit are truncated
+// which is only logged for the first occurrence.
+func NewAttributePartitioner(attribute string, log *zap.Logger) LogPartitioner {
+	return NewAttributesPartitioner([]string{attribute}, log)
+}
+
+// NewAttributesPartitioner returns a Partitioner like NewAttributePartitioner that
+// uses the value of the first attribute that is set, in order, as the partition key.
+// Resources without any of the attributes are given a random key.
+func NewAttributesPartitioner(attributes []string, log *zap.Logger) LogPartitioner {
+	if log == nil {
+		log = zap.NewNop()
+	}
+	return &attributePartitioner{
+		attributes: attributes,
+		fallback:   NewRandomPartitioner(),
+		log:        log,
+	}
+}
+
+func (ap *attributePartitioner) Partition(resource pdata.Resource) string {
+	if key, ok := ap.key(resource.Attributes()); ok {
+		return key
+	}
+	return ap.fallback.Partition(resource)
+}
+
+func (ap *attributePartitioner) PartitionLog(record pdata.LogRecord) (string, bool) {
+	return ap.key(record.Attributes())
+}
+
+// key returns the value of the first attribute to be used as a partition key,
+// false is returned if none of the attributes are set or they are all empty.
+func (ap *attributePartitioner) key(attrs pdata.AttributeMap) (string, bool) {
+	for _, attribute := range ap.attributes {
+		v, ok := attrs.Get(attribute)
+		if !ok {
+			continue
+		}
+		key := v.AsString()
+		if key == "" {
+			continue
+		}
+		if len(key) > MaxPartitionKeyLength {
+			ap.truncated.Do(func() {
+				ap.log.Warn("Truncating partition key that exceeds the kinesis limit",
+					zap.String("attribute", attribute),
+					zap.Int("length", len(key)),
+					zap.Int("limit", MaxPartitionKeyLength),
+				)
+			})
+			key = truncateKey(key, MaxPartitionKeyLength)
+		}
+		return key, true
+	}
+	return "", false
+}
+
+type traceIDPartitioner struct {
+	fallback Partitioner
+}
+
+var _ TracePartitioner = (*traceIDPartitioner)(nil)
+
+// NewTraceIDPartitioner returns a TracePartitioner that uses the hex
+// encoded trace id as the partition key so that all spans of a trace are
+// written to the same shard. Data without a trace id is given a random key.
+func NewTraceIDPartitioner() TracePartitioner {
+	return traceIDPartitioner{fallback: NewRandomPartitioner()}
+}
+
+func (tp traceIDPartitioner) Partition(resource pdata.Resource) string {
+	return tp.fallback.Partition(resource)
+}
+
+func (tp traceIDPartitioner) PartitionTrace(resource pdata.Resource, traceID pdata.TraceID) string {
+	if traceID.IsEmpty() {
+		return tp.fallback.Partition(resource)
+	}
+	return traceID.HexString()
+}
+
+type contentPartitioner struct{}
+
+var _ ContentPartitioner = (*contentPartitioner)(nil)
+
+// NewContentPartitioner returns a ContentPartitioner that uses the hex
+// encoded SHA-256 hash of the encoded record, before compression, as the
+// partition key so that identical records are written to the same shard.
+func NewContentPartitioner() ContentPartitioner {
+	return contentPartitioner{}
+}
+
+// Partition returns a placeholder key that is replaced
+// once the record has been encoded.
+func (contentPartitioner) Partition(_ pdata.Resource) string {
+	return "content"
+}
+
+func (contentPartitioner) PartitionContent(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+type roundRobinPartitioner struct {
+	// keys holds the []string of keys so that they can be resized while in use
+	keys atomic.Value
+	next uint64
+}
+
+var _ ResizablePartitioner = (*roundRobinPartitioner)(nil)
+
+// NewRoundRobinPartitioner returns a Partitioner that cycles through
+// the keys "0" to "n-1" so that records are spread evenly across shards
+// when the data has no natural partition key. It is safe for concurrent use.
+func NewRoundRobinPartitioner(n int) ResizablePartitioner {
+	rp := &roundRobinPartitioner{}
+	rp.Resize(n)
+	return rp
+}
+
+func (rp *roundRobinPartitioner) Partition(_ pdata.Resource) string {
+	keys := rp.keys.Load().([]string)
+	i := atomic.AddUint64(&rp.next, 1) - 1
+	return keys[i%uint64(len(keys))]
+}
+
+// Resize cycles through the keys "0" to "n-1" from now on.
+func (rp *roundRobinPartitioner) Resize(n int) {
+	if n < 1 {
+		n = 1
+	}
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	rp.keys.Store(keys)
+}
+
+type overridePartitioner struct {
+	Partitioner
+
+	attribute *attributePartitioner
+}
+
+var (
+	_ LogPartitioner  = (*overridePartitioner)(nil)
+	_ SpanPartitioner = (*overridePartitioner)(nil)
+)
+
+// NewOverridePartitioner returns a Partitioner that uses the value of the
+// span or log record attribute as the partition key of that record,
+// overriding the key that next would have given it. Records without the
+// attribute use the key from next, including the key of their trace when
+// next is a TracePartitioner.
+// Values that exceed the kinesis partition key limit are truncated
+// which is only logged for the first occurrence.
+func NewOverridePartitioner(next Partitioner, attribute string, log *zap.Logger) Partitioner {
+	if log == nil {
+		log = zap.NewNop()
+	}
+	return &overridePartitioner{
+		Partitioner: next,
+		attribute: &attributePartitioner{
+			attributes: []string{attribute},
+			fallback:   next,
+			log:        log,
+		},
+	}
+}
+
+func (op *overridePartitioner) PartitionSpan(resource pdata.Resource, span pdata.Span) (string, bool) {
+	if key, ok := op.attribute.key(span.Attributes()); ok {
+		return key, true
+	}
+	if tp, ok := op.Partitioner.(TracePartitioner); ok {
+		return tp.PartitionTrace(resource, span.TraceID()), true
+	}
+	return "", false
+}
+
+func (op *overridePartitioner) PartitionLog(record pdata.LogRecord) (string, bool) {
+	if key, ok := op.attribute.key(record.Attributes()); ok {
+		return key, true
+	}
+	if lp, ok := op.Partitioner.(LogPartitioner); ok {
+		return lp.PartitionLog(record)
+	}
+	return "", false
+}
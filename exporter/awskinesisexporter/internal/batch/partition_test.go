@@ -0,0 +1,346 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/model/otlp"
+	"go.opentelemetry.io/collector/model/pdata"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+)
+
+func TestRandomPartitioner(t *testing.T) {
+	t.Parallel()
+
+	p := batch.NewRandomPartitioner()
+	resource := pdata.NewResource()
+
+	first, second := p.Partition(resource), p.Partition(resource)
+	assert.NotEmpty(t, first, "Must have generated a partition key")
+	assert.NotEqual(t, first, second, "Must generate a new key for each record")
+}
+
+func TestAttributePartitioner(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zap.WarnLevel)
+	p := batch.NewAttributePartitioner("service.name", zap.New(core))
+
+	resource := pdata.NewResource()
+	resource.Attributes().InsertString("service.name", "checkout")
+	assert.Equal(t, "checkout", p.Partition(resource), "Must use the attribute value as the key")
+
+	missing := pdata.NewResource()
+	first, second := p.Partition(missing), p.Partition(missing)
+	assert.NotEmpty(t, first, "Must have generated a fallback key")
+	assert.NotEqual(t, first, second, "Must use a random key when the attribute is missing")
+
+	long := pdata.NewResource()
+	long.Attributes().InsertString("service.name", strings.Repeat("a", 300))
+	for i := 0; i < 3; i++ {
+		assert.Len(t, p.Partition(long), batch.MaxPartitionKeyLength, "Must truncate the key to the kinesis limit")
+	}
+	assert.Equal(t, 1, logs.Len(), "Must have only logged the truncation once")
+
+	multibyte := pdata.NewResource()
+	multibyte.Attributes().InsertString("service.name", strings.Repeat("a", batch.MaxPartitionKeyLength-1)+"é")
+	key := p.Partition(multibyte)
+	assert.Equal(t, strings.Repeat("a", batch.MaxPartitionKeyLength-1), key, "Must not split a multi-byte character at the limit")
+	assert.True(t, utf8.ValidString(key), "Must keep the key valid utf-8")
+}
+
+func TestAttributePartitionedRecords(t *testing.T) {
+	t.Parallel()
+
+	enc, err := batch.NewEncoder("otlp_proto",
+		batch.WithPartitioner(batch.NewAttributePartitioner("service.name", zap.NewNop())),
+	)
+	require.NoError(t, err, "Must have a valid encoder")
+
+	services := []string{"frontend", "checkout", "payments"}
+	td := pdata.NewTraces()
+	for _, name := range services {
+		rs := td.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().InsertString("service.name", name)
+		rs.InstrumentationLibrarySpans().AppendEmpty().Spans().AppendEmpty().SetName("span")
+	}
+
+	bt, err := enc.Traces(td)
+	require.NoError(t, err, "Must not error when encoding traces")
+
+	chunks := bt.Chunk()
+	require.Len(t, chunks, 1, "Must have exactly one chunk")
+
+	var keys []string
+	for _, record := range chunks[0] {
+		keys = append(keys, *record.PartitionKey)
+	}
+	assert.Equal(t, services, keys, "Must have used each service as the partition key")
+}
+
+func TestAttributesPartitionedRecords(t *testing.T) {
+	t.Parallel()
+
+	enc, err := batch.NewEncoder("otlp_proto",
+		batch.WithPartitioner(batch.NewAttributesPartitioner([]string{"service.name", "host.name"}, zap.NewNop())),
+	)
+	require.NoError(t, err, "Must have a valid encoder")
+
+	td := pdata.NewTraces()
+	for _, attrs := range []map[string]string{
+		{"service.name": "checkout", "host.name": "host-a"},
+		{"host.name": "host-b"},
+		{"service.name": "", "host.name": "host-c"},
+		{"deployment.environment": "test"},
+	} {
+		rs := td.ResourceSpans().AppendEmpty()
+		for k, v := range attrs {
+			rs.Resource().Attributes().InsertString(k, v)
+		}
+		rs.InstrumentationLibrarySpans().AppendEmpty().Spans().AppendEmpty().SetName("span")
+	}
+
+	bt, err := enc.Traces(td)
+	require.NoError(t, err, "Must not error when encoding traces")
+
+	chunks := bt.Chunk()
+	require.Len(t, chunks, 1, "Must have exactly one chunk")
+	require.Len(t, chunks[0], 4, "Must have a record for each resource")
+
+	keys := make([]string, 0, len(chunks[0]))
+	for _, record := range chunks[0] {
+		keys = append(keys, *record.PartitionKey)
+	}
+	assert.Equal(t, []string{"checkout", "host-b", "host-c"}, keys[:3], "Must have used the first source that is set as the partition key")
+	assert.NotContains(t, []string{"", "test"}, keys[3], "Must use a random key when none of the sources are set")
+}
+
+func TestTraceIDPartitionedRecords(t *testing.T) {
+	t.Parallel()
+
+	enc, err := batch.NewEncoder("otlp_proto", batch.WithPartitioner(batch.NewTraceIDPartitioner()))
+	require.NoError(t, err, "Must have a valid encoder")
+
+	var (
+		first  = pdata.NewTraceID([16]byte{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1})
+		second = pdata.NewTraceID([16]byte{2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2})
+	)
+
+	td := pdata.NewTraces()
+	for i := 0; i < 2; i++ {
+		rs := td.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().InsertString("service.name", "service")
+		spans := rs.InstrumentationLibrarySpans().AppendEmpty().Spans()
+		// Spans of both traces are interleaved within the same resource
+		for _, id := range []pdata.TraceID{first, second, first} {
+			spans.AppendEmpty().SetTraceID(id)
+		}
+	}
+
+	bt, err := enc.Traces(td)
+	require.NoError(t, err, "Must not error when encoding traces")
+
+	chunks := bt.Chunk()
+	require.Len(t, chunks, 1, "Must have exactly one chunk")
+	require.Len(t, chunks[0], 4, "Must have a record for each trace within each resource")
+
+	var keys []string
+	for _, record := range chunks[0] {
+		keys = append(keys, *record.PartitionKey)
+	}
+	assert.Equal(t, []string{
+		first.HexString(), second.HexString(),
+		first.HexString(), second.HexString(),
+	}, keys, "Must have used the trace id as the partition key")
+
+	decoded, err := otlp.NewProtobufTracesUnmarshaler().UnmarshalTraces(chunks[0][0].Data)
+	require.NoError(t, err, "Must be able to decode the record")
+	assert.Equal(t, 2, decoded.SpanCount(), "Must have grouped the spans of the same trace")
+}
+
+func TestAttributePartitionedLogs(t *testing.T) {
+	t.Parallel()
+
+	enc, err := batch.NewEncoder("otlp_proto",
+		batch.WithPartitioner(batch.NewAttributePartitioner("tenant", zap.NewNop())),
+	)
+	require.NoError(t, err, "Must have a valid encoder")
+
+	ld := pdata.NewLogs()
+	rl := ld.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().InsertString("tenant", "shared")
+	logs := rl.InstrumentationLibraryLogs().AppendEmpty().Logs()
+	// Records of each tenant are interleaved within the same resource
+	for _, tenant := range []string{"first", "second", "", "first"} {
+		record := logs.AppendEmpty()
+		if tenant != "" {
+			record.Attributes().InsertString("tenant", tenant)
+		}
+	}
+
+	bt, err := enc.Logs(ld)
+	require.NoError(t, err, "Must not error when encoding logs")
+
+	chunks := bt.Chunk()
+	require.Len(t, chunks, 1, "Must have exactly one chunk")
+
+	var keys []string
+	for _, record := range chunks[0] {
+		keys = append(keys, *record.PartitionKey)
+	}
+	assert.Equal(t, []string{"first", "second", "shared"}, keys, "Must group the log records by their attribute")
+
+	decoded, err := otlp.NewProtobufLogsUnmarshaler().UnmarshalLogs(chunks[0][0].Data)
+	require.NoError(t, err, "Must be able to decode the record")
+	assert.Equal(t, 2, decoded.LogRecordCount(), "Must have grouped the log records of the same tenant")
+}
+
+func TestOverridePartitionedRecords(t *testing.T) {
+	t.Parallel()
+
+	traceID := pdata.NewTraceID([16]byte{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1})
+	td := pdata.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().InsertString("service.name", "service")
+	spans := rs.InstrumentationLibrarySpans().AppendEmpty().Spans()
+	for i, override := range []string{"", "override"} {
+		span := spans.AppendEmpty()
+		span.SetTraceID(traceID)
+		span.SetSpanID(pdata.NewSpanID([8]byte{byte(i + 1)}))
+		if override != "" {
+			span.Attributes().InsertString("kinesis.partition_key", override)
+		}
+	}
+
+	for _, encoding := range []string{"otlp_proto", "ndjson"} {
+		encoding := encoding
+		t.Run(encoding, func(t *testing.T) {
+			t.Parallel()
+
+			enc, err := batch.NewEncoder(encoding, batch.WithPartitioner(
+				batch.NewOverridePartitioner(batch.NewTraceIDPartitioner(), "kinesis.partition_key", zap.NewNop()),
+			))
+			require.NoError(t, err, "Must have a valid encoder")
+
+			bt, err := enc.Traces(td)
+			require.NoError(t, err, "Must not error when encoding traces")
+
+			chunks := bt.Chunk()
+			require.Len(t, chunks, 1, "Must have exactly one chunk")
+
+			var keys []string
+			for _, record := range chunks[0] {
+				keys = append(keys, *record.PartitionKey)
+			}
+			assert.Equal(t, []string{traceID.HexString(), "override"}, keys, "Must use the attribute value over the trace id")
+		})
+	}
+
+	ld := pdata.NewLogs()
+	logs := ld.ResourceLogs().AppendEmpty().InstrumentationLibraryLogs().AppendEmpty().Logs()
+	logs.AppendEmpty().Attributes().InsertString("kinesis.partition_key", strings.Repeat("a", 300))
+
+	enc, err := batch.NewEncoder("otlp_proto", batch.WithPartitioner(
+		batch.NewOverridePartitioner(batch.NewRandomPartitioner(), "kinesis.partition_key", zap.NewNop()),
+	))
+	require.NoError(t, err, "Must have a valid encoder")
+
+	bt, err := enc.Logs(ld)
+	require.NoError(t, err, "Must not error when encoding logs")
+
+	chunks := bt.Chunk()
+	require.Len(t, chunks, 1, "Must have exactly one chunk")
+	require.Len(t, chunks[0], 1, "Must have exactly one record")
+	assert.Equal(t, strings.Repeat("a", batch.MaxPartitionKeyLength), *chunks[0][0].PartitionKey, "Must truncate the attribute value to the key limit")
+}
+
+func TestContentPartitionedRecords(t *testing.T) {
+	t.Parallel()
+
+	newTraces := func(name string) pdata.Traces {
+		td := pdata.NewTraces()
+		rs := td.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().InsertString("service.name", "service")
+		rs.Resource().Attributes().InsertString("host.name", "host")
+		rs.InstrumentationLibrarySpans().AppendEmpty().Spans().AppendEmpty().SetName(name)
+		return td
+	}
+
+	keys := func(td pdata.Traces) []string {
+		enc, err := batch.NewEncoder("otlp_proto", batch.WithPartitioner(batch.NewContentPartitioner()))
+		require.NoError(t, err, "Must have a valid encoder")
+		bt, err := enc.Traces(td)
+		require.NoError(t, err, "Must not error when encoding traces")
+
+		var keys []string
+		for _, chunk := range bt.Chunk() {
+			for _, record := range chunk {
+				keys = append(keys, *record.PartitionKey)
+			}
+		}
+		return keys
+	}
+
+	first, second := keys(newTraces("span")), keys(newTraces("span"))
+	require.Len(t, first, 1, "Must have exactly one record")
+	assert.Len(t, first[0], 64, "Must have used the hex encoded hash as the key")
+	assert.Equal(t, first, second, "Must use the same key for the same payload")
+	assert.NotEqual(t, first, keys(newTraces("other")), "Must use a different key for a different payload")
+}
+
+func TestRoundRobinPartitioner(t *testing.T) {
+	t.Parallel()
+
+	const (
+		keys    = 4
+		records = 100
+	)
+	p := batch.NewRoundRobinPartitioner(keys)
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		counts = make(map[string]int)
+	)
+	for i := 0; i < records; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key := p.Partition(pdata.NewResource())
+			mu.Lock()
+			counts[key]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, map[string]int{"0": 25, "1": 25, "2": 25, "3": 25}, counts, "Must have spread the records evenly across the keys")
+
+	p.Resize(2)
+	counts = make(map[string]int)
+	for i := 0; i < records; i++ {
+		counts[p.Partition(pdata.NewResource())]++
+	}
+	assert.Equal(t, map[string]int{"0": 50, "1": 50}, counts, "Must have spread the records across the resized keys")
+}
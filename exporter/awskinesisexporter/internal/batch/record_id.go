@@ -0,0 +1,45 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// RecordIDSize is the number of bytes of the record ID prepended to the data of each record.
+const RecordIDSize = 16
+
+// recordID returns the leading bytes of the SHA-256 hash of the partition key
+// and the encoded data, so that the same data written with the same partition
+// key always has the same ID, including when it is written again on retry.
+func recordID(key string, data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(key))
+	// Separating the key from the data so that moving bytes between them changes the ID
+	h.Write([]byte{0})
+	h.Write(data)
+	return h.Sum(nil)[:RecordIDSize]
+}
+
+// SplitRecordID returns the hex encoded record ID and the remaining data
+// of a record written with WithRecordID, false is returned when the
+// data is too short to hold a record ID.
+func SplitRecordID(data []byte) (id string, rest []byte, ok bool) {
+	if len(data) < RecordIDSize {
+		return "", data, false
+	}
+	return hex.EncodeToString(data[:RecordIDSize]), data[RecordIDSize:], true
+}
@@ -0,0 +1,56 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/compress"
+)
+
+func TestRecordID(t *testing.T) {
+	t.Parallel()
+
+	compressor, err := compress.NewCompressor(compress.Gzip, 0)
+	require.NoError(t, err, "Must have a valid compressor")
+
+	ids := func(records ...string) (ids []string) {
+		bt := batch.New(batch.WithRecordID(), batch.WithCompression(compressor), batch.WithCompressionMarkerByte())
+		for i := 0; i < len(records); i += 2 {
+			require.NoError(t, bt.AddRecord([]byte(records[i]), records[i+1]))
+		}
+		for _, record := range bt.Chunk()[0] {
+			id, rest, ok := batch.SplitRecordID(record.Data)
+			require.True(t, ok, "Must have prepended the record id")
+			marker, _ := compress.Marker(compress.Gzip)
+			assert.Equal(t, marker, rest[0], "Must prepend the record id ahead of the compression marker")
+			ids = append(ids, id)
+		}
+		return ids
+	}
+
+	first := ids("payload", "key-a", "payload", "key-b", "other", "key-a")
+	assert.Len(t, first[0], 2*batch.RecordIDSize, "Must be the hex encoded record id")
+	assert.Equal(t, first, ids("payload", "key-a", "payload", "key-b", "other", "key-a"), "Must have the same ids for the same records")
+	assert.NotEqual(t, first[0], first[1], "Must have a different id for a different partition key")
+	assert.NotEqual(t, first[0], first[2], "Must have a different id for different data")
+
+	_, _, ok := batch.SplitRecordID([]byte("short"))
+	assert.False(t, ok, "Must not split data shorter than a record id")
+}
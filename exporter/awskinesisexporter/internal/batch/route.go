@@ -0,0 +1,198 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/collector/model/pdata"
+	"go.uber.org/multierr"
+)
+
+// StreamTemplate resolves the stream of the records created from a resource
+// by replacing each {attribute} placeholder with the value of the resource attribute.
+type StreamTemplate struct {
+	// parts alternate between literal text and attribute names, starting with text
+	parts []string
+}
+
+// NewStreamTemplate parses the template, an error is returned
+// if a placeholder is not closed or does not name an attribute.
+func NewStreamTemplate(template string) (*StreamTemplate, error) {
+	var parts []string
+	rest := template
+	for {
+		open, end := strings.IndexByte(rest, '{'), strings.IndexByte(rest, '}')
+		switch {
+		case open < 0 && end < 0:
+			return &StreamTemplate{parts: append(parts, rest)}, nil
+		case end < 0:
+			return nil, fmt.Errorf("stream template %q has an unclosed placeholder", template)
+		case open < 0 || end < open:
+			return nil, fmt.Errorf("stream template %q has an unopened placeholder", template)
+		}
+		name := rest[open+1 : end]
+		if name == "" || strings.IndexByte(name, '{') >= 0 {
+			return nil, fmt.Errorf("stream template %q has an invalid placeholder", template)
+		}
+		parts = append(parts, rest[:open], name)
+		rest = rest[end+1:]
+	}
+}
+
+// Resolve returns the stream of the resource, false is returned
+// if any of the attributes is missing or empty.
+func (st *StreamTemplate) Resolve(resource pdata.Resource) (string, bool) {
+	var sb strings.Builder
+	for i, part := range st.parts {
+		if i%2 == 0 {
+			sb.WriteString(part)
+			continue
+		}
+		v, ok := resource.Attributes().Get(part)
+		if !ok || v.AsString() == "" {
+			return "", false
+		}
+		sb.WriteString(v.AsString())
+	}
+	return sb.String(), true
+}
+
+type streamRouter struct {
+	next     Encoder
+	template *StreamTemplate
+}
+
+var _ Encoder = (*streamRouter)(nil)
+
+// NewStreamRouter returns an Encoder that groups the resources by the stream
+// resolved by the template and encodes each group with the provided encoder,
+// the batches of the other streams are routed by the returned batch.
+// Resources that the template can not be resolved for use the stream of the Batcher.
+func NewStreamRouter(next Encoder, template *StreamTemplate) Encoder {
+	return streamRouter{next: next, template: template}
+}
+
+// groups returns the indexes of the resources of each resolved
+// stream, ordered by the first resource of each stream.
+func (sr streamRouter) groups(n int, resource func(i int) pdata.Resource) (streams []string, indexes [][]int) {
+	seen := make(map[string]int)
+	for i := 0; i < n; i++ {
+		stream, _ := sr.template.Resolve(resource(i))
+		g, ok := seen[stream]
+		if !ok {
+			g = len(streams)
+			seen[stream] = g
+			streams = append(streams, stream)
+			indexes = append(indexes, nil)
+		}
+		indexes[g] = append(indexes[g], i)
+	}
+	return streams, indexes
+}
+
+// combine sets the stream of each batch and routes the
+// other batches through the first batch.
+func combine(streams []string, batches []*Batch) *Batch {
+	var first *Batch
+	for i, bt := range batches {
+		if bt == nil {
+			continue
+		}
+		bt.stream = streams[i]
+		if first == nil {
+			first = bt
+			continue
+		}
+		first.routes = append(first.routes, bt)
+	}
+	return first
+}
+
+func (sr streamRouter) Traces(td pdata.Traces) (*Batch, error) {
+	rss := td.ResourceSpans()
+	streams, indexes := sr.groups(rss.Len(), func(i int) pdata.Resource { return rss.At(i).Resource() })
+	if len(streams) <= 1 {
+		bt, err := sr.next.Traces(td)
+		if bt != nil && len(streams) == 1 {
+			bt.stream = streams[0]
+		}
+		return bt, err
+	}
+
+	var errs error
+	batches := make([]*Batch, len(streams))
+	for g, group := range indexes {
+		export := pdata.NewTraces()
+		for _, i := range group {
+			rss.At(i).CopyTo(export.ResourceSpans().AppendEmpty())
+		}
+		var err error
+		batches[g], err = sr.next.Traces(export)
+		errs = multierr.Append(errs, err)
+	}
+	return combine(streams, batches), errs
+}
+
+func (sr streamRouter) Metrics(md pdata.Metrics) (*Batch, error) {
+	rms := md.ResourceMetrics()
+	streams, indexes := sr.groups(rms.Len(), func(i int) pdata.Resource { return rms.At(i).Resource() })
+	if len(streams) <= 1 {
+		bt, err := sr.next.Metrics(md)
+		if bt != nil && len(streams) == 1 {
+			bt.stream = streams[0]
+		}
+		return bt, err
+	}
+
+	var errs error
+	batches := make([]*Batch, len(streams))
+	for g, group := range indexes {
+		export := pdata.NewMetrics()
+		for _, i := range group {
+			rms.At(i).CopyTo(export.ResourceMetrics().AppendEmpty())
+		}
+		var err error
+		batches[g], err = sr.next.Metrics(export)
+		errs = multierr.Append(errs, err)
+	}
+	return combine(streams, batches), errs
+}
+
+func (sr streamRouter) Logs(ld pdata.Logs) (*Batch, error) {
+	rls := ld.ResourceLogs()
+	streams, indexes := sr.groups(rls.Len(), func(i int) pdata.Resource { return rls.At(i).Resource() })
+	if len(streams) <= 1 {
+		bt, err := sr.next.Logs(ld)
+		if bt != nil && len(streams) == 1 {
+			bt.stream = streams[0]
+		}
+		return bt, err
+	}
+
+	var errs error
+	batches := make([]*Batch, len(streams))
+	for g, group := range indexes {
+		export := pdata.NewLogs()
+		for _, i := range group {
+			rls.At(i).CopyTo(export.ResourceLogs().AppendEmpty())
+		}
+		var err error
+		batches[g], err = sr.next.Logs(export)
+		errs = multierr.Append(errs, err)
+	}
+	return combine(streams, batches), errs
+}
@@ -0,0 +1,100 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/model/pdata"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+)
+
+func TestStreamTemplate(t *testing.T) {
+	t.Parallel()
+
+	resource := pdata.NewResource()
+	resource.Attributes().InsertString("tenant.id", "tenant-a")
+	resource.Attributes().InsertString("env", "prod")
+
+	for _, tc := range []struct {
+		template string
+		stream   string
+		resolved bool
+	}{
+		{template: "otel-{tenant.id}", stream: "otel-tenant-a", resolved: true},
+		{template: "{env}-{tenant.id}-spans", stream: "prod-tenant-a-spans", resolved: true},
+		{template: "static-stream", stream: "static-stream", resolved: true},
+		{template: "otel-{tenant.id}-{region}"},
+	} {
+		st, err := batch.NewStreamTemplate(tc.template)
+		require.NoError(t, err, "Must parse the template %q", tc.template)
+		stream, ok := st.Resolve(resource)
+		assert.Equal(t, tc.resolved, ok, "Must report if %q was resolved", tc.template)
+		assert.Equal(t, tc.stream, stream, "Must have resolved %q", tc.template)
+	}
+
+	for _, template := range []string{"otel-{tenant.id", "otel-}tenant.id{", "otel-{}", "otel-{{tenant.id}"} {
+		_, err := batch.NewStreamTemplate(template)
+		assert.Error(t, err, "Must error with the invalid template %q", template)
+	}
+}
+
+func TestStreamRouter(t *testing.T) {
+	t.Parallel()
+
+	encoder, err := batch.NewEncoder("otlp_proto")
+	require.NoError(t, err, "Must have a valid encoder")
+	st, err := batch.NewStreamTemplate("otel-{tenant.id}")
+	require.NoError(t, err, "Must parse the template")
+	router := batch.NewStreamRouter(encoder, st)
+
+	td := pdata.NewTraces()
+	for _, tenant := range []string{"tenant-a", "tenant-b", "", "tenant-a"} {
+		rs := td.ResourceSpans().AppendEmpty()
+		if tenant != "" {
+			rs.Resource().Attributes().InsertString("tenant.id", tenant)
+		}
+		rs.InstrumentationLibrarySpans().AppendEmpty().Spans().AppendEmpty().SetName("span")
+	}
+
+	bt, err := router.Traces(td)
+	require.NoError(t, err, "Must not error when routing the traces")
+	assert.Equal(t, 4, bt.Len(), "Must have kept every record")
+
+	records := make(map[string]int)
+	for _, r := range bt.Routes() {
+		for _, chunk := range r.Chunk() {
+			records[r.Stream()] += len(chunk)
+		}
+	}
+	assert.Equal(t, map[string]int{"otel-tenant-a": 2, "otel-tenant-b": 1, "": 1}, records,
+		"Must have routed the records by tenant, using the default stream when unresolved")
+
+	other, err := router.Traces(td)
+	require.NoError(t, err, "Must not error when routing the traces")
+	require.NoError(t, bt.Merge(other), "Must not error when merging routed batches")
+	assert.Len(t, bt.Routes(), 3, "Must have merged the records of each stream")
+	assert.Equal(t, 8, bt.Len(), "Must have kept every merged record")
+
+	single := pdata.NewTraces()
+	td.ResourceSpans().At(1).CopyTo(single.ResourceSpans().AppendEmpty())
+	bt, err = router.Traces(single)
+	require.NoError(t, err, "Must not error when routing the traces")
+	require.Len(t, bt.Routes(), 1, "Must not route a single stream")
+	assert.Equal(t, "otel-tenant-b", bt.Stream(), "Must have set the stream of the batch")
+}
@@ -0,0 +1,52 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+)
+
+// Sampler decides which records are kept by the hash of their partition key,
+// so that each key is consistently kept or dropped.
+type Sampler struct {
+	// threshold is the highest hash of a key that is kept
+	threshold uint64
+	all       bool
+}
+
+// NewSampler returns a sampler that keeps the ratio of partition keys,
+// a ratio at or above 1 keeps every key and a ratio at or below 0 keeps none.
+func NewSampler(ratio float64) *Sampler {
+	switch {
+	case ratio >= 1:
+		return &Sampler{all: true}
+	case ratio <= 0:
+		return &Sampler{}
+	}
+	return &Sampler{threshold: uint64(ratio * math.MaxUint64)}
+}
+
+// Sample reports if the records with the partition key are kept.
+func (s *Sampler) Sample(key string) bool {
+	if s.all {
+		return true
+	}
+	// The leading bytes of a cryptographic hash are used since the high bits of
+	// faster hashes barely change between short keys such as sequential ids
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8]) < s.threshold
+}
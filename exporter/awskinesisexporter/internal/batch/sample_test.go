@@ -0,0 +1,64 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+)
+
+func TestSampler(t *testing.T) {
+	t.Parallel()
+
+	const keys = 10000
+	s := batch.NewSampler(0.5)
+
+	kept := 0
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		sampled := s.Sample(key)
+		if sampled {
+			kept++
+		}
+		assert.Equal(t, sampled, s.Sample(key), "Must make the same decision for the key %q", key)
+		assert.Equal(t, sampled, batch.NewSampler(0.5).Sample(key), "Must make the same decision across samplers")
+	}
+	assert.InDelta(t, keys/2, kept, keys*0.05, "Must have kept roughly half of the keys")
+
+	assert.True(t, batch.NewSampler(1).Sample("key"), "Must keep every key with a ratio of 1")
+	assert.False(t, batch.NewSampler(0).Sample("key"), "Must drop every key with a ratio of 0")
+}
+
+func TestSampledRecords(t *testing.T) {
+	t.Parallel()
+
+	bt := batch.New(batch.WithSampler(batch.NewSampler(0.5)))
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, bt.AddRecord([]byte("data"), fmt.Sprintf("key-%d", i)), "Must not error when dropping records")
+	}
+	assert.Equal(t, 1000, bt.Len()+bt.SampledOut(), "Must have either kept or counted every record")
+	assert.Greater(t, bt.SampledOut(), 0, "Must have dropped some of the records")
+
+	other := batch.New(batch.WithSampler(batch.NewSampler(0)))
+	require.NoError(t, other.AddRecord([]byte("data"), "key"))
+	dropped := bt.SampledOut()
+	require.NoError(t, bt.Merge(other))
+	assert.Equal(t, dropped+1, bt.SampledOut(), "Must count the dropped records of merged batches")
+}
@@ -0,0 +1,96 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math/big"
+	"sort"
+	"sync/atomic"
+)
+
+// HashKeyRange is the range of hash keys of a shard, both ends are inclusive.
+type HashKeyRange struct {
+	Start *big.Int
+	End   *big.Int
+}
+
+// ShardHasher maps partition keys to explicit hash keys within the ranges of
+// the open shards of the stream, so that keys are spread evenly across the shards
+// regardless of the width of their ranges instead of by the md5 hash of each key.
+// Keys are assigned to shards using jump consistent hashing, which only moves
+// the keys of the added or removed shards once the ranges change.
+// It is safe for concurrent use.
+type ShardHasher struct {
+	// ranges holds the []HashKeyRange sorted by their start so they can be replaced while in use
+	ranges atomic.Value
+}
+
+// NewShardHasher returns a ShardHasher without any ranges,
+// which leaves the hash keys to kinesis until SetRanges is called.
+func NewShardHasher() *ShardHasher {
+	sh := &ShardHasher{}
+	sh.ranges.Store([]HashKeyRange(nil))
+	return sh
+}
+
+// WithShardHasher sets the explicit hash key of the records that do not
+// already have one to the hash key of their partition key within the shard ranges.
+func WithShardHasher(hasher *ShardHasher) Option {
+	return func(bt *Batch) {
+		bt.shardHasher = hasher
+	}
+}
+
+// SetRanges replaces the shard ranges that keys are mapped to from now on.
+func (sh *ShardHasher) SetRanges(ranges []HashKeyRange) {
+	sorted := make([]HashKeyRange, len(ranges))
+	copy(sorted, ranges)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Start.Cmp(sorted[j].Start) < 0
+	})
+	sh.ranges.Store(sorted)
+}
+
+// HashKey returns the explicit hash key of the partition key, an empty
+// hash key is returned while the shard ranges are not known.
+func (sh *ShardHasher) HashKey(key string) string {
+	ranges := sh.ranges.Load().([]HashKeyRange)
+	if len(ranges) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	r := ranges[jumpHash(binary.BigEndian.Uint64(sum[:8]), len(ranges))]
+
+	// The rest of the hash picks the hash key within the range of the shard
+	width := new(big.Int).Sub(r.End, r.Start)
+	width.Add(width, big.NewInt(1))
+	offset := new(big.Int).SetBytes(sum[8:24])
+	offset.Mod(offset, width)
+	return offset.Add(offset, r.Start).String()
+}
+
+// jumpHash returns the bucket, within [0, buckets), of the key
+// using the jump consistent hash of Lamping and Veach.
+func jumpHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
@@ -0,0 +1,126 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch_test
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+)
+
+// hashKeyRanges splits the hash key space into ranges of the given fractions.
+func hashKeyRanges(fractions ...int64) []batch.HashKeyRange {
+	space := new(big.Int).Lsh(big.NewInt(1), 128)
+	var (
+		ranges []batch.HashKeyRange
+		total  int64
+	)
+	for _, f := range fractions {
+		total += f
+	}
+	start := big.NewInt(0)
+	for i, f := range fractions {
+		end := new(big.Int).Div(new(big.Int).Mul(space, big.NewInt(f)), big.NewInt(total))
+		end.Add(end, start).Sub(end, big.NewInt(1))
+		if i == len(fractions)-1 {
+			end.Sub(space, big.NewInt(1))
+		}
+		ranges = append(ranges, batch.HashKeyRange{Start: start, End: end})
+		start = new(big.Int).Add(end, big.NewInt(1))
+	}
+	return ranges
+}
+
+// shardOf returns the index of the range containing the hash key.
+func shardOf(t *testing.T, ranges []batch.HashKeyRange, hashKey string) int {
+	v, ok := new(big.Int).SetString(hashKey, 10)
+	require.True(t, ok, "Must be a decimal hash key")
+	for i, r := range ranges {
+		if v.Cmp(r.Start) >= 0 && v.Cmp(r.End) <= 0 {
+			return i
+		}
+	}
+	require.FailNow(t, "Must be within the range of a shard", hashKey)
+	return -1
+}
+
+func TestShardHasher(t *testing.T) {
+	t.Parallel()
+
+	sh := batch.NewShardHasher()
+	assert.Empty(t, sh.HashKey("key"), "Must leave the hash key to kinesis without any ranges")
+
+	// Uneven ranges that the md5 hash of each key would fill unevenly
+	ranges := hashKeyRanges(8, 4, 2, 1, 1)
+	sh.SetRanges([]batch.HashKeyRange{ranges[3], ranges[0], ranges[4], ranges[2], ranges[1]})
+
+	const keys = 10000
+	counts := make([]int, len(ranges))
+	assigned := make(map[string]int, keys)
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		hashKey := sh.HashKey(key)
+		assert.Equal(t, hashKey, sh.HashKey(key), "Must be stable for each key")
+		shard := shardOf(t, ranges, hashKey)
+		counts[shard]++
+		assigned[key] = shard
+	}
+	for shard, n := range counts {
+		assert.InDelta(t, keys/len(ranges), n, float64(keys/len(ranges)/10), "Must have spread the keys evenly, including shard %d", shard)
+	}
+
+	// Splitting the last shard only moves keys onto the new shard
+	last := ranges[len(ranges)-1]
+	mid := new(big.Int).Rsh(new(big.Int).Add(last.Start, last.End), 1)
+	resharded := append(ranges[:len(ranges)-1:len(ranges)-1],
+		batch.HashKeyRange{Start: last.Start, End: mid},
+		batch.HashKeyRange{Start: new(big.Int).Add(mid, big.NewInt(1)), End: last.End},
+	)
+	sh.SetRanges(resharded)
+	moved := 0
+	for key, shard := range assigned {
+		now := shardOf(t, resharded, sh.HashKey(key))
+		if now == len(resharded)-1 {
+			moved++
+			continue
+		}
+		if shard < len(ranges)-1 {
+			assert.Equal(t, shard, now, "Must keep the keys of the unchanged shards")
+		}
+	}
+	assert.InDelta(t, keys/len(resharded), moved, float64(keys/len(resharded)/10), "Must only move an even share of the keys to the new shard")
+}
+
+func TestShardHasherRecords(t *testing.T) {
+	t.Parallel()
+
+	sh := batch.NewShardHasher()
+	ranges := hashKeyRanges(1, 1)
+	sh.SetRanges(ranges)
+
+	bt := batch.New(batch.WithShardHasher(sh))
+	require.NoError(t, bt.AddRecord([]byte("data"), "key"))
+	require.NoError(t, bt.AddRecordWithHashKey([]byte("data"), "key", "1"))
+
+	records := bt.Chunk()[0]
+	assert.Equal(t, sh.HashKey("key"), aws.StringValue(records[0].ExplicitHashKey), "Must have set the hash key of the partition key")
+	assert.Equal(t, "1", aws.StringValue(records[1].ExplicitHashKey), "Must keep the explicit hash key of the record")
+}
@@ -0,0 +1,263 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"go.opentelemetry.io/collector/model/pdata"
+)
+
+// splitByTraceID groups the spans of the resource by their trace id,
+// preserving the resource and instrumentation library of each span.
+// The returned traces are in the order that each trace id was first seen.
+func splitByTraceID(rs pdata.ResourceSpans) (ids []pdata.TraceID, traces []pdata.Traces) {
+	index := make(map[pdata.TraceID]int)
+	for i := 0; i < rs.InstrumentationLibrarySpans().Len(); i++ {
+		ils := rs.InstrumentationLibrarySpans().At(i)
+		// Tracks the instrumentation library created for each trace
+		// for the current instrumentation library spans.
+		libraries := make(map[pdata.TraceID]pdata.InstrumentationLibrarySpans)
+		for j := 0; j < ils.Spans().Len(); j++ {
+			span := ils.Spans().At(j)
+			id := span.TraceID()
+
+			pos, exist := index[id]
+			if !exist {
+				td := pdata.NewTraces()
+				dest := td.ResourceSpans().AppendEmpty()
+				rs.Resource().CopyTo(dest.Resource())
+				dest.SetSchemaUrl(rs.SchemaUrl())
+
+				pos = len(traces)
+				index[id] = pos
+				ids = append(ids, id)
+				traces = append(traces, td)
+			}
+
+			lib, exist := libraries[id]
+			if !exist {
+				lib = traces[pos].ResourceSpans().At(0).InstrumentationLibrarySpans().AppendEmpty()
+				ils.InstrumentationLibrary().CopyTo(lib.InstrumentationLibrary())
+				lib.SetSchemaUrl(ils.SchemaUrl())
+				libraries[id] = lib
+			}
+			span.CopyTo(lib.Spans().AppendEmpty())
+		}
+	}
+	return ids, traces
+}
+
+// splitTraces divides the spans of a single resource into two halves
+// along the instrumentation library boundaries where possible,
+// otherwise the spans of the only instrumentation library are halved.
+func splitTraces(td pdata.Traces) (pdata.Traces, pdata.Traces) {
+	rs := td.ResourceSpans().At(0)
+	first, second := pdata.NewTraces(), pdata.NewTraces()
+	for _, half := range []pdata.Traces{first, second} {
+		dest := half.ResourceSpans().AppendEmpty()
+		rs.Resource().CopyTo(dest.Resource())
+		dest.SetSchemaUrl(rs.SchemaUrl())
+	}
+
+	libs := rs.InstrumentationLibrarySpans()
+	if libs.Len() > 1 {
+		for i := 0; i < libs.Len(); i++ {
+			dest := first
+			if i >= libs.Len()/2 {
+				dest = second
+			}
+			libs.At(i).CopyTo(dest.ResourceSpans().At(0).InstrumentationLibrarySpans().AppendEmpty())
+		}
+		return first, second
+	}
+
+	ils := libs.At(0)
+	for i, half := range []pdata.Traces{first, second} {
+		lib := half.ResourceSpans().At(0).InstrumentationLibrarySpans().AppendEmpty()
+		ils.InstrumentationLibrary().CopyTo(lib.InstrumentationLibrary())
+		lib.SetSchemaUrl(ils.SchemaUrl())
+
+		start, end := 0, ils.Spans().Len()/2
+		if i == 1 {
+			start, end = end, ils.Spans().Len()
+		}
+		for j := start; j < end; j++ {
+			ils.Spans().At(j).CopyTo(lib.Spans().AppendEmpty())
+		}
+	}
+	return first, second
+}
+
+// splitMetrics divides the metrics of a single resource into two halves
+// along the instrumentation library boundaries where possible,
+// otherwise the metrics of the only instrumentation library are halved.
+func splitMetrics(md pdata.Metrics) (pdata.Metrics, pdata.Metrics) {
+	rm := md.ResourceMetrics().At(0)
+	first, second := pdata.NewMetrics(), pdata.NewMetrics()
+	for _, half := range []pdata.Metrics{first, second} {
+		dest := half.ResourceMetrics().AppendEmpty()
+		rm.Resource().CopyTo(dest.Resource())
+		dest.SetSchemaUrl(rm.SchemaUrl())
+	}
+
+	libs := rm.InstrumentationLibraryMetrics()
+	if libs.Len() > 1 {
+		for i := 0; i < libs.Len(); i++ {
+			dest := first
+			if i >= libs.Len()/2 {
+				dest = second
+			}
+			libs.At(i).CopyTo(dest.ResourceMetrics().At(0).InstrumentationLibraryMetrics().AppendEmpty())
+		}
+		return first, second
+	}
+
+	ilm := libs.At(0)
+	for i, half := range []pdata.Metrics{first, second} {
+		lib := half.ResourceMetrics().At(0).InstrumentationLibraryMetrics().AppendEmpty()
+		ilm.InstrumentationLibrary().CopyTo(lib.InstrumentationLibrary())
+		lib.SetSchemaUrl(ilm.SchemaUrl())
+
+		start, end := 0, ilm.Metrics().Len()/2
+		if i == 1 {
+			start, end = end, ilm.Metrics().Len()
+		}
+		for j := start; j < end; j++ {
+			ilm.Metrics().At(j).CopyTo(lib.Metrics().AppendEmpty())
+		}
+	}
+	return first, second
+}
+
+// splitSpansByKey groups the spans of the resource by the key returned
+// for each span, preserving the resource and instrumentation library of each span.
+// The returned traces are in the order that each key was first seen.
+func splitSpansByKey(rs pdata.ResourceSpans, key func(pdata.Span) string) (keys []string, traces []pdata.Traces) {
+	index := make(map[string]int)
+	for i := 0; i < rs.InstrumentationLibrarySpans().Len(); i++ {
+		ils := rs.InstrumentationLibrarySpans().At(i)
+		// Tracks the instrumentation library created for each key
+		// for the current instrumentation library spans.
+		libraries := make(map[string]pdata.InstrumentationLibrarySpans)
+		for j := 0; j < ils.Spans().Len(); j++ {
+			span := ils.Spans().At(j)
+			k := key(span)
+
+			pos, exist := index[k]
+			if !exist {
+				td := pdata.NewTraces()
+				dest := td.ResourceSpans().AppendEmpty()
+				rs.Resource().CopyTo(dest.Resource())
+				dest.SetSchemaUrl(rs.SchemaUrl())
+
+				pos = len(traces)
+				index[k] = pos
+				keys = append(keys, k)
+				traces = append(traces, td)
+			}
+
+			lib, exist := libraries[k]
+			if !exist {
+				lib = traces[pos].ResourceSpans().At(0).InstrumentationLibrarySpans().AppendEmpty()
+				ils.InstrumentationLibrary().CopyTo(lib.InstrumentationLibrary())
+				lib.SetSchemaUrl(ils.SchemaUrl())
+				libraries[k] = lib
+			}
+			span.CopyTo(lib.Spans().AppendEmpty())
+		}
+	}
+	return keys, traces
+}
+
+// splitLogsByKey groups the log records of the resource by the key
+// returned for each record, preserving the resource and instrumentation
+// library of each record.
+// The returned logs are in the order that each key was first seen.
+func splitLogsByKey(rl pdata.ResourceLogs, key func(pdata.LogRecord) string) (keys []string, logs []pdata.Logs) {
+	index := make(map[string]int)
+	for i := 0; i < rl.InstrumentationLibraryLogs().Len(); i++ {
+		ill := rl.InstrumentationLibraryLogs().At(i)
+		// Tracks the instrumentation library created for each key
+		// for the current instrumentation library logs.
+		libraries := make(map[string]pdata.InstrumentationLibraryLogs)
+		for j := 0; j < ill.Logs().Len(); j++ {
+			record := ill.Logs().At(j)
+			k := key(record)
+
+			pos, exist := index[k]
+			if !exist {
+				ld := pdata.NewLogs()
+				dest := ld.ResourceLogs().AppendEmpty()
+				rl.Resource().CopyTo(dest.Resource())
+				dest.SetSchemaUrl(rl.SchemaUrl())
+
+				pos = len(logs)
+				index[k] = pos
+				keys = append(keys, k)
+				logs = append(logs, ld)
+			}
+
+			lib, exist := libraries[k]
+			if !exist {
+				lib = logs[pos].ResourceLogs().At(0).InstrumentationLibraryLogs().AppendEmpty()
+				ill.InstrumentationLibrary().CopyTo(lib.InstrumentationLibrary())
+				lib.SetSchemaUrl(ill.SchemaUrl())
+				libraries[k] = lib
+			}
+			record.CopyTo(lib.Logs().AppendEmpty())
+		}
+	}
+	return keys, logs
+}
+
+// splitLogs divides the log records of a single resource into two halves
+// along the instrumentation library boundaries where possible,
+// otherwise the records of the only instrumentation library are halved.
+func splitLogs(ld pdata.Logs) (pdata.Logs, pdata.Logs) {
+	rl := ld.ResourceLogs().At(0)
+	first, second := pdata.NewLogs(), pdata.NewLogs()
+	for _, half := range []pdata.Logs{first, second} {
+		dest := half.ResourceLogs().AppendEmpty()
+		rl.Resource().CopyTo(dest.Resource())
+		dest.SetSchemaUrl(rl.SchemaUrl())
+	}
+
+	libs := rl.InstrumentationLibraryLogs()
+	if libs.Len() > 1 {
+		for i := 0; i < libs.Len(); i++ {
+			dest := first
+			if i >= libs.Len()/2 {
+				dest = second
+			}
+			libs.At(i).CopyTo(dest.ResourceLogs().At(0).InstrumentationLibraryLogs().AppendEmpty())
+		}
+		return first, second
+	}
+
+	ill := libs.At(0)
+	for i, half := range []pdata.Logs{first, second} {
+		lib := half.ResourceLogs().At(0).InstrumentationLibraryLogs().AppendEmpty()
+		ill.InstrumentationLibrary().CopyTo(lib.InstrumentationLibrary())
+		lib.SetSchemaUrl(ill.SchemaUrl())
+
+		start, end := 0, ill.Logs().Len()/2
+		if i == 1 {
+			start, end = end, ill.Logs().Len()
+		}
+		for j := start; j < end; j++ {
+			ill.Logs().At(j).CopyTo(lib.Logs().AppendEmpty())
+		}
+	}
+	return first, second
+}
@@ -0,0 +1,47 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import "go.uber.org/zap"
+
+// WithPayloadValidation drops the records that have an empty payload, and the
+// records of the otlp encodings that have no spans, metrics or log records,
+// instead of writing them. The dropped records are counted by Invalid and
+// logged at debug level with the reason they were dropped.
+func WithPayloadValidation(log *zap.Logger) Option {
+	if log == nil {
+		log = zap.NewNop()
+	}
+	return func(bt *Batch) {
+		bt.validate = true
+		bt.log = log
+	}
+}
+
+// dropInvalid counts the record as dropped by the payload validation.
+func (b *Batch) dropInvalid(key, reason string) {
+	b.invalid++
+	b.log.Debug("Dropped an invalid record", zap.String("partition_key", key), zap.String("reason", reason))
+}
+
+// Invalid returns the number of records that were dropped by the payload
+// validation, including the records of the routed batches.
+func (b *Batch) Invalid() int {
+	n := 0
+	for _, r := range b.Routes() {
+		n += r.invalid
+	}
+	return n
+}
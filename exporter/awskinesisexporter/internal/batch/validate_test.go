@@ -0,0 +1,90 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/model/pdata"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+)
+
+func TestPayloadValidation(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zap.DebugLevel)
+	bt := batch.New(batch.WithPayloadValidation(zap.New(core)))
+	require.NoError(t, bt.AddRecord(nil, "empty"), "Must not error when dropping an empty payload")
+	require.NoError(t, bt.AddRecord([]byte("data"), "valid"), "Must not error when adding a valid payload")
+
+	assert.Equal(t, 1, bt.Len(), "Must only have the valid record")
+	assert.Equal(t, 1, bt.Invalid(), "Must have counted the empty payload as invalid")
+	entries := logs.FilterMessage("Dropped an invalid record").All()
+	require.Len(t, entries, 1, "Must have logged the invalid record")
+	assert.Equal(t, "empty payload", entries[0].ContextMap()["reason"], "Must have logged the reason")
+
+	unvalidated := batch.New()
+	require.NoError(t, unvalidated.AddRecord(nil, "empty"), "Must not error when adding an empty payload")
+	assert.Equal(t, 1, unvalidated.Len(), "Must keep empty payloads without validation")
+	assert.Zero(t, unvalidated.Invalid(), "Must not count invalid records without validation")
+}
+
+func TestPayloadValidationEncoders(t *testing.T) {
+	t.Parallel()
+
+	td := pdata.NewTraces()
+	td.ResourceSpans().AppendEmpty().Resource().Attributes().InsertString("service.name", "empty")
+	spans := td.ResourceSpans().AppendEmpty().InstrumentationLibrarySpans().AppendEmpty().Spans()
+	spans.AppendEmpty().SetName("span")
+
+	md := pdata.NewMetrics()
+	md.ResourceMetrics().AppendEmpty().InstrumentationLibraryMetrics().AppendEmpty()
+
+	ld := pdata.NewLogs()
+	ld.ResourceLogs().AppendEmpty()
+	ld.ResourceLogs().AppendEmpty().InstrumentationLibraryLogs().AppendEmpty().Logs().AppendEmpty().SetName("log")
+
+	for _, name := range []string{"otlp_proto", "otlp_json"} {
+		core, logs := observer.New(zap.DebugLevel)
+		enc, err := batch.NewEncoder(name, batch.WithPayloadValidation(zap.New(core)))
+		require.NoError(t, err, "Must have a valid encoder")
+
+		bt, err := enc.Traces(td)
+		require.NoError(t, err, "Must not error when dropping the resource without spans")
+		assert.Equal(t, 1, bt.Len(), "Must only have the record with spans for %s", name)
+		assert.Equal(t, 1, bt.Invalid(), "Must have dropped the resource without spans for %s", name)
+
+		bt, err = enc.Metrics(md)
+		require.NoError(t, err, "Must not error when dropping the resource without metrics")
+		assert.Zero(t, bt.Len(), "Must not have any records without metrics for %s", name)
+		assert.Equal(t, 1, bt.Invalid(), "Must have dropped the resource without metrics for %s", name)
+
+		bt, err = enc.Logs(ld)
+		require.NoError(t, err, "Must not error when dropping the resource without log records")
+		assert.Equal(t, 1, bt.Len(), "Must only have the record with log records for %s", name)
+		assert.Equal(t, 1, bt.Invalid(), "Must have dropped the resource without log records for %s", name)
+
+		var reasons []string
+		for _, entry := range logs.FilterMessage("Dropped an invalid record").All() {
+			reasons = append(reasons, entry.ContextMap()["reason"].(string))
+		}
+		assert.Equal(t, []string{"no spans", "no metrics", "no log records"}, reasons, "Must have logged why each record was dropped for %s", name)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compress
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidCandidates is used when the candidates of Auto can not be used.
+var ErrInvalidCandidates = errors.New("invalid compression candidates")
+
+// autoCompressor compresses inputs of at least minSize bytes with every
+// candidate and keeps the smallest output, trading CPU for smaller records.
+type autoCompressor struct {
+	candidates []Compressor
+	minSize    int
+}
+
+var _ Selector = (*autoCompressor)(nil)
+
+func newAuto(level int, o options) (*autoCompressor, error) {
+	formats := o.candidates
+	if len(formats) == 0 {
+		formats = []string{Gzip, Zstd}
+	}
+	if o.minSize < 0 {
+		return nil, fmt.Errorf("%w: the minimum size must not be negative", ErrInvalidCandidates)
+	}
+	a := &autoCompressor{minSize: o.minSize}
+	seen := make(map[string]bool)
+	for _, format := range formats {
+		switch format {
+		case "", None, Auto:
+			return nil, fmt.Errorf("%w: %q can not be a candidate", ErrInvalidCandidates, format)
+		}
+		if seen[format] {
+			return nil, fmt.Errorf("%w: %q is repeated", ErrInvalidCandidates, format)
+		}
+		seen[format] = true
+
+		var opts []Option
+		if format == Zstd {
+			opts = append(opts, WithDictionary(o.dictionary))
+		}
+		c, err := NewCompressor(format, level, opts...)
+		if err != nil {
+			return nil, err
+		}
+		a.candidates = append(a.candidates, c)
+	}
+	if len(o.dictionary) > 0 && !seen[Zstd] {
+		return nil, fmt.Errorf("%w: %s without a %s candidate does not support dictionaries", ErrInvalidDictionary, Auto, Zstd)
+	}
+	return a, nil
+}
+
+func (*autoCompressor) Type() string { return Auto }
+
+func (a *autoCompressor) Do(in []byte) ([]byte, error) {
+	out, _, err := a.Select(in)
+	return out, err
+}
+
+func (a *autoCompressor) Select(in []byte) ([]byte, string, error) {
+	candidates := a.candidates
+	if len(in) < a.minSize {
+		candidates = candidates[:1]
+	}
+	var (
+		smallest []byte
+		format   string
+	)
+	for _, c := range candidates {
+		out, err := c.Do(in)
+		if err != nil {
+			return nil, "", err
+		}
+		if smallest == nil || len(out) < len(smallest) {
+			smallest, format = out, c.Type()
+		}
+	}
+	return smallest, format, nil
+}
@@ -0,0 +1,257 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// None is used to leave the encoded data unmodified.
+	None = "none"
+	// Gzip compresses the encoded data using gzip.
+	Gzip = "gzip"
+	// Zstd compresses the encoded data using zstandard.
+	Zstd = "zstd"
+	// Snappy compresses the encoded data using the snappy block format.
+	Snappy = "snappy"
+	// Auto compresses the encoded data using whichever candidate format
+	// produces the smallest output.
+	Auto = "auto"
+)
+
+// markers are the leading bytes that identify the compression format of
+// records when they are marked with a byte instead of their partition key.
+var markers = map[string]byte{
+	None:   0,
+	Gzip:   1,
+	Zstd:   2,
+	Snappy: 3,
+}
+
+// Marker returns the byte that identifies the compression format.
+func Marker(format string) (byte, bool) {
+	m, ok := markers[format]
+	return m, ok
+}
+
+// ErrUnknownCompression is used when the configured compression is not supported.
+var ErrUnknownCompression = errors.New("unknown compression format")
+
+// Compressor reduces the size of encoded records before they are sent to kinesis.
+// Implementations must be safe for concurrent use so that a single compressor
+// can be shared across batches.
+type Compressor interface {
+	// Type returns the name of the compression format in use.
+	Type() string
+
+	// Do compresses the input and returns the compressed result.
+	Do(in []byte) (out []byte, err error)
+}
+
+// Selector is a Compressor that chooses the format used for each input.
+type Selector interface {
+	Compressor
+
+	// Select compresses the input and returns the format that was used.
+	Select(in []byte) (out []byte, format string, err error)
+}
+
+// ErrInvalidLevel is used when the compression level is not supported by the format.
+var ErrInvalidLevel = errors.New("invalid compression level")
+
+// ErrInvalidDictionary is used when the dictionary can not be used by the format.
+var ErrInvalidDictionary = errors.New("invalid compression dictionary")
+
+// Option configures the compressor returned by NewCompressor.
+type Option func(o *options)
+
+type options struct {
+	dictionary []byte
+	candidates []string
+	minSize    int
+}
+
+// WithDictionary compresses the data using the trained zstd dictionary,
+// the same dictionary must be used to decompress the data.
+// Only zstd supports dictionaries, an empty dictionary is not used.
+func WithDictionary(dictionary []byte) Option {
+	return func(o *options) {
+		o.dictionary = dictionary
+	}
+}
+
+// WithCandidates sets the formats that Auto chooses between,
+// gzip and zstd are used when unset.
+func WithCandidates(formats ...string) Option {
+	return func(o *options) {
+		o.candidates = formats
+	}
+}
+
+// WithAutoMinSize sets the smallest input that Auto compresses with
+// every candidate, smaller inputs only use the first candidate.
+func WithAutoMinSize(size int) Option {
+	return func(o *options) {
+		o.minSize = size
+	}
+}
+
+// NewCompressor returns the compressor for the named format.
+// The level is specific to the format used, a level of 0 uses
+// the default level of the format.
+func NewCompressor(format string, level int, opts ...Option) (Compressor, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if len(o.dictionary) > 0 && format != Zstd && format != Auto {
+		return nil, fmt.Errorf("%w: %s does not support dictionaries", ErrInvalidDictionary, format)
+	}
+	switch format {
+	case "", None:
+		if level != 0 {
+			return nil, fmt.Errorf("%w: compression must be set to use a level", ErrInvalidLevel)
+		}
+		return noop{}, nil
+	case Gzip:
+		return newGzip(level)
+	case Zstd:
+		return newZstd(level, o.dictionary)
+	case Snappy:
+		if level != 0 {
+			return nil, fmt.Errorf("%w: %s does not support compression levels", ErrInvalidLevel, Snappy)
+		}
+		return snappyCompressor{}, nil
+	case Auto:
+		return newAuto(level, o)
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownCompression, format)
+}
+
+// Compress compresses the input and returns the format that was used,
+// which differs from the type of the compressor when it is a Selector.
+func Compress(c Compressor, in []byte) ([]byte, string, error) {
+	if s, ok := c.(Selector); ok {
+		return s.Select(in)
+	}
+	out, err := c.Do(in)
+	return out, c.Type(), err
+}
+
+type noop struct{}
+
+func (noop) Type() string { return None }
+
+func (noop) Do(in []byte) ([]byte, error) { return in, nil }
+
+type gzipCompressor struct {
+	writers sync.Pool
+}
+
+func newGzip(level int) (*gzipCompressor, error) {
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		return nil, fmt.Errorf("%w: %d is not within [%d, %d] for %s", ErrInvalidLevel, level, gzip.HuffmanOnly, gzip.BestCompression, Gzip)
+	}
+	return &gzipCompressor{
+		writers: sync.Pool{
+			New: func() interface{} {
+				// The level has already been validated so the error can be ignored
+				w, _ := gzip.NewWriterLevel(nil, level)
+				return w
+			},
+		},
+	}, nil
+}
+
+func (*gzipCompressor) Type() string { return Gzip }
+
+func (g *gzipCompressor) Do(in []byte) ([]byte, error) {
+	w := g.writers.Get().(*gzip.Writer)
+	defer g.writers.Put(w)
+
+	buf := bytes.NewBuffer(make([]byte, 0, len(in)/2))
+	w.Reset(buf)
+
+	if _, err := w.Write(in); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// zstdCompressor shares a single encoder since
+// EncodeAll is safe to be called concurrently.
+type zstdCompressor struct {
+	encoder *zstd.Encoder
+}
+
+func newZstd(level int, dictionary []byte) (*zstdCompressor, error) {
+	var opts []zstd.EOption
+	if level != 0 {
+		if level < 1 || level > 22 {
+			return nil, fmt.Errorf("%w: %d is not within [1, 22] for %s", ErrInvalidLevel, level, Zstd)
+		}
+		opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	}
+	concurrency := runtime.GOMAXPROCS(0)
+	if len(dictionary) > 0 {
+		opts = append(opts, zstd.WithEncoderDict(dictionary), zstd.WithEncoderConcurrency(concurrency))
+	}
+	enc, err := zstd.NewWriter(nil, opts...)
+	if err != nil {
+		if len(dictionary) > 0 {
+			// The level has already been validated so the dictionary failed to load
+			return nil, fmt.Errorf("%w: %v", ErrInvalidDictionary, err)
+		}
+		return nil, err
+	}
+	if len(dictionary) > 0 {
+		// The first encode of each pooled encoder at the default level does not
+		// use the dictionary, the encoders are taken in turn to be warmed up.
+		warmup := make([]byte, 64)
+		for i := 0; i < concurrency; i++ {
+			enc.EncodeAll(warmup, nil)
+		}
+	}
+	return &zstdCompressor{encoder: enc}, nil
+}
+
+func (*zstdCompressor) Type() string { return Zstd }
+
+func (z *zstdCompressor) Do(in []byte) ([]byte, error) {
+	return z.encoder.EncodeAll(in, make([]byte, 0, len(in)/2)), nil
+}
+
+type snappyCompressor struct{}
+
+func (snappyCompressor) Type() string { return Snappy }
+
+func (snappyCompressor) Do(in []byte) ([]byte, error) {
+	return snappy.Encode(nil, in), nil
+}
@@ -0,0 +1,81 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compress_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/model/otlp"
+	"go.opentelemetry.io/collector/model/pdata"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/compress"
+)
+
+func newTraceBatch(b *testing.B) []byte {
+	td := pdata.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().InsertString("service.name", "benchmark-service")
+	rs.Resource().Attributes().InsertString("host.name", "benchmark-host")
+	spans := rs.InstrumentationLibrarySpans().AppendEmpty().Spans()
+	for i := 0; i < 500; i++ {
+		span := spans.AppendEmpty()
+		span.SetName(fmt.Sprintf("operation-%d", i%10))
+		span.SetTraceID(pdata.NewTraceID([16]byte{byte(i), byte(i >> 8), 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}))
+		span.SetSpanID(pdata.NewSpanID([8]byte{byte(i), byte(i >> 8), 1, 2, 3, 4, 5, 6}))
+		span.SetStartTimestamp(pdata.Timestamp(1000 * i))
+		span.SetEndTimestamp(pdata.Timestamp(1000*i + 500))
+		span.Attributes().InsertString("http.method", "GET")
+		span.Attributes().InsertString("http.url", fmt.Sprintf("https://example.com/api/v1/resource/%d", i))
+		span.Attributes().InsertInt("http.status_code", 200)
+	}
+	data, err := otlp.NewProtobufTracesMarshaler().MarshalTraces(td)
+	require.NoError(b, err, "Must be able to marshal the trace batch")
+	return data
+}
+
+func benchmarkCompression(b *testing.B, format string) {
+	c, err := compress.NewCompressor(format, 0)
+	require.NoError(b, err, "Must have a valid compressor")
+
+	data := newTraceBatch(b)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+
+	var size int
+	for i := 0; i < b.N; i++ {
+		out, err := c.Do(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+		size = len(out)
+	}
+	b.ReportMetric(float64(size)/float64(len(data)), "ratio")
+}
+
+func BenchmarkGzipTraceBatch(b *testing.B) {
+	benchmarkCompression(b, compress.Gzip)
+}
+
+func BenchmarkZstdTraceBatch(b *testing.B) {
+	benchmarkCompression(b, compress.Zstd)
+}
+
+func BenchmarkSnappyTraceBatch(b *testing.B) {
+	benchmarkCompression(b, compress.Snappy)
+}
@@ -0,0 +1,249 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compress_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"testing"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/compress"
+)
+
+func TestUnknownCompression(t *testing.T) {
+	t.Parallel()
+
+	c, err := compress.NewCompressor("not-a-compression", 0)
+	assert.ErrorIs(t, err, compress.ErrUnknownCompression, "Must error with an unknown format")
+	assert.Nil(t, c, "Must not return a compressor")
+}
+
+func TestNoneCompression(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []string{"", compress.None} {
+		c, err := compress.NewCompressor(format, 0)
+		require.NoError(t, err, "Must not error with a valid format")
+		assert.Equal(t, compress.None, c.Type())
+
+		data := []byte("hello world")
+		out, err := c.Do(data)
+		assert.NoError(t, err, "Must not error when compressing")
+		assert.Equal(t, data, out, "Must not modify the data")
+	}
+}
+
+func TestGzipCompression(t *testing.T) {
+	t.Parallel()
+
+	c, err := compress.NewCompressor(compress.Gzip, 0)
+	require.NoError(t, err, "Must not error with a valid format")
+	assert.Equal(t, compress.Gzip, c.Type())
+
+	data := bytes.Repeat([]byte("compressible"), 1000)
+	for i := 0; i < 3; i++ {
+		out, err := c.Do(data)
+		require.NoError(t, err, "Must not error when compressing")
+		assert.Less(t, len(out), len(data), "Must have reduced the size of the data")
+
+		r, err := gzip.NewReader(bytes.NewReader(out))
+		require.NoError(t, err, "Must be a valid gzip stream")
+		raw, err := ioutil.ReadAll(r)
+		require.NoError(t, err, "Must be able to decompress the data")
+		assert.Equal(t, data, raw, "Must match the original data")
+	}
+}
+
+func TestZstdCompression(t *testing.T) {
+	t.Parallel()
+
+	for _, level := range []int{0, 1, 3, 19} {
+		c, err := compress.NewCompressor(compress.Zstd, level)
+		require.NoError(t, err, "Must not error with a valid level %d", level)
+		assert.Equal(t, compress.Zstd, c.Type())
+
+		data := bytes.Repeat([]byte("compressible"), 1000)
+		out, err := c.Do(data)
+		require.NoError(t, err, "Must not error when compressing")
+		assert.Less(t, len(out), len(data), "Must have reduced the size of the data")
+
+		dec, err := zstd.NewReader(nil)
+		require.NoError(t, err, "Must have a valid decoder")
+		raw, err := dec.DecodeAll(out, nil)
+		dec.Close()
+		require.NoError(t, err, "Must be able to decompress the data")
+		assert.Equal(t, data, raw, "Must match the original data")
+	}
+}
+
+func TestZstdDictionary(t *testing.T) {
+	t.Parallel()
+
+	dictionary, err := ioutil.ReadFile("testdata/otlp.dict")
+	require.NoError(t, err, "Must be able to read the dictionary")
+
+	plain, err := compress.NewCompressor(compress.Zstd, 0)
+	require.NoError(t, err, "Must not error without a dictionary")
+	c, err := compress.NewCompressor(compress.Zstd, 0, compress.WithDictionary(dictionary))
+	require.NoError(t, err, "Must not error with a trained dictionary")
+	assert.Equal(t, compress.Zstd, c.Type())
+
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderDicts(dictionary))
+	require.NoError(t, err, "Must have a valid decoder")
+	defer dec.Close()
+
+	// The dictionary was trained on small otlp json span records like this one
+	data := []byte(`{"resourceSpans":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"checkout"}}]},` +
+		`"instrumentationLibrarySpans":[{"instrumentationLibrary":{"name":"go.opentelemetry.io/otel"},"spans":[{` +
+		`"traceId":"5b8efff798038103d269b633813fc60c","spanId":"eee19b7ec3c1b174","name":"GET /api/v1/orders/42",` +
+		`"kind":"SPAN_KIND_SERVER","startTimeUnixNano":"1634000000000000000","endTimeUnixNano":"1634000000010000000",` +
+		`"attributes":[{"key":"http.method","value":{"stringValue":"GET"}},{"key":"http.status_code","value":{"intValue":"200"}}],` +
+		`"status":{}}]}]}]}`)
+
+	withoutDict, err := plain.Do(data)
+	require.NoError(t, err, "Must not error when compressing")
+	withDict, err := c.Do(data)
+	require.NoError(t, err, "Must not error when compressing with the dictionary")
+	assert.Less(t, len(withDict), len(withoutDict)/2, "Must have compressed the small record further with the dictionary")
+
+	raw, err := dec.DecodeAll(withDict, nil)
+	require.NoError(t, err, "Must be able to decompress the data with the dictionary")
+	assert.Equal(t, data, raw, "Must match the original data")
+
+	_, err = compress.NewCompressor(compress.Zstd, 0, compress.WithDictionary([]byte("not a dictionary")))
+	assert.ErrorIs(t, err, compress.ErrInvalidDictionary, "Must error with an invalid dictionary")
+	_, err = compress.NewCompressor(compress.Gzip, 0, compress.WithDictionary(dictionary))
+	assert.ErrorIs(t, err, compress.ErrInvalidDictionary, "Must error when the format does not support dictionaries")
+	_, err = compress.NewCompressor(compress.Gzip, 0, compress.WithDictionary(nil))
+	assert.NoError(t, err, "Must not use an empty dictionary")
+}
+
+func TestSnappyCompression(t *testing.T) {
+	t.Parallel()
+
+	c, err := compress.NewCompressor(compress.Snappy, 0)
+	require.NoError(t, err, "Must not error with a valid format")
+	assert.Equal(t, compress.Snappy, c.Type())
+
+	data := bytes.Repeat([]byte("compressible"), 1000)
+	out, err := c.Do(data)
+	require.NoError(t, err, "Must not error when compressing")
+	assert.Less(t, len(out), len(data), "Must have reduced the size of the data")
+
+	raw, err := snappy.Decode(nil, out)
+	require.NoError(t, err, "Must be able to decompress the data")
+	assert.Equal(t, data, raw, "Must match the original data")
+}
+
+func TestCompressionLevels(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		format string
+		level  int
+		valid  bool
+	}{
+		{format: compress.None, level: 0, valid: true},
+		{format: compress.None, level: 3, valid: false},
+		{format: compress.Gzip, level: 9, valid: true},
+		{format: compress.Gzip, level: 10, valid: false},
+		{format: compress.Zstd, level: 22, valid: true},
+		{format: compress.Zstd, level: 23, valid: false},
+		{format: compress.Zstd, level: -1, valid: false},
+		{format: compress.Snappy, level: 0, valid: true},
+		{format: compress.Snappy, level: 1, valid: false},
+	}
+
+	for _, tc := range cases {
+		_, err := compress.NewCompressor(tc.format, tc.level)
+		if tc.valid {
+			assert.NoError(t, err, "Must not error for %s at level %d", tc.format, tc.level)
+			continue
+		}
+		assert.ErrorIs(t, err, compress.ErrInvalidLevel, "Must error for %s at level %d", tc.format, tc.level)
+	}
+}
+
+func TestAutoCompression(t *testing.T) {
+	t.Parallel()
+
+	c, err := compress.NewCompressor(compress.Auto, 0,
+		compress.WithCandidates(compress.Gzip, compress.Zstd, compress.Snappy),
+		compress.WithAutoMinSize(16),
+	)
+	require.NoError(t, err, "Must not error with valid candidates")
+	assert.Equal(t, compress.Auto, c.Type())
+
+	cases := []struct {
+		name   string
+		data   []byte
+		expect string
+	}{
+		{name: "repeated text", data: bytes.Repeat([]byte("hello world "), 100), expect: compress.Zstd},
+		{name: "short text", data: []byte("hello hello hello world"), expect: compress.Snappy},
+		{name: "below the minimum size", data: []byte("hello world"), expect: compress.Gzip},
+	}
+	for _, tc := range cases {
+		out, format, err := compress.Compress(c, tc.data)
+		require.NoError(t, err, "Must not error when compressing %s", tc.name)
+		assert.Equal(t, tc.expect, format, "Must have selected the smallest format for %s", tc.name)
+
+		expect, err := compress.NewCompressor(tc.expect, 0)
+		require.NoError(t, err, "Must not error with a valid format")
+		smallest, err := expect.Do(tc.data)
+		require.NoError(t, err, "Must not error when compressing %s", tc.name)
+		assert.Equal(t, smallest, out, "Must have kept the output of the selected format for %s", tc.name)
+	}
+
+	out, format, err := compress.Compress(&bytesCompressor{}, []byte("data"))
+	require.NoError(t, err, "Must not error when compressing")
+	assert.Equal(t, "bytes", format, "Must use the type of a compressor that does not select")
+	assert.Equal(t, []byte("data"), out)
+}
+
+type bytesCompressor struct{}
+
+func (*bytesCompressor) Type() string { return "bytes" }
+
+func (*bytesCompressor) Do(in []byte) ([]byte, error) { return in, nil }
+
+func TestAutoCompressionCandidates(t *testing.T) {
+	t.Parallel()
+
+	for _, candidates := range [][]string{{compress.None}, {compress.Auto}, {compress.Gzip, compress.Gzip}} {
+		_, err := compress.NewCompressor(compress.Auto, 0, compress.WithCandidates(candidates...))
+		assert.ErrorIs(t, err, compress.ErrInvalidCandidates, "Must error with the candidates %v", candidates)
+	}
+	_, err := compress.NewCompressor(compress.Auto, 0, compress.WithCandidates("not-a-compression"))
+	assert.ErrorIs(t, err, compress.ErrUnknownCompression, "Must error with an unknown candidate")
+
+	_, err = compress.NewCompressor(compress.Auto, 3)
+	assert.NoError(t, err, "Must apply a level supported by every candidate")
+	_, err = compress.NewCompressor(compress.Auto, 3, compress.WithCandidates(compress.Gzip, compress.Snappy))
+	assert.ErrorIs(t, err, compress.ErrInvalidLevel, "Must error with a level a candidate does not support")
+
+	dictionary, err := ioutil.ReadFile("testdata/otlp.dict")
+	require.NoError(t, err, "Must be able to read the dictionary")
+	_, err = compress.NewCompressor(compress.Auto, 0, compress.WithDictionary(dictionary))
+	assert.NoError(t, err, "Must use the dictionary with the zstd candidate")
+	_, err = compress.NewCompressor(compress.Auto, 0, compress.WithDictionary(dictionary), compress.WithCandidates(compress.Gzip))
+	assert.ErrorIs(t, err, compress.ErrInvalidDictionary, "Must error with a dictionary without a zstd candidate")
+}
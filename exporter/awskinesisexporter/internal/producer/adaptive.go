@@ -0,0 +1,100 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+// adaptiveSize is the number of records written by each request, it is halved
+// whenever a request is throttled and grows by a fixed step after each request
+// that is written without being throttled, so that the size settles on what
+// the stream is able to accept. A nil size keeps the chunks of the batch as is.
+type adaptiveSize struct {
+	min, max int
+	step     int
+
+	mu      sync.Mutex
+	current int
+}
+
+func newAdaptiveSize(min, max int) (*adaptiveSize, error) {
+	if min < 1 || max < min {
+		return nil, errors.New("adaptive batching requires a minimum of at least 1 record up to the maximum")
+	}
+	step := max / 50
+	if step < 1 {
+		step = 1
+	}
+	return &adaptiveSize{min: min, max: max, step: step, current: max}, nil
+}
+
+// size returns the current number of records written by each request.
+func (as *adaptiveSize) size() int {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	return as.current
+}
+
+// throttled halves the size, down to the minimum,
+// returning the change that was made to the size.
+func (as *adaptiveSize) throttled() int {
+	if as == nil {
+		return 0
+	}
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	next := as.current / 2
+	if next < as.min {
+		next = as.min
+	}
+	delta := next - as.current
+	as.current = next
+	return delta
+}
+
+// succeeded grows the size by the step, up to the maximum,
+// returning the change that was made to the size.
+func (as *adaptiveSize) succeeded() int {
+	if as == nil {
+		return 0
+	}
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	next := as.current + as.step
+	if next > as.max {
+		next = as.max
+	}
+	delta := next - as.current
+	as.current = next
+	return delta
+}
+
+// split divides the records into chunks of at most the current size.
+func (as *adaptiveSize) split(records []*kinesis.PutRecordsRequestEntry) [][]*kinesis.PutRecordsRequestEntry {
+	if as == nil {
+		return [][]*kinesis.PutRecordsRequestEntry{records}
+	}
+	size := as.size()
+	var chunks [][]*kinesis.PutRecordsRequestEntry
+	for len(records) > size {
+		chunks = append(chunks, records[:size:size])
+		records = records[size:]
+	}
+	return append(chunks, records)
+}
@@ -0,0 +1,59 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer
+
+import (
+	"context"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+)
+
+// BackoffSettings configures the exponential backoff
+// used when writes to kinesis are throttled.
+type BackoffSettings struct {
+	// InitialInterval is the time waited before the first retry.
+	InitialInterval time.Duration
+	// MaxInterval is the upper bound of the time waited between retries.
+	MaxInterval time.Duration
+	// MaxElapsedTime is the maximum amount of time spent retrying,
+	// a value of zero will retry until the context is done.
+	MaxElapsedTime time.Duration
+	// Multiplier is the factor the interval is increased by after each retry.
+	Multiplier float64
+}
+
+// DefaultBackoffSettings returns the backoff settings used by the Batcher
+// when no backoff has been configured.
+func DefaultBackoffSettings() BackoffSettings {
+	return BackoffSettings{
+		InitialInterval: 100 * time.Millisecond,
+		MaxInterval:     time.Second,
+		MaxElapsedTime:  5 * time.Second,
+		Multiplier:      backoff.DefaultMultiplier,
+	}
+}
+
+// newBackOff creates a randomized exponential backoff so that throttled
+// collector replicas do not retry at the same time as each other.
+func (bs BackoffSettings) newBackOff(ctx context.Context) backoff.BackOff {
+	eb := backoff.NewExponentialBackOff()
+	eb.InitialInterval = bs.InitialInterval
+	eb.MaxInterval = bs.MaxInterval
+	eb.MaxElapsedTime = bs.MaxElapsedTime
+	eb.Multiplier = bs.Multiplier
+	eb.Reset()
+	return backoff.WithContext(eb, ctx)
+}
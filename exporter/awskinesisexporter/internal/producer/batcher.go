@@ -16,51 +16,354 @@ package producer
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/kinesis"
 	"github.com/aws/aws-sdk-go/service/kinesis/kinesisiface"
+	"github.com/cenkalti/backoff/v4"
 	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 
 	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
 )
 
 type batcher struct {
-	stream *string
+	stream         *string
+	maxAttempts    int
+	maxConcurrency int
+	backoff        BackoffSettings
+	// retryableCodes replaces the default retryable error codes of the service when set
+	retryableCodes []string
+	// singleRecord writes each record with PutRecord instead of PutRecords
+	singleRecord bool
+	// dropPermanent drops the records that permanently failed instead of returning the error
+	dropPermanent bool
+	// order writes the chunks that share a partition key in the order they were submitted when set
+	order *keyOrder
+	// sequences orders the records of each partition key in single record mode when set
+	sequences *sequences
+	// requestTimeout limits each request when set, separately from the backoff
+	requestTimeout time.Duration
+	// dryRun logs the records that would have been written instead of writing them
+	dryRun bool
+
+	client      kinesisiface.KinesisAPI
+	deadLetter  *deadLetter
+	limiter     *rateLimiter
+	maxInflight *inflightBytes
+	retryBudget *retryBudget
+	shards      *shardTracker
+	log         *zap.Logger
+	telemetry   *telemetry
+	tracer      trace.Tracer
+	emf         *emfEmitter
+	shardCount  *shardCountWatcher
+	shardRanges *shardRangeWatcher
+	adaptive    *adaptiveSize
+	health      health
+
+	// failureLog logs the failed writes, it is derived from log by
+	// failureSampler once the options are applied when set.
+	failureLog     *zap.Logger
+	failureSampler func(*zap.Logger) *zap.Logger
+
+	// routedShards track the shards of the streams that batches are routed to
+	routedMu     sync.Mutex
+	routedShards map[string]*shardTracker
+
+	// mu guards closed so that no Put is started once Shutdown is called
+	mu              sync.Mutex
+	closed          bool
+	inflight        sync.WaitGroup
+	inflightRecords int64
+	// maxBuffered limits inflightRecords when set, released is closed
+	// and replaced whenever records are no longer in-flight.
+	maxBuffered int64
+	released    chan struct{}
+	// forced is closed when Shutdown gives up waiting on the in-flight
+	// writes, which cancels them so that their records are dropped.
+	forced chan struct{}
+}
 
+// ErrShutdown is returned when data is given to a Batcher that has been shut down
+var ErrShutdown = consumererror.NewPermanent(errors.New("batcher has been shut down"))
+
+// ErrRequestTimeout is returned, wrapping the error of the request, when a single
+// request did not complete within the request timeout. It is retried like a throttled request.
+var ErrRequestTimeout = errors.New("request timed out")
+
+// deadLetter is the stream that records are written to
+// once they have permanently failed to be written.
+type deadLetter struct {
 	client kinesisiface.KinesisAPI
-	log    *zap.Logger
+	stream *string
 }
 
 var _ Batcher = (*batcher)(nil)
 
 func NewBatcher(kinesisAPI kinesisiface.KinesisAPI, stream string, opts ...BatcherOptions) (Batcher, error) {
+	be, err := newBatcher(kinesisAPI, stream, opts...)
+	if err != nil {
+		return nil, err
+	}
+	be.startEMF()
+	if be.shardCount != nil {
+		be.shardCount.start(be.client, be.stream, be.log)
+	}
+	if be.shardRanges != nil {
+		be.shardRanges.start(be.client, be.stream, be.log)
+	}
+	return be, nil
+}
+
+func newBatcher(kinesisAPI kinesisiface.KinesisAPI, stream string, opts ...BatcherOptions) (*batcher, error) {
 	be := &batcher{
-		stream: aws.String(stream),
-		client: kinesisAPI,
-		log:    zap.NewNop(),
+		stream:    aws.String(stream),
+		backoff:   DefaultBackoffSettings(),
+		client:    kinesisAPI,
+		shards:    newShardTracker(),
+		log:       zap.NewNop(),
+		telemetry: newTelemetry(metric.NoopMeterProvider{}),
+		tracer:    trace.NewNoopTracerProvider().Tracer(instrumentationName),
+		health:    health{threshold: defaultUnhealthyThreshold},
+		forced:    make(chan struct{}),
 	}
 	for _, opt := range opts {
 		if err := opt(be); err != nil {
 			return nil, err
 		}
 	}
+	if be.sequences != nil && !be.singleRecord {
+		return nil, errors.New("sequence ordering requires single record mode")
+	}
+	be.failureLog = be.log
+	if be.failureSampler != nil {
+		be.failureLog = be.failureSampler(be.log)
+	}
+	if be.adaptive != nil {
+		be.telemetry.resized(context.Background(), be.adaptive.size())
+	}
 	return be, nil
 }
 
+// startEMF starts writing the EMF health metrics when configured, which
+// is done once the options are applied so that they use the final telemetry.
+func (b *batcher) startEMF() {
+	if b.emf != nil {
+		b.emf.start(b.telemetry, b.log)
+	}
+}
+
 func (b *batcher) Put(ctx context.Context, bt *batch.Batch) error {
-	for _, records := range bt.Chunk() {
-		out, err := b.client.PutRecordsWithContext(ctx, &kinesis.PutRecordsInput{
-			StreamName: b.stream,
-			Records:    records,
-		})
+	start := time.Now()
+	b.telemetry.sampled(ctx, bt.SampledOut())
+	b.telemetry.invalid(ctx, bt.Invalid())
+	// Chunking compresses the frames of the batch compression
+	chunks := b.chunks(bt)
+	uncompressed, compressed := bt.CompressedBytes()
+	b.telemetry.compressed(ctx, uncompressed, compressed)
+	var dropped droppedErrors
+	err := b.dispatch(ctx, chunks, func(ctx context.Context, c chunk) error {
+		if err := dropped.keep(b.putRecords(ctx, c)); err != nil {
+			return err
+		}
+		b.log.Debug("Successfully wrote batch to kinesis", zap.Stringp("stream", c.stream))
+		return nil
+	})
+	b.telemetry.put(ctx, start, err)
+	b.observe(dropped.observed(err))
+	return err
+}
+
+// observe updates the health with the result of a Put and logs when it changes.
+func (b *batcher) observe(err error) {
+	if !b.health.observe(err) {
+		return
+	}
+	if b.health.healthy() {
+		b.log.Info("Writes are succeeding again, the exporter is healthy", zap.Stringp("stream", b.stream))
+	} else {
+		b.log.Warn("Consecutive writes have permanently failed, the exporter is unhealthy",
+			zap.Stringp("stream", b.stream), zap.Int64("failures", b.health.threshold), zap.Error(err))
+	}
+}
+
+// keepsOrder reports if the chunks that share a partition key are written in order.
+func (b *batcher) keepsOrder() bool {
+	return b.order != nil
+}
+
+// chunk is the records written to a stream by a single request.
+type chunk struct {
+	stream  *string
+	records []*kinesis.PutRecordsRequestEntry
+	// ticket is the place of the chunk within the key order when set
+	ticket *ticket
+	// offset is the index of the first record of the chunk within the batch
+	offset int
+}
+
+// chunks returns the chunks of the batch and of its routed batches,
+// batches without a stream are written to the stream of the Batcher.
+func (b *batcher) chunks(bt *batch.Batch) []chunk {
+	var (
+		chunks []chunk
+		offset int
+	)
+	for _, r := range bt.Routes() {
+		stream := b.stream
+		if r.Stream() != "" {
+			stream = aws.String(r.Stream())
+		}
+		for _, records := range r.Chunk() {
+			for _, records := range b.adaptive.split(records) {
+				chunks = append(chunks, chunk{stream: stream, records: records, offset: offset})
+				offset += len(records)
+			}
+		}
+	}
+	return chunks
+}
 
-		if err != nil {
+// dispatch calls put for each chunk using up to maxConcurrency concurrent calls.
+// When the chunks are written concurrently, every chunk is attempted and
+// the errors are combined and are only permanent if every failed chunk was permanent.
+// When written one at a time, the chunks after a permanently failed chunk are
+// still attempted and the first transient error is returned since the batch is retried.
+func (b *batcher) dispatch(ctx context.Context, chunks []chunk, put func(context.Context, chunk) error) error {
+	var records int
+	for _, c := range chunks {
+		records += len(c.records)
+	}
+	done, err := b.begin(ctx, records)
+	if err != nil {
+		return err
+	}
+	defer done()
+	ctx, cancel := b.cancelOnShutdown(ctx)
+	defer cancel()
+	// The bytes are acquired once it is the turn of a chunk so that
+	// chunks waiting for earlier chunks do not hold any bytes.
+	put = b.maxInflight.limit(put)
+	if b.order != nil {
+		// The chunks are queued once admitted so that they only
+		// wait for chunks that are already being written.
+		for i := range chunks {
+			chunks[i].ticket = b.order.enqueue(chunks[i])
+		}
+		defer func() {
+			// Chunks that are not written after an error give up their place
+			for _, c := range chunks {
+				b.order.release(c.ticket)
+			}
+		}()
+		put = b.order.ordered(put)
+	}
+	for _, c := range chunks {
+		b.telemetry.flushed(ctx, len(c.records), recordsSize(c.records))
+	}
+
+	if b.maxConcurrency <= 1 || len(chunks) <= 1 {
+		var errs []error
+		for _, c := range chunks {
+			err := put(ctx, c)
+			if err == nil {
+				continue
+			}
+			if !consumererror.IsPermanent(err) {
+				return err
+			}
+			errs = append(errs, err)
+		}
+		return multierr.Combine(errs...)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+		sem  = make(chan struct{}, b.maxConcurrency)
+	)
+	for _, c := range chunks {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(c chunk) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := put(ctx, c); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	// The permanent errors are dropped if any chunk can be retried
+	// since they would otherwise cause the whole batch to be dropped.
+	var transient []error
+	for _, err := range errs {
+		if !consumererror.IsPermanent(err) {
+			transient = append(transient, err)
+		}
+	}
+	if len(transient) > 0 {
+		return multierr.Combine(transient...)
+	}
+	return multierr.Combine(errs...)
+}
+
+// putRecords writes the records to kinesis and only retries the records
+// that were reported as failed so that successfully written records
+// are not duplicated within the stream.
+// Throttled writes are retried using the configured backoff, records of
+// shards that have recently been throttled are held back until the other
+// records have been written or the shards have cooled down.
+func (b *batcher) putRecords(ctx context.Context, c chunk) error {
+	stream, records := c.stream, c.records
+	failures := recordFailures{c: c}
+	shards := b.shardsOf(stream)
+	bo := b.backoff.newBackOff(ctx)
+	onlyHeld := false
+	// throttled is set once the chunk has been throttled so that it does not grow the adaptive size
+	throttled := false
+	for attempt := 1; ; attempt++ {
+		send, held, cooldown := shards.split(records)
+		if onlyHeld {
+			// Failed records have already waited for the backoff
+			// so only held back records wait for their shards.
+			if err := b.cooldown(ctx, cooldown); err != nil {
+				return err
+			}
+			onlyHeld = false
+		}
+		if err := b.limiter.wait(ctx, len(send), recordsSize(send)); err != nil {
+			return err
+		}
+		out, err := b.tracedPutRecords(ctx, stream, send, attempt)
+
+		if err != nil && b.singleRecord && out != nil {
+			// The records written before the failed PutRecord call
+			// are not failed or retried along with the rest.
+			shards.observe(send, out)
+			failed, _ := b.failedRecords(ctx, send, out, &failures)
+			records = append(failed, held...)
+		}
+		if err != nil && !isThrottled(err) && !errors.Is(err, ErrRequestTimeout) {
 			if aerr, ok := err.(awserr.Error); ok {
-				switch aerr.Code() {
-				case kinesis.ErrCodeResourceNotFoundException, kinesis.ErrCodeInvalidArgumentException:
+				switch code := aerr.Code(); {
+				case code == kinesis.ErrCodeResourceNotFoundException, code == kinesis.ErrCodeInvalidArgumentException, isPermanentCode(code):
 					err = consumererror.NewPermanent(err)
 				}
 			}
@@ -70,18 +373,436 @@ func (b *batcher) Put(ctx context.Context, bt *batch.Batch) error {
 			if out != nil {
 				fields = append(fields, zap.Int64p("failed-records", out.FailedRecordCount))
 			}
-			b.log.Error("Failed to write records to kinesis", fields...)
-			return err
+			b.failureLog.Error("Failed to write records to kinesis", fields...)
+			b.telemetry.failed(ctx, len(records))
+			err = failures.wrap(err, records)
+			if consumererror.IsPermanent(err) && b.deadLetter != nil {
+				err = b.putDeadLetter(ctx, records, err)
+			}
+			return b.drop(ctx, len(records), err)
+		}
+
+		if err == nil {
+			shards.observe(send, out)
+			failed, codes := b.failedRecords(ctx, send, out, &failures)
+			if codes.throttled() && !throttled {
+				throttled = true
+				b.telemetry.resized(ctx, b.adaptive.throttled())
+			}
+			records = append(failed, held...)
+			if len(records) == 0 {
+				if !throttled {
+					b.telemetry.resized(ctx, b.adaptive.succeeded())
+				}
+				return nil
+			}
+			if len(failed) == 0 {
+				// Only held back records are left which do not
+				// count as an attempt since they were not sent.
+				attempt--
+				onlyHeld = true
+				continue
+			}
+			err = fmt.Errorf("failed to write %d records to kinesis after %d attempts: %s", len(records), attempt, codes)
+			if codes.permanent(b.retryable(DefaultRetryableErrorCodes)) {
+				// Retrying can not succeed when a record has been rejected
+				err = failures.wrap(consumererror.NewPermanent(err), records)
+				b.failureLog.Error("Failed to write records to kinesis",
+					zap.Error(err),
+					zap.Int("failed-records", len(records)),
+					zap.Int("attempts", attempt),
+				)
+				b.telemetry.failed(ctx, len(records))
+				if b.deadLetter != nil {
+					err = b.putDeadLetter(ctx, records, err)
+				}
+				return b.drop(ctx, len(records), err)
+			}
+		} else if isThrottled(err) {
+			b.telemetry.throttled(ctx, 1)
+			var counts throttleCounts
+			counts.add(err.(awserr.Error).Message(), len(send), recordsSize(send))
+			b.telemetry.shardThrottled(ctx, counts)
+			if !throttled {
+				throttled = true
+				b.telemetry.resized(ctx, b.adaptive.throttled())
+			}
 		}
 
-		b.log.Debug("Successfully wrote batch to kinesis", zap.Stringp("stream", b.stream))
+		if retry, rerr := b.wait(ctx, bo, attempt, len(records), err); !retry {
+			b.failureLog.Error("Failed to write records to kinesis",
+				zap.Error(err),
+				zap.Int("failed-records", len(records)),
+				zap.Int("attempts", attempt),
+			)
+			b.telemetry.failed(ctx, len(records))
+			if rerr != nil {
+				return b.drop(ctx, len(records), failures.wrap(rerr, records))
+			}
+			// The error is left as transient to allow for
+			// the exporter queue to retry the data later on
+			return failures.wrap(err, records)
+		}
 	}
+}
+
+// drop returns a droppedError in place of a permanent error when permanently failed
+// records are dropped, the records are counted as dropped so that the export is not failed.
+func (b *batcher) drop(ctx context.Context, records int, err error) error {
+	if !b.dropPermanent || !consumererror.IsPermanent(err) {
+		return err
+	}
+	b.failureLog.Warn("Dropped records that permanently failed to be written",
+		zap.Stringp("stream", b.stream),
+		zap.Int("records", records),
+		zap.Error(err),
+	)
+	b.telemetry.dropped(ctx, records)
+	return droppedError{err: err}
+}
+
+// shardsOf returns the shard tracker of the stream,
+// the partition keys of each stream are mapped to their own shards.
+func (b *batcher) shardsOf(stream *string) *shardTracker {
+	if stream == b.stream {
+		return b.shards
+	}
+	b.routedMu.Lock()
+	defer b.routedMu.Unlock()
+	st, ok := b.routedShards[*stream]
+	if !ok {
+		if b.routedShards == nil {
+			b.routedShards = make(map[string]*shardTracker)
+		}
+		st = newShardTracker()
+		b.routedShards[*stream] = st
+	}
+	return st
+}
+
+// cooldown blocks for the time until the shards have cooled down, bounded
+// by the max backoff interval, the context error is returned if it is done first.
+func (b *batcher) cooldown(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	if d > b.backoff.MaxInterval {
+		d = b.backoff.MaxInterval
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// tracedPutRecords makes a single PutRecords call, or a PutRecord call for each
+// record in single record mode, within a span describing the records written
+// and how many of them failed.
+func (b *batcher) tracedPutRecords(ctx context.Context, stream *string, records []*kinesis.PutRecordsRequestEntry, attempt int) (*kinesis.PutRecordsOutput, error) {
+	name := "PutRecords"
+	if b.singleRecord {
+		name = "PutRecord"
+	}
+	ctx, span := b.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(putAttributes(aws.StringValue(stream), len(records), recordsSize(records), attempt)...),
+	)
+	defer span.End()
+
+	var out *kinesis.PutRecordsOutput
+	err := b.request(ctx, func(ctx context.Context) (err error) {
+		out, err = b.put(ctx, b.client, stream, records)
+		return err
+	})
+	if out != nil {
+		endPut(span, err, int(aws.Int64Value(out.FailedRecordCount)))
+	} else {
+		endPut(span, err, 0)
+	}
+	return out, err
+}
+
+// request makes a single request with the context limited to the request timeout,
+// the context is derived from ctx so that cancelling it still stops the request.
+func (b *batcher) request(ctx context.Context, call func(context.Context) error) error {
+	if b.requestTimeout <= 0 {
+		return call(ctx)
+	}
+	rctx, cancel := context.WithTimeout(ctx, b.requestTimeout)
+	defer cancel()
+	err := call(rctx)
+	if err != nil && ctx.Err() == nil && errors.Is(rctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %s: %v", ErrRequestTimeout, b.requestTimeout, err)
+	}
+	return err
+}
+
+// wait blocks until the next backoff interval has passed and reports
+// if the failed records should be retried, the context error is returned
+// if it is done before the interval has passed. Once the retry budget is
+// used up the cause is returned as a permanent error without waiting.
+func (b *batcher) wait(ctx context.Context, bo backoff.BackOff, attempt, failed int, cause error) (bool, error) {
+	next := bo.NextBackOff()
+	if next == backoff.Stop || (b.maxAttempts > 0 && attempt >= b.maxAttempts) {
+		return false, nil
+	}
+	if !b.retryBudget.take() {
+		b.telemetry.budgetExhausted(ctx, failed)
+		return false, consumererror.NewPermanent(fmt.Errorf("%w: %v", ErrRetryBudgetExhausted, cause))
+	}
+
+	b.log.Debug("Retrying throttled records",
+		zap.Int("failed-records", failed),
+		zap.Int("attempt", attempt),
+		zap.Duration("backoff", next),
+	)
+	timer := time.NewTimer(next)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-timer.C:
+		return true, nil
+	}
+}
+
+// failedRecords returns the records that have an error code set
+// within their matching result entry with the counts of each error code,
+// and reports the written records.
+func (b *batcher) failedRecords(ctx context.Context, records []*kinesis.PutRecordsRequestEntry, out *kinesis.PutRecordsOutput, failures *recordFailures) (failed []*kinesis.PutRecordsRequestEntry, codes recordErrors) {
+	var (
+		sent, size, throttles int
+		limits                throttleCounts
+	)
+	requested := recordsSize(records)
+	codes = make(recordErrors)
+	for i, record := range records {
+		// The result entries are returned in the same order as the request records
+		if out != nil && i < len(out.Records) && out.Records[i].ErrorCode != nil {
+			code, message := aws.StringValue(out.Records[i].ErrorCode), aws.StringValue(out.Records[i].ErrorMessage)
+			if code == kinesis.ErrCodeProvisionedThroughputExceededException {
+				throttles++
+				limits.add(message, len(records), requested)
+			}
+			codes.add(code, message)
+			failures.failed(record, code)
+			failed = append(failed, record)
+			continue
+		}
+		sent++
+		size += len(record.Data) + len(aws.StringValue(record.PartitionKey))
+	}
+	b.telemetry.sent(ctx, sent, size)
+	b.telemetry.throttled(ctx, throttles)
+	b.telemetry.shardThrottled(ctx, limits)
+	return failed, codes
+}
+
+// putDeadLetter writes the records to the dead letter stream,
+// the original error is returned if the records could not be written.
+func (b *batcher) putDeadLetter(ctx context.Context, records []*kinesis.PutRecordsRequestEntry, cause error) error {
+	out, err := b.put(ctx, b.deadLetter.client, b.deadLetter.stream, records)
+	if err == nil && out != nil && aws.Int64Value(out.FailedRecordCount) > 0 {
+		err = fmt.Errorf("failed to write %d records", aws.Int64Value(out.FailedRecordCount))
+	}
+	if err != nil {
+		b.failureLog.Error("Failed to write records to the dead letter stream",
+			zap.Error(err),
+			zap.Stringp("stream", b.deadLetter.stream),
+		)
+		return cause
+	}
+	b.log.Warn("Wrote permanently failed records to the dead letter stream",
+		zap.Stringp("stream", b.deadLetter.stream),
+		zap.Int("records", len(records)),
+	)
 	return nil
 }
 
+// put writes the records to the stream using the api of the configured mode.
+func (b *batcher) put(ctx context.Context, client kinesisiface.KinesisAPI, stream *string, records []*kinesis.PutRecordsRequestEntry) (*kinesis.PutRecordsOutput, error) {
+	if b.dryRun {
+		return b.putDryRun(stream, records), nil
+	}
+	if b.singleRecord {
+		return putEachRecord(ctx, client, stream, records, b.sequences)
+	}
+	name, arn := streamParams(stream)
+	return client.PutRecordsWithContext(ctx, &kinesis.PutRecordsInput{
+		StreamName: name,
+		StreamARN:  arn,
+		Records:    records,
+	})
+}
+
+// putDryRun logs the records that would have been written and reports them all as written.
+func (b *batcher) putDryRun(stream *string, records []*kinesis.PutRecordsRequestEntry) *kinesis.PutRecordsOutput {
+	b.log.Info("Dry run, skipped writing records to kinesis",
+		zap.Stringp("stream", stream),
+		zap.Int("records", len(records)),
+		zap.Int("bytes", recordsSize(records)),
+	)
+	out := &kinesis.PutRecordsOutput{
+		FailedRecordCount: aws.Int64(0),
+		Records:           make([]*kinesis.PutRecordsResultEntry, len(records)),
+	}
+	for i := range records {
+		out.Records[i] = &kinesis.PutRecordsResultEntry{}
+	}
+	return out
+}
+
+// retryable returns the configured retryable error codes,
+// otherwise the defaults of the service are used.
+func (b *batcher) retryable(defaults func() []string) []string {
+	if b.retryableCodes != nil {
+		return b.retryableCodes
+	}
+	return defaults()
+}
+
+// recordsSize returns the size of the records counted towards the kinesis limits.
+func recordsSize(records []*kinesis.PutRecordsRequestEntry) (size int) {
+	for _, record := range records {
+		size += len(record.Data) + len(aws.StringValue(record.PartitionKey))
+	}
+	return size
+}
+
+func isThrottled(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == kinesis.ErrCodeProvisionedThroughputExceededException
+}
+
+// Ready checks that the stream exists and can be written to,
+// a stream that is still being created or deleted is reported as an error.
+// The stream is not checked in dry run mode.
 func (b *batcher) Ready(ctx context.Context) error {
-	_, err := b.client.DescribeStreamWithContext(ctx, &kinesis.DescribeStreamInput{
-		StreamName: b.stream,
+	if b.dryRun {
+		return nil
+	}
+	name, arn := streamParams(b.stream)
+	out, err := b.client.DescribeStreamSummaryWithContext(ctx, &kinesis.DescribeStreamSummaryInput{
+		StreamName: name,
+		StreamARN:  arn,
 	})
+	if isNotFound(err) {
+		return fmt.Errorf("stream %q does not exist: %w", aws.StringValue(b.stream), err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to describe stream %q: %w", aws.StringValue(b.stream), err)
+	}
+	switch status := aws.StringValue(out.StreamDescriptionSummary.StreamStatus); status {
+	case kinesis.StreamStatusActive, kinesis.StreamStatusUpdating:
+		return nil
+	default:
+		return fmt.Errorf("stream %q is not active, its status is %s", aws.StringValue(b.stream), status)
+	}
+}
+
+// begin registers the records that are about to be written so that
+// Shutdown can wait for them, an error is returned once shut down.
+// While the max buffered records are in-flight, begin blocks until
+// enough records are released or the context is done. The records are
+// always accepted when none are in-flight so that a batch larger than
+// the limit is still written.
+func (b *batcher) begin(ctx context.Context, records int) (func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for {
+		if b.closed {
+			return nil, ErrShutdown
+		}
+		buffered := atomic.LoadInt64(&b.inflightRecords)
+		if b.maxBuffered == 0 || buffered == 0 || buffered+int64(records) <= b.maxBuffered {
+			break
+		}
+		released := b.released
+		b.mu.Unlock()
+		select {
+		case <-released:
+			b.mu.Lock()
+		case <-ctx.Done():
+			b.mu.Lock()
+			return nil, fmt.Errorf("buffer full with %d in-flight records: %w", buffered, ctx.Err())
+		}
+	}
+	b.inflight.Add(1)
+	atomic.AddInt64(&b.inflightRecords, int64(records))
+	return func() {
+		b.mu.Lock()
+		atomic.AddInt64(&b.inflightRecords, -int64(records))
+		if b.released != nil {
+			close(b.released)
+			b.released = make(chan struct{})
+		}
+		b.mu.Unlock()
+		b.inflight.Done()
+	}, nil
+}
+
+// force cancels the in-flight writes, it is safe to call more than once.
+func (b *batcher) force() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	select {
+	case <-b.forced:
+	default:
+		close(b.forced)
+	}
+}
+
+// cancelOnShutdown returns a context that is also canceled once
+// Shutdown forces the in-flight writes to stop.
+func (b *batcher) cancelOnShutdown(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-b.forced:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// Shutdown stops accepting new data and waits for the in-flight writes to
+// complete. If the context is done first, the in-flight writes are canceled
+// and their records dropped, an error reporting the dropped records is returned.
+func (b *batcher) Shutdown(ctx context.Context) error {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		b.inflight.Wait()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		dropped := atomic.LoadInt64(&b.inflightRecords)
+		b.force()
+		b.log.Warn("Shutdown did not complete in time, dropping the in-flight records",
+			zap.Stringp("stream", b.stream), zap.Int64("records", dropped))
+		b.telemetry.shutdownDropped(context.Background(), dropped)
+		err = fmt.Errorf("shutdown before %d in-flight records were written: %w", dropped, ctx.Err())
+	}
+	if b.emf != nil {
+		b.emf.shutdown(ctx)
+	}
+	if b.shardCount != nil {
+		b.shardCount.shutdown()
+	}
+	if b.shardRanges != nil {
+		b.shardRanges.shutdown()
+	}
 	return err
 }
@@ -16,8 +16,18 @@ package producer
 
 import (
 	"errors"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/kinesis/kinesisiface"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
 )
 
 type BatcherOptions func(*batcher) error
@@ -32,3 +42,312 @@ func WithLogger(l *zap.Logger) BatcherOptions {
 		return nil
 	}
 }
+
+// WithFailureLogging limits the logging of failed writes, which are already counted
+// by the metrics, so that sustained failures do not flood the logs. Each second the
+// first failure of each message is logged followed by one of every sampleRate failures,
+// no failures are logged when disabled.
+func WithFailureLogging(enabled bool, sampleRate int) BatcherOptions {
+	return func(p *batcher) error {
+		if !enabled {
+			p.failureSampler = func(*zap.Logger) *zap.Logger { return zap.NewNop() }
+			return nil
+		}
+		if sampleRate < 1 {
+			return errors.New("failure log sample rate must be at least 1")
+		}
+		p.failureSampler = func(l *zap.Logger) *zap.Logger {
+			return l.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+				return zapcore.NewSamplerWithOptions(core, time.Second, 1, sampleRate)
+			}))
+		}
+		return nil
+	}
+}
+
+// WithMaxAttempts sets the number of times that failed records
+// are attempted to be written to kinesis before returning an error,
+// by default only the backoff limits the number of attempts.
+func WithMaxAttempts(attempts int) BatcherOptions {
+	return func(p *batcher) error {
+		if attempts < 1 {
+			return errors.New("max attempts must be at least 1")
+		}
+		p.maxAttempts = attempts
+		return nil
+	}
+}
+
+// WithMaxConcurrency sets the number of chunks of a batch
+// that can be written concurrently, by default chunks are written one at a time.
+func WithMaxConcurrency(requests int) BatcherOptions {
+	return func(p *batcher) error {
+		if requests < 1 {
+			return errors.New("max concurrency must be at least 1")
+		}
+		p.maxConcurrency = requests
+		return nil
+	}
+}
+
+// WithRateLimit limits the records and bytes written per second by the Batcher,
+// including retries and concurrent writes, a zero limit is not applied.
+func WithRateLimit(recordsPerSecond, bytesPerSecond int) BatcherOptions {
+	return func(p *batcher) error {
+		if recordsPerSecond < 0 || bytesPerSecond < 0 {
+			return errors.New("rate limits must not be negative")
+		}
+		if recordsPerSecond > 0 || bytesPerSecond > 0 {
+			p.limiter = newRateLimiter(recordsPerSecond, bytesPerSecond)
+		}
+		return nil
+	}
+}
+
+// WithMaxBufferedRecords limits the records being written by the Batcher,
+// including the records being retried, so that Put blocks until enough
+// records are written instead of holding an unbounded number of records.
+// A zero limit is not applied.
+func WithMaxBufferedRecords(records int) BatcherOptions {
+	return func(p *batcher) error {
+		if records < 0 {
+			return errors.New("max buffered records must not be negative")
+		}
+		if records > 0 {
+			p.maxBuffered = int64(records)
+			p.released = make(chan struct{})
+		}
+		return nil
+	}
+}
+
+// WithMaxInflightBytes limits the bytes of the chunks written at once by the Batcher,
+// including concurrent writes and their retries, so that writes block until enough
+// bytes are written instead of holding an unbounded number of bytes.
+// A zero limit is not applied.
+func WithMaxInflightBytes(bytes int) BatcherOptions {
+	return func(p *batcher) error {
+		if bytes < 0 {
+			return errors.New("max in-flight bytes must not be negative")
+		}
+		if bytes > 0 {
+			p.maxInflight = newInflightBytes(bytes)
+		}
+		return nil
+	}
+}
+
+// WithRetryBudget limits the failed writes that are retried within each window
+// across all the writes of the Batcher. Once the budget is used up, writes that
+// fail are returned as a permanent error without being retried until the
+// window has passed.
+func WithRetryBudget(retries int, window time.Duration) BatcherOptions {
+	return func(p *batcher) error {
+		if retries < 0 || window <= 0 {
+			return errors.New("retry budget must not be negative with a positive window")
+		}
+		p.retryBudget = newRetryBudget(retries, window)
+		return nil
+	}
+}
+
+// WithRequestTimeout limits the time of each request made by the Batcher,
+// a request that times out is retried using the backoff.
+func WithRequestTimeout(timeout time.Duration) BatcherOptions {
+	return func(p *batcher) error {
+		if timeout < 0 {
+			return errors.New("request timeout must not be negative")
+		}
+		p.requestTimeout = timeout
+		return nil
+	}
+}
+
+// WithDropPermanentErrors drops the records that permanently failed to be written,
+// after trying the dead letter stream when set, so that Put does not return the
+// permanent error. The dropped records are logged and counted.
+func WithDropPermanentErrors() BatcherOptions {
+	return func(p *batcher) error {
+		p.dropPermanent = true
+		return nil
+	}
+}
+
+// WithUnhealthyThreshold sets the number of consecutive writes that must
+// permanently fail before the Batcher logs that it is unhealthy.
+func WithUnhealthyThreshold(failures int) BatcherOptions {
+	return func(p *batcher) error {
+		if failures < 1 {
+			return errors.New("unhealthy threshold must be at least 1")
+		}
+		p.health.threshold = int64(failures)
+		return nil
+	}
+}
+
+// WithRetryableErrorCodes sets the record error codes that are retried,
+// replacing the defaults of the service. Records that fail with any other
+// error code are returned as a permanent error.
+func WithRetryableErrorCodes(codes ...string) BatcherOptions {
+	return func(p *batcher) error {
+		if len(codes) == 0 {
+			return errors.New("no retryable error codes provided")
+		}
+		p.retryableCodes = append([]string(nil), codes...)
+		return nil
+	}
+}
+
+// WithBackoff sets the backoff used to retry throttled writes
+func WithBackoff(settings BackoffSettings) BatcherOptions {
+	return func(p *batcher) error {
+		if settings.InitialInterval <= 0 || settings.MaxInterval < settings.InitialInterval {
+			return errors.New("backoff intervals must be positive with max interval no less than the initial interval")
+		}
+		if settings.MaxElapsedTime < 0 {
+			return errors.New("backoff max elapsed time must not be negative")
+		}
+		if settings.Multiplier < 1 {
+			return errors.New("backoff multiplier must be at least 1")
+		}
+		p.backoff = settings
+		return nil
+	}
+}
+
+// WithDeadLetter sets the stream that records are written to once they have
+// permanently failed to be written, a nil client will reuse the Batcher client.
+func WithDeadLetter(client kinesisiface.KinesisAPI, stream string) BatcherOptions {
+	return func(p *batcher) error {
+		if stream == "" {
+			return errors.New("empty dead letter stream name")
+		}
+		if client == nil {
+			client = p.client
+		}
+		p.deadLetter = &deadLetter{client: client, stream: aws.String(stream)}
+		return nil
+	}
+}
+
+// WithMeterProvider sets the meter provider used to report the Batcher metrics,
+// the attributes are added to every measurement.
+func WithMeterProvider(mp metric.MeterProvider, attrs ...attribute.KeyValue) BatcherOptions {
+	return func(p *batcher) error {
+		if mp == nil {
+			return errors.New("nil meter provider trying to be assigned")
+		}
+		p.telemetry = newTelemetry(mp, attrs...)
+		return nil
+	}
+}
+
+// WithTracerProvider sets the tracer provider used to create
+// a span for each write made by the Batcher.
+func WithTracerProvider(tp trace.TracerProvider) BatcherOptions {
+	return func(p *batcher) error {
+		if tp == nil {
+			return errors.New("nil tracer provider trying to be assigned")
+		}
+		p.tracer = tp.Tracer(instrumentationName)
+		return nil
+	}
+}
+
+// WithEMF periodically writes the records sent, failed and throttled by the
+// Batcher to the log stream using the CloudWatch embedded metric format,
+// the attributes of WithMeterProvider are used as the metric dimensions.
+func WithEMF(client cloudwatchlogsiface.CloudWatchLogsAPI, settings EMFSettings) BatcherOptions {
+	return func(p *batcher) error {
+		emf, err := newEMFEmitter(client, settings)
+		if err != nil {
+			return err
+		}
+		p.emf = emf
+		return nil
+	}
+}
+
+// WithShardCountRefresh describes the stream every interval and calls onChange with
+// its number of open shards whenever it changes, including once first described.
+// The last known count is kept when the stream can not be described.
+func WithShardCountRefresh(interval time.Duration, onChange func(shards int)) BatcherOptions {
+	return func(p *batcher) error {
+		w, err := newShardCountWatcher(interval, onChange)
+		if err != nil {
+			return err
+		}
+		p.shardCount = w
+		return nil
+	}
+}
+
+// WithShardRangeRefresh lists the shards of the stream every interval and calls onChange
+// with the hash key ranges of its open shards whenever they change, including once first listed.
+// The last known ranges are kept when the shards can not be listed.
+func WithShardRangeRefresh(interval time.Duration, onChange func(ranges []batch.HashKeyRange)) BatcherOptions {
+	return func(p *batcher) error {
+		w, err := newShardRangeWatcher(interval, onChange)
+		if err != nil {
+			return err
+		}
+		p.shardRanges = w
+		return nil
+	}
+}
+
+// WithAdaptiveBatching writes the chunks of each batch using requests of up to
+// an adaptive number of records, starting at max. The size is halved whenever
+// a request is throttled, down to min, and grows by a fiftieth of max after
+// each chunk that is written without being throttled.
+func WithAdaptiveBatching(min, max int) BatcherOptions {
+	return func(p *batcher) error {
+		as, err := newAdaptiveSize(min, max)
+		if err != nil {
+			return err
+		}
+		p.adaptive = as
+		return nil
+	}
+}
+
+// WithSingleRecordMode writes each record using PutRecord instead of
+// batching them with PutRecords, such as for roles only granted PutRecord.
+func WithSingleRecordMode() BatcherOptions {
+	return func(p *batcher) error {
+		p.singleRecord = true
+		return nil
+	}
+}
+
+// WithSequenceOrdering sets the sequence number of the last record written with
+// the same partition key as the SequenceNumberForOrdering of each record, so that
+// kinesis keeps the records of a key in order across PutRecord calls. The records of
+// each key are written one at a time, which reduces the throughput. Requires WithSingleRecordMode.
+func WithSequenceOrdering() BatcherOptions {
+	return func(p *batcher) error {
+		p.sequences = newSequences(maxSequenceKeys)
+		return nil
+	}
+}
+
+// WithDryRun encodes, chunks and accounts for the records as usual but logs each
+// request that would have been made instead of writing the records, so that the
+// configuration can be validated and the load estimated without writing to the stream.
+func WithDryRun() BatcherOptions {
+	return func(p *batcher) error {
+		p.dryRun = true
+		return nil
+	}
+}
+
+// WithOrdering writes the chunks that share a partition key one at a time in the
+// order that they were put, including the retries of each chunk, so that the
+// records of a key are not written out of order by concurrent writes. Chunks
+// without a shared key are still written concurrently.
+func WithOrdering() BatcherOptions {
+	return func(p *batcher) error {
+		p.order = newKeyOrder()
+		return nil
+	}
+}
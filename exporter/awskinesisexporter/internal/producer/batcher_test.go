@@ -15,9 +15,15 @@
 package producer_test
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -28,9 +34,18 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/model/pdata"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric/metrictest"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
 
 	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/compress"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/producer"
 )
 
@@ -93,7 +108,8 @@ func TestBatchedExporter(t *testing.T) {
 	}{
 		{name: "Successful put to kinesis", PutRecordsOP: SuccessfulPutRecordsOperation, shouldErr: false, isPermanent: false},
 		{name: "Invalid kinesis configuration", PutRecordsOP: HardFailedPutRecordsOperation, shouldErr: true, isPermanent: true},
-		{name: "Test throttled kinesis operation", PutRecordsOP: TransiantPutRecordsOperation(2), shouldErr: true, isPermanent: false},
+		{name: "Test throttled kinesis operation recovers", PutRecordsOP: TransiantPutRecordsOperation(2), shouldErr: false, isPermanent: false},
+		{name: "Test throttled kinesis operation", PutRecordsOP: TransiantPutRecordsOperation(100), shouldErr: true, isPermanent: false},
 	}
 
 	bt := batch.New()
@@ -108,6 +124,12 @@ func TestBatchedExporter(t *testing.T) {
 				SetPutRecordsOperation(tc.PutRecordsOP),
 				tc.name,
 				producer.WithLogger(zaptest.NewLogger(t)),
+				producer.WithBackoff(producer.BackoffSettings{
+					InitialInterval: time.Millisecond,
+					MaxInterval:     10 * time.Millisecond,
+					MaxElapsedTime:  100 * time.Millisecond,
+					Multiplier:      2,
+				}),
 			)
 			require.NoError(t, err, "Must not error when creating BatchedExporter")
 			require.NotNil(t, be, "Must have a valid client to use")
@@ -125,3 +147,1686 @@ func TestBatchedExporter(t *testing.T) {
 		})
 	}
 }
+
+// PartialFailedPutRecordsOperation fails the records at the provided indexes
+// on the first attempt and returns the records sent in each attempt.
+func PartialFailedPutRecordsOperation(indexes ...int) (func(*kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error), *[][]*kinesis.PutRecordsRequestEntry) {
+	attempts := new([][]*kinesis.PutRecordsRequestEntry)
+	return func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+		*attempts = append(*attempts, r.Records)
+		if len(*attempts) > 1 {
+			return SuccessfulPutRecordsOperation(r)
+		}
+		out := &kinesis.PutRecordsOutput{FailedRecordCount: aws.Int64(int64(len(indexes)))}
+		for range r.Records {
+			out.Records = append(out.Records, &kinesis.PutRecordsResultEntry{
+				ShardId:        aws.String("0000000000000000000001"),
+				SequenceNumber: aws.String("0000000000000000000001"),
+			})
+		}
+		for _, i := range indexes {
+			out.Records[i] = &kinesis.PutRecordsResultEntry{
+				ErrorCode:    aws.String(kinesis.ErrCodeProvisionedThroughputExceededException),
+				ErrorMessage: aws.String("testing throttled record"),
+			}
+		}
+		return out, nil
+	}, attempts
+}
+
+func TestPartialFailureRetry(t *testing.T) {
+	t.Parallel()
+
+	op, attempts := PartialFailedPutRecordsOperation(3, 7)
+	be, err := producer.NewBatcher(SetPutRecordsOperation(op), "partial-failure",
+		producer.WithLogger(zaptest.NewLogger(t)),
+	)
+	require.NoError(t, err, "Must not error when creating BatchedExporter")
+
+	bt := batch.New()
+	for i := 0; i < 10; i++ {
+		require.NoError(t, bt.AddRecord([]byte(fmt.Sprint(i)), "fixed-key"))
+	}
+
+	assert.NoError(t, be.Put(context.Background(), bt), "Must have written the failed records on retry")
+	require.Len(t, *attempts, 2, "Must have retried once")
+	assert.Len(t, (*attempts)[0], 10, "Must have sent all records on the first attempt")
+
+	var retried []string
+	for _, record := range (*attempts)[1] {
+		retried = append(retried, string(record.Data))
+	}
+	assert.Equal(t, []string{"3", "7"}, retried, "Must have only resent the failed records")
+}
+
+func TestRecordIDRetried(t *testing.T) {
+	t.Parallel()
+
+	recordIDs := func(records []*kinesis.PutRecordsRequestEntry) (ids []string) {
+		for _, record := range records {
+			id, _, ok := batch.SplitRecordID(record.Data)
+			require.True(t, ok, "Must have the record id")
+			ids = append(ids, id)
+		}
+		return ids
+	}
+	put := func() [][]*kinesis.PutRecordsRequestEntry {
+		op, attempts := PartialFailedPutRecordsOperation(1)
+		be, err := producer.NewBatcher(SetPutRecordsOperation(op), "record-id",
+			producer.WithLogger(zaptest.NewLogger(t)),
+		)
+		require.NoError(t, err, "Must not error when creating BatchedExporter")
+
+		bt := batch.New(batch.WithRecordID())
+		for i := 0; i < 3; i++ {
+			require.NoError(t, bt.AddRecord([]byte(fmt.Sprint(i)), "fixed-key"))
+		}
+		require.NoError(t, be.Put(context.Background(), bt), "Must have written the failed record on retry")
+		require.Len(t, *attempts, 2, "Must have retried once")
+		return *attempts
+	}
+
+	first := put()
+	ids := recordIDs(first[0])
+	assert.Equal(t, ids[1:2], recordIDs(first[1]), "Must resend the failed record with the same id")
+	assert.Equal(t, ids, recordIDs(put()[0]), "Must have the same ids when the same data is put again")
+}
+
+func TestPartialFailureExhausted(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	be, err := producer.NewBatcher(SetPutRecordsOperation(func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+		calls++
+		return &kinesis.PutRecordsOutput{
+			FailedRecordCount: aws.Int64(1),
+			Records: []*kinesis.PutRecordsResultEntry{
+				{ErrorCode: aws.String(kinesis.ErrCodeProvisionedThroughputExceededException)},
+			},
+		}, nil
+	}), "partial-failure",
+		producer.WithLogger(zaptest.NewLogger(t)),
+		producer.WithMaxAttempts(2),
+	)
+	require.NoError(t, err, "Must not error when creating BatchedExporter")
+
+	bt := batch.New()
+	require.NoError(t, bt.AddRecord([]byte("data"), "fixed-key"))
+
+	err = be.Put(context.Background(), bt)
+	assert.EqualError(t, err, "failed to write 1 records to kinesis after 2 attempts: ProvisionedThroughputExceededException x1")
+	assert.False(t, consumererror.IsPermanent(err), "Must not be a permanent error")
+	assert.Equal(t, 2, calls, "Must have attempted the configured number of times")
+}
+
+func TestPartialFailureErrorCodes(t *testing.T) {
+	t.Parallel()
+
+	mixed := func(codes ...string) func(*kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+		return func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+			out := &kinesis.PutRecordsOutput{FailedRecordCount: aws.Int64(int64(len(codes)))}
+			for _, code := range codes {
+				out.Records = append(out.Records, &kinesis.PutRecordsResultEntry{
+					ErrorCode:    aws.String(code),
+					ErrorMessage: aws.String("testing " + code),
+				})
+			}
+			return out, nil
+		}
+	}
+
+	bt := batch.New()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, bt.AddRecord([]byte(fmt.Sprint(i)), "fixed-key"))
+	}
+
+	be, err := producer.NewBatcher(SetPutRecordsOperation(mixed(
+		kinesis.ErrCodeProvisionedThroughputExceededException,
+		"InternalFailure",
+		kinesis.ErrCodeProvisionedThroughputExceededException,
+		"InternalFailure",
+		kinesis.ErrCodeProvisionedThroughputExceededException,
+	)), "mixed-codes",
+		producer.WithMaxAttempts(1),
+	)
+	require.NoError(t, err, "Must not error when creating the batcher")
+
+	err = be.Put(context.Background(), bt)
+	assert.EqualError(t, err, "failed to write 5 records to kinesis after 1 attempts: "+
+		"InternalFailure x2 (testing InternalFailure), "+
+		"ProvisionedThroughputExceededException x3 (testing ProvisionedThroughputExceededException)",
+		"Must list each error code with its count")
+	assert.False(t, consumererror.IsPermanent(err), "Must not be permanent when every code is retryable")
+
+	calls := 0
+	be, err = producer.NewBatcher(SetPutRecordsOperation(func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+		calls++
+		return mixed(
+			kinesis.ErrCodeProvisionedThroughputExceededException,
+			kinesis.ErrCodeKMSAccessDeniedException,
+		)(r)
+	}), "rejected-codes")
+	require.NoError(t, err, "Must not error when creating the batcher")
+
+	err = be.Put(context.Background(), bt)
+	assert.Contains(t, err.Error(), "KMSAccessDeniedException x1", "Must list the rejected error code")
+	assert.True(t, consumererror.IsPermanent(err), "Must be permanent when a code is not retryable")
+	assert.Equal(t, 1, calls, "Must not retry records that were rejected")
+}
+
+func TestPutError(t *testing.T) {
+	t.Parallel()
+
+	bt := batch.New(batch.WithMaxRecordsPerBatch(2))
+	for i := 0; i < 5; i++ {
+		require.NoError(t, bt.AddRecord([]byte(fmt.Sprint(i)), "fixed-key"))
+	}
+
+	rejected := func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+		out := &kinesis.PutRecordsOutput{FailedRecordCount: aws.Int64(0)}
+		for _, record := range r.Records {
+			entry := &kinesis.PutRecordsResultEntry{
+				ShardId:        aws.String("0000000000000000000001"),
+				SequenceNumber: aws.String("0000000000000000000001"),
+			}
+			if string(record.Data) == "3" {
+				entry = &kinesis.PutRecordsResultEntry{
+					ErrorCode:    aws.String(kinesis.ErrCodeKMSAccessDeniedException),
+					ErrorMessage: aws.String("testing rejected record"),
+				}
+				*out.FailedRecordCount++
+			}
+			out.Records = append(out.Records, entry)
+		}
+		return out, nil
+	}
+
+	cases := []struct {
+		name      string
+		op        func(*kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error)
+		records   []int
+		codes     []string
+		permanent bool
+	}{
+		{
+			name:      "rejected record",
+			op:        rejected,
+			records:   []int{3},
+			codes:     []string{kinesis.ErrCodeKMSAccessDeniedException},
+			permanent: true,
+		},
+		{
+			name:      "failed request",
+			op:        HardFailedPutRecordsOperation,
+			records:   []int{0, 1},
+			codes:     []string{kinesis.ErrCodeResourceNotFoundException, kinesis.ErrCodeResourceNotFoundException},
+			permanent: true,
+		},
+		{
+			name:      "throttled records",
+			op:        TransiantPutRecordsOperation(100),
+			records:   []int{0, 1},
+			codes:     []string{kinesis.ErrCodeProvisionedThroughputExceededException, kinesis.ErrCodeProvisionedThroughputExceededException},
+			permanent: false,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			be, err := producer.NewBatcher(SetPutRecordsOperation(tc.op), "put-error",
+				producer.WithLogger(zaptest.NewLogger(t)),
+				producer.WithMaxAttempts(1),
+			)
+			require.NoError(t, err, "Must not error when creating the batcher")
+
+			err = be.Put(context.Background(), bt)
+			require.Error(t, err, "Must error when the records failed")
+
+			var pe *producer.PutError
+			require.True(t, errors.As(err, &pe), "Must be able to extract the PutError")
+			assert.Equal(t, "put-error", pe.Stream, "Must have the stream written to")
+			assert.Equal(t, tc.records, pe.Records, "Must have the indexes of the failed records")
+			assert.Equal(t, tc.codes, pe.Codes, "Must have the error code of each failed record")
+			assert.Equal(t, tc.permanent, pe.Permanent, "Must report if the records can be retried")
+			assert.Equal(t, tc.permanent, consumererror.IsPermanent(err), "Must keep the permanent semantics")
+		})
+	}
+}
+
+func TestAuthFailuresArePermanent(t *testing.T) {
+	t.Parallel()
+
+	for _, code := range producer.DefaultPermanentErrorCodes() {
+		code := code
+		t.Run(code, func(t *testing.T) {
+			calls := 0
+			be, err := producer.NewBatcher(SetPutRecordsOperation(func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+				calls++
+				return nil, awserr.New(code, "testing "+code, nil)
+			}), "access-denied",
+				producer.WithLogger(zaptest.NewLogger(t)),
+				producer.WithBackoff(producer.BackoffSettings{
+					InitialInterval: time.Millisecond,
+					MaxInterval:     time.Millisecond,
+					MaxElapsedTime:  time.Second,
+					Multiplier:      1,
+				}),
+			)
+			require.NoError(t, err, "Must not error when creating the batcher")
+
+			bt := batch.New()
+			require.NoError(t, bt.AddRecord([]byte("data"), "key"))
+
+			err = be.Put(context.Background(), bt)
+			assert.True(t, consumererror.IsPermanent(err), "Must return a permanent error for %s", code)
+			assert.Equal(t, 1, calls, "Must not retry the request")
+		})
+	}
+}
+
+func TestRetryableErrorCodes(t *testing.T) {
+	t.Parallel()
+
+	failOnce := func(code string) (func(*kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error), *int) {
+		calls := new(int)
+		return func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+			*calls++
+			if *calls > 1 {
+				return SuccessfulPutRecordsOperation(r)
+			}
+			return &kinesis.PutRecordsOutput{
+				FailedRecordCount: aws.Int64(1),
+				Records:           []*kinesis.PutRecordsResultEntry{{ErrorCode: aws.String(code)}},
+			}, nil
+		}, calls
+	}
+
+	bt := batch.New()
+	require.NoError(t, bt.AddRecord([]byte("data"), "fixed-key"))
+
+	op, calls := failOnce("InternalFailure")
+	be, err := producer.NewBatcher(SetPutRecordsOperation(op), "default-codes")
+	require.NoError(t, err, "Must not error when creating the batcher")
+	assert.NoError(t, be.Put(context.Background(), bt), "Must retry a default retryable code")
+	assert.Equal(t, 2, *calls, "Must have retried the failed record")
+
+	op, calls = failOnce("CustomTransientException")
+	be, err = producer.NewBatcher(SetPutRecordsOperation(op), "unknown-codes")
+	require.NoError(t, err, "Must not error when creating the batcher")
+	err = be.Put(context.Background(), bt)
+	assert.True(t, consumererror.IsPermanent(err), "Must be permanent with an unlisted code")
+	assert.Equal(t, 1, *calls, "Must not retry an unlisted code")
+
+	op, calls = failOnce("CustomTransientException")
+	be, err = producer.NewBatcher(SetPutRecordsOperation(op), "custom-codes",
+		producer.WithRetryableErrorCodes(append(producer.DefaultRetryableErrorCodes(), "CustomTransientException")...),
+	)
+	require.NoError(t, err, "Must not error when creating the batcher")
+	assert.NoError(t, be.Put(context.Background(), bt), "Must retry a configured retryable code")
+	assert.Equal(t, 2, *calls, "Must have retried the failed record")
+
+	_, err = producer.NewBatcher(SetPutRecordsOperation(SuccessfulPutRecordsOperation), "no-codes", producer.WithRetryableErrorCodes())
+	assert.Error(t, err, "Must error without any retryable codes")
+}
+
+func TestThrottledBackoff(t *testing.T) {
+	t.Parallel()
+
+	settings := producer.BackoffSettings{
+		InitialInterval: 5 * time.Millisecond,
+		MaxInterval:     20 * time.Millisecond,
+		MaxElapsedTime:  100 * time.Millisecond,
+		Multiplier:      2,
+	}
+
+	attempts := 0
+	be, err := producer.NewBatcher(SetPutRecordsOperation(func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+		attempts++
+		return TransiantPutRecordsOperation(1)(r)
+	}), "throttled",
+		producer.WithLogger(zaptest.NewLogger(t)),
+		producer.WithBackoff(settings),
+	)
+	require.NoError(t, err, "Must not error when creating BatchedExporter")
+
+	bt := batch.New()
+	require.NoError(t, bt.AddRecord([]byte("data"), "fixed-key"))
+
+	start := time.Now()
+	err = be.Put(context.Background(), bt)
+	elapsed := time.Since(start)
+
+	require.Error(t, err, "Must error once the backoff has been exhausted")
+	assert.False(t, consumererror.IsPermanent(err), "Must not be a permanent error")
+
+	// Randomization allows each interval to be up to 50% longer or shorter,
+	// the backoff stops once the next interval would exceed the max elapsed time.
+	assert.GreaterOrEqual(t, elapsed, settings.MaxElapsedTime-settings.MaxInterval*3/2, "Must have retried until near the max elapsed time")
+	assert.Less(t, elapsed, settings.MaxElapsedTime+50*time.Millisecond, "Must not have waited beyond the max elapsed time")
+
+	minInterval := settings.InitialInterval / 2
+	assert.Greater(t, attempts, 2, "Must have retried multiple times")
+	assert.LessOrEqual(t, attempts, int(settings.MaxElapsedTime/minInterval)+1, "Must not have retried more than the backoff allows")
+}
+
+func TestBatcherRoutedStreams(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu      sync.Mutex
+		streams = make(map[string]int)
+	)
+	be, err := producer.NewBatcher(SetPutRecordsOperation(func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+		mu.Lock()
+		streams[aws.StringValue(r.StreamName)] += len(r.Records)
+		mu.Unlock()
+		return SuccessfulPutRecordsOperation(r)
+	}), "default-stream",
+		producer.WithLogger(zaptest.NewLogger(t)),
+	)
+	require.NoError(t, err, "Must not error when creating BatchedExporter")
+
+	encoder, err := batch.NewEncoder("otlp_proto")
+	require.NoError(t, err, "Must have a valid encoder")
+	template, err := batch.NewStreamTemplate("otel-{tenant.id}")
+	require.NoError(t, err, "Must parse the template")
+
+	td := pdata.NewTraces()
+	for _, tenant := range []string{"tenant-a", "tenant-b", "tenant-a", ""} {
+		rs := td.ResourceSpans().AppendEmpty()
+		if tenant != "" {
+			rs.Resource().Attributes().InsertString("tenant.id", tenant)
+		}
+		rs.InstrumentationLibrarySpans().AppendEmpty().Spans().AppendEmpty().SetName("span")
+	}
+	bt, err := batch.NewStreamRouter(encoder, template).Traces(td)
+	require.NoError(t, err, "Must not error when routing the traces")
+	require.NoError(t, be.Put(context.Background(), bt), "Must have written the records of every stream")
+
+	assert.Equal(t, map[string]int{
+		"otel-tenant-a":  2,
+		"otel-tenant-b":  1,
+		"default-stream": 1,
+	}, streams, "Must have written the records of each tenant to its own stream")
+}
+
+func TestRetryBudget(t *testing.T) {
+	t.Parallel()
+
+	impl, mp := metrictest.NewMeterProvider()
+	calls := 0
+	be, err := producer.NewBatcher(SetPutRecordsOperation(func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+		calls++
+		return TransiantPutRecordsOperation(1)(r)
+	}), "budget",
+		producer.WithLogger(zaptest.NewLogger(t)),
+		producer.WithMeterProvider(mp),
+		producer.WithBackoff(producer.BackoffSettings{
+			InitialInterval: time.Millisecond,
+			MaxInterval:     time.Millisecond,
+			Multiplier:      1,
+		}),
+		producer.WithRetryBudget(2, time.Hour),
+	)
+	require.NoError(t, err, "Must not error when creating BatchedExporter")
+
+	bt := batch.New()
+	require.NoError(t, bt.AddRecord([]byte("data"), "fixed-key"))
+
+	err = be.Put(context.Background(), bt)
+	assert.ErrorIs(t, err, producer.ErrRetryBudgetExhausted, "Must stop retrying once the budget is exhausted")
+	assert.True(t, consumererror.IsPermanent(err), "Must be permanent once the budget is exhausted")
+	assert.Equal(t, 3, calls, "Must have only retried within the budget")
+
+	err = be.Put(context.Background(), bt)
+	assert.ErrorIs(t, err, producer.ErrRetryBudgetExhausted, "Must not retry while the budget is exhausted")
+	assert.True(t, consumererror.IsPermanent(err), "Must be permanent while the budget is exhausted")
+	assert.Equal(t, 4, calls, "Must not have made any further calls for the failed records")
+
+	var exhausted int64
+	for _, m := range metrictest.AsStructs(impl.MeasurementBatches) {
+		if m.Name == "exporter/awskinesis/retry_budget_exhausted" {
+			exhausted += m.Number.AsInt64()
+		}
+	}
+	assert.EqualValues(t, 2, exhausted, "Must have recorded the records that were not retried")
+}
+
+// HangingKinesisAPI blocks the first hang calls until their context is done,
+// the same way a request that does not complete would. Every hanging
+// call is sent to started when set.
+type HangingKinesisAPI struct {
+	kinesisiface.KinesisAPI
+
+	hang    int
+	calls   int
+	started chan struct{}
+}
+
+func (hka *HangingKinesisAPI) PutRecordsWithContext(ctx context.Context, r *kinesis.PutRecordsInput, opts ...request.Option) (*kinesis.PutRecordsOutput, error) {
+	hka.calls++
+	if hka.calls <= hka.hang {
+		if hka.started != nil {
+			hka.started <- struct{}{}
+		}
+		<-ctx.Done()
+		return nil, awserr.New(request.CanceledErrorCode, "request context canceled", ctx.Err())
+	}
+	return SuccessfulPutRecordsOperation(r)
+}
+
+func TestRequestTimeout(t *testing.T) {
+	t.Parallel()
+
+	newBatcher := func(client kinesisiface.KinesisAPI) producer.Batcher {
+		be, err := producer.NewBatcher(client, "timeout",
+			producer.WithLogger(zaptest.NewLogger(t)),
+			producer.WithBackoff(producer.BackoffSettings{
+				InitialInterval: time.Millisecond,
+				MaxInterval:     time.Millisecond,
+				MaxElapsedTime:  100 * time.Millisecond,
+				Multiplier:      1,
+			}),
+			producer.WithRequestTimeout(10*time.Millisecond),
+		)
+		require.NoError(t, err, "Must not error when creating BatchedExporter")
+		return be
+	}
+
+	bt := batch.New()
+	require.NoError(t, bt.AddRecord([]byte("data"), "fixed-key"))
+
+	client := &HangingKinesisAPI{hang: 1}
+	assert.NoError(t, newBatcher(client).Put(context.Background(), bt), "Must have retried the timed out request")
+	assert.Equal(t, 2, client.calls, "Must have retried once after the timeout")
+
+	client = &HangingKinesisAPI{hang: 1000}
+	err := newBatcher(client).Put(context.Background(), bt)
+	assert.ErrorIs(t, err, producer.ErrRequestTimeout, "Must have returned the timeout once the retries are exhausted")
+	assert.False(t, consumererror.IsPermanent(err), "Must treat a timed out request as transient")
+	assert.Greater(t, client.calls, 1, "Must have retried the timed out requests")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client = &HangingKinesisAPI{hang: 1000}
+	err = newBatcher(client).Put(ctx, bt)
+	assert.Error(t, err, "Must error when the context is cancelled")
+	assert.NotErrorIs(t, err, producer.ErrRequestTimeout, "Must not report a cancelled context as a timeout")
+	assert.Equal(t, 1, client.calls, "Must not retry once the context is cancelled")
+
+	_, err = producer.NewBatcher(client, "timeout", producer.WithRequestTimeout(-time.Second))
+	assert.Error(t, err, "Must error with a negative request timeout")
+}
+
+func TestDropPermanentErrors(t *testing.T) {
+	t.Parallel()
+
+	impl, mp := metrictest.NewMeterProvider()
+	be, err := producer.NewBatcher(SetPutRecordsOperation(HardFailedPutRecordsOperation), "drop",
+		producer.WithLogger(zaptest.NewLogger(t)),
+		producer.WithMeterProvider(mp),
+		producer.WithDropPermanentErrors(),
+	)
+	require.NoError(t, err, "Must not error when creating BatchedExporter")
+
+	bt := batch.New()
+	for i := 0; i < 10; i++ {
+		require.NoError(t, bt.AddRecord([]byte("data"), "fixed-key"))
+	}
+	assert.NoError(t, be.Put(context.Background(), bt), "Must not return the permanent error when dropping records")
+
+	var dropped int64
+	for _, m := range metrictest.AsStructs(impl.MeasurementBatches) {
+		if m.Name == "exporter/awskinesis/records_dropped" {
+			dropped += m.Number.AsInt64()
+		}
+	}
+	assert.EqualValues(t, 10, dropped, "Must have counted the dropped records")
+
+	be, err = producer.NewBatcher(SetPutRecordsOperation(TransiantPutRecordsOperation(100)), "drop",
+		producer.WithLogger(zaptest.NewLogger(t)),
+		producer.WithBackoff(producer.BackoffSettings{
+			InitialInterval: time.Millisecond,
+			MaxInterval:     time.Millisecond,
+			MaxElapsedTime:  10 * time.Millisecond,
+			Multiplier:      1,
+		}),
+		producer.WithDropPermanentErrors(),
+	)
+	require.NoError(t, err, "Must not error when creating BatchedExporter")
+	err = be.Put(context.Background(), bt)
+	assert.Error(t, err, "Must still return transient errors")
+	assert.False(t, consumererror.IsPermanent(err), "Must still return transient errors")
+}
+
+func TestFailureLogging(t *testing.T) {
+	t.Parallel()
+
+	bt := batch.New()
+	require.NoError(t, bt.AddRecord([]byte("data"), "fixed-key"))
+
+	for _, tc := range []struct {
+		name    string
+		opts    []producer.BatcherOptions
+		minLogs int
+		maxLogs int
+	}{
+		{name: "every failure", minLogs: 1000, maxLogs: 1000},
+		{name: "sampled", opts: []producer.BatcherOptions{producer.WithFailureLogging(true, 100)}, minLogs: 1, maxLogs: 50},
+		{name: "disabled", opts: []producer.BatcherOptions{producer.WithFailureLogging(false, 0)}, minLogs: 0, maxLogs: 0},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			core, logs := observer.New(zap.DebugLevel)
+			be, err := producer.NewBatcher(SetPutRecordsOperation(HardFailedPutRecordsOperation), "logging",
+				append([]producer.BatcherOptions{producer.WithLogger(zap.New(core))}, tc.opts...)...,
+			)
+			require.NoError(t, err, "Must not error when creating BatchedExporter")
+
+			for i := 0; i < 1000; i++ {
+				require.Error(t, be.Put(context.Background(), bt))
+			}
+			failures := logs.FilterMessage("Failed to write records to kinesis").Len()
+			assert.GreaterOrEqual(t, failures, tc.minLogs, "Must have logged the failures")
+			assert.LessOrEqual(t, failures, tc.maxLogs, "Must have limited the logged failures")
+		})
+	}
+
+	_, err := producer.NewBatcher(SetPutRecordsOperation(SuccessfulPutRecordsOperation), "logging", producer.WithFailureLogging(true, 0))
+	assert.Error(t, err, "Must error without a positive sample rate")
+}
+
+func TestOrdering(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu      sync.Mutex
+		written []string
+		keyB    = make(chan struct{})
+	)
+	be, err := producer.NewBatcher(SetPutRecordsOperation(func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+		data := string(r.Records[0].Data)
+		if data == "a-0" {
+			// Holding back the first record of key a until both
+			// records of key b have been written concurrently.
+			select {
+			case <-keyB:
+			case <-time.After(time.Second):
+			}
+		}
+		mu.Lock()
+		written = append(written, data)
+		if data == "b-1" {
+			close(keyB)
+		}
+		mu.Unlock()
+		return SuccessfulPutRecordsOperation(r)
+	}), "ordered",
+		producer.WithLogger(zaptest.NewLogger(t)),
+		producer.WithMaxConcurrency(4),
+		producer.WithOrdering(),
+	)
+	require.NoError(t, err, "Must not error when creating BatchedExporter")
+
+	bt := batch.New(batch.WithMaxRecordsPerBatch(1))
+	for _, record := range []string{"a-0", "b-0", "a-1", "b-1"} {
+		require.NoError(t, bt.AddRecord([]byte(record), record[:1]))
+	}
+	require.NoError(t, be.Put(context.Background(), bt), "Must have written every record")
+
+	assert.Equal(t, []string{"b-0", "b-1", "a-0", "a-1"}, written,
+		"Must have written each key in order while writing the other key concurrently")
+}
+
+func TestBatcherHealthy(t *testing.T) {
+	t.Parallel()
+
+	var fail, throttle bool
+	be, err := producer.NewBatcher(SetPutRecordsOperation(func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+		switch {
+		case fail:
+			return HardFailedPutRecordsOperation(r)
+		case throttle:
+			return TransiantPutRecordsOperation(100)(r)
+		}
+		return SuccessfulPutRecordsOperation(r)
+	}), "health",
+		producer.WithLogger(zaptest.NewLogger(t)),
+		producer.WithBackoff(producer.BackoffSettings{
+			InitialInterval: time.Millisecond,
+			MaxInterval:     time.Millisecond,
+			MaxElapsedTime:  10 * time.Millisecond,
+			Multiplier:      1,
+		}),
+		producer.WithUnhealthyThreshold(2),
+	)
+	require.NoError(t, err, "Must not error when creating BatchedExporter")
+	assert.True(t, producer.Healthy(be), "Must be healthy before writing")
+
+	bt := batch.New()
+	require.NoError(t, bt.AddRecord([]byte("data"), "fixed-key"))
+
+	fail = true
+	assert.Error(t, be.Put(context.Background(), bt))
+	assert.True(t, producer.Healthy(be), "Must stay healthy below the threshold")
+	assert.Error(t, be.Put(context.Background(), bt))
+	assert.False(t, producer.Healthy(be), "Must be unhealthy after consecutive permanent failures")
+
+	fail, throttle = false, true
+	assert.Error(t, be.Put(context.Background(), bt))
+	assert.False(t, producer.Healthy(be), "Must not recover from a transient failure")
+
+	throttle = false
+	assert.NoError(t, be.Put(context.Background(), bt))
+	assert.True(t, producer.Healthy(be), "Must recover once a write succeeds")
+
+	fail = true
+	assert.Error(t, be.Put(context.Background(), bt))
+	assert.True(t, producer.Healthy(be), "Must have reset the failures after the successful write")
+}
+
+func TestBatcherHealthyDroppingPermanentErrors(t *testing.T) {
+	t.Parallel()
+
+	fail := true
+	be, err := producer.NewBatcher(SetPutRecordsOperation(func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+		if fail {
+			return HardFailedPutRecordsOperation(r)
+		}
+		return SuccessfulPutRecordsOperation(r)
+	}), "health",
+		producer.WithLogger(zaptest.NewLogger(t)),
+		producer.WithDropPermanentErrors(),
+		producer.WithUnhealthyThreshold(2),
+	)
+	require.NoError(t, err, "Must not error when creating BatchedExporter")
+
+	bt := batch.New()
+	require.NoError(t, bt.AddRecord([]byte("data"), "fixed-key"))
+
+	assert.NoError(t, be.Put(context.Background(), bt), "Must not return the permanent error when dropping records")
+	assert.True(t, producer.Healthy(be), "Must stay healthy below the threshold")
+	assert.NoError(t, be.Put(context.Background(), bt), "Must not return the permanent error when dropping records")
+	assert.False(t, producer.Healthy(be), "Must be unhealthy after consecutive dropped writes")
+
+	fail = false
+	assert.NoError(t, be.Put(context.Background(), bt))
+	assert.True(t, producer.Healthy(be), "Must recover once a write succeeds")
+}
+
+func TestInvalidUnhealthyThreshold(t *testing.T) {
+	t.Parallel()
+
+	_, err := producer.NewBatcher(SetPutRecordsOperation(SuccessfulPutRecordsOperation), "health", producer.WithUnhealthyThreshold(0))
+	assert.Error(t, err, "Must error without a positive threshold")
+}
+
+func TestBatcherStreamARN(t *testing.T) {
+	t.Parallel()
+
+	const streamARN = "arn:aws:kinesis:us-west-2:123456789012:stream/other-account"
+	for _, tc := range []struct {
+		name       string
+		stream     string
+		streamName *string
+		streamARN  *string
+	}{
+		{name: "stream name", stream: "stream", streamName: aws.String("stream")},
+		{name: "stream arn", stream: streamARN, streamARN: aws.String(streamARN)},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var in *kinesis.PutRecordsInput
+			be, err := producer.NewBatcher(SetPutRecordsOperation(func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+				in = r
+				return SuccessfulPutRecordsOperation(r)
+			}), tc.stream, producer.WithLogger(zaptest.NewLogger(t)))
+			require.NoError(t, err, "Must not error when creating BatchedExporter")
+
+			bt := batch.New()
+			require.NoError(t, bt.AddRecord([]byte("data"), "fixed-key"))
+			require.NoError(t, be.Put(context.Background(), bt), "Must not error when writing the record")
+
+			require.NotNil(t, in, "Must have written the record")
+			assert.Equal(t, tc.streamName, in.StreamName, "Must only set the stream name when writing by name")
+			assert.Equal(t, tc.streamARN, in.StreamARN, "Must only set the stream arn when writing by arn")
+		})
+	}
+}
+
+func TestHotShardHeldBack(t *testing.T) {
+	t.Parallel()
+
+	const hotShard = "shardId-000000000001"
+	var (
+		calls     [][]string
+		throttled bool
+	)
+	be, err := producer.NewBatcher(SetPutRecordsOperation(func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+		var keys []string
+		out := &kinesis.PutRecordsOutput{FailedRecordCount: aws.Int64(0)}
+		for _, record := range r.Records {
+			key := aws.StringValue(record.PartitionKey)
+			keys = append(keys, key)
+			if key != "hot" {
+				out.Records = append(out.Records, &kinesis.PutRecordsResultEntry{
+					ShardId:        aws.String("shardId-000000000000"),
+					SequenceNumber: aws.String("1"),
+				})
+				continue
+			}
+			if !throttled {
+				out.Records = append(out.Records, &kinesis.PutRecordsResultEntry{
+					ErrorCode:    aws.String(kinesis.ErrCodeProvisionedThroughputExceededException),
+					ErrorMessage: aws.String("Rate exceeded for shard " + hotShard + " in stream hot-shard under account 111111111111."),
+				})
+				*out.FailedRecordCount++
+				continue
+			}
+			out.Records = append(out.Records, &kinesis.PutRecordsResultEntry{
+				ShardId:        aws.String(hotShard),
+				SequenceNumber: aws.String("1"),
+			})
+		}
+		throttled = true
+		calls = append(calls, keys)
+		return out, nil
+	}), "hot-shard",
+		producer.WithBackoff(producer.BackoffSettings{
+			InitialInterval: time.Millisecond,
+			MaxInterval:     time.Second,
+			MaxElapsedTime:  5 * time.Second,
+			Multiplier:      1,
+		}),
+	)
+	require.NoError(t, err, "Must not error when creating the batcher")
+
+	newBatch := func() *batch.Batch {
+		bt := batch.New()
+		for _, key := range []string{"hot", "first", "second"} {
+			require.NoError(t, bt.AddRecord([]byte("data"), key))
+		}
+		return bt
+	}
+
+	require.NoError(t, be.Put(context.Background(), newBatch()), "Must have written the throttled records on retry")
+	require.Len(t, calls, 2, "Must have retried the throttled record")
+	assert.Equal(t, []string{"hot"}, calls[1], "Must have only retried the throttled record")
+
+	calls = nil
+	start := time.Now()
+	require.NoError(t, be.Put(context.Background(), newBatch()), "Must not error when writing the next batch")
+	assert.Equal(t, [][]string{{"first", "second"}, {"hot"}}, calls,
+		"Must have written the records of other shards before the records of the hot shard")
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond, "Must have waited for the hot shard to cool down")
+}
+
+func TestInvalidBackoff(t *testing.T) {
+	t.Parallel()
+
+	for _, settings := range []producer.BackoffSettings{
+		{InitialInterval: 0, MaxInterval: time.Second, Multiplier: 1.5},
+		{InitialInterval: time.Second, MaxInterval: time.Millisecond, Multiplier: 1.5},
+		{InitialInterval: time.Second, MaxInterval: time.Second, MaxElapsedTime: -1, Multiplier: 1.5},
+		{InitialInterval: time.Second, MaxInterval: time.Second, Multiplier: 0.5},
+	} {
+		_, err := producer.NewBatcher(SetPutRecordsOperation(SuccessfulPutRecordsOperation), "invalid",
+			producer.WithBackoff(settings),
+		)
+		assert.Error(t, err, "Must error with invalid backoff settings %+v", settings)
+	}
+}
+
+func TestDeadLetterStream(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		deadLetter func(*kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error)
+		shouldErr  bool
+	}{
+		{name: "Successful dead letter write", deadLetter: SuccessfulPutRecordsOperation, shouldErr: false},
+		{name: "Failed dead letter write", deadLetter: HardFailedPutRecordsOperation, shouldErr: true},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var written []*kinesis.PutRecordsInput
+			dlq := SetPutRecordsOperation(func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+				written = append(written, r)
+				return tc.deadLetter(r)
+			})
+
+			be, err := producer.NewBatcher(SetPutRecordsOperation(HardFailedPutRecordsOperation), "primary",
+				producer.WithLogger(zaptest.NewLogger(t)),
+				producer.WithDeadLetter(dlq, "dead-letter"),
+			)
+			require.NoError(t, err, "Must not error when creating BatchedExporter")
+
+			bt := batch.New()
+			for i := 0; i < 5; i++ {
+				require.NoError(t, bt.AddRecord([]byte(fmt.Sprint(i)), "fixed-key"))
+			}
+
+			err = be.Put(context.Background(), bt)
+			require.Len(t, written, 1, "Must have attempted to write to the dead letter stream")
+			assert.Equal(t, "dead-letter", aws.StringValue(written[0].StreamName), "Must have written to the dead letter stream")
+			assert.Len(t, written[0].Records, 5, "Must have written all the failed records")
+
+			if !tc.shouldErr {
+				assert.NoError(t, err, "Must not error once the records are in the dead letter stream")
+				return
+			}
+			assert.Error(t, err, "Must error when the dead letter write failed")
+			assert.True(t, consumererror.IsPermanent(err), "Must return the original permanent error")
+		})
+	}
+}
+
+func TestBatcherMetrics(t *testing.T) {
+	t.Parallel()
+
+	impl, mp := metrictest.NewMeterProvider()
+
+	op, _ := PartialFailedPutRecordsOperation(1, 2)
+	be, err := producer.NewBatcher(SetPutRecordsOperation(op), "metrics",
+		producer.WithLogger(zaptest.NewLogger(t)),
+		producer.WithMeterProvider(mp, attribute.String("exporter", "awskinesis")),
+	)
+	require.NoError(t, err, "Must not error when creating BatchedExporter")
+
+	bt := batch.New()
+	for i := 0; i < 4; i++ {
+		require.NoError(t, bt.AddRecord([]byte("data"), "key"))
+	}
+	require.NoError(t, be.Put(context.Background(), bt), "Must have written all the records")
+
+	failing, err := producer.NewBatcher(SetPutRecordsOperation(HardFailedPutRecordsOperation), "metrics",
+		producer.WithLogger(zaptest.NewLogger(t)),
+		producer.WithMeterProvider(mp, attribute.String("exporter", "awskinesis")),
+	)
+	require.NoError(t, err, "Must not error when creating BatchedExporter")
+	require.Error(t, failing.Put(context.Background(), bt), "Must error with the failed write")
+
+	totals := make(map[string]int64)
+	var outcomes []string
+	for _, m := range metrictest.AsStructs(impl.MeasurementBatches) {
+		assert.Equal(t, "awskinesis", m.Labels["exporter"].AsString(), "Must have the configured attributes")
+		if m.Name == "exporter/awskinesis/put_duration" {
+			outcomes = append(outcomes, m.Labels["outcome"].AsString())
+			continue
+		}
+		totals[m.Name] += m.Number.AsInt64()
+	}
+	assert.Equal(t, []string{"success", "permanent"}, outcomes, "Must have observed the duration of each put by its outcome")
+	assert.Equal(t, map[string]int64{
+		"exporter/awskinesis/records_sent":     4,
+		"exporter/awskinesis/bytes_sent":       4 * int64(len("data")+len("key")),
+		"exporter/awskinesis/throttle_events":  2,
+		"exporter/awskinesis/throttle_records": 2,
+		"exporter/awskinesis/records_failed":   4,
+		"exporter/awskinesis/batch_records":    8,
+		"exporter/awskinesis/batch_bytes":      8 * int64(len("data")+len("key")),
+	}, totals, "Must have recorded the sent, throttled and failed records")
+}
+
+func TestBatcherThrottleLimits(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name    string
+		message string
+		data    []byte
+		limit   string
+	}{
+		{name: "records message", message: "Rate exceeded for records per second of shard", data: bytes.Repeat([]byte("d"), 2<<10), limit: "throttle_records"},
+		{name: "bytes message", message: "Rate exceeded for bytes per second of shard", data: []byte("data"), limit: "throttle_bytes"},
+		{name: "small records", message: "Rate exceeded for shard", data: []byte("data"), limit: "throttle_records"},
+		{name: "large records", message: "Rate exceeded for shard", data: bytes.Repeat([]byte("d"), 2<<10), limit: "throttle_bytes"},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			impl, mp := metrictest.NewMeterProvider()
+			attempts := 0
+			be, err := producer.NewBatcher(SetPutRecordsOperation(func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+				attempts++
+				switch attempts {
+				case 1:
+					// The request is throttled as a whole
+					return nil, awserr.New(kinesis.ErrCodeProvisionedThroughputExceededException, tc.message, nil)
+				case 2:
+					out, _ := SuccessfulPutRecordsOperation(r)
+					out.FailedRecordCount = aws.Int64(1)
+					out.Records[0] = &kinesis.PutRecordsResultEntry{
+						ErrorCode:    aws.String(kinesis.ErrCodeProvisionedThroughputExceededException),
+						ErrorMessage: aws.String(tc.message),
+					}
+					return out, nil
+				}
+				return SuccessfulPutRecordsOperation(r)
+			}), "throttled",
+				producer.WithLogger(zaptest.NewLogger(t)),
+				producer.WithMeterProvider(mp),
+				producer.WithBackoff(producer.BackoffSettings{InitialInterval: time.Millisecond, MaxInterval: time.Millisecond, Multiplier: 1}),
+			)
+			require.NoError(t, err, "Must not error when creating BatchedExporter")
+
+			bt := batch.New()
+			for i := 0; i < 2; i++ {
+				require.NoError(t, bt.AddRecord(tc.data, "key"))
+			}
+			require.NoError(t, be.Put(context.Background(), bt), "Must have written the records once no longer throttled")
+
+			totals := make(map[string]int64)
+			for _, m := range metrictest.AsStructs(impl.MeasurementBatches) {
+				if strings.HasPrefix(m.Name, "exporter/awskinesis/throttle_") {
+					totals[strings.TrimPrefix(m.Name, "exporter/awskinesis/")] += m.Number.AsInt64()
+				}
+			}
+			assert.Equal(t, map[string]int64{
+				"throttle_events": 2,
+				tc.limit:          2,
+			}, totals, "Must have counted the request and record throttles by the limit they exceeded")
+		})
+	}
+}
+
+func TestBatcherBatchSizeMetrics(t *testing.T) {
+	t.Parallel()
+
+	impl, mp := metrictest.NewMeterProvider()
+	be, err := producer.NewBatcher(SetPutRecordsOperation(SuccessfulPutRecordsOperation), "metrics",
+		producer.WithLogger(zaptest.NewLogger(t)),
+		producer.WithMeterProvider(mp),
+	)
+	require.NoError(t, err, "Must not error when creating BatchedExporter")
+
+	for _, records := range []int{3, 1} {
+		bt := batch.New()
+		for i := 0; i < records; i++ {
+			require.NoError(t, bt.AddRecord([]byte("data"), "key"))
+		}
+		require.NoError(t, be.Put(context.Background(), bt), "Must have written all the records")
+	}
+
+	observed := make(map[string][]int64)
+	for _, m := range metrictest.AsStructs(impl.MeasurementBatches) {
+		observed[m.Name] = append(observed[m.Name], m.Number.AsInt64())
+	}
+	size := int64(len("data") + len("key"))
+	assert.Equal(t, []int64{3, 1}, observed["exporter/awskinesis/batch_records"], "Must have observed the records of each batch")
+	assert.Equal(t, []int64{3 * size, size}, observed["exporter/awskinesis/batch_bytes"], "Must have observed the bytes of each batch")
+}
+
+func TestBatcherSampledMetric(t *testing.T) {
+	t.Parallel()
+
+	impl, mp := metrictest.NewMeterProvider()
+	be, err := producer.NewBatcher(SetPutRecordsOperation(SuccessfulPutRecordsOperation), "metrics",
+		producer.WithLogger(zaptest.NewLogger(t)),
+		producer.WithMeterProvider(mp),
+	)
+	require.NoError(t, err, "Must not error when creating BatchedExporter")
+
+	bt := batch.New(batch.WithSampler(batch.NewSampler(0)))
+	for i := 0; i < 4; i++ {
+		require.NoError(t, bt.AddRecord([]byte("data"), fmt.Sprintf("key-%d", i)))
+	}
+	require.NoError(t, be.Put(context.Background(), bt), "Must not error without any records to write")
+
+	totals := make(map[string]int64)
+	for _, m := range metrictest.AsStructs(impl.MeasurementBatches) {
+		if m.Name == "exporter/awskinesis/put_duration" {
+			continue
+		}
+		totals[m.Name] += m.Number.AsInt64()
+	}
+	assert.Equal(t, map[string]int64{
+		"exporter/awskinesis/dropped_by_sampling": 4,
+	}, totals, "Must have recorded the records dropped by sampling")
+}
+
+func TestBatcherInvalidMetric(t *testing.T) {
+	t.Parallel()
+
+	impl, mp := metrictest.NewMeterProvider()
+	op, calls := recordingPut()
+	be, err := producer.NewBatcher(SetPutRecordsOperation(op), "metrics",
+		producer.WithLogger(zaptest.NewLogger(t)),
+		producer.WithMeterProvider(mp),
+	)
+	require.NoError(t, err, "Must not error when creating BatchedExporter")
+
+	bt := batch.New(batch.WithPayloadValidation(zaptest.NewLogger(t)))
+	require.NoError(t, bt.AddRecord(nil, "empty"), "Must not error when dropping an empty payload")
+	require.NoError(t, be.Put(context.Background(), bt), "Must not error without any records to write")
+	assert.Len(t, calls, 0, "Must not have sent the empty payload")
+
+	totals := make(map[string]int64)
+	for _, m := range metrictest.AsStructs(impl.MeasurementBatches) {
+		if m.Name == "exporter/awskinesis/put_duration" {
+			continue
+		}
+		totals[m.Name] += m.Number.AsInt64()
+	}
+	assert.Equal(t, map[string]int64{
+		"exporter/awskinesis/dropped_invalid": 1,
+	}, totals, "Must have recorded the records dropped as invalid")
+}
+
+func TestBatcherPutDuration(t *testing.T) {
+	t.Parallel()
+
+	const delay = 20 * time.Millisecond
+
+	impl, mp := metrictest.NewMeterProvider()
+	settings := producer.BackoffSettings{
+		InitialInterval: 5 * time.Millisecond,
+		MaxInterval:     5 * time.Millisecond,
+		MaxElapsedTime:  30 * time.Millisecond,
+		Multiplier:      1,
+	}
+	delayed := func(op func(*kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error)) kinesisiface.KinesisAPI {
+		return SetPutRecordsOperation(func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+			time.Sleep(delay)
+			return op(r)
+		})
+	}
+
+	bt := batch.New()
+	require.NoError(t, bt.AddRecord([]byte("data"), "key"))
+
+	be, err := producer.NewBatcher(delayed(TransiantPutRecordsOperation(1)), "duration",
+		producer.WithLogger(zaptest.NewLogger(t)),
+		producer.WithMeterProvider(mp),
+		producer.WithBackoff(settings),
+	)
+	require.NoError(t, err, "Must not error when creating BatchedExporter")
+	require.NoError(t, be.Put(context.Background(), bt), "Must have written the records once retried")
+
+	throttled, err := producer.NewBatcher(delayed(TransiantPutRecordsOperation(100)), "duration",
+		producer.WithLogger(zaptest.NewLogger(t)),
+		producer.WithMeterProvider(mp),
+		producer.WithBackoff(settings),
+	)
+	require.NoError(t, err, "Must not error when creating BatchedExporter")
+	require.Error(t, throttled.Put(context.Background(), bt), "Must error once the backoff has been exhausted")
+
+	durations := make(map[string]float64)
+	for _, m := range metrictest.AsStructs(impl.MeasurementBatches) {
+		if m.Name == "exporter/awskinesis/put_duration" {
+			durations[m.Labels["outcome"].AsString()] = m.Number.AsFloat64()
+		}
+	}
+	require.Len(t, durations, 2, "Must have observed the duration of each put")
+	assert.GreaterOrEqual(t, durations["success"], float64(2*delay/time.Millisecond), "Must include the retried request")
+	assert.GreaterOrEqual(t, durations["transient"], float64(2*delay/time.Millisecond), "Must include every attempt until the backoff was exhausted")
+}
+
+func TestBatcherCompressionRatio(t *testing.T) {
+	t.Parallel()
+
+	impl, mp := metrictest.NewMeterProvider()
+	compressor, err := compress.NewCompressor(compress.Gzip, -1)
+	require.NoError(t, err, "Must have a valid compressor")
+
+	bt := batch.New(batch.WithCompression(compressor), batch.WithCompressionMinSize(0))
+	for i := 0; i < 3; i++ {
+		require.NoError(t, bt.AddRecord(bytes.Repeat([]byte("compressible "), 100), "key"))
+	}
+	// Records are only measured when compression is enabled
+	uncompressed := batch.New()
+	require.NoError(t, uncompressed.AddRecord([]byte("data"), "key"))
+
+	be, err := producer.NewBatcher(SetPutRecordsOperation(SuccessfulPutRecordsOperation), "compression",
+		producer.WithLogger(zaptest.NewLogger(t)),
+		producer.WithMeterProvider(mp),
+	)
+	require.NoError(t, err, "Must not error when creating BatchedExporter")
+	require.NoError(t, be.Put(context.Background(), bt), "Must not error when writing the batch")
+	require.NoError(t, be.Put(context.Background(), uncompressed), "Must not error when writing the batch")
+
+	var ratios []float64
+	for _, m := range metrictest.AsStructs(impl.MeasurementBatches) {
+		if m.Name == "exporter/awskinesis/compression_ratio" {
+			ratios = append(ratios, m.Number.AsFloat64())
+		}
+	}
+	require.Len(t, ratios, 1, "Must have only recorded the ratio of the compressed batch")
+	assert.Greater(t, ratios[0], 0.0, "Must have recorded the compressed size")
+	assert.Less(t, ratios[0], 1.0, "Must have recorded the savings of compressing the records")
+}
+
+func TestBatcherAdaptiveBatching(t *testing.T) {
+	t.Parallel()
+
+	impl, mp := metrictest.NewMeterProvider()
+	throttleOnce := TransiantPutRecordsOperation(1)
+	var sizes []int
+	be, err := producer.NewBatcher(SetPutRecordsOperation(func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+		sizes = append(sizes, len(r.Records))
+		return throttleOnce(r)
+	}), "adaptive",
+		producer.WithLogger(zaptest.NewLogger(t)),
+		producer.WithMeterProvider(mp),
+		producer.WithBackoff(producer.BackoffSettings{
+			InitialInterval: time.Millisecond,
+			MaxInterval:     time.Millisecond,
+			MaxElapsedTime:  time.Second,
+			Multiplier:      1,
+		}),
+		producer.WithAdaptiveBatching(10, 100),
+	)
+	require.NoError(t, err, "Must not error when creating BatchedExporter")
+
+	put := func() []int {
+		sizes = nil
+		bt := batch.New()
+		for i := 0; i < 100; i++ {
+			require.NoError(t, bt.AddRecord([]byte("data"), fmt.Sprint("key-", i)))
+		}
+		require.NoError(t, be.Put(context.Background(), bt), "Must have written the records")
+		return sizes
+	}
+
+	assert.Equal(t, []int{100, 100}, put(), "Must have retried the throttled request at the starting size")
+	assert.Equal(t, []int{50, 50}, put(), "Must have halved the size once throttled")
+	assert.Equal(t, []int{54, 46}, put(), "Must have grown the size after each request that was not throttled")
+
+	var size int64
+	for _, m := range metrictest.AsStructs(impl.MeasurementBatches) {
+		if m.Name == "exporter/awskinesis/adaptive_batch_size" {
+			size += m.Number.AsInt64()
+		}
+	}
+	assert.Equal(t, int64(58), size, "Must have reported the current adaptive size")
+
+	_, err = producer.NewBatcher(SetPutRecordsOperation(SuccessfulPutRecordsOperation), "adaptive",
+		producer.WithAdaptiveBatching(0, 100),
+	)
+	assert.Error(t, err, "Must error without a minimum size")
+}
+
+func TestBatcherSpans(t *testing.T) {
+	t.Parallel()
+
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	op, _ := PartialFailedPutRecordsOperation(1, 2)
+	be, err := producer.NewBatcher(SetPutRecordsOperation(op), "traced",
+		producer.WithLogger(zaptest.NewLogger(t)),
+		producer.WithTracerProvider(tp),
+	)
+	require.NoError(t, err, "Must not error when creating the batcher")
+
+	bt := batch.New()
+	for i := 0; i < 4; i++ {
+		require.NoError(t, bt.AddRecord([]byte("data"), "key"))
+	}
+	require.NoError(t, be.Put(context.Background(), bt), "Must have written all the records")
+
+	failing, err := producer.NewBatcher(SetPutRecordsOperation(HardFailedPutRecordsOperation), "traced",
+		producer.WithTracerProvider(tp),
+	)
+	require.NoError(t, err, "Must not error when creating the batcher")
+	require.Error(t, failing.Put(context.Background(), bt), "Must error with the failed write")
+
+	spans := sr.Ended()
+	require.Len(t, spans, 3, "Must have a span for each PutRecords call")
+
+	size := len("data") + len("key")
+	for i, expect := range []struct {
+		records, attempt, failed int
+		status                   codes.Code
+	}{
+		{records: 4, attempt: 1, failed: 2, status: codes.Error},
+		{records: 2, attempt: 2, failed: 0, status: codes.Unset},
+		{records: 4, attempt: 1, failed: 4, status: codes.Error},
+	} {
+		span := spans[i]
+		assert.Equal(t, "PutRecords", span.Name(), "Must name the span after the call")
+		assert.ElementsMatch(t, []attribute.KeyValue{
+			attribute.String("stream", "traced"),
+			attribute.Int("records", expect.records),
+			attribute.Int("bytes", expect.records*size),
+			attribute.Int("attempt", expect.attempt),
+			attribute.Int("failed_records", expect.failed),
+		}, span.Attributes(), "Must describe the records of the call")
+		assert.Equal(t, expect.status, span.Status().Code, "Must set the status of the call")
+	}
+	require.Len(t, spans[2].Events(), 1, "Must have recorded the error")
+	assert.Equal(t, "exception", spans[2].Events()[0].Name, "Must have recorded the error")
+}
+
+func TestMaxConcurrency(t *testing.T) {
+	t.Parallel()
+
+	const maxConcurrency = 3
+
+	var inflight, peak, calls int32
+	be, err := producer.NewBatcher(SetPutRecordsOperation(func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+		current := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		for {
+			max := atomic.LoadInt32(&peak)
+			if current <= max || atomic.CompareAndSwapInt32(&peak, max, current) {
+				break
+			}
+		}
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(5 * time.Millisecond)
+		return SuccessfulPutRecordsOperation(r)
+	}), "concurrent",
+		producer.WithLogger(zaptest.NewLogger(t)),
+		producer.WithMaxConcurrency(maxConcurrency),
+	)
+	require.NoError(t, err, "Must not error when creating the batcher")
+
+	bt := batch.New(batch.WithMaxRecordsPerBatch(1))
+	for i := 0; i < 20; i++ {
+		require.NoError(t, bt.AddRecord([]byte("data"), "fixed-key"))
+	}
+
+	require.NoError(t, be.Put(context.Background(), bt), "Must have written all the chunks")
+	assert.EqualValues(t, 20, atomic.LoadInt32(&calls), "Must have written every chunk")
+	assert.LessOrEqual(t, atomic.LoadInt32(&peak), int32(maxConcurrency), "Must not exceed the max concurrency")
+	assert.Greater(t, atomic.LoadInt32(&peak), int32(1), "Must have written chunks concurrently")
+
+	_, err = producer.NewBatcher(SetPutRecordsOperation(SuccessfulPutRecordsOperation), "invalid", producer.WithMaxConcurrency(0))
+	assert.Error(t, err, "Must error with an invalid max concurrency")
+}
+
+func TestMaxConcurrencyErrors(t *testing.T) {
+	t.Parallel()
+
+	bt := batch.New(batch.WithMaxRecordsPerBatch(1))
+	for _, key := range []string{"first", "second", "third"} {
+		require.NoError(t, bt.AddRecord([]byte("data"), key))
+	}
+
+	be, err := producer.NewBatcher(SetPutRecordsOperation(HardFailedPutRecordsOperation), "permanent",
+		producer.WithMaxConcurrency(3),
+	)
+	require.NoError(t, err, "Must not error when creating the batcher")
+	err = be.Put(context.Background(), bt)
+	assert.Error(t, err, "Must have returned the combined errors")
+	assert.True(t, consumererror.IsPermanent(err), "Must be permanent when every chunk permanently failed")
+
+	be, err = producer.NewBatcher(SetPutRecordsOperation(func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+		if aws.StringValue(r.Records[0].PartitionKey) == "second" {
+			return nil, errors.New("transient failure")
+		}
+		return HardFailedPutRecordsOperation(r)
+	}), "mixed",
+		producer.WithMaxConcurrency(3),
+		producer.WithBackoff(producer.BackoffSettings{
+			InitialInterval: time.Millisecond,
+			MaxInterval:     time.Millisecond,
+			MaxElapsedTime:  10 * time.Millisecond,
+			Multiplier:      1,
+		}),
+	)
+	require.NoError(t, err, "Must not error when creating the batcher")
+	err = be.Put(context.Background(), bt)
+	assert.Error(t, err, "Must have returned the combined errors")
+	assert.False(t, consumererror.IsPermanent(err), "Must be transient when any chunk transiently failed")
+}
+
+func TestPutChunks(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name    string
+		records int
+		size    int
+	}{
+		{name: "record limit", records: 1200, size: 10},
+		{name: "byte limit", records: 12, size: 900 << 10},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			bt := batch.New()
+			for i := 0; i < tc.records; i++ {
+				require.NoError(t, bt.AddRecord(bytes.Repeat([]byte("d"), tc.size), "fixed-string"))
+			}
+
+			var calls, records int
+			be, err := producer.NewBatcher(SetPutRecordsOperation(func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+				calls++
+				records += len(r.Records)
+				return SuccessfulPutRecordsOperation(r)
+			}), "chunks")
+			require.NoError(t, err, "Must not error when creating the batcher")
+			require.NoError(t, be.Put(context.Background(), bt), "Must have written every chunk")
+
+			assert.Equal(t, 3, calls, "Must have split the records across three requests")
+			assert.Equal(t, tc.records, records, "Must have written every record")
+		})
+	}
+
+	bt := batch.New(batch.WithMaxRecordsPerBatch(1))
+	for _, key := range []string{"first", "second", "third"} {
+		require.NoError(t, bt.AddRecord([]byte("data"), key))
+	}
+	var written []string
+	be, err := producer.NewBatcher(SetPutRecordsOperation(func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+		key := aws.StringValue(r.Records[0].PartitionKey)
+		if key == "first" {
+			return HardFailedPutRecordsOperation(r)
+		}
+		written = append(written, key)
+		return SuccessfulPutRecordsOperation(r)
+	}), "permanent")
+	require.NoError(t, err, "Must not error when creating the batcher")
+
+	err = be.Put(context.Background(), bt)
+	assert.True(t, consumererror.IsPermanent(err), "Must keep the permanent error of the failed chunk")
+	assert.Equal(t, []string{"second", "third"}, written, "Must have written the chunks after the permanently failed chunk")
+}
+
+func TestShutdownDrainsInFlight(t *testing.T) {
+	t.Parallel()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	be, err := producer.NewBatcher(SetPutRecordsOperation(func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+		close(started)
+		<-release
+		return SuccessfulPutRecordsOperation(r)
+	}), "draining")
+	require.NoError(t, err, "Must not error when creating the batcher")
+
+	bt := batch.New()
+	require.NoError(t, bt.AddRecord([]byte("data"), "fixed-key"))
+
+	put := make(chan error, 1)
+	go func() { put <- be.Put(context.Background(), bt) }()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- be.Shutdown(context.Background()) }()
+
+	select {
+	case <-shutdown:
+		t.Fatal("Must wait for the in-flight records before shutting down")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	assert.NoError(t, <-put, "Must have written the in-flight records")
+	assert.NoError(t, <-shutdown, "Must not error once the in-flight records are written")
+
+	err = be.Put(context.Background(), bt)
+	assert.ErrorIs(t, err, producer.ErrShutdown, "Must not accept data once shut down")
+	assert.True(t, consumererror.IsPermanent(err), "Must be permanent once shut down")
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	t.Parallel()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	be, err := producer.NewBatcher(SetPutRecordsOperation(func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+		close(started)
+		<-release
+		return SuccessfulPutRecordsOperation(r)
+	}), "timeout")
+	require.NoError(t, err, "Must not error when creating the batcher")
+
+	bt := batch.New()
+	for _, key := range []string{"first", "second"} {
+		require.NoError(t, bt.AddRecord([]byte("data"), key))
+	}
+	go func() { _ = be.Put(context.Background(), bt) }()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = be.Shutdown(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "Must error when the in-flight records are not written in time")
+	assert.Contains(t, err.Error(), "2 in-flight records", "Must report the records that were dropped")
+}
+
+func TestShutdownTimeoutDropsRecords(t *testing.T) {
+	t.Parallel()
+
+	impl, mp := metrictest.NewMeterProvider()
+	core, logs := observer.New(zap.WarnLevel)
+	client := &HangingKinesisAPI{hang: 1, started: make(chan struct{}, 1)}
+	be, err := producer.NewBatcher(client, "stuck",
+		producer.WithLogger(zap.New(core)),
+		producer.WithMeterProvider(mp),
+	)
+	require.NoError(t, err, "Must not error when creating the batcher")
+
+	bt := batch.New()
+	for _, key := range []string{"first", "second"} {
+		require.NoError(t, bt.AddRecord([]byte("data"), key))
+	}
+	put := make(chan error, 1)
+	go func() { put <- be.Put(context.Background(), bt) }()
+	<-client.started
+
+	const timeout = 20 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	err = be.Shutdown(ctx)
+	assert.Less(t, time.Since(start), 10*timeout, "Must return once the shutdown timeout has passed")
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "Must error when the in-flight records are not written in time")
+
+	select {
+	case err := <-put:
+		assert.Error(t, err, "Must fail the write that was dropped")
+	case <-time.After(time.Second):
+		t.Fatal("Must cancel the in-flight write once the shutdown timeout has passed")
+	}
+
+	dropLogs := logs.FilterMessageSnippet("dropping the in-flight records").All()
+	if assert.Len(t, dropLogs, 1, "Must log the dropped records") {
+		entry := dropLogs[0]
+		assert.Equal(t, int64(2), entry.ContextMap()["records"], "Must log the number of dropped records")
+	}
+	var dropped int64
+	for _, m := range metrictest.AsStructs(impl.MeasurementBatches) {
+		if m.Name == "exporter/awskinesis/dropped_on_shutdown" {
+			dropped += m.Number.AsInt64()
+		}
+	}
+	assert.Equal(t, int64(2), dropped, "Must count the records dropped on shutdown")
+}
+
+func TestMaxBufferedRecords(t *testing.T) {
+	t.Parallel()
+
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	be, err := producer.NewBatcher(SetPutRecordsOperation(func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+		started <- struct{}{}
+		<-release
+		return SuccessfulPutRecordsOperation(r)
+	}), "bounded",
+		producer.WithMaxBufferedRecords(2),
+	)
+	require.NoError(t, err, "Must not error when creating the batcher")
+
+	bt := batch.New()
+	for _, key := range []string{"first", "second"} {
+		require.NoError(t, bt.AddRecord([]byte("data"), key))
+	}
+	first := make(chan error, 1)
+	go func() { first <- be.Put(context.Background(), bt) }()
+	<-started
+
+	next := batch.New()
+	require.NoError(t, next.AddRecord([]byte("data"), "third"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = be.Put(ctx, next)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "Must error when the buffer stays full until the context is done")
+	assert.False(t, consumererror.IsPermanent(err), "Must be able to retry once the buffer is drained")
+
+	blocked := make(chan error, 1)
+	go func() { blocked <- be.Put(context.Background(), next) }()
+	select {
+	case <-blocked:
+		t.Fatal("Must block while the buffer is full")
+	case <-started:
+		t.Fatal("Must not write records while the buffer is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	assert.NoError(t, <-first, "Must have written the buffered records")
+	assert.NoError(t, <-blocked, "Must write the blocked records once the buffer is drained")
+}
+
+func TestInvalidMaxBufferedRecords(t *testing.T) {
+	t.Parallel()
+
+	_, err := producer.NewBatcher(SetPutRecordsOperation(SuccessfulPutRecordsOperation), "invalid", producer.WithMaxBufferedRecords(-1))
+	assert.Error(t, err, "Must error with a negative max buffered records")
+}
+
+func TestMaxInflightBytes(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu           sync.Mutex
+		active, peak int
+	)
+	started, release := make(chan struct{}, 8), make(chan struct{})
+	op := func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+		mu.Lock()
+		active++
+		if active > peak {
+			peak = active
+		}
+		mu.Unlock()
+		started <- struct{}{}
+		<-release
+		mu.Lock()
+		active--
+		mu.Unlock()
+		return SuccessfulPutRecordsOperation(r)
+	}
+
+	// Each chunk holds a single record of 1000 bytes including its partition key
+	be, err := producer.NewBatcher(SetPutRecordsOperation(op), "bounded",
+		producer.WithMaxConcurrency(4),
+		producer.WithMaxInflightBytes(2500),
+	)
+	require.NoError(t, err, "Must not error when creating the batcher")
+	bt := batch.New(batch.WithMaxRecordsPerBatch(1))
+	for i := 0; i < 4; i++ {
+		require.NoError(t, bt.AddRecord(bytes.Repeat([]byte("d"), 997), fmt.Sprint("k-", i)))
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- be.Put(context.Background(), bt) }()
+	<-started
+	<-started
+	select {
+	case <-started:
+		t.Fatal("Must not write more chunks than fit within the in-flight bytes")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	next := batch.New()
+	require.NoError(t, next.AddRecord(bytes.Repeat([]byte("d"), 997), "k-4"))
+	assert.ErrorIs(t, be.Put(ctx, next), context.DeadlineExceeded, "Must error when the bytes stay in-flight until the context is done")
+
+	close(release)
+	require.NoError(t, <-done, "Must have written every chunk")
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 2, peak, "Must have limited the concurrent writes by their bytes")
+}
+
+func TestInvalidMaxInflightBytes(t *testing.T) {
+	t.Parallel()
+
+	_, err := producer.NewBatcher(SetPutRecordsOperation(SuccessfulPutRecordsOperation), "invalid", producer.WithMaxInflightBytes(-1))
+	assert.Error(t, err, "Must error with negative max in-flight bytes")
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+
+	const (
+		limit   = 200
+		records = 400
+	)
+	var sent int64
+	be, err := producer.NewBatcher(SetPutRecordsOperation(func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+		atomic.AddInt64(&sent, int64(len(r.Records)))
+		return SuccessfulPutRecordsOperation(r)
+	}), "rate-limited",
+		producer.WithMaxConcurrency(4),
+		producer.WithRateLimit(limit, 0),
+	)
+	require.NoError(t, err, "Must not error when creating the batcher")
+
+	bt := batch.New(batch.WithMaxRecordsPerBatch(50))
+	for i := 0; i < records; i++ {
+		require.NoError(t, bt.AddRecord([]byte("data"), fmt.Sprint("key-", i)))
+	}
+
+	start := time.Now()
+	require.NoError(t, be.Put(context.Background(), bt), "Must not error when writing the burst")
+	elapsed := time.Since(start)
+
+	assert.Equal(t, int64(records), atomic.LoadInt64(&sent), "Must have written every record")
+	// The first second of records is allowed as a burst
+	assert.GreaterOrEqual(t, elapsed, time.Duration(records-limit)*time.Second/limit-50*time.Millisecond,
+		"Must not exceed the configured rate across concurrent writes")
+
+	_, err = producer.NewBatcher(SetPutRecordsOperation(SuccessfulPutRecordsOperation), "invalid", producer.WithRateLimit(-1, 0))
+	assert.Error(t, err, "Must error with a negative rate limit")
+}
+
+func TestDryRun(t *testing.T) {
+	t.Parallel()
+
+	var calls int
+	core, logs := observer.New(zap.InfoLevel)
+	be, err := producer.NewBatcher(SetPutRecordsOperation(func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+		calls++
+		return SuccessfulPutRecordsOperation(r)
+	}), "dry-run",
+		producer.WithLogger(zap.New(core)),
+		producer.WithDryRun(),
+	)
+	require.NoError(t, err, "Must not error when creating BatchedExporter")
+	// The mock panics if the stream is described
+	require.NoError(t, be.Ready(context.Background()), "Must not check the stream in dry run mode")
+
+	bt := batch.New(batch.WithMaxRecordsPerBatch(2))
+	for i := 0; i < 3; i++ {
+		require.NoError(t, bt.AddRecord([]byte("data"), "key"))
+	}
+	require.NoError(t, be.Put(context.Background(), bt), "Must report the records as written")
+	assert.Zero(t, calls, "Must not have called PutRecords")
+
+	var records, bytes int64
+	entries := logs.FilterMessage("Dry run, skipped writing records to kinesis").All()
+	require.Len(t, entries, 2, "Must have logged each chunk that would have been written")
+	for _, entry := range entries {
+		records += entry.ContextMap()["records"].(int64)
+		bytes += entry.ContextMap()["bytes"].(int64)
+	}
+	assert.EqualValues(t, 3, records, "Must have logged every record")
+	assert.EqualValues(t, 3*len("datakey"), bytes, "Must have logged the size of the records")
+}
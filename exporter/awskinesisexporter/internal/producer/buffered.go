@@ -0,0 +1,193 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+)
+
+// bufferedBatcher accumulates the records of each Put until either
+// flushRecords are pending or the flush interval has passed
+// since the oldest pending record was added.
+type bufferedBatcher struct {
+	next         Batcher
+	interval     time.Duration
+	flushRecords int
+	telemetry    *telemetry
+	log          *zap.Logger
+
+	// writeMu is held from when the pending records are taken until they are
+	// written when the wrapped Batcher keeps the order of each key, so that
+	// the taken batches are written in the order that they were taken.
+	ordered bool
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	closed  bool
+	pending *batch.Batch
+	timer   *time.Timer
+}
+
+var _ Batcher = (*bufferedBatcher)(nil)
+
+// NewBufferedBatcher wraps the Batcher so that small batches are combined
+// before they are written. Pending records are written once flushRecords are
+// pending, which returns the error to the caller along with the errors of the
+// records of earlier calls, or once the interval has passed which only logs the
+// error and counts the records that were not written as dropped, so the records
+// written after the interval are delivered at most once. The written records are
+// still chunked by the wrapped Batcher, so flushRecords may be lower than a full
+// chunk to limit the latency of each record. The dropped records are counted
+// with the attributes using the meter provider, which may be nil.
+func NewBufferedBatcher(next Batcher, interval time.Duration, flushRecords int, mp metric.MeterProvider, attrs []attribute.KeyValue, log *zap.Logger) Batcher {
+	if log == nil {
+		log = zap.NewNop()
+	}
+	if mp == nil {
+		mp = metric.NoopMeterProvider{}
+	}
+	ob, ok := next.(interface{ keepsOrder() bool })
+	return &bufferedBatcher{
+		next:         next,
+		interval:     interval,
+		flushRecords: flushRecords,
+		telemetry:    newTelemetry(mp, attrs...),
+		log:          log,
+		ordered:      ok && ob.keepsOrder(),
+	}
+}
+
+// lockWrites holds writeMu when the order of each key is kept.
+func (bb *bufferedBatcher) lockWrites() func() {
+	if !bb.ordered {
+		return func() {}
+	}
+	bb.writeMu.Lock()
+	return bb.writeMu.Unlock
+}
+
+func (bb *bufferedBatcher) Put(ctx context.Context, bt *batch.Batch) error {
+	defer bb.lockWrites()()
+	bb.mu.Lock()
+	if bb.closed {
+		bb.mu.Unlock()
+		return ErrShutdown
+	}
+	if bb.pending == nil {
+		bb.pending = bt
+		bb.timer = time.AfterFunc(bb.interval, bb.flushInterval)
+	} else if err := bb.pending.Merge(bt); err != nil {
+		// None of the records were merged, so the caller can retry the batch
+		bb.mu.Unlock()
+		return err
+	}
+	var full *batch.Batch
+	if bb.pending.Len() >= bb.flushRecords {
+		full = bb.take()
+	}
+	bb.mu.Unlock()
+
+	if full != nil {
+		return bb.next.Put(ctx, full)
+	}
+	return nil
+}
+
+// take returns the pending batch and resets the flush timer,
+// the lock must be held by the caller.
+func (bb *bufferedBatcher) take() *batch.Batch {
+	pending := bb.pending
+	bb.pending = nil
+	if bb.timer != nil {
+		bb.timer.Stop()
+		bb.timer = nil
+	}
+	return pending
+}
+
+// flushInterval writes the pending records once the interval has passed,
+// the records that failed are not retried and are counted as dropped.
+func (bb *bufferedBatcher) flushInterval() {
+	defer bb.lockWrites()()
+	bb.mu.Lock()
+	pending := bb.take()
+	bb.mu.Unlock()
+
+	if pending == nil {
+		return
+	}
+	ctx := context.Background()
+	if err := bb.next.Put(ctx, pending); err != nil {
+		dropped := unwritten(pending, err)
+		bb.telemetry.flushDropped(ctx, dropped)
+		bb.log.Error("Failed to write buffered records after the flush interval, dropping them",
+			zap.Int("records", dropped),
+			zap.Error(err),
+		)
+	}
+}
+
+// unwritten returns the number of records of the batch that failed to be written,
+// which are all of the records unless the error describes the failed records.
+func unwritten(bt *batch.Batch, err error) int {
+	failed := 0
+	for _, err := range multierr.Errors(err) {
+		var pe *PutError
+		if !errors.As(err, &pe) {
+			return bt.Len()
+		}
+		failed += len(pe.Records)
+	}
+	return failed
+}
+
+func (bb *bufferedBatcher) Ready(ctx context.Context) error {
+	return bb.next.Ready(ctx)
+}
+
+// Shutdown stops accepting new data and writes the pending records
+// before shutting down the wrapped Batcher, which is shut down even
+// when the pending records could not be written.
+func (bb *bufferedBatcher) Shutdown(ctx context.Context) error {
+	return multierr.Append(bb.drain(ctx), bb.next.Shutdown(ctx))
+}
+
+// drain stops accepting new data and writes the pending records.
+func (bb *bufferedBatcher) drain(ctx context.Context) error {
+	defer bb.lockWrites()()
+	bb.mu.Lock()
+	bb.closed = true
+	pending := bb.take()
+	bb.mu.Unlock()
+
+	if pending == nil {
+		return nil
+	}
+	if err := bb.next.Put(ctx, pending); err != nil {
+		return fmt.Errorf("failed to write %d buffered records on shutdown: %w", pending.Len(), err)
+	}
+	return nil
+}
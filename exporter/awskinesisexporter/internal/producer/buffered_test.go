@@ -0,0 +1,181 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/metric/metrictest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/producer"
+)
+
+// recordingPut returns a PutRecords operation that reports the size of each call.
+func recordingPut() (func(*kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error), <-chan int) {
+	calls := make(chan int, 100)
+	return func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+		calls <- len(r.Records)
+		return SuccessfulPutRecordsOperation(r)
+	}, calls
+}
+
+func newBufferedBatcher(t *testing.T, interval time.Duration, flushRecords int) (producer.Batcher, <-chan int) {
+	op, calls := recordingPut()
+	be, err := producer.NewBatcher(SetPutRecordsOperation(op), "buffered", producer.WithLogger(zaptest.NewLogger(t)))
+	require.NoError(t, err, "Must not error when creating the batcher")
+	return producer.NewBufferedBatcher(be, interval, flushRecords, nil, nil, zaptest.NewLogger(t)), calls
+}
+
+func singleRecord(t *testing.T) *batch.Batch {
+	bt := batch.New()
+	require.NoError(t, bt.AddRecord([]byte("data"), "fixed-key"))
+	return bt
+}
+
+func TestBufferedFlushInterval(t *testing.T) {
+	t.Parallel()
+
+	be, calls := newBufferedBatcher(t, 10*time.Millisecond, batch.MaxBatchedRecords)
+	require.NoError(t, be.Put(context.Background(), singleRecord(t)), "Must not error when buffering records")
+
+	select {
+	case n := <-calls:
+		assert.Equal(t, 1, n, "Must have written the single buffered record")
+	case <-time.After(time.Second):
+		t.Fatal("Must have written the record once the flush interval passed")
+	}
+}
+
+func TestBufferedFlushIntervalFailure(t *testing.T) {
+	t.Parallel()
+
+	be, err := producer.NewBatcher(SetPutRecordsOperation(HardFailedPutRecordsOperation), "buffered",
+		producer.WithLogger(zaptest.NewLogger(t)),
+	)
+	require.NoError(t, err, "Must not error when creating the batcher")
+	impl, mp := metrictest.NewMeterProvider()
+	core, logs := observer.New(zap.ErrorLevel)
+	bb := producer.NewBufferedBatcher(be, 10*time.Millisecond, batch.MaxBatchedRecords, mp, nil, zap.New(core))
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, bb.Put(context.Background(), singleRecord(t)), "Must not error when buffering records")
+	}
+	require.Eventually(t, func() bool {
+		return logs.FilterMessageSnippet("flush interval").Len() == 1
+	}, time.Second, time.Millisecond, "Must log the records that failed after the flush interval")
+
+	var dropped int64
+	for _, m := range metrictest.AsStructs(impl.MeasurementBatches) {
+		if m.Name == "exporter/awskinesis/dropped_on_flush" {
+			dropped += m.Number.AsInt64()
+		}
+	}
+	assert.EqualValues(t, 3, dropped, "Must count the records dropped after the flush interval")
+}
+
+func TestBufferedFullBatch(t *testing.T) {
+	t.Parallel()
+
+	be, calls := newBufferedBatcher(t, time.Hour, 3)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, be.Put(context.Background(), singleRecord(t)), "Must not error when buffering records")
+	}
+	assert.Len(t, calls, 0, "Must not write records before the batch is full")
+
+	require.NoError(t, be.Put(context.Background(), singleRecord(t)), "Must not error when writing the full batch")
+	require.Len(t, calls, 1, "Must have written the full batch")
+	assert.Equal(t, 3, <-calls, "Must have combined the buffered records")
+}
+
+func TestBufferedFlushRecordCount(t *testing.T) {
+	t.Parallel()
+
+	records := func() *batch.Batch {
+		bt := batch.New()
+		for i := 0; i < 4; i++ {
+			require.NoError(t, bt.AddRecord([]byte("data"), "fixed-key"))
+		}
+		return bt
+	}
+
+	be, calls := newBufferedBatcher(t, time.Hour, 10)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, be.Put(context.Background(), records()), "Must not error when buffering records")
+	}
+	assert.Len(t, calls, 0, "Must not write records below the flush record count")
+
+	require.NoError(t, be.Put(context.Background(), records()), "Must not error when writing the pending records")
+	require.Len(t, calls, 1, "Must have written the records at the flush record count")
+	n := <-calls
+	assert.Equal(t, 12, n, "Must have written every pending record")
+	assert.Less(t, n, batch.MaxBatchedRecords, "Must have written the records before a full batch")
+}
+
+func TestBufferedMergeFailure(t *testing.T) {
+	t.Parallel()
+
+	be, calls := newBufferedBatcher(t, time.Hour, batch.MaxBatchedRecords)
+	pending := batch.New(batch.WithAggregation(), batch.WithMaxRecordSize(100))
+	require.NoError(t, pending.AddRecord([]byte("data"), "fixed-key"))
+	require.NoError(t, be.Put(context.Background(), pending), "Must not error when buffering records")
+
+	bt := batch.New(batch.WithAggregation())
+	require.NoError(t, bt.AddRecord([]byte("data"), "other-key"))
+	require.NoError(t, bt.AddRecord(make([]byte, 200), "large-key"))
+	assert.ErrorIs(t, be.Put(context.Background(), bt), batch.ErrRecordLength, "Must error when the records exceed the pending batch limits")
+
+	require.NoError(t, be.Shutdown(context.Background()), "Must not error when writing the pending records")
+	require.Len(t, calls, 1, "Must have written the pending records on shutdown")
+	assert.Equal(t, 1, <-calls, "Must not have kept any of the records of the failed merge")
+}
+
+func TestBufferedShutdown(t *testing.T) {
+	t.Parallel()
+
+	be, calls := newBufferedBatcher(t, time.Hour, batch.MaxBatchedRecords)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(bt *batch.Batch) {
+			defer wg.Done()
+			assert.NoError(t, be.Put(context.Background(), bt), "Must not error when buffering records")
+		}(singleRecord(t))
+	}
+	wg.Wait()
+	assert.Len(t, calls, 0, "Must not write records before the flush interval")
+
+	require.NoError(t, be.Shutdown(context.Background()), "Must not error when flushing on shutdown")
+	require.Len(t, calls, 1, "Must have written the buffered records on shutdown")
+	assert.Equal(t, 4, <-calls, "Must have written every buffered record")
+}
+
+func TestBufferedPutAfterShutdown(t *testing.T) {
+	t.Parallel()
+
+	be, _ := newBufferedBatcher(t, time.Hour, batch.MaxBatchedRecords)
+	require.NoError(t, be.Shutdown(context.Background()), "Must not error when shutting down")
+	assert.ErrorIs(t, be.Put(context.Background(), singleRecord(t)), producer.ErrShutdown, "Must not buffer records once shut down")
+}
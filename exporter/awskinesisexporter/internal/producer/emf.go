@@ -0,0 +1,201 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"go.uber.org/zap"
+)
+
+// EMFSettings defines where the health of the Batcher is written
+// as CloudWatch embedded metric format logs.
+type EMFSettings struct {
+	Namespace string
+	LogGroup  string
+	LogStream string
+	// Interval is how often the metrics are written
+	Interval time.Duration
+}
+
+// emfEmitter periodically writes the records sent, failed and throttled
+// since the last write to a log stream using the embedded metric format
+// so that the metrics can be seen without the collector telemetry.
+type emfEmitter struct {
+	client   cloudwatchlogsiface.CloudWatchLogsAPI
+	settings EMFSettings
+
+	telemetry *telemetry
+	log       *zap.Logger
+
+	// last are the counts of the last successful write, a failed write
+	// carries its counts over to the next write.
+	last    healthCounts
+	created bool
+	token   *string
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newEMFEmitter(client cloudwatchlogsiface.CloudWatchLogsAPI, settings EMFSettings) (*emfEmitter, error) {
+	if client == nil {
+		return nil, errors.New("nil cloudwatch logs client trying to be assigned")
+	}
+	if settings.Namespace == "" || settings.LogGroup == "" || settings.LogStream == "" {
+		return nil, errors.New("emf namespace, log group and log stream must be set")
+	}
+	if settings.Interval <= 0 {
+		return nil, errors.New("emf interval must be positive")
+	}
+	return &emfEmitter{client: client, settings: settings}, nil
+}
+
+// start writes the metrics of the telemetry every interval until shutdown is called.
+func (e *emfEmitter) start(t *telemetry, log *zap.Logger) {
+	e.telemetry, e.log = t, log
+	e.stop, e.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(e.done)
+		ticker := time.NewTicker(e.settings.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-e.stop:
+				return
+			case now := <-ticker.C:
+				if err := e.emit(context.Background(), now); err != nil {
+					e.log.Warn("Failed to write the emf health metrics", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// shutdown stops the periodic writes and writes the metrics recorded
+// since the last write, unless the context is already done.
+func (e *emfEmitter) shutdown(ctx context.Context) {
+	close(e.stop)
+	<-e.done
+	if ctx.Err() != nil {
+		return
+	}
+	if err := e.emit(ctx, time.Now()); err != nil {
+		e.log.Warn("Failed to write the emf health metrics on shutdown", zap.Error(err))
+	}
+}
+
+func (e *emfEmitter) emit(ctx context.Context, now time.Time) error {
+	counts := e.telemetry.counts()
+	message, err := e.message(now, healthCounts{
+		recordsSent:    counts.recordsSent - e.last.recordsSent,
+		recordsFailed:  counts.recordsFailed - e.last.recordsFailed,
+		throttleEvents: counts.throttleEvents - e.last.throttleEvents,
+	})
+	if err != nil {
+		return err
+	}
+	if !e.created {
+		if err := e.createLogStream(ctx); err != nil {
+			return err
+		}
+		e.created = true
+	}
+
+	input := &cloudwatchlogs.PutLogEventsInput{
+		LogGroupName:  aws.String(e.settings.LogGroup),
+		LogStreamName: aws.String(e.settings.LogStream),
+		LogEvents: []*cloudwatchlogs.InputLogEvent{{
+			Message:   aws.String(string(message)),
+			Timestamp: aws.Int64(now.UnixNano() / int64(time.Millisecond)),
+		}},
+		SequenceToken: e.token,
+	}
+	out, err := e.client.PutLogEventsWithContext(ctx, input)
+	var invalid *cloudwatchlogs.InvalidSequenceTokenException
+	if errors.As(err, &invalid) {
+		// The token is unknown when the log stream was created by a previous run
+		input.SequenceToken = invalid.ExpectedSequenceToken
+		out, err = e.client.PutLogEventsWithContext(ctx, input)
+	}
+	if err != nil {
+		return err
+	}
+	e.token = out.NextSequenceToken
+	e.last = counts
+	return nil
+}
+
+func (e *emfEmitter) createLogStream(ctx context.Context) error {
+	_, err := e.client.CreateLogStreamWithContext(ctx, &cloudwatchlogs.CreateLogStreamInput{
+		LogGroupName:  aws.String(e.settings.LogGroup),
+		LogStreamName: aws.String(e.settings.LogStream),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == cloudwatchlogs.ErrCodeResourceAlreadyExistsException {
+		return nil
+	}
+	return err
+}
+
+type emfMetric struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit"`
+}
+
+type emfDirective struct {
+	Namespace  string      `json:"Namespace"`
+	Dimensions [][]string  `json:"Dimensions"`
+	Metrics    []emfMetric `json:"Metrics"`
+}
+
+type emfMetadata struct {
+	Timestamp         int64          `json:"Timestamp"`
+	CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+}
+
+// message returns the log event of the counts, the attributes
+// of the telemetry are used as the dimensions of the metrics.
+func (e *emfEmitter) message(now time.Time, counts healthCounts) ([]byte, error) {
+	dimensions := []string{}
+	fields := map[string]interface{}{
+		"records_sent":    counts.recordsSent,
+		"records_failed":  counts.recordsFailed,
+		"throttle_events": counts.throttleEvents,
+	}
+	for _, attr := range e.telemetry.attrs {
+		dimensions = append(dimensions, string(attr.Key))
+		fields[string(attr.Key)] = attr.Value.Emit()
+	}
+	fields["_aws"] = emfMetadata{
+		Timestamp: now.UnixNano() / int64(time.Millisecond),
+		CloudWatchMetrics: []emfDirective{{
+			Namespace:  e.settings.Namespace,
+			Dimensions: [][]string{dimensions},
+			Metrics: []emfMetric{
+				{Name: "records_sent", Unit: cloudwatchlogs.StandardUnitCount},
+				{Name: "records_failed", Unit: cloudwatchlogs.StandardUnitCount},
+				{Name: "throttle_events", Unit: cloudwatchlogs.StandardUnitCount},
+			},
+		}},
+	}
+	return json.Marshal(fields)
+}
@@ -0,0 +1,137 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/producer"
+)
+
+// MockLogsAPI records the written log events, the first write is rejected
+// with an invalid sequence token as if the log stream already existed.
+type MockLogsAPI struct {
+	cloudwatchlogsiface.CloudWatchLogsAPI
+
+	created []*cloudwatchlogs.CreateLogStreamInput
+	puts    []*cloudwatchlogs.PutLogEventsInput
+}
+
+func (m *MockLogsAPI) CreateLogStreamWithContext(_ context.Context, in *cloudwatchlogs.CreateLogStreamInput, _ ...request.Option) (*cloudwatchlogs.CreateLogStreamOutput, error) {
+	m.created = append(m.created, in)
+	return nil, awserr.New(cloudwatchlogs.ErrCodeResourceAlreadyExistsException, "log stream exists", nil)
+}
+
+func (m *MockLogsAPI) PutLogEventsWithContext(_ context.Context, in *cloudwatchlogs.PutLogEventsInput, _ ...request.Option) (*cloudwatchlogs.PutLogEventsOutput, error) {
+	if aws.StringValue(in.SequenceToken) != "expected" {
+		return nil, &cloudwatchlogs.InvalidSequenceTokenException{ExpectedSequenceToken: aws.String("expected")}
+	}
+	m.puts = append(m.puts, in)
+	return &cloudwatchlogs.PutLogEventsOutput{NextSequenceToken: aws.String("expected")}, nil
+}
+
+func TestEMFHealthMetrics(t *testing.T) {
+	t.Parallel()
+
+	logs := &MockLogsAPI{}
+	be, err := producer.NewBatcher(SetPutRecordsOperation(SuccessfulPutRecordsOperation), "emf",
+		producer.WithMeterProvider(metric.NoopMeterProvider{},
+			attribute.String("exporter", "awskinesis"),
+			attribute.String("stream", "emf"),
+		),
+		producer.WithEMF(logs, producer.EMFSettings{
+			Namespace: "test-namespace",
+			LogGroup:  "test-log-group",
+			LogStream: "test-log-stream",
+			Interval:  time.Hour,
+		}),
+	)
+	require.NoError(t, err, "Must not error when creating the batcher")
+
+	bt := batch.New()
+	for _, key := range []string{"first", "second"} {
+		require.NoError(t, bt.AddRecord([]byte("data"), key))
+	}
+	require.NoError(t, be.Put(context.Background(), bt))
+	require.NoError(t, be.Shutdown(context.Background()), "Must not error once shut down")
+
+	require.Len(t, logs.created, 1, "Must have created the log stream")
+	require.Len(t, logs.puts, 1, "Must have written the metrics on shutdown")
+	put := logs.puts[0]
+	assert.Equal(t, "test-log-group", aws.StringValue(put.LogGroupName))
+	assert.Equal(t, "test-log-stream", aws.StringValue(put.LogStreamName))
+	require.Len(t, put.LogEvents, 1, "Must have written a single event")
+
+	var event struct {
+		AWS struct {
+			Timestamp         int64 `json:"Timestamp"`
+			CloudWatchMetrics []struct {
+				Namespace  string     `json:"Namespace"`
+				Dimensions [][]string `json:"Dimensions"`
+				Metrics    []struct {
+					Name string `json:"Name"`
+					Unit string `json:"Unit"`
+				} `json:"Metrics"`
+			} `json:"CloudWatchMetrics"`
+		} `json:"_aws"`
+		Exporter       string `json:"exporter"`
+		Stream         string `json:"stream"`
+		RecordsSent    int64  `json:"records_sent"`
+		RecordsFailed  int64  `json:"records_failed"`
+		ThrottleEvents int64  `json:"throttle_events"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(aws.StringValue(put.LogEvents[0].Message)), &event), "Must be valid json")
+
+	assert.Equal(t, aws.Int64Value(put.LogEvents[0].Timestamp), event.AWS.Timestamp, "Must use the event timestamp")
+	require.Len(t, event.AWS.CloudWatchMetrics, 1, "Must have a single metric directive")
+	directive := event.AWS.CloudWatchMetrics[0]
+	assert.Equal(t, "test-namespace", directive.Namespace)
+	assert.Equal(t, [][]string{{"exporter", "stream"}}, directive.Dimensions, "Must use the telemetry attributes as dimensions")
+	var names []string
+	for _, m := range directive.Metrics {
+		names = append(names, m.Name)
+		assert.Equal(t, cloudwatchlogs.StandardUnitCount, m.Unit)
+	}
+	assert.Equal(t, []string{"records_sent", "records_failed", "throttle_events"}, names)
+
+	assert.Equal(t, "awskinesis", event.Exporter)
+	assert.Equal(t, "emf", event.Stream)
+	assert.EqualValues(t, 2, event.RecordsSent, "Must have reported the sent records")
+	assert.Zero(t, event.RecordsFailed)
+	assert.Zero(t, event.ThrottleEvents)
+}
+
+func TestInvalidEMFSettings(t *testing.T) {
+	t.Parallel()
+
+	_, err := producer.NewBatcher(SetPutRecordsOperation(SuccessfulPutRecordsOperation), "emf",
+		producer.WithEMF(&MockLogsAPI{}, producer.EMFSettings{Namespace: "test-namespace", Interval: time.Minute}),
+	)
+	assert.Error(t, err, "Must error without a log group")
+}
@@ -0,0 +1,28 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer
+
+// MaxSequenceKeys is the number of partition keys ordered by WithSequenceOrdering.
+const MaxSequenceKeys = maxSequenceKeys
+
+// Healthy reports if fewer than the unhealthy threshold of consecutive writes of the batcher permanently failed.
+func Healthy(b Batcher) bool {
+	return b.(*batcher).health.healthy()
+}
+
+// SequenceKeys returns the number of partition keys the batcher holds the last sequence number of.
+func SequenceKeys(b Batcher) int {
+	return b.(*batcher).sequences.len()
+}
@@ -0,0 +1,219 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	"github.com/aws/aws-sdk-go/service/firehose/firehoseiface"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+)
+
+// firehoseBatcher writes records to a firehose delivery stream,
+// it shares the configuration of the kinesis batcher.
+type firehoseBatcher struct {
+	*batcher
+
+	firehose firehoseiface.FirehoseAPI
+}
+
+var _ Batcher = (*firehoseBatcher)(nil)
+
+// NewFirehoseBatcher creates a Batcher that writes to the firehose delivery stream
+// using PutRecordBatch, the partition keys of the records are not used.
+// Options that require a kinesis client are not supported.
+func NewFirehoseBatcher(firehoseAPI firehoseiface.FirehoseAPI, stream string, opts ...BatcherOptions) (Batcher, error) {
+	be, err := newBatcher(nil, stream, opts...)
+	if err != nil {
+		return nil, err
+	}
+	fb := &firehoseBatcher{batcher: be, firehose: firehoseAPI}
+	if fb.deadLetter != nil {
+		return nil, errors.New("dead letter streams are not supported with firehose")
+	}
+	if fb.shardCount != nil {
+		return nil, errors.New("shard count refreshes are not supported with firehose")
+	}
+	if fb.shardRanges != nil {
+		return nil, errors.New("shard range refreshes are not supported with firehose")
+	}
+	if fb.adaptive != nil {
+		return nil, errors.New("adaptive batching is not supported with firehose")
+	}
+	fb.startEMF()
+	return fb, nil
+}
+
+func (fb *firehoseBatcher) Put(ctx context.Context, bt *batch.Batch) error {
+	start := time.Now()
+	fb.telemetry.sampled(ctx, bt.SampledOut())
+	fb.telemetry.invalid(ctx, bt.Invalid())
+	// Chunking compresses the frames of the batch compression
+	chunks := fb.chunks(bt)
+	uncompressed, compressed := bt.CompressedBytes()
+	fb.telemetry.compressed(ctx, uncompressed, compressed)
+	var dropped droppedErrors
+	err := fb.dispatch(ctx, chunks, func(ctx context.Context, c chunk) error {
+		if err := dropped.keep(fb.putRecordBatch(ctx, c)); err != nil {
+			return err
+		}
+		fb.log.Debug("Successfully wrote batch to firehose", zap.Stringp("stream", fb.stream))
+		return nil
+	})
+	fb.telemetry.put(ctx, start, err)
+	fb.observe(dropped.observed(err))
+	return err
+}
+
+func (fb *firehoseBatcher) putRecordBatch(ctx context.Context, c chunk) error {
+	entries := c.records
+	failures := recordFailures{c: c}
+	records := make([]*firehose.Record, 0, len(entries))
+	for _, entry := range entries {
+		records = append(records, &firehose.Record{Data: entry.Data})
+	}
+
+	bo := fb.backoff.newBackOff(ctx)
+	for attempt := 1; ; attempt++ {
+		var size int
+		for _, record := range records {
+			size += len(record.Data)
+		}
+		if err := fb.limiter.wait(ctx, len(records), size); err != nil {
+			return err
+		}
+		out, err := fb.tracedPutRecordBatch(ctx, records, size, attempt)
+
+		if err != nil && !isFirehoseThrottled(err) && !errors.Is(err, ErrRequestTimeout) {
+			if aerr, ok := err.(awserr.Error); ok {
+				switch code := aerr.Code(); {
+				case code == firehose.ErrCodeResourceNotFoundException, code == firehose.ErrCodeInvalidArgumentException, isPermanentCode(code):
+					err = consumererror.NewPermanent(err)
+				}
+			}
+			fb.failureLog.Error("Failed to write records to firehose", zap.Error(err))
+			fb.telemetry.failed(ctx, len(records))
+			return fb.drop(ctx, len(records), failures.wrap(err, entries))
+		}
+
+		if err == nil {
+			var codes recordErrors
+			entries, records, codes = fb.failedRecords(ctx, entries, records, out, &failures)
+			if len(records) == 0 {
+				return nil
+			}
+			err = fmt.Errorf("failed to write %d records to firehose after %d attempts: %s", len(records), attempt, codes)
+			if codes.permanent(fb.retryable(DefaultFirehoseRetryableErrorCodes)) {
+				err = failures.wrap(consumererror.NewPermanent(err), entries)
+				fb.failureLog.Error("Failed to write records to firehose",
+					zap.Error(err),
+					zap.Int("failed-records", len(records)),
+					zap.Int("attempts", attempt),
+				)
+				fb.telemetry.failed(ctx, len(records))
+				return fb.drop(ctx, len(records), err)
+			}
+		} else if isFirehoseThrottled(err) {
+			fb.telemetry.throttled(ctx, 1)
+		}
+
+		if retry, rerr := fb.wait(ctx, bo, attempt, len(records), err); !retry {
+			fb.failureLog.Error("Failed to write records to firehose",
+				zap.Error(err),
+				zap.Int("failed-records", len(records)),
+				zap.Int("attempts", attempt),
+			)
+			fb.telemetry.failed(ctx, len(records))
+			if rerr != nil {
+				return fb.drop(ctx, len(records), failures.wrap(rerr, entries))
+			}
+			return failures.wrap(err, entries)
+		}
+	}
+}
+
+// tracedPutRecordBatch makes a single PutRecordBatch call within a span
+// describing the records written and how many of them failed.
+func (fb *firehoseBatcher) tracedPutRecordBatch(ctx context.Context, records []*firehose.Record, size, attempt int) (*firehose.PutRecordBatchOutput, error) {
+	ctx, span := fb.tracer.Start(ctx, "PutRecordBatch",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(putAttributes(aws.StringValue(fb.stream), len(records), size, attempt)...),
+	)
+	defer span.End()
+
+	var out *firehose.PutRecordBatchOutput
+	err := fb.request(ctx, func(ctx context.Context) (err error) {
+		out, err = fb.firehose.PutRecordBatchWithContext(ctx, &firehose.PutRecordBatchInput{
+			DeliveryStreamName: fb.stream,
+			Records:            records,
+		})
+		return err
+	})
+	if out != nil {
+		endPut(span, err, int(aws.Int64Value(out.FailedPutCount)))
+	} else {
+		endPut(span, err, 0)
+	}
+	return out, err
+}
+
+// failedRecords returns the records, and the entries they were created from, that
+// have an error code set within their matching response entry with the counts of
+// each error code, and reports the written records.
+func (fb *firehoseBatcher) failedRecords(ctx context.Context, entries []*kinesis.PutRecordsRequestEntry, records []*firehose.Record, out *firehose.PutRecordBatchOutput, failures *recordFailures) (failedEntries []*kinesis.PutRecordsRequestEntry, failed []*firehose.Record, codes recordErrors) {
+	var sent, size, throttles int
+	codes = make(recordErrors)
+	for i, record := range records {
+		if out != nil && i < len(out.RequestResponses) && out.RequestResponses[i].ErrorCode != nil {
+			code := aws.StringValue(out.RequestResponses[i].ErrorCode)
+			if code == firehose.ErrCodeServiceUnavailableException {
+				throttles++
+			}
+			codes.add(code, aws.StringValue(out.RequestResponses[i].ErrorMessage))
+			failures.failed(entries[i], code)
+			failedEntries = append(failedEntries, entries[i])
+			failed = append(failed, record)
+			continue
+		}
+		sent++
+		size += len(record.Data)
+	}
+	fb.telemetry.sent(ctx, sent, size)
+	fb.telemetry.throttled(ctx, throttles)
+	return failedEntries, failed, codes
+}
+
+func (fb *firehoseBatcher) Ready(ctx context.Context) error {
+	_, err := fb.firehose.DescribeDeliveryStreamWithContext(ctx, &firehose.DescribeDeliveryStreamInput{
+		DeliveryStreamName: fb.stream,
+	})
+	return err
+}
+
+func isFirehoseThrottled(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == firehose.ErrCodeServiceUnavailableException
+}
@@ -0,0 +1,180 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	"github.com/aws/aws-sdk-go/service/firehose/firehoseiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/producer"
+)
+
+type MockFirehoseAPI struct {
+	firehoseiface.FirehoseAPI
+
+	op func(*firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error)
+}
+
+var _ firehoseiface.FirehoseAPI = (*MockFirehoseAPI)(nil)
+
+func (mfa *MockFirehoseAPI) PutRecordBatchWithContext(ctx context.Context, r *firehose.PutRecordBatchInput, opts ...request.Option) (*firehose.PutRecordBatchOutput, error) {
+	return mfa.op(r)
+}
+
+func SetPutRecordBatchOperation(op func(r *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error)) firehoseiface.FirehoseAPI {
+	return &MockFirehoseAPI{op: op}
+}
+
+func SuccessfulPutRecordBatchOperation(r *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+	out := &firehose.PutRecordBatchOutput{FailedPutCount: aws.Int64(0)}
+	for range r.Records {
+		out.RequestResponses = append(out.RequestResponses, &firehose.PutRecordBatchResponseEntry{RecordId: aws.String("record-id")})
+	}
+	return out, nil
+}
+
+func HardFailedPutRecordBatchOperation(r *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+	return &firehose.PutRecordBatchOutput{FailedPutCount: aws.Int64(int64(len(r.Records)))}, awserr.New(
+		firehose.ErrCodeResourceNotFoundException,
+		"testing incorrect firehose configuration",
+		errors.New("test case failure"),
+	)
+}
+
+func TransiantPutRecordBatchOperation(recoverAfter int) func(_ *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+	attempt := 0
+	return func(r *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+		if attempt < recoverAfter {
+			attempt++
+			return &firehose.PutRecordBatchOutput{FailedPutCount: aws.Int64(int64(len(r.Records)))}, awserr.New(
+				firehose.ErrCodeServiceUnavailableException,
+				"testing throttled firehose operation",
+				errors.New("test case throttled"),
+			)
+		}
+		return SuccessfulPutRecordBatchOperation(r)
+	}
+}
+
+func TestFirehoseBatcher(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name             string
+		PutRecordBatchOP func(*firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error)
+		shouldErr        bool
+		isPermanent      bool
+	}{
+		{name: "Successful put to firehose", PutRecordBatchOP: SuccessfulPutRecordBatchOperation, shouldErr: false, isPermanent: false},
+		{name: "Invalid firehose configuration", PutRecordBatchOP: HardFailedPutRecordBatchOperation, shouldErr: true, isPermanent: true},
+		{name: "Test throttled firehose operation recovers", PutRecordBatchOP: TransiantPutRecordBatchOperation(2), shouldErr: false, isPermanent: false},
+		{name: "Test throttled firehose operation", PutRecordBatchOP: TransiantPutRecordBatchOperation(100), shouldErr: true, isPermanent: false},
+	}
+
+	bt := batch.New(
+		batch.WithMaxBatchBytes(batch.MaxFirehoseBatchSize),
+	)
+	for i := 0; i < 500; i++ {
+		assert.NoError(t, bt.AddRecord([]byte("data"), "fixed-key"))
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var written int
+			be, err := producer.NewFirehoseBatcher(
+				SetPutRecordBatchOperation(func(r *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+					assert.Equal(t, tc.name, aws.StringValue(r.DeliveryStreamName), "Must write to the configured delivery stream")
+					out, err := tc.PutRecordBatchOP(r)
+					if err == nil {
+						written += len(r.Records)
+					}
+					return out, err
+				}),
+				tc.name,
+				producer.WithLogger(zaptest.NewLogger(t)),
+				producer.WithBackoff(producer.BackoffSettings{
+					InitialInterval: time.Millisecond,
+					MaxInterval:     10 * time.Millisecond,
+					MaxElapsedTime:  100 * time.Millisecond,
+					Multiplier:      2,
+				}),
+			)
+			require.NoError(t, err, "Must not error when creating the firehose batcher")
+
+			err = be.Put(context.Background(), bt)
+			if !tc.shouldErr {
+				assert.NoError(t, err, "Must not have returned an error for this test case")
+				assert.Equal(t, 500, written, "Must have written all the records")
+				return
+			}
+
+			assert.Error(t, err, "Must have returned an error for this test case")
+			assert.Equal(t, tc.isPermanent, consumererror.IsPermanent(err), "Must have classified the error")
+		})
+	}
+}
+
+func TestFirehosePartialFailureRetry(t *testing.T) {
+	t.Parallel()
+
+	var attempts [][]*firehose.Record
+	be, err := producer.NewFirehoseBatcher(SetPutRecordBatchOperation(func(r *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+		attempts = append(attempts, r.Records)
+		out, err := SuccessfulPutRecordBatchOperation(r)
+		if len(attempts) == 1 {
+			out.FailedPutCount = aws.Int64(1)
+			out.RequestResponses[1] = &firehose.PutRecordBatchResponseEntry{
+				ErrorCode: aws.String(firehose.ErrCodeServiceUnavailableException),
+			}
+		}
+		return out, err
+	}), "partial-failure",
+		producer.WithLogger(zaptest.NewLogger(t)),
+	)
+	require.NoError(t, err, "Must not error when creating the firehose batcher")
+
+	bt := batch.New()
+	for _, data := range []string{"first", "second", "third"} {
+		require.NoError(t, bt.AddRecord([]byte(data), "fixed-key"))
+	}
+
+	require.NoError(t, be.Put(context.Background(), bt), "Must have written the failed record on retry")
+	require.Len(t, attempts, 2, "Must have retried once")
+	require.Len(t, attempts[1], 1, "Must have only retried the failed record")
+	assert.Equal(t, []byte("second"), attempts[1][0].Data, "Must have retried the failed record")
+}
+
+func TestFirehoseDeadLetterUnsupported(t *testing.T) {
+	t.Parallel()
+
+	_, err := producer.NewFirehoseBatcher(SetPutRecordBatchOperation(SuccessfulPutRecordBatchOperation), "dead-letter",
+		producer.WithDeadLetter(nil, "dead-letter-stream"),
+	)
+	assert.Error(t, err, "Must error when using a dead letter stream")
+}
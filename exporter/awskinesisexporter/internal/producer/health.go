@@ -0,0 +1,96 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.uber.org/multierr"
+)
+
+// defaultUnhealthyThreshold is the number of consecutive permanently
+// failed writes after which a Batcher logs that it is unhealthy.
+const defaultUnhealthyThreshold = 3
+
+// health counts the consecutive writes of a Batcher that permanently failed,
+// such as when the stream does not exist, transient failures are not counted
+// since they are retried and a successful write resets the count.
+type health struct {
+	threshold int64
+	failures  int64
+}
+
+// observe records the result of a write and reports whether it changed the health.
+func (h *health) observe(err error) bool {
+	switch {
+	case err == nil:
+		return atomic.SwapInt64(&h.failures, 0) >= h.threshold
+	case consumererror.IsPermanent(err):
+		return atomic.AddInt64(&h.failures, 1) == h.threshold
+	}
+	return false
+}
+
+func (h *health) healthy() bool {
+	return atomic.LoadInt64(&h.failures) < h.threshold
+}
+
+// droppedError is the permanent error of records that were dropped instead of failing
+// the export, which still counts towards the health of the Batcher.
+type droppedError struct {
+	err error
+}
+
+func (de droppedError) Error() string {
+	return de.err.Error()
+}
+
+func (de droppedError) Unwrap() error {
+	return de.err
+}
+
+// droppedErrors collects the errors of the records dropped by the chunks of a Put.
+type droppedErrors struct {
+	mu   sync.Mutex
+	errs error
+}
+
+// keep returns nil in place of a droppedError and collects its error.
+func (de *droppedErrors) keep(err error) error {
+	dropped, ok := err.(droppedError)
+	if !ok {
+		return err
+	}
+	de.mu.Lock()
+	defer de.mu.Unlock()
+	de.errs = multierr.Append(de.errs, dropped.err)
+	return nil
+}
+
+// observed returns the error observed by the health for a Put that returned err,
+// a Put that only failed by dropping records is observed as a permanent failure.
+func (de *droppedErrors) observed(err error) error {
+	if err != nil {
+		return err
+	}
+	de.mu.Lock()
+	defer de.mu.Unlock()
+	if de.errs == nil {
+		return nil
+	}
+	return consumererror.NewPermanent(de.errs)
+}
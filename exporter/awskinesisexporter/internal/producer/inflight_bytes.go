@@ -0,0 +1,81 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// inflightBytes limits the bytes of the chunks that are written at once,
+// it is shared by all concurrent writes of a Batcher, a nil limit is unlimited.
+type inflightBytes struct {
+	max int
+
+	mu   sync.Mutex
+	used int
+	// released is closed and replaced whenever bytes are released
+	released chan struct{}
+}
+
+func newInflightBytes(max int) *inflightBytes {
+	return &inflightBytes{max: max, released: make(chan struct{})}
+}
+
+// acquire blocks until the bytes fit within the limit, the context error is returned
+// if it is done before then. The bytes are always acquired when none are in-flight so
+// that a chunk larger than the limit is still written. The returned func releases the bytes.
+func (ib *inflightBytes) acquire(ctx context.Context, bytes int) (func(), error) {
+	if ib == nil {
+		return func() {}, nil
+	}
+	ib.mu.Lock()
+	for ib.used > 0 && ib.used+bytes > ib.max {
+		used, released := ib.used, ib.released
+		ib.mu.Unlock()
+		select {
+		case <-released:
+			ib.mu.Lock()
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%d bytes in-flight: %w", used, ctx.Err())
+		}
+	}
+	ib.used += bytes
+	ib.mu.Unlock()
+	return func() {
+		ib.mu.Lock()
+		ib.used -= bytes
+		close(ib.released)
+		ib.released = make(chan struct{})
+		ib.mu.Unlock()
+	}, nil
+}
+
+// limit returns put limited to the in-flight bytes,
+// each chunk holds its bytes until it has been written.
+func (ib *inflightBytes) limit(put func(context.Context, chunk) error) func(context.Context, chunk) error {
+	if ib == nil {
+		return put
+	}
+	return func(ctx context.Context, c chunk) error {
+		release, err := ib.acquire(ctx, recordsSize(c.records))
+		if err != nil {
+			return err
+		}
+		defer release()
+		return put(ctx, c)
+	}
+}
@@ -25,9 +25,14 @@ type Batcher interface {
 	// Put is a blocking operation that will attempt to write the data at most once to kinesis.
 	// Any unrecoverable errors such as misconfigured client or hard limits being exceeded
 	// will result in consumeerr.Permanent being returned to allow for existing retry patterns within
-	// the project to be used.
+	// the project to be used. The records that failed to be written are described by
+	// a PutError within the returned error.
 	Put(ctx context.Context, b *batch.Batch) error
 
 	// Ready ensures that the configuration is valid and can write the configured stream.
 	Ready(ctx context.Context) error
+
+	// Shutdown writes any data that is still buffered by the Batcher and waits
+	// for the in-flight writes to complete, no data is accepted once called.
+	Shutdown(ctx context.Context) error
 }
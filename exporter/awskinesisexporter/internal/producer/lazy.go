@@ -0,0 +1,97 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+)
+
+// lazyBatcher creates the Batcher that it wraps on the first Put,
+// a Put that fails to create it returns a transient error so that
+// the Batcher is created again when the batch is retried.
+type lazyBatcher struct {
+	create func() (Batcher, error)
+
+	// mu guards next and closed so that no Batcher is
+	// created once Shutdown is called
+	mu     sync.Mutex
+	next   Batcher
+	closed bool
+}
+
+var _ Batcher = (*lazyBatcher)(nil)
+
+// NewLazyBatcher returns a Batcher that calls create on the first Put instead
+// of on start, so that failing to set up the client does not fail the start.
+// Ready does not check the stream until the Batcher is created.
+func NewLazyBatcher(create func() (Batcher, error)) Batcher {
+	return &lazyBatcher{create: create}
+}
+
+// batcher returns the wrapped Batcher, creating it when it is not created yet.
+func (lb *lazyBatcher) batcher() (Batcher, error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	if lb.closed {
+		return nil, ErrShutdown
+	}
+	if lb.next == nil {
+		next, err := lb.create()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the batcher: %w", err)
+		}
+		lb.next = next
+	}
+	return lb.next, nil
+}
+
+// created returns the wrapped Batcher, which is nil when it is not created yet.
+func (lb *lazyBatcher) created() Batcher {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.next
+}
+
+func (lb *lazyBatcher) Put(ctx context.Context, bt *batch.Batch) error {
+	next, err := lb.batcher()
+	if err != nil {
+		return err
+	}
+	return next.Put(ctx, bt)
+}
+
+func (lb *lazyBatcher) Ready(ctx context.Context) error {
+	if next := lb.created(); next != nil {
+		return next.Ready(ctx)
+	}
+	return nil
+}
+
+// Shutdown stops any Batcher from being created and shuts down
+// the wrapped Batcher when it has been created.
+func (lb *lazyBatcher) Shutdown(ctx context.Context) error {
+	lb.mu.Lock()
+	lb.closed = true
+	next := lb.next
+	lb.mu.Unlock()
+	if next == nil {
+		return nil
+	}
+	return next.Shutdown(ctx)
+}
@@ -0,0 +1,69 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/producer"
+)
+
+func TestLazyBatcher(t *testing.T) {
+	t.Parallel()
+
+	op, calls := recordingPut()
+	var created int
+	createErr := errors.New("no credentials")
+	be := producer.NewLazyBatcher(func() (producer.Batcher, error) {
+		created++
+		if created == 1 {
+			return nil, createErr
+		}
+		return producer.NewBatcher(SetPutRecordsOperation(op), "stream", producer.WithLogger(zaptest.NewLogger(t)))
+	})
+
+	require.NoError(t, be.Ready(context.Background()), "Must not check the stream before the batcher is created")
+	assert.Zero(t, created, "Must not create the batcher before the first write")
+
+	err := be.Put(context.Background(), singleRecord(t))
+	assert.ErrorIs(t, err, createErr, "Must return the error of creating the batcher")
+	assert.False(t, consumererror.IsPermanent(err), "Must retry the write when the batcher could not be created")
+
+	require.NoError(t, be.Put(context.Background(), singleRecord(t)), "Must create the batcher on the retried write")
+	require.NoError(t, be.Put(context.Background(), singleRecord(t)), "Must not error when writing again")
+	assert.Equal(t, 2, created, "Must only create the batcher until it succeeds")
+	assert.Len(t, calls, 2, "Must have written both batches")
+
+	require.NoError(t, be.Shutdown(context.Background()), "Must not error when shutting down")
+	assert.ErrorIs(t, be.Put(context.Background(), singleRecord(t)), producer.ErrShutdown, "Must not accept data once shut down")
+}
+
+func TestLazyBatcherShutdownBeforeCreated(t *testing.T) {
+	t.Parallel()
+
+	be := producer.NewLazyBatcher(func() (producer.Batcher, error) {
+		t.Fatal("Must not create the batcher once shut down")
+		return nil, nil
+	})
+	require.NoError(t, be.Shutdown(context.Background()), "Must not error when the batcher was never created")
+	assert.ErrorIs(t, be.Put(context.Background(), singleRecord(t)), producer.ErrShutdown, "Must not create the batcher once shut down")
+}
@@ -0,0 +1,116 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+)
+
+// Mirror is a Batcher that every batch is also written to,
+// such as a Batcher of the same stream within another region.
+type Mirror struct {
+	// Name identifies the mirror in the logs, such as its region.
+	Name    string
+	Batcher Batcher
+}
+
+// mirroredBatcher writes each batch to the primary Batcher
+// and then to each of the mirrors in turn once the result of the primary is final.
+type mirroredBatcher struct {
+	primary    Batcher
+	mirrors    []Mirror
+	requireAll bool
+	log        *zap.Logger
+}
+
+var _ Batcher = (*mirroredBatcher)(nil)
+
+// NewMirroredBatcher wraps the primary Batcher so that each batch is also written
+// to the mirrors. The batch is written to one Batcher at a time since the records
+// are shared between them. The mirrors are not written when the primary fails with
+// an error that is not permanent, since the export is retried and would write the
+// records to the mirrors again. Failing to write to a mirror is only logged, the records
+// that failed are counted by the telemetry of the mirror, unless requireAll is set
+// which returns the errors of the mirrors along with the error of the primary.
+func NewMirroredBatcher(primary Batcher, mirrors []Mirror, requireAll bool, log *zap.Logger) Batcher {
+	if log == nil {
+		log = zap.NewNop()
+	}
+	return &mirroredBatcher{
+		primary:    primary,
+		mirrors:    mirrors,
+		requireAll: requireAll,
+		log:        log,
+	}
+}
+
+// keepsOrder reports if the primary Batcher keeps the order of each key,
+// the mirrors are written in the same order since each batch is written in turn.
+func (mb *mirroredBatcher) keepsOrder() bool {
+	ob, ok := mb.primary.(interface{ keepsOrder() bool })
+	return ok && ob.keepsOrder()
+}
+
+func (mb *mirroredBatcher) Put(ctx context.Context, bt *batch.Batch) error {
+	errs := mb.primary.Put(ctx, bt)
+	if errs != nil && !consumererror.IsPermanent(errs) {
+		return errs
+	}
+	for _, m := range mb.mirrors {
+		err := m.Batcher.Put(ctx, bt)
+		if err == nil {
+			continue
+		}
+		if mb.requireAll {
+			errs = multierr.Append(errs, fmt.Errorf("mirror %s: %w", m.Name, err))
+			continue
+		}
+		mb.log.Warn("Failed to write the batch to the mirror", zap.String("mirror", m.Name), zap.Error(err))
+	}
+	return errs
+}
+
+func (mb *mirroredBatcher) Ready(ctx context.Context) error {
+	errs := mb.primary.Ready(ctx)
+	for _, m := range mb.mirrors {
+		err := m.Batcher.Ready(ctx)
+		if err == nil {
+			continue
+		}
+		if mb.requireAll {
+			errs = multierr.Append(errs, fmt.Errorf("mirror %s: %w", m.Name, err))
+			continue
+		}
+		mb.log.Warn("Mirror is not ready to be written", zap.String("mirror", m.Name), zap.Error(err))
+	}
+	return errs
+}
+
+func (mb *mirroredBatcher) Shutdown(ctx context.Context) error {
+	errs := mb.primary.Shutdown(ctx)
+	for _, m := range mb.mirrors {
+		if err := m.Batcher.Shutdown(ctx); err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("mirror %s: %w", m.Name, err))
+		}
+	}
+	return errs
+}
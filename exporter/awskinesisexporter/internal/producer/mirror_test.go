@@ -0,0 +1,107 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/producer"
+)
+
+func TestMirroredBatcher(t *testing.T) {
+	t.Parallel()
+
+	primaryOp, primaryCalls := recordingPut()
+	primary, err := producer.NewBatcher(SetPutRecordsOperation(primaryOp), "stream", producer.WithLogger(zaptest.NewLogger(t)))
+	require.NoError(t, err, "Must not error when creating the batcher")
+
+	mirrorOp, mirrorCalls := recordingPut()
+	mirror, err := producer.NewBatcher(SetPutRecordsOperation(mirrorOp), "stream", producer.WithLogger(zaptest.NewLogger(t)))
+	require.NoError(t, err, "Must not error when creating the batcher")
+
+	be := producer.NewMirroredBatcher(primary, []producer.Mirror{{Name: "eu-west-1", Batcher: mirror}}, false, zaptest.NewLogger(t))
+	require.NoError(t, be.Put(context.Background(), singleRecord(t)), "Must not error when writing to every region")
+
+	require.Len(t, primaryCalls, 1, "Must have written the batch to the primary region")
+	assert.Equal(t, 1, <-primaryCalls, "Must have written the record to the primary region")
+	require.Len(t, mirrorCalls, 1, "Must have written the batch to the mirror region")
+	assert.Equal(t, 1, <-mirrorCalls, "Must have written the record to the mirror region")
+}
+
+func TestMirroredBatcherPrimaryRetry(t *testing.T) {
+	t.Parallel()
+
+	newMirrored := func(op func(*kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error)) (producer.Batcher, <-chan int) {
+		primary, err := producer.NewBatcher(SetPutRecordsOperation(op), "stream", producer.WithLogger(zaptest.NewLogger(t)))
+		require.NoError(t, err, "Must not error when creating the batcher")
+		mirrorOp, mirrorCalls := recordingPut()
+		mirror, err := producer.NewBatcher(SetPutRecordsOperation(mirrorOp), "stream", producer.WithLogger(zaptest.NewLogger(t)))
+		require.NoError(t, err, "Must not error when creating the batcher")
+		return producer.NewMirroredBatcher(primary, []producer.Mirror{{Name: "eu-west-1", Batcher: mirror}}, false, zaptest.NewLogger(t)), mirrorCalls
+	}
+
+	throttled := TransiantPutRecordsOperation(100)
+	recovered := false
+	be, mirrorCalls := newMirrored(func(r *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+		if recovered {
+			return SuccessfulPutRecordsOperation(r)
+		}
+		return throttled(r)
+	})
+	err := be.Put(context.Background(), singleRecord(t))
+	require.Error(t, err, "Must return the error of the primary region")
+	assert.False(t, consumererror.IsPermanent(err), "Must allow the export to be retried")
+	assert.Len(t, mirrorCalls, 0, "Must not write to the mirror before the primary result is final")
+
+	recovered = true
+	require.NoError(t, be.Put(context.Background(), singleRecord(t)), "Must not error once the primary region recovered")
+	assert.Len(t, mirrorCalls, 1, "Must have written to the mirror only once")
+
+	be, mirrorCalls = newMirrored(HardFailedPutRecordsOperation)
+	err = be.Put(context.Background(), singleRecord(t))
+	assert.True(t, consumererror.IsPermanent(err), "Must return the permanent error of the primary region")
+	assert.Len(t, mirrorCalls, 1, "Must still write to the mirror when the primary failed permanently")
+}
+
+func TestMirroredBatcherFailures(t *testing.T) {
+	t.Parallel()
+
+	newMirrored := func(requireAll bool) producer.Batcher {
+		primary, err := producer.NewBatcher(SetPutRecordsOperation(SuccessfulPutRecordsOperation), "stream",
+			producer.WithLogger(zaptest.NewLogger(t)),
+		)
+		require.NoError(t, err, "Must not error when creating the batcher")
+		mirror, err := producer.NewBatcher(SetPutRecordsOperation(HardFailedPutRecordsOperation), "stream",
+			producer.WithLogger(zaptest.NewLogger(t)),
+		)
+		require.NoError(t, err, "Must not error when creating the batcher")
+		return producer.NewMirroredBatcher(primary, []producer.Mirror{{Name: "eu-west-1", Batcher: mirror}}, requireAll, zaptest.NewLogger(t))
+	}
+
+	assert.NoError(t, newMirrored(false).Put(context.Background(), singleRecord(t)),
+		"Must not fail the write when only the mirror failed",
+	)
+
+	err := newMirrored(true).Put(context.Background(), singleRecord(t))
+	require.Error(t, err, "Must fail the write when every region is required")
+	assert.Contains(t, err.Error(), "mirror eu-west-1", "Must describe the mirror that failed")
+}
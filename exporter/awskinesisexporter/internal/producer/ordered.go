@@ -0,0 +1,125 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// keyOrder writes the chunks that share a partition key one at a time in the
+// order that they were submitted, chunks that do not share a key of the same
+// stream are still written concurrently.
+type keyOrder struct {
+	mu     sync.Mutex
+	queues map[sequenceKey][]*ticket
+}
+
+// ticket is the place of a chunk within the queue of each of its keys,
+// ready is closed once the chunk is first within every queue.
+type ticket struct {
+	keys     []sequenceKey
+	waiting  int
+	ready    chan struct{}
+	released bool
+}
+
+func newKeyOrder() *keyOrder {
+	return &keyOrder{queues: make(map[sequenceKey][]*ticket)}
+}
+
+// enqueue places the chunk at the end of the queue of each of its keys.
+func (o *keyOrder) enqueue(c chunk) *ticket {
+	t := &ticket{ready: make(chan struct{})}
+	seen := make(map[sequenceKey]struct{})
+	for _, record := range c.records {
+		key := sequenceKey{stream: aws.StringValue(c.stream), partitionKey: aws.StringValue(record.PartitionKey)}
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			t.keys = append(t.keys, key)
+		}
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, key := range t.keys {
+		if len(o.queues[key]) > 0 {
+			t.waiting++
+		}
+		o.queues[key] = append(o.queues[key], t)
+	}
+	if t.waiting == 0 {
+		close(t.ready)
+	}
+	return t
+}
+
+// wait blocks until the chunks submitted before with the same keys have been
+// written, the ticket is released if the context is done before then.
+func (o *keyOrder) wait(ctx context.Context, t *ticket) error {
+	select {
+	case <-t.ready:
+		return nil
+	case <-ctx.Done():
+		o.release(t)
+		return ctx.Err()
+	}
+}
+
+// release removes the ticket from its queues, letting the
+// next chunk of each key be written. It is safe to call more than once.
+func (o *keyOrder) release(t *ticket) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if t.released {
+		return
+	}
+	t.released = true
+	for _, key := range t.keys {
+		queue := o.queues[key]
+		for i, queued := range queue {
+			if queued != t {
+				continue
+			}
+			queue = append(queue[:i], queue[i+1:]...)
+			if i == 0 && len(queue) > 0 {
+				next := queue[0]
+				next.waiting--
+				if next.waiting == 0 {
+					close(next.ready)
+				}
+			}
+			break
+		}
+		if len(queue) == 0 {
+			delete(o.queues, key)
+		} else {
+			o.queues[key] = queue
+		}
+	}
+}
+
+// ordered wraps put so that each chunk waits for its ticket before it is written.
+func (o *keyOrder) ordered(put func(context.Context, chunk) error) func(context.Context, chunk) error {
+	return func(ctx context.Context, c chunk) error {
+		if err := o.wait(ctx, c.ticket); err != nil {
+			return err
+		}
+		defer o.release(c.ticket)
+		return put(ctx, c)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+)
+
+// PutError describes the records of a request that could not be written,
+// it is returned by Put and can be found within the returned error using errors.As.
+// The error it wraps is still permanent when the records can not be retried.
+type PutError struct {
+	// Stream is the stream, or delivery stream, that the records were written to.
+	Stream string
+	// Records are the indexes of the failed records in order, counted across the
+	// records of every route of the batch in the order they are chunked.
+	Records []int
+	// Codes are the error codes of the failed records in the same order as Records,
+	// the code is empty for records that were held back or failed without a code.
+	Codes []string
+	// Permanent reports if retrying the records can not succeed.
+	Permanent bool
+
+	err error
+}
+
+func (e *PutError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the wrapped error for functions Is and As in standard package errors.
+func (e *PutError) Unwrap() error {
+	return e.err
+}
+
+// recordFailures keeps the error code of each failed record of a chunk
+// from the last attempt that it failed in, so that the records can be
+// described once the chunk has failed.
+type recordFailures struct {
+	c     chunk
+	codes map[*kinesis.PutRecordsRequestEntry]string
+}
+
+// failed sets the error code of a record of the chunk.
+func (rf *recordFailures) failed(record *kinesis.PutRecordsRequestEntry, code string) {
+	if rf.codes == nil {
+		rf.codes = make(map[*kinesis.PutRecordsRequestEntry]string)
+	}
+	rf.codes[record] = code
+}
+
+// wrap returns err as a PutError describing the failed records of the chunk, records
+// without an error code use the code of err when it is an aws error.
+func (rf *recordFailures) wrap(err error, records []*kinesis.PutRecordsRequestEntry) error {
+	if err == nil {
+		return nil
+	}
+	var (
+		fallback string
+		aerr     awserr.Error
+	)
+	if errors.As(err, &aerr) {
+		fallback = aerr.Code()
+	}
+	failed := make(map[*kinesis.PutRecordsRequestEntry]bool, len(records))
+	for _, record := range records {
+		failed[record] = true
+	}
+	pe := &PutError{
+		Stream:    *rf.c.stream,
+		Records:   make([]int, 0, len(records)),
+		Codes:     make([]string, 0, len(records)),
+		Permanent: consumererror.IsPermanent(err),
+		err:       err,
+	}
+	// The chunk is walked so that the records are described in order
+	// since failed records are retried ahead of held back records.
+	for i, record := range rf.c.records {
+		if !failed[record] {
+			continue
+		}
+		code, ok := rf.codes[record]
+		if !ok {
+			code = fallback
+		}
+		pe.Records = append(pe.Records, rf.c.offset+i)
+		pe.Codes = append(pe.Codes, code)
+	}
+	return pe
+}
@@ -0,0 +1,72 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer
+
+import (
+	"context"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimiter limits the records and bytes written per second using
+// token buckets that allow a burst of up to one second of writes.
+// It is shared by all concurrent writes of a Batcher, a nil limit is unlimited.
+type rateLimiter struct {
+	records *rate.Limiter
+	bytes   *rate.Limiter
+}
+
+func newRateLimiter(recordsPerSecond, bytesPerSecond int) *rateLimiter {
+	rl := &rateLimiter{}
+	if recordsPerSecond > 0 {
+		rl.records = rate.NewLimiter(rate.Limit(recordsPerSecond), recordsPerSecond)
+	}
+	if bytesPerSecond > 0 {
+		rl.bytes = rate.NewLimiter(rate.Limit(bytesPerSecond), bytesPerSecond)
+	}
+	return rl
+}
+
+// wait blocks until the records can be written within the limits,
+// the context error is returned if it is done before then.
+func (rl *rateLimiter) wait(ctx context.Context, records, bytes int) error {
+	if rl == nil {
+		return nil
+	}
+	if err := waitN(ctx, rl.records, records); err != nil {
+		return err
+	}
+	return waitN(ctx, rl.bytes, bytes)
+}
+
+// waitN takes n tokens from the limiter, waiting for at most
+// its burst at a time so that requests larger than the burst
+// are spread over multiple intervals instead of failing.
+func waitN(ctx context.Context, l *rate.Limiter, n int) error {
+	if l == nil {
+		return nil
+	}
+	for n > 0 {
+		take := n
+		if b := l.Burst(); take > b {
+			take = b
+		}
+		if err := l.WaitN(ctx, take); err != nil {
+			return err
+		}
+		n -= take
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/firehose"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+const (
+	// internalFailure is the error code set on records that failed
+	// due to an internal error of the service.
+	internalFailure = "InternalFailure"
+	// serviceUnavailable is the error code set on records that failed
+	// while the service was unable to handle them.
+	serviceUnavailable = "ServiceUnavailable"
+)
+
+// DefaultRetryableErrorCodes returns the record error codes that are retried
+// when writing to kinesis, records that fail with other codes are permanent errors.
+func DefaultRetryableErrorCodes() []string {
+	return []string{kinesis.ErrCodeProvisionedThroughputExceededException, internalFailure, serviceUnavailable}
+}
+
+// DefaultPermanentErrorCodes returns the error codes of requests that failed to be
+// authenticated or authorized, these fail permanently without being retried since
+// retrying can not succeed until the credentials or the policy have been fixed.
+func DefaultPermanentErrorCodes() []string {
+	return []string{
+		"AccessDeniedException",
+		"UnrecognizedClientException",
+		"InvalidSignatureException",
+		"IncompleteSignature",
+		"MissingAuthenticationToken",
+		kinesis.ErrCodeKMSAccessDeniedException,
+	}
+}
+
+// isPermanentCode reports if a failed request with the error code is permanent.
+func isPermanentCode(code string) bool {
+	for _, c := range DefaultPermanentErrorCodes() {
+		if code == c {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultFirehoseRetryableErrorCodes returns the record error codes that are
+// retried when writing to firehose, records that fail with other codes are permanent errors.
+func DefaultFirehoseRetryableErrorCodes() []string {
+	return []string{firehose.ErrCodeServiceUnavailableException, internalFailure, serviceUnavailable}
+}
+
+// recordError is the number of records that failed with
+// an error code and the first message given for it.
+type recordError struct {
+	count   int
+	message string
+}
+
+// recordErrors counts the error codes of the records that failed within a response.
+type recordErrors map[string]*recordError
+
+func (re recordErrors) add(code, message string) {
+	e, ok := re[code]
+	if !ok {
+		e = &recordError{message: message}
+		re[code] = e
+	}
+	e.count++
+}
+
+// permanent reports if any record failed with an error code
+// that is not one of the retryable codes.
+func (re recordErrors) permanent(retryable []string) bool {
+	for code := range re {
+		ok := false
+		for _, r := range retryable {
+			ok = ok || code == r
+		}
+		if !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// throttled reports if any record was throttled by the shard it was written to.
+func (re recordErrors) throttled() bool {
+	_, ok := re[kinesis.ErrCodeProvisionedThroughputExceededException]
+	return ok
+}
+
+// String lists the error codes in order with their count and message.
+func (re recordErrors) String() string {
+	codes := make([]string, 0, len(re))
+	for code := range re {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	parts := make([]string, 0, len(codes))
+	for _, code := range codes {
+		e := re[code]
+		part := fmt.Sprintf("%s x%d", code, e.count)
+		if e.message != "" {
+			part += fmt.Sprintf(" (%s)", e.message)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,59 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRetryBudgetExhausted is returned, wrapping the failure of the write, when
+// failed records are not retried since the retry budget of the window is used up.
+var ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+
+// retryBudget limits the retries made within each window across all the
+// writes of a Batcher, so that a sustained outage is not amplified by
+// every write retrying. A nil budget allows every retry.
+type retryBudget struct {
+	retries int
+	window  time.Duration
+
+	mu    sync.Mutex
+	start time.Time
+	used  int
+}
+
+func newRetryBudget(retries int, window time.Duration) *retryBudget {
+	return &retryBudget{retries: retries, window: window}
+}
+
+// take reports if a retry is allowed within the current window,
+// using up one retry of the budget when it is.
+func (rb *retryBudget) take() bool {
+	if rb == nil {
+		return true
+	}
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	if now := time.Now(); now.Sub(rb.start) >= rb.window {
+		rb.start, rb.used = now, 0
+	}
+	if rb.used >= rb.retries {
+		return false
+	}
+	rb.used++
+	return true
+}
@@ -0,0 +1,104 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/kinesis/kinesisiface"
+	"go.uber.org/zap"
+)
+
+// shardCountWatcher periodically describes the stream to learn its number of
+// open shards and reports the count whenever it changes, such as once the stream
+// has been resharded. The last known count is kept while the stream can not be described.
+type shardCountWatcher struct {
+	interval time.Duration
+	onChange func(shards int)
+
+	client kinesisiface.KinesisAPI
+	stream *string
+	log    *zap.Logger
+	// count is the last known number of open shards, 0 until first described
+	count int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newShardCountWatcher(interval time.Duration, onChange func(shards int)) (*shardCountWatcher, error) {
+	if interval <= 0 {
+		return nil, errors.New("shard count refresh interval must be positive")
+	}
+	if onChange == nil {
+		return nil, errors.New("nil shard count change function trying to be assigned")
+	}
+	return &shardCountWatcher{interval: interval, onChange: onChange}, nil
+}
+
+// start describes the stream straight away and then every interval until shutdown is called.
+func (w *shardCountWatcher) start(client kinesisiface.KinesisAPI, stream *string, log *zap.Logger) {
+	w.client, w.stream, w.log = client, stream, log
+	w.stop, w.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			w.refresh()
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// refresh describes the stream and calls onChange when the open shard count has changed.
+func (w *shardCountWatcher) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), w.interval)
+	defer cancel()
+	name, arn := streamParams(w.stream)
+	out, err := w.client.DescribeStreamSummaryWithContext(ctx, &kinesis.DescribeStreamSummaryInput{
+		StreamName: name,
+		StreamARN:  arn,
+	})
+	if err != nil {
+		w.log.Warn("Failed to describe the stream to refresh its shard count, keeping the last known count",
+			zap.Stringp("stream", w.stream),
+			zap.Int64("shards", atomic.LoadInt64(&w.count)),
+			zap.Error(err),
+		)
+		return
+	}
+	shards := aws.Int64Value(out.StreamDescriptionSummary.OpenShardCount)
+	if shards < 1 || atomic.SwapInt64(&w.count, shards) == shards {
+		return
+	}
+	w.log.Debug("Refreshed the shard count of the stream", zap.Stringp("stream", w.stream), zap.Int64("shards", shards))
+	w.onChange(int(shards))
+}
+
+// shutdown stops describing the stream.
+func (w *shardCountWatcher) shutdown() {
+	close(w.stop)
+	<-w.done
+}
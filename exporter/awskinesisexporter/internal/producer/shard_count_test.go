@@ -0,0 +1,126 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/kinesis/kinesisiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/model/pdata"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/producer"
+)
+
+// MockShardCountAPI reports the open shard counts in order, a count of 0
+// fails the describe and the last count is repeated once they are used up.
+type MockShardCountAPI struct {
+	kinesisiface.KinesisAPI
+
+	mu        sync.Mutex
+	counts    []int64
+	describes int
+}
+
+func (m *MockShardCountAPI) DescribeStreamSummaryWithContext(_ context.Context, _ *kinesis.DescribeStreamSummaryInput, _ ...request.Option) (*kinesis.DescribeStreamSummaryOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.describes++
+	count := m.counts[0]
+	if len(m.counts) > 1 {
+		m.counts = m.counts[1:]
+	}
+	if count == 0 {
+		return nil, errors.New("testing failed describe")
+	}
+	return &kinesis.DescribeStreamSummaryOutput{
+		StreamDescriptionSummary: &kinesis.StreamDescriptionSummary{
+			StreamStatus:   aws.String(kinesis.StreamStatusActive),
+			OpenShardCount: aws.Int64(count),
+		},
+	}, nil
+}
+
+func TestShardCountRefresh(t *testing.T) {
+	t.Parallel()
+
+	api := &MockShardCountAPI{counts: []int64{2, 0, 2, 4}}
+	partitioner := batch.NewRoundRobinPartitioner(1)
+	changes := make(chan int, 4)
+
+	be, err := producer.NewBatcher(api, "resharded",
+		producer.WithLogger(zaptest.NewLogger(t)),
+		producer.WithShardCountRefresh(5*time.Millisecond, func(shards int) {
+			partitioner.Resize(shards)
+			changes <- shards
+		}),
+	)
+	require.NoError(t, err, "Must not error when creating the batcher")
+
+	keys := func() map[string]bool {
+		seen := make(map[string]bool)
+		for i := 0; i < 8; i++ {
+			seen[partitioner.Partition(pdata.NewResource())] = true
+		}
+		return seen
+	}
+	next := func() int {
+		select {
+		case shards := <-changes:
+			return shards
+		case <-time.After(time.Second):
+			require.FailNow(t, "Must have refreshed the shard count")
+			return 0
+		}
+	}
+
+	assert.Equal(t, 2, next(), "Must have reported the shard count once first described")
+	assert.Equal(t, map[string]bool{"0": true, "1": true}, keys(), "Must have sized the keys to the shards")
+
+	assert.Equal(t, 4, next(), "Must have reported the resharded count without reporting the failed describe")
+	assert.Equal(t, map[string]bool{"0": true, "1": true, "2": true, "3": true}, keys(), "Must have resized the keys once resharded")
+
+	require.NoError(t, be.Shutdown(context.Background()), "Must not error when shutting down")
+	api.mu.Lock()
+	describes := api.describes
+	api.mu.Unlock()
+	assert.GreaterOrEqual(t, describes, 4, "Must have described the stream every interval")
+
+	time.Sleep(20 * time.Millisecond)
+	api.mu.Lock()
+	assert.Equal(t, describes, api.describes, "Must have stopped describing the stream once shut down")
+	api.mu.Unlock()
+	assert.Empty(t, changes, "Must only report the shard count when it changes")
+}
+
+func TestInvalidShardCountRefresh(t *testing.T) {
+	t.Parallel()
+
+	_, err := producer.NewBatcher(&MockShardCountAPI{}, "invalid", producer.WithShardCountRefresh(0, func(int) {}))
+	assert.Error(t, err, "Must error with a non positive refresh interval")
+
+	_, err = producer.NewBatcher(&MockShardCountAPI{}, "invalid", producer.WithShardCountRefresh(time.Second, nil))
+	assert.Error(t, err, "Must error without a change function")
+}
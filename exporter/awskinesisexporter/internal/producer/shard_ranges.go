@@ -0,0 +1,164 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/kinesis/kinesisiface"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+)
+
+// shardRangeWatcher periodically lists the shards of the stream to learn the hash key
+// ranges of its open shards and reports the ranges whenever they change, such as once
+// the stream has been resharded. The last known ranges are kept while the shards can not be listed.
+type shardRangeWatcher struct {
+	interval time.Duration
+	onChange func(ranges []batch.HashKeyRange)
+
+	client kinesisiface.KinesisAPI
+	stream *string
+	log    *zap.Logger
+	// ranges identifies the last known ranges, empty until first listed,
+	// it is only used by the refresh goroutine.
+	ranges string
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newShardRangeWatcher(interval time.Duration, onChange func(ranges []batch.HashKeyRange)) (*shardRangeWatcher, error) {
+	if interval <= 0 {
+		return nil, errors.New("shard range refresh interval must be positive")
+	}
+	if onChange == nil {
+		return nil, errors.New("nil shard range change function trying to be assigned")
+	}
+	return &shardRangeWatcher{interval: interval, onChange: onChange}, nil
+}
+
+// start lists the shards straight away and then every interval until shutdown is called.
+func (w *shardRangeWatcher) start(client kinesisiface.KinesisAPI, stream *string, log *zap.Logger) {
+	w.client, w.stream, w.log = client, stream, log
+	w.stop, w.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			w.refresh()
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// refresh lists the shards and calls onChange when the ranges of the open shards have changed.
+func (w *shardRangeWatcher) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), w.interval)
+	defer cancel()
+	ranges, err := w.list(ctx)
+	if err != nil {
+		w.log.Warn("Failed to list the shards of the stream to refresh their hash key ranges, keeping the last known ranges",
+			zap.Stringp("stream", w.stream),
+			zap.Error(err),
+		)
+		return
+	}
+	if len(ranges) == 0 {
+		return
+	}
+	id := rangesID(ranges)
+	if id == w.ranges {
+		return
+	}
+	w.ranges = id
+	w.log.Debug("Refreshed the hash key ranges of the stream", zap.Stringp("stream", w.stream), zap.Int("shards", len(ranges)))
+	w.onChange(ranges)
+}
+
+// list returns the hash key ranges of the open shards, closed shards
+// are those that have an ending sequence number.
+func (w *shardRangeWatcher) list(ctx context.Context) ([]batch.HashKeyRange, error) {
+	var ranges []batch.HashKeyRange
+	name, arn := streamParams(w.stream)
+	in := &kinesis.ListShardsInput{StreamName: name, StreamARN: arn}
+	for {
+		out, err := w.client.ListShardsWithContext(ctx, in)
+		if err != nil {
+			return nil, err
+		}
+		for _, shard := range out.Shards {
+			if shard.SequenceNumberRange != nil && shard.SequenceNumberRange.EndingSequenceNumber != nil {
+				continue
+			}
+			r, err := hashKeyRange(shard)
+			if err != nil {
+				return nil, err
+			}
+			ranges = append(ranges, r)
+		}
+		if out.NextToken == nil {
+			return ranges, nil
+		}
+		// The stream name can not be set along with the token of the next page,
+		// the ARN is still set so that the request is authorized for the stream.
+		in = &kinesis.ListShardsInput{NextToken: out.NextToken, StreamARN: arn}
+	}
+}
+
+func hashKeyRange(shard *kinesis.Shard) (batch.HashKeyRange, error) {
+	if shard.HashKeyRange == nil {
+		return batch.HashKeyRange{}, fmt.Errorf("shard %q has no hash key range", aws.StringValue(shard.ShardId))
+	}
+	start, ok := new(big.Int).SetString(aws.StringValue(shard.HashKeyRange.StartingHashKey), 10)
+	if !ok {
+		return batch.HashKeyRange{}, fmt.Errorf("shard %q has an invalid starting hash key", aws.StringValue(shard.ShardId))
+	}
+	end, ok := new(big.Int).SetString(aws.StringValue(shard.HashKeyRange.EndingHashKey), 10)
+	if !ok || end.Cmp(start) < 0 {
+		return batch.HashKeyRange{}, fmt.Errorf("shard %q has an invalid ending hash key", aws.StringValue(shard.ShardId))
+	}
+	return batch.HashKeyRange{Start: start, End: end}, nil
+}
+
+// rangesID returns the ranges as a string, ignoring their order, to compare them with the last known ranges.
+func rangesID(ranges []batch.HashKeyRange) string {
+	ids := make([]string, len(ranges))
+	for i, r := range ranges {
+		ids[i] = r.Start.String() + "-" + r.End.String()
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
+
+// shutdown stops listing the shards.
+func (w *shardRangeWatcher) shutdown() {
+	close(w.stop)
+	<-w.done
+}
@@ -0,0 +1,176 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/kinesis/kinesisiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/producer"
+)
+
+// MockListShardsAPI lists the shards in order, returning each shard as its own
+// page, nil shards fail the list and the last shards are repeated once used up.
+type MockListShardsAPI struct {
+	kinesisiface.KinesisAPI
+
+	mu     sync.Mutex
+	shards [][]*kinesis.Shard
+	lists  int
+}
+
+func (m *MockListShardsAPI) ListShardsWithContext(_ context.Context, in *kinesis.ListShardsInput, _ ...request.Option) (*kinesis.ListShardsOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	page := 0
+	if in.NextToken != nil {
+		if in.StreamName != nil {
+			return nil, errors.New("testing stream name with next token")
+		}
+		page, _ = strconv.Atoi(aws.StringValue(in.NextToken))
+	} else {
+		m.lists++
+		if len(m.shards) > 1 && m.lists > 1 {
+			m.shards = m.shards[1:]
+		}
+	}
+	shards := m.shards[0]
+	if shards == nil {
+		return nil, errors.New("testing failed list")
+	}
+	out := &kinesis.ListShardsOutput{Shards: shards[page : page+1]}
+	if page+1 < len(shards) {
+		out.NextToken = aws.String(fmt.Sprint(page + 1))
+	}
+	return out, nil
+}
+
+// openShard returns a shard covering the fraction a/b of
+// the hash key space starting from the fraction s/b.
+func openShard(s, a, b int64) *kinesis.Shard {
+	space := new(big.Int).Lsh(big.NewInt(1), 128)
+	start := new(big.Int).Div(new(big.Int).Mul(space, big.NewInt(s)), big.NewInt(b))
+	end := new(big.Int).Div(new(big.Int).Mul(space, big.NewInt(s+a)), big.NewInt(b))
+	end.Sub(end, big.NewInt(1))
+	return &kinesis.Shard{
+		ShardId: aws.String(fmt.Sprintf("shardId-%d-%d", s, a)),
+		HashKeyRange: &kinesis.HashKeyRange{
+			StartingHashKey: aws.String(start.String()),
+			EndingHashKey:   aws.String(end.String()),
+		},
+		SequenceNumberRange: &kinesis.SequenceNumberRange{StartingSequenceNumber: aws.String("1")},
+	}
+}
+
+func closedShard(s, a, b int64) *kinesis.Shard {
+	shard := openShard(s, a, b)
+	shard.SequenceNumberRange.EndingSequenceNumber = aws.String("2")
+	return shard
+}
+
+func TestShardRangeRefresh(t *testing.T) {
+	t.Parallel()
+
+	// Uneven ranges, followed by a failed list and splitting the widest shard
+	api := &MockListShardsAPI{shards: [][]*kinesis.Shard{
+		{openShard(0, 4, 8), openShard(4, 2, 8), openShard(6, 1, 8), openShard(7, 1, 8)},
+		nil,
+		{openShard(0, 4, 8), openShard(4, 2, 8), openShard(6, 1, 8), openShard(7, 1, 8)},
+		{closedShard(0, 4, 8), openShard(0, 2, 8), openShard(2, 2, 8), openShard(4, 2, 8), openShard(6, 1, 8), openShard(7, 1, 8)},
+	}}
+	changes := make(chan []batch.HashKeyRange, 4)
+
+	be, err := producer.NewBatcher(api, "resharded",
+		producer.WithLogger(zaptest.NewLogger(t)),
+		producer.WithShardRangeRefresh(5*time.Millisecond, func(ranges []batch.HashKeyRange) {
+			changes <- ranges
+		}),
+	)
+	require.NoError(t, err, "Must not error when creating the batcher")
+
+	next := func() []batch.HashKeyRange {
+		select {
+		case ranges := <-changes:
+			return ranges
+		case <-time.After(time.Second):
+			require.FailNow(t, "Must have refreshed the shard ranges")
+			return nil
+		}
+	}
+	distribution := func(ranges []batch.HashKeyRange) []int {
+		hasher := batch.NewShardHasher()
+		hasher.SetRanges(ranges)
+		counts := make([]int, len(ranges))
+		for i := 0; i < 8000; i++ {
+			v, ok := new(big.Int).SetString(hasher.HashKey(fmt.Sprintf("key-%d", i)), 10)
+			require.True(t, ok, "Must be a decimal hash key")
+			for j, r := range ranges {
+				if v.Cmp(r.Start) >= 0 && v.Cmp(r.End) <= 0 {
+					counts[j]++
+				}
+			}
+		}
+		return counts
+	}
+
+	ranges := next()
+	require.Len(t, ranges, 4, "Must have listed every page of shards")
+	for i, n := range distribution(ranges) {
+		assert.InDelta(t, 2000, n, 200, "Must have spread the keys evenly across the shard ranges, including shard %d", i)
+	}
+
+	ranges = next()
+	require.Len(t, ranges, 5, "Must have reported the open shards once resharded without reporting the failed list")
+	for i, n := range distribution(ranges) {
+		assert.InDelta(t, 1600, n, 160, "Must have spread the keys evenly across the resharded ranges, including shard %d", i)
+	}
+
+	require.NoError(t, be.Shutdown(context.Background()), "Must not error when shutting down")
+	api.mu.Lock()
+	lists := api.lists
+	api.mu.Unlock()
+	assert.GreaterOrEqual(t, lists, 4, "Must have listed the shards every interval")
+
+	time.Sleep(20 * time.Millisecond)
+	api.mu.Lock()
+	assert.Equal(t, lists, api.lists, "Must have stopped listing the shards once shut down")
+	api.mu.Unlock()
+	assert.Empty(t, changes, "Must only report the shard ranges when they change")
+}
+
+func TestInvalidShardRangeRefresh(t *testing.T) {
+	t.Parallel()
+
+	_, err := producer.NewBatcher(&MockListShardsAPI{}, "invalid", producer.WithShardRangeRefresh(0, func([]batch.HashKeyRange) {}))
+	assert.Error(t, err, "Must error with a non positive refresh interval")
+
+	_, err = producer.NewBatcher(&MockListShardsAPI{}, "invalid", producer.WithShardRangeRefresh(time.Second, nil))
+	assert.Error(t, err, "Must error without a change function")
+}
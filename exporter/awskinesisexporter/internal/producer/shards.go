@@ -0,0 +1,165 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer
+
+import (
+	"math"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+const (
+	// maxTrackedKeys bounds the number of keys that are mapped to a shard,
+	// the mapping is reset once exceeded.
+	maxTrackedKeys = 10000
+	// hotShardScore is the throttle score that a shard is considered hot at.
+	hotShardScore = 0.5
+	// shardScoreHalfLife is the time it takes the throttle score of a shard to halve.
+	shardScoreHalfLife = 250 * time.Millisecond
+)
+
+// throttledShard matches the shard id within the error message of a throttled record.
+var throttledShard = regexp.MustCompile(`shardId-[0-9]+`)
+
+// shardScore is the throttle score of a shard as of a point in time.
+type shardScore struct {
+	value float64
+	at    time.Time
+}
+
+// shardTracker learns which shard each key is written to from the
+// PutRecords results and tracks how often each shard is throttled,
+// so that records of a hot shard can be held back without delaying
+// records written to other shards.
+type shardTracker struct {
+	mu     sync.Mutex
+	now    func() time.Time
+	keys   map[string]string
+	scores map[string]shardScore
+}
+
+func newShardTracker() *shardTracker {
+	return &shardTracker{
+		now:    time.Now,
+		keys:   make(map[string]string),
+		scores: make(map[string]shardScore),
+	}
+}
+
+// routingKey returns the key kinesis uses to select the shard of a record.
+func routingKey(record *kinesis.PutRecordsRequestEntry) string {
+	if record.ExplicitHashKey != nil {
+		return "hash:" + aws.StringValue(record.ExplicitHashKey)
+	}
+	return aws.StringValue(record.PartitionKey)
+}
+
+// observe records the shard of each written record and increases
+// the score of each shard that throttled a record within the response.
+func (st *shardTracker) observe(records []*kinesis.PutRecordsRequestEntry, out *kinesis.PutRecordsOutput) {
+	if out == nil {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	throttled := make(map[string]struct{})
+	for i, record := range records {
+		if i >= len(out.Records) {
+			break
+		}
+		result, key := out.Records[i], routingKey(record)
+		if result.ShardId != nil {
+			st.track(key, aws.StringValue(result.ShardId))
+			continue
+		}
+		if aws.StringValue(result.ErrorCode) != kinesis.ErrCodeProvisionedThroughputExceededException {
+			continue
+		}
+		shard := throttledShard.FindString(aws.StringValue(result.ErrorMessage))
+		if shard == "" {
+			shard = st.keys[key]
+		}
+		if shard == "" {
+			continue
+		}
+		st.track(key, shard)
+		throttled[shard] = struct{}{}
+	}
+
+	now := st.now()
+	for shard := range throttled {
+		st.scores[shard] = shardScore{value: st.score(shard, now) + 1, at: now}
+	}
+}
+
+// split returns the records that are not written to a hot shard and
+// the records that are held back. If every record would be held back,
+// all records are returned with the time until their shards cool down.
+func (st *shardTracker) split(records []*kinesis.PutRecordsRequestEntry) (send, held []*kinesis.PutRecordsRequestEntry, cooldown time.Duration) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if len(st.scores) == 0 {
+		return records, nil, 0
+	}
+	now := st.now()
+	var hottest float64
+	for _, record := range records {
+		shard, ok := st.keys[routingKey(record)]
+		if !ok {
+			send = append(send, record)
+			continue
+		}
+		score := st.score(shard, now)
+		if score < hotShardScore {
+			send = append(send, record)
+			continue
+		}
+		hottest = math.Max(hottest, score)
+		held = append(held, record)
+	}
+	if len(send) == 0 {
+		return records, nil, time.Duration(float64(shardScoreHalfLife) * math.Log2(hottest/hotShardScore))
+	}
+	return send, held, 0
+}
+
+// track maps the key to the shard, the lock must be held by the caller.
+func (st *shardTracker) track(key, shard string) {
+	if _, ok := st.keys[key]; !ok && len(st.keys) >= maxTrackedKeys {
+		st.keys = make(map[string]string)
+	}
+	st.keys[key] = shard
+}
+
+// score returns the decayed score of the shard, cooled down shards
+// are no longer tracked. The lock must be held by the caller.
+func (st *shardTracker) score(shard string, now time.Time) float64 {
+	s, ok := st.scores[shard]
+	if !ok {
+		return 0
+	}
+	value := s.value * math.Exp2(-float64(now.Sub(s.at))/float64(shardScoreHalfLife))
+	if value < hotShardScore/8 {
+		delete(st.scores, shard)
+		return 0
+	}
+	return value
+}
@@ -0,0 +1,194 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/kinesis/kinesisiface"
+)
+
+// putEachRecord writes the records one at a time using PutRecord and reports
+// the results as a PutRecords response so that failures are handled the same way.
+// Errors that PutRecords reports per record are set on the matching result entry,
+// any other error stops the write and is returned like a failed PutRecords call
+// along with the results so far, where the record that failed and the records
+// after it are set as failed with the code of the error.
+// When sequences is set, each record is ordered after the last record written
+// with the same partition key.
+func putEachRecord(ctx context.Context, client kinesisiface.KinesisAPI, stream *string, records []*kinesis.PutRecordsRequestEntry, seqs *sequences) (*kinesis.PutRecordsOutput, error) {
+	out := &kinesis.PutRecordsOutput{
+		FailedRecordCount: aws.Int64(0),
+		Records:           make([]*kinesis.PutRecordsResultEntry, 0, len(records)),
+	}
+	name, arn := streamParams(stream)
+	for i, record := range records {
+		in := &kinesis.PutRecordInput{
+			StreamName:      name,
+			StreamARN:       arn,
+			Data:            record.Data,
+			PartitionKey:    record.PartitionKey,
+			ExplicitHashKey: record.ExplicitHashKey,
+		}
+		res, err := seqs.put(ctx, client, in)
+		if aerr, ok := err.(awserr.Error); ok && isRecordError(aerr.Code()) {
+			*out.FailedRecordCount++
+			out.Records = append(out.Records, &kinesis.PutRecordsResultEntry{
+				ErrorCode:    aws.String(aerr.Code()),
+				ErrorMessage: aws.String(aerr.Message()),
+			})
+			continue
+		}
+		if err != nil {
+			code, message := request.ErrCodeRequestError, err.Error()
+			if aerr, ok := err.(awserr.Error); ok {
+				code, message = aerr.Code(), aerr.Message()
+			}
+			for range records[i:] {
+				*out.FailedRecordCount++
+				out.Records = append(out.Records, &kinesis.PutRecordsResultEntry{
+					ErrorCode:    aws.String(code),
+					ErrorMessage: aws.String(message),
+				})
+			}
+			return out, err
+		}
+		out.Records = append(out.Records, &kinesis.PutRecordsResultEntry{
+			ShardId:        res.ShardId,
+			SequenceNumber: res.SequenceNumber,
+		})
+	}
+	return out, nil
+}
+
+// isRecordError reports if the error code is one that PutRecords
+// reports for a single record rather than for the whole request.
+func isRecordError(code string) bool {
+	return code == kinesis.ErrCodeProvisionedThroughputExceededException || code == internalFailure
+}
+
+// maxSequenceKeys bounds the partition keys that sequences holds the last
+// sequence number of, since most partitioners create a key for each record.
+const maxSequenceKeys = 10000
+
+// sequences holds the sequence number of the last record written with each
+// partition key of a stream, which is set as the SequenceNumberForOrdering of
+// the next record with the same key so that kinesis keeps them in order.
+// The records of a key are written one at a time. A nil sequences writes
+// the records without ordering them.
+// Once more than max keys are held, the least recently written keys without
+// a record being written are forgotten, the next record of a forgotten key
+// is written after the previous record completed so it is still ordered after it.
+type sequences struct {
+	mu  sync.Mutex
+	max int
+	// keys are the elements of recent, which is ordered from
+	// the most to the least recently written key.
+	keys   map[sequenceKey]*list.Element
+	recent *list.List
+}
+
+type sequenceKey struct {
+	stream, partitionKey string
+}
+
+// sequence is the last sequence number of a key, mu is held while
+// a record of the key is being written. writers counts the records
+// of the key that are written or waiting to be, guarded by the mu of sequences.
+type sequence struct {
+	key     sequenceKey
+	writers int
+
+	mu     sync.Mutex
+	number *string
+}
+
+func newSequences(max int) *sequences {
+	return &sequences{
+		max:    max,
+		keys:   make(map[sequenceKey]*list.Element),
+		recent: list.New(),
+	}
+}
+
+func (s *sequences) put(ctx context.Context, client kinesisiface.KinesisAPI, in *kinesis.PutRecordInput) (*kinesis.PutRecordOutput, error) {
+	if s == nil {
+		return client.PutRecordWithContext(ctx, in)
+	}
+	stream := in.StreamName
+	if stream == nil {
+		stream = in.StreamARN
+	}
+	seq := s.acquire(sequenceKey{stream: aws.StringValue(stream), partitionKey: aws.StringValue(in.PartitionKey)})
+	defer s.release(seq)
+
+	seq.mu.Lock()
+	defer seq.mu.Unlock()
+	in.SequenceNumberForOrdering = seq.number
+	out, err := client.PutRecordWithContext(ctx, in)
+	if err == nil {
+		seq.number = out.SequenceNumber
+	}
+	return out, err
+}
+
+// acquire returns the sequence of the key, which is not forgotten until released.
+func (s *sequences) acquire(key sequenceKey) *sequence {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elem, ok := s.keys[key]
+	if ok {
+		s.recent.MoveToFront(elem)
+	} else {
+		elem = s.recent.PushFront(&sequence{key: key})
+		s.keys[key] = elem
+		s.forget()
+	}
+	seq := elem.Value.(*sequence)
+	seq.writers++
+	return seq
+}
+
+func (s *sequences) release(seq *sequence) {
+	s.mu.Lock()
+	seq.writers--
+	s.mu.Unlock()
+}
+
+// forget removes the least recently written keys without any writers
+// until at most max keys are held.
+func (s *sequences) forget() {
+	for elem := s.recent.Back(); elem != nil && len(s.keys) > s.max; {
+		prev := elem.Prev()
+		if seq := elem.Value.(*sequence); seq.writers == 0 {
+			s.recent.Remove(elem)
+			delete(s.keys, seq.key)
+		}
+		elem = prev
+	}
+}
+
+// len returns the number of keys held.
+func (s *sequences) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.keys)
+}
@@ -0,0 +1,245 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/kinesis/kinesisiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/batch"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/producer"
+)
+
+// MockPutRecordAPI only implements PutRecord so that
+// any PutRecords call made by the batcher panics.
+type MockPutRecordAPI struct {
+	kinesisiface.KinesisAPI
+
+	op    func(*kinesis.PutRecordInput) (*kinesis.PutRecordOutput, error)
+	calls []string
+}
+
+func (m *MockPutRecordAPI) PutRecordWithContext(_ context.Context, in *kinesis.PutRecordInput, _ ...request.Option) (*kinesis.PutRecordOutput, error) {
+	m.calls = append(m.calls, string(in.Data))
+	return m.op(in)
+}
+
+func SuccessfulPutRecordOperation(_ *kinesis.PutRecordInput) (*kinesis.PutRecordOutput, error) {
+	return &kinesis.PutRecordOutput{ShardId: aws.String("shardId-000000000000"), SequenceNumber: aws.String("1")}, nil
+}
+
+func newSingleRecordBatch(t *testing.T, records int) *batch.Batch {
+	bt := batch.New()
+	for i := 0; i < records; i++ {
+		require.NoError(t, bt.AddRecord([]byte(fmt.Sprintf("record-%d", i)), "key"))
+	}
+	return bt
+}
+
+func TestSingleRecordMode(t *testing.T) {
+	t.Parallel()
+
+	mock := &MockPutRecordAPI{op: SuccessfulPutRecordOperation}
+	be, err := producer.NewBatcher(mock, "stream",
+		producer.WithLogger(zaptest.NewLogger(t)),
+		producer.WithSingleRecordMode(),
+	)
+	require.NoError(t, err, "Must not error when creating the batcher")
+
+	require.NoError(t, be.Put(context.Background(), newSingleRecordBatch(t, 5)), "Must have written every record")
+	assert.Equal(t, []string{"record-0", "record-1", "record-2", "record-3", "record-4"}, mock.calls,
+		"Must have made one PutRecord call per record")
+}
+
+func TestSingleRecordModeRetriesThrottledRecords(t *testing.T) {
+	t.Parallel()
+
+	throttled := false
+	mock := &MockPutRecordAPI{op: func(in *kinesis.PutRecordInput) (*kinesis.PutRecordOutput, error) {
+		if string(in.Data) == "record-1" && !throttled {
+			throttled = true
+			return nil, awserr.New(kinesis.ErrCodeProvisionedThroughputExceededException, "rate exceeded for shard", nil)
+		}
+		return SuccessfulPutRecordOperation(in)
+	}}
+	be, err := producer.NewBatcher(mock, "stream",
+		producer.WithLogger(zaptest.NewLogger(t)),
+		producer.WithSingleRecordMode(),
+		producer.WithBackoff(producer.BackoffSettings{InitialInterval: time.Millisecond, MaxInterval: time.Millisecond, MaxElapsedTime: time.Second, Multiplier: 1}),
+	)
+	require.NoError(t, err, "Must not error when creating the batcher")
+
+	require.NoError(t, be.Put(context.Background(), newSingleRecordBatch(t, 3)), "Must have retried the throttled record")
+	assert.Equal(t, []string{"record-0", "record-1", "record-2", "record-1"}, mock.calls,
+		"Must have only retried the throttled record")
+}
+
+func TestSingleRecordModeErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name      string
+		err       error
+		permanent bool
+	}{
+		{
+			name:      "stream not found",
+			err:       awserr.New(kinesis.ErrCodeResourceNotFoundException, "stream not found", nil),
+			permanent: true,
+		},
+		{
+			name:      "rejected record",
+			err:       awserr.New(kinesis.ErrCodeInvalidArgumentException, "invalid record", nil),
+			permanent: true,
+		},
+		{
+			name: "request failed",
+			err:  awserr.New(kinesis.ErrCodeKMSThrottlingException, "kms throttled", nil),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock := &MockPutRecordAPI{op: func(_ *kinesis.PutRecordInput) (*kinesis.PutRecordOutput, error) {
+				return nil, tc.err
+			}}
+			be, err := producer.NewBatcher(mock, "stream",
+				producer.WithLogger(zaptest.NewLogger(t)),
+				producer.WithSingleRecordMode(),
+				producer.WithMaxAttempts(1),
+			)
+			require.NoError(t, err, "Must not error when creating the batcher")
+
+			err = be.Put(context.Background(), newSingleRecordBatch(t, 3))
+			require.Error(t, err, "Must error when the record can not be written")
+			assert.Equal(t, tc.permanent, consumererror.IsPermanent(err), "Must classify the error like PutRecords")
+			assert.Len(t, mock.calls, 1, "Must stop writing once the request has failed")
+		})
+	}
+}
+
+func TestSingleRecordModeFailedRequest(t *testing.T) {
+	t.Parallel()
+
+	mock := &MockPutRecordAPI{op: func(in *kinesis.PutRecordInput) (*kinesis.PutRecordOutput, error) {
+		if string(in.Data) == "record-1" {
+			return nil, errors.New("connection reset by peer")
+		}
+		return SuccessfulPutRecordOperation(in)
+	}}
+	be, err := producer.NewBatcher(mock, "stream",
+		producer.WithLogger(zaptest.NewLogger(t)),
+		producer.WithSingleRecordMode(),
+	)
+	require.NoError(t, err, "Must not error when creating the batcher")
+
+	err = be.Put(context.Background(), newSingleRecordBatch(t, 4))
+	require.Error(t, err, "Must error when the request failed")
+	assert.False(t, consumererror.IsPermanent(err), "Must be able to retry the failed request")
+
+	var pe *producer.PutError
+	require.True(t, errors.As(err, &pe), "Must be able to extract the PutError")
+	assert.Equal(t, []int{1, 2, 3}, pe.Records, "Must only fail the records from the one that failed")
+	assert.Equal(t, []string{request.ErrCodeRequestError, request.ErrCodeRequestError, request.ErrCodeRequestError}, pe.Codes,
+		"Must set the code of the failed request on each failed record")
+	assert.Equal(t, []string{"record-0", "record-1"}, mock.calls, "Must stop writing once the request has failed")
+}
+
+func TestSingleRecordModeSequenceOrdering(t *testing.T) {
+	t.Parallel()
+
+	var ordering []*string
+	mock := &MockPutRecordAPI{op: func(in *kinesis.PutRecordInput) (*kinesis.PutRecordOutput, error) {
+		ordering = append(ordering, in.SequenceNumberForOrdering)
+		return &kinesis.PutRecordOutput{
+			ShardId:        aws.String("shardId-000000000000"),
+			SequenceNumber: aws.String(fmt.Sprintf("seq-%d", len(ordering))),
+		}, nil
+	}}
+	be, err := producer.NewBatcher(mock, "stream",
+		producer.WithLogger(zaptest.NewLogger(t)),
+		producer.WithSingleRecordMode(),
+		producer.WithSequenceOrdering(),
+	)
+	require.NoError(t, err, "Must not error when creating the batcher")
+
+	bt := batch.New()
+	require.NoError(t, bt.AddRecord([]byte("first"), "key"))
+	require.NoError(t, bt.AddRecord([]byte("other"), "other-key"))
+	require.NoError(t, be.Put(context.Background(), bt), "Must have written every record")
+
+	bt = batch.New()
+	require.NoError(t, bt.AddRecord([]byte("second"), "key"))
+	require.NoError(t, be.Put(context.Background(), bt), "Must have written every record")
+
+	require.Len(t, ordering, 3, "Must have made one PutRecord call per record")
+	assert.Nil(t, ordering[0], "Must not order the first record of a key")
+	assert.Nil(t, ordering[1], "Must not order the first record of another key")
+	assert.Equal(t, "seq-1", aws.StringValue(ordering[2]), "Must order the record after the last record of its key")
+
+	_, err = producer.NewBatcher(mock, "stream", producer.WithSequenceOrdering())
+	assert.Error(t, err, "Must error when ordering records without single record mode")
+}
+
+func TestSingleRecordModeSequenceKeysBounded(t *testing.T) {
+	t.Parallel()
+
+	var ordering []*string
+	mock := &MockPutRecordAPI{op: func(in *kinesis.PutRecordInput) (*kinesis.PutRecordOutput, error) {
+		ordering = append(ordering, in.SequenceNumberForOrdering)
+		return &kinesis.PutRecordOutput{
+			ShardId:        aws.String("shardId-000000000000"),
+			SequenceNumber: aws.String(fmt.Sprintf("seq-%d", len(ordering))),
+		}, nil
+	}}
+	be, err := producer.NewBatcher(mock, "stream",
+		producer.WithLogger(zaptest.NewLogger(t)),
+		producer.WithSingleRecordMode(),
+		producer.WithSequenceOrdering(),
+	)
+	require.NoError(t, err, "Must not error when creating the batcher")
+
+	bt := batch.New()
+	for i := 0; i < 2*producer.MaxSequenceKeys; i++ {
+		require.NoError(t, bt.AddRecord([]byte("record"), fmt.Sprintf("key-%d", i)))
+	}
+	require.NoError(t, be.Put(context.Background(), bt), "Must have written every record")
+	assert.Equal(t, producer.MaxSequenceKeys, producer.SequenceKeys(be), "Must forget the least recently written keys")
+
+	bt = batch.New()
+	require.NoError(t, bt.AddRecord([]byte("first"), "key-0"))
+	require.NoError(t, bt.AddRecord([]byte("last"), fmt.Sprintf("key-%d", 2*producer.MaxSequenceKeys-1)))
+	require.NoError(t, be.Put(context.Background(), bt), "Must have written every record")
+	assert.Equal(t, producer.MaxSequenceKeys, producer.SequenceKeys(be), "Must keep the number of keys bounded")
+
+	require.Len(t, ordering, 2*producer.MaxSequenceKeys+2, "Must have made one PutRecord call per record")
+	assert.Nil(t, ordering[2*producer.MaxSequenceKeys], "Must not order the record of a forgotten key")
+	assert.Equal(t, fmt.Sprintf("seq-%d", 2*producer.MaxSequenceKeys), aws.StringValue(ordering[2*producer.MaxSequenceKeys+1]),
+		"Must order the record of a recent key after its last record")
+}
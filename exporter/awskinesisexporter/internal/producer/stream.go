@@ -0,0 +1,103 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/kinesis/kinesisiface"
+	"go.uber.org/zap"
+)
+
+// EnsureStream creates the stream with the number of shards if it does not
+// exist and waits until it can be written to, polling its status at the interval.
+// A stream that is created concurrently by another collector is waited for.
+func EnsureStream(ctx context.Context, client kinesisiface.KinesisAPI, stream string, shards int, poll time.Duration, log *zap.Logger) error {
+	if shards < 1 {
+		return errors.New("shard count must be at least 1")
+	}
+	created := false
+	for {
+		out, err := client.DescribeStreamSummaryWithContext(ctx, &kinesis.DescribeStreamSummaryInput{
+			StreamName: aws.String(stream),
+		})
+		switch {
+		case err == nil:
+			switch status := aws.StringValue(out.StreamDescriptionSummary.StreamStatus); status {
+			case kinesis.StreamStatusActive, kinesis.StreamStatusUpdating:
+				return nil
+			default:
+				log.Debug("Waiting for stream to become active", zap.String("stream", stream), zap.String("status", status))
+			}
+		case isNotFound(err) && !created:
+			if err = createStream(ctx, client, stream, shards); err != nil {
+				return err
+			}
+			log.Info("Created missing stream", zap.String("stream", stream), zap.Int("shards", shards))
+			created = true
+			continue
+		case isNotFound(err):
+			// The stream may not be described straight after it has been created
+		default:
+			return fmt.Errorf("failed to describe stream %q: %w", stream, err)
+		}
+
+		timer := time.NewTimer(poll)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// createStream creates the stream, a stream that has been
+// created concurrently is not reported as an error.
+func createStream(ctx context.Context, client kinesisiface.KinesisAPI, stream string, shards int) error {
+	_, err := client.CreateStreamWithContext(ctx, &kinesis.CreateStreamInput{
+		StreamName: aws.String(stream),
+		ShardCount: aws.Int64(int64(shards)),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == kinesis.ErrCodeResourceInUseException {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create stream %q: %w", stream, err)
+	}
+	return nil
+}
+
+func isNotFound(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == kinesis.ErrCodeResourceNotFoundException
+}
+
+// streamParams returns the stream as the StreamName parameter of a request or,
+// when it is the ARN of a stream, as the StreamARN parameter instead.
+// Stream names can not contain a colon, so an ARN is never mistaken for a name.
+func streamParams(stream *string) (name, arn *string) {
+	if strings.HasPrefix(aws.StringValue(stream), "arn:") {
+		return nil, stream
+	}
+	return stream, nil
+}
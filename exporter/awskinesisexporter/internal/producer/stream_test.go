@@ -0,0 +1,159 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/kinesis/kinesisiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter/internal/producer"
+)
+
+// MockStreamAPI returns the stream statuses in order, a missing stream
+// is reported with an empty status, and records the created streams.
+type MockStreamAPI struct {
+	kinesisiface.KinesisAPI
+
+	statuses  []string
+	createErr error
+	created   []*kinesis.CreateStreamInput
+}
+
+func (m *MockStreamAPI) DescribeStreamSummaryWithContext(_ context.Context, _ *kinesis.DescribeStreamSummaryInput, _ ...request.Option) (*kinesis.DescribeStreamSummaryOutput, error) {
+	if len(m.statuses) == 0 {
+		return nil, errors.New("unexpected describe")
+	}
+	status := m.statuses[0]
+	m.statuses = m.statuses[1:]
+	if status == "" {
+		return nil, awserr.New(kinesis.ErrCodeResourceNotFoundException, "stream not found", nil)
+	}
+	return &kinesis.DescribeStreamSummaryOutput{
+		StreamDescriptionSummary: &kinesis.StreamDescriptionSummary{StreamStatus: aws.String(status)},
+	}, nil
+}
+
+func (m *MockStreamAPI) CreateStreamWithContext(_ context.Context, in *kinesis.CreateStreamInput, _ ...request.Option) (*kinesis.CreateStreamOutput, error) {
+	m.created = append(m.created, in)
+	return &kinesis.CreateStreamOutput{}, m.createErr
+}
+
+func TestEnsureStream(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name      string
+		statuses  []string
+		createErr error
+		created   int
+		err       bool
+	}{
+		{
+			name:     "already exists",
+			statuses: []string{kinesis.StreamStatusActive},
+		},
+		{
+			name:     "updating",
+			statuses: []string{kinesis.StreamStatusUpdating},
+		},
+		{
+			name:     "created then active",
+			statuses: []string{"", "", kinesis.StreamStatusCreating, kinesis.StreamStatusActive},
+			created:  1,
+		},
+		{
+			name:      "created concurrently",
+			statuses:  []string{"", kinesis.StreamStatusCreating, kinesis.StreamStatusActive},
+			createErr: awserr.New(kinesis.ErrCodeResourceInUseException, "stream already exists", nil),
+			created:   1,
+		},
+		{
+			name:      "failed to create",
+			statuses:  []string{""},
+			createErr: awserr.New(kinesis.ErrCodeLimitExceededException, "too many streams", nil),
+			created:   1,
+			err:       true,
+		},
+		{
+			name: "failed to describe",
+			err:  true,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock := &MockStreamAPI{statuses: tc.statuses, createErr: tc.createErr}
+			err := producer.EnsureStream(context.Background(), mock, "stream", 2, time.Millisecond, zaptest.NewLogger(t))
+			if tc.err {
+				assert.Error(t, err, "Must error when the stream can not be ensured")
+			} else {
+				assert.NoError(t, err, "Must not error once the stream is active")
+				assert.Empty(t, mock.statuses, "Must have waited for the stream to become active")
+			}
+			assert.Len(t, mock.created, tc.created, "Must only create a missing stream")
+			for _, in := range mock.created {
+				assert.Equal(t, int64(2), aws.Int64Value(in.ShardCount), "Must create the stream with the shard count")
+			}
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	mock := &MockStreamAPI{statuses: []string{kinesis.StreamStatusCreating}}
+	assert.ErrorIs(t, producer.EnsureStream(ctx, mock, "stream", 1, time.Hour, zaptest.NewLogger(t)), context.Canceled,
+		"Must stop waiting once the context is done")
+}
+
+func TestBatcherReady(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name   string
+		status string
+		err    bool
+	}{
+		{name: "active", status: kinesis.StreamStatusActive},
+		{name: "updating", status: kinesis.StreamStatusUpdating},
+		{name: "creating", status: kinesis.StreamStatusCreating, err: true},
+		{name: "deleting", status: kinesis.StreamStatusDeleting, err: true},
+		{name: "missing", err: true},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			be, err := producer.NewBatcher(&MockStreamAPI{statuses: []string{tc.status}}, "stream")
+			require.NoError(t, err, "Must not error when creating the batcher")
+
+			if tc.err {
+				assert.Error(t, be.Ready(context.Background()), "Must error when the stream can not be written to")
+			} else {
+				assert.NoError(t, be.Ready(context.Background()), "Must not error when the stream is active")
+			}
+		})
+	}
+}
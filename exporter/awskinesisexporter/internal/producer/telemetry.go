@@ -0,0 +1,281 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/unit"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/awskinesisexporter"
+
+	metricPrefix = "exporter/awskinesis/"
+)
+
+// telemetry holds the instruments used to report how the Batcher is performing.
+type telemetry struct {
+	attrs []attribute.KeyValue
+
+	recordsSent    metric.Int64Counter
+	recordsFailed  metric.Int64Counter
+	throttleEvents metric.Int64Counter
+	bytesSent      metric.Int64Counter
+	sampledOut     metric.Int64Counter
+	droppedInvalid metric.Int64Counter
+	retryBudget    metric.Int64Counter
+	recordsDropped metric.Int64Counter
+	shutdownDrops  metric.Int64Counter
+	flushDrops     metric.Int64Counter
+	batchRecords   metric.Int64Histogram
+	batchBytes     metric.Int64Histogram
+	putDuration    metric.Float64Histogram
+	compression    metric.Float64Histogram
+	adaptiveSize   metric.Int64UpDownCounter
+
+	// throttleRecords and throttleBytes count the throttled writes
+	// by the per shard limit that they are inferred to have exceeded.
+	throttleRecords metric.Int64Counter
+	throttleBytes   metric.Int64Counter
+
+	// totals are kept alongside the instruments so that they can be
+	// read back, such as to emit the health of the Batcher as EMF logs.
+	totals healthCounts
+}
+
+// healthCounts are the running totals of the writes made by the Batcher.
+type healthCounts struct {
+	recordsSent    int64
+	recordsFailed  int64
+	throttleEvents int64
+}
+
+func newTelemetry(mp metric.MeterProvider, attrs ...attribute.KeyValue) *telemetry {
+	meter := metric.Must(mp.Meter(instrumentationName))
+	return &telemetry{
+		attrs: attrs,
+		recordsSent: meter.NewInt64Counter(metricPrefix+"records_sent",
+			metric.WithDescription("Number of records successfully written to kinesis"),
+			metric.WithUnit(unit.Dimensionless),
+		),
+		recordsFailed: meter.NewInt64Counter(metricPrefix+"records_failed",
+			metric.WithDescription("Number of records that failed to be written to kinesis"),
+			metric.WithUnit(unit.Dimensionless),
+		),
+		throttleEvents: meter.NewInt64Counter(metricPrefix+"throttle_events",
+			metric.WithDescription("Number of times kinesis throttled a write"),
+			metric.WithUnit(unit.Dimensionless),
+		),
+		throttleRecords: meter.NewInt64Counter(metricPrefix+"throttle_records",
+			metric.WithDescription("Number of times kinesis throttled a write by the records per second limit of a shard"),
+			metric.WithUnit(unit.Dimensionless),
+		),
+		throttleBytes: meter.NewInt64Counter(metricPrefix+"throttle_bytes",
+			metric.WithDescription("Number of times kinesis throttled a write by the bytes per second limit of a shard"),
+			metric.WithUnit(unit.Dimensionless),
+		),
+		bytesSent: meter.NewInt64Counter(metricPrefix+"bytes_sent",
+			metric.WithDescription("Number of bytes successfully written to kinesis"),
+			metric.WithUnit(unit.Bytes),
+		),
+		sampledOut: meter.NewInt64Counter(metricPrefix+"dropped_by_sampling",
+			metric.WithDescription("Number of records dropped by the sampling ratio before being written"),
+			metric.WithUnit(unit.Dimensionless),
+		),
+		droppedInvalid: meter.NewInt64Counter(metricPrefix+"dropped_invalid",
+			metric.WithDescription("Number of records dropped by the payload validation before being written"),
+			metric.WithUnit(unit.Dimensionless),
+		),
+		retryBudget: meter.NewInt64Counter(metricPrefix+"retry_budget_exhausted",
+			metric.WithDescription("Number of failed records that were not retried since the retry budget was exhausted"),
+			metric.WithUnit(unit.Dimensionless),
+		),
+		recordsDropped: meter.NewInt64Counter(metricPrefix+"records_dropped",
+			metric.WithDescription("Number of records that permanently failed to be written and were dropped"),
+			metric.WithUnit(unit.Dimensionless),
+		),
+		shutdownDrops: meter.NewInt64Counter(metricPrefix+"dropped_on_shutdown",
+			metric.WithDescription("Number of in-flight records that were dropped since shutdown did not complete in time"),
+			metric.WithUnit(unit.Dimensionless),
+		),
+		flushDrops: meter.NewInt64Counter(metricPrefix+"dropped_on_flush",
+			metric.WithDescription("Number of buffered records that were dropped since they failed to be written after the flush interval"),
+			metric.WithUnit(unit.Dimensionless),
+		),
+		batchRecords: meter.NewInt64Histogram(metricPrefix+"batch_records",
+			metric.WithDescription("Number of records within each batch that is written"),
+			metric.WithUnit(unit.Dimensionless),
+		),
+		batchBytes: meter.NewInt64Histogram(metricPrefix+"batch_bytes",
+			metric.WithDescription("Number of bytes within each batch that is written"),
+			metric.WithUnit(unit.Bytes),
+		),
+		putDuration: meter.NewFloat64Histogram(metricPrefix+"put_duration",
+			metric.WithDescription("Duration of writing a batch, including its retries and backoff"),
+			metric.WithUnit(unit.Milliseconds),
+		),
+		compression: meter.NewFloat64Histogram(metricPrefix+"compression_ratio",
+			metric.WithDescription("Ratio of the compressed to the uncompressed bytes of the records within each batch"),
+			metric.WithUnit(unit.Dimensionless),
+		),
+		adaptiveSize: meter.NewInt64UpDownCounter(metricPrefix+"adaptive_batch_size",
+			metric.WithDescription("Number of records written by each request when adaptive batching is enabled"),
+			metric.WithUnit(unit.Dimensionless),
+		),
+	}
+}
+
+func (t *telemetry) sent(ctx context.Context, records, bytes int) {
+	if records > 0 {
+		atomic.AddInt64(&t.totals.recordsSent, int64(records))
+		t.recordsSent.Add(ctx, int64(records), t.attrs...)
+		t.bytesSent.Add(ctx, int64(bytes), t.attrs...)
+	}
+}
+
+func (t *telemetry) failed(ctx context.Context, records int) {
+	if records > 0 {
+		atomic.AddInt64(&t.totals.recordsFailed, int64(records))
+		t.recordsFailed.Add(ctx, int64(records), t.attrs...)
+	}
+}
+
+func (t *telemetry) sampled(ctx context.Context, dropped int) {
+	if dropped > 0 {
+		t.sampledOut.Add(ctx, int64(dropped), t.attrs...)
+	}
+}
+
+func (t *telemetry) invalid(ctx context.Context, dropped int) {
+	if dropped > 0 {
+		t.droppedInvalid.Add(ctx, int64(dropped), t.attrs...)
+	}
+}
+
+func (t *telemetry) compressed(ctx context.Context, uncompressed, compressed int) {
+	if uncompressed > 0 {
+		t.compression.Record(ctx, float64(compressed)/float64(uncompressed), t.attrs...)
+	}
+}
+
+// resized records the change made to the adaptive batch size.
+func (t *telemetry) resized(ctx context.Context, delta int) {
+	if delta != 0 {
+		t.adaptiveSize.Add(ctx, int64(delta), t.attrs...)
+	}
+}
+
+func (t *telemetry) budgetExhausted(ctx context.Context, records int) {
+	if records > 0 {
+		t.retryBudget.Add(ctx, int64(records), t.attrs...)
+	}
+}
+
+func (t *telemetry) dropped(ctx context.Context, records int) {
+	if records > 0 {
+		t.recordsDropped.Add(ctx, int64(records), t.attrs...)
+	}
+}
+
+func (t *telemetry) shutdownDropped(ctx context.Context, records int64) {
+	if records > 0 {
+		t.shutdownDrops.Add(ctx, records, t.attrs...)
+	}
+}
+
+func (t *telemetry) flushDropped(ctx context.Context, records int) {
+	if records > 0 {
+		t.flushDrops.Add(ctx, int64(records), t.attrs...)
+	}
+}
+
+// flushed records the size of a batch before it is first written,
+// retries of the batch are not recorded.
+func (t *telemetry) flushed(ctx context.Context, records, bytes int) {
+	t.batchRecords.Record(ctx, int64(records), t.attrs...)
+	t.batchBytes.Record(ctx, int64(bytes), t.attrs...)
+}
+
+// put records the duration of a Put since start by its outcome,
+// which is one of success, permanent or transient.
+func (t *telemetry) put(ctx context.Context, start time.Time, err error) {
+	outcome := "success"
+	switch {
+	case consumererror.IsPermanent(err):
+		outcome = "permanent"
+	case err != nil:
+		outcome = "transient"
+	}
+	attrs := append(t.attrs[:len(t.attrs):len(t.attrs)], attribute.String("outcome", outcome))
+	t.putDuration.Record(ctx, float64(time.Since(start))/float64(time.Millisecond), attrs...)
+}
+
+func (t *telemetry) throttled(ctx context.Context, events int) {
+	if events > 0 {
+		atomic.AddInt64(&t.totals.throttleEvents, int64(events))
+		t.throttleEvents.Add(ctx, int64(events), t.attrs...)
+	}
+}
+
+// shardThrottled records the throttled writes by the shard limit they exceeded.
+func (t *telemetry) shardThrottled(ctx context.Context, counts throttleCounts) {
+	if n := counts[throttleRecordRate]; n > 0 {
+		t.throttleRecords.Add(ctx, int64(n), t.attrs...)
+	}
+	if n := counts[throttleByteRate]; n > 0 {
+		t.throttleBytes.Add(ctx, int64(n), t.attrs...)
+	}
+}
+
+// counts returns the totals recorded so far.
+func (t *telemetry) counts() healthCounts {
+	return healthCounts{
+		recordsSent:    atomic.LoadInt64(&t.totals.recordsSent),
+		recordsFailed:  atomic.LoadInt64(&t.totals.recordsFailed),
+		throttleEvents: atomic.LoadInt64(&t.totals.throttleEvents),
+	}
+}
+
+// putAttributes describes a single write of the records to the stream.
+func putAttributes(stream string, records, bytes, attempt int) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("stream", stream),
+		attribute.Int("records", records),
+		attribute.Int("bytes", bytes),
+		attribute.Int("attempt", attempt),
+	}
+}
+
+// endPut records the outcome of a write on its span,
+// records that failed within the response also mark the span as failed.
+func endPut(span trace.Span, err error, failed int) {
+	span.SetAttributes(attribute.Int("failed_records", failed))
+	switch {
+	case err != nil:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	case failed > 0:
+		span.SetStatus(codes.Error, fmt.Sprintf("%d records failed", failed))
+	}
+}
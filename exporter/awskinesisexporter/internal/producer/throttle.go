@@ -0,0 +1,57 @@
+// Copyright  OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package producer
+
+import "strings"
+
+// balancedRecordSize is the record size at which a shard reaches its limit of 1000 records
+// and 1MiB per second together, smaller records reach the records limit first.
+const balancedRecordSize = 1 << 10
+
+// throttleLimit is the per shard limit that kinesis throttled a write by.
+type throttleLimit int
+
+const (
+	// throttleRecordRate is the limit of 1000 records per second of each shard.
+	throttleRecordRate throttleLimit = iota
+	// throttleByteRate is the limit of 1MiB per second of each shard.
+	throttleByteRate
+)
+
+// throttleLimitOf infers the limit from the message of the throttling error since
+// kinesis uses the same error code for both, messages that do not mention either limit
+// use the average size of the records that were written.
+func throttleLimitOf(message string, records, bytes int) throttleLimit {
+	message = strings.ToLower(message)
+	for _, s := range []string{"byte", "mib", "mb/"} {
+		if strings.Contains(message, s) {
+			return throttleByteRate
+		}
+	}
+	if strings.Contains(message, "record") {
+		return throttleRecordRate
+	}
+	if records > 0 && bytes/records >= balancedRecordSize {
+		return throttleByteRate
+	}
+	return throttleRecordRate
+}
+
+// throttleCounts are the throttled writes by the limit they exceeded.
+type throttleCounts [2]int
+
+func (tc *throttleCounts) add(message string, records, bytes int) {
+	tc[throttleLimitOf(message, records, bytes)]++
+}